@@ -48,6 +48,25 @@ func CallBatchCreateSecretsByWorkspaceAndEnv(httpClient *resty.Client, request B
 	return nil
 }
 
+func CallBatchSecrets(httpClient *resty.Client, request BatchSecretsRequest) error {
+	endpoint := fmt.Sprintf("%v/v2/secrets/batch", config.INFISICAL_URL)
+	response, err := httpClient.
+		R().
+		SetBody(request).
+		SetHeader("User-Agent", USER_AGENT).
+		Post(endpoint)
+
+	if err != nil {
+		return fmt.Errorf("CallBatchSecrets: Unable to complete api request [err=%s]", err)
+	}
+
+	if response.IsError() {
+		return fmt.Errorf("CallBatchSecrets: Unsuccessful response: [response=%s]", response)
+	}
+
+	return nil
+}
+
 func CallBatchDeleteSecretsByWorkspaceAndEnv(httpClient *resty.Client, request BatchDeleteSecretsBySecretIdsRequest) error {
 	endpoint := fmt.Sprintf("%v/v2/secrets", config.INFISICAL_URL)
 	response, err := httpClient.
@@ -115,6 +134,7 @@ func CallGetSecretsV2(httpClient *resty.Client, request GetEncryptedSecretsV2Req
 		SetQueryParam("environment", request.Environment).
 		SetQueryParam("workspaceId", request.WorkspaceId).
 		SetQueryParam("tagSlugs", request.TagSlugs).
+		SetQueryParam("secretsPath", request.SecretsPath).
 		Get(fmt.Sprintf("%v/v2/secrets", config.INFISICAL_URL))
 
 	if err != nil {
@@ -247,3 +267,42 @@ func CallGetAccessibleEnvironments(httpClient *resty.Client, request GetAccessib
 
 	return accessibleEnvironmentsResponse, nil
 }
+
+func CallGetWorkspaceTags(httpClient *resty.Client, request GetWorkspaceTagsRequest) (GetWorkspaceTagsResponse, error) {
+	var workspaceTagsResponse GetWorkspaceTagsResponse
+	response, err := httpClient.
+		R().
+		SetResult(&workspaceTagsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Get(fmt.Sprintf("%v/v2/workspace/%s/tags", config.INFISICAL_URL, request.WorkspaceId))
+
+	if err != nil {
+		return GetWorkspaceTagsResponse{}, fmt.Errorf("CallGetWorkspaceTags: Unable to complete api request [err=%s]", err)
+	}
+
+	if response.IsError() {
+		return GetWorkspaceTagsResponse{}, fmt.Errorf("CallGetWorkspaceTags: Unsuccessful response: [response=%s]", response)
+	}
+
+	return workspaceTagsResponse, nil
+}
+
+func CallCreateFolder(httpClient *resty.Client, request CreateFolderRequest) (CreateFolderResponse, error) {
+	var createFolderResponse CreateFolderResponse
+	response, err := httpClient.
+		R().
+		SetResult(&createFolderResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/folders", config.INFISICAL_URL))
+
+	if err != nil {
+		return CreateFolderResponse{}, fmt.Errorf("CallCreateFolder: Unable to complete api request [err=%s]", err)
+	}
+
+	if response.IsError() {
+		return CreateFolderResponse{}, fmt.Errorf("CallCreateFolder: Unsuccessful response: [response=%s]", response)
+	}
+
+	return createFolderResponse, nil
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/go-resty/resty/v2"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+var generateRequestIdOnce sync.Once
+var requestWasSent int32
+
+// NewHttpClient returns the client every call to the Infisical API should be made with. All requests of an invocation
+// carry the same X-Request-ID header so that they can be found in the server logs, are throttled by --rate-limit, limited by --connect-timeout and --timeout and traced with --trace or --trace-file
+func NewHttpClient() *resty.Client {
+	limiter := getSharedRateLimiter()
+	client := setRateLimitedRetries(resty.New()).
+		SetHeader(REQUEST_ID_HEADER, GetRequestId()).
+		OnBeforeRequest(func(client *resty.Client, request *resty.Request) error {
+			if limiter != nil {
+				limiter.Wait()
+			}
+			atomic.StoreInt32(&requestWasSent, 1)
+			return nil
+		})
+
+	client = setTimeouts(client, config.INFISICAL_CONNECT_TIMEOUT, config.INFISICAL_REQUEST_TIMEOUT)
+
+	if config.INFISICAL_TRACE_FILE != "" {
+		useTraceFile(config.INFISICAL_TRACE_FILE)
+	}
+
+	if config.INFISICAL_TRACE || config.INFISICAL_TRACE_FILE != "" {
+		client = setTracing(client)
+	}
+
+	return client
+}
+
+// GetRequestId returns the id set with --request-id or a random UUID generated once per invocation
+func GetRequestId() string {
+	generateRequestIdOnce.Do(func() {
+		if config.INFISICAL_REQUEST_ID == "" {
+			config.INFISICAL_REQUEST_ID = newUUID()
+		}
+	})
+	return config.INFISICAL_REQUEST_ID
+}
+
+// GetRequestIdIfSent returns the request id when at least one API request was made, so that it is only shown when it can help
+func GetRequestIdIfSent() string {
+	if atomic.LoadInt32(&requestWasSent) == 0 {
+		return ""
+	}
+	return GetRequestId()
+}
+
+func newUUID() string {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return "unknown"
+	}
+
+	// version 4, variant 10 (RFC 4122)
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_NewHttpClient_SendsRequestId(t *testing.T) {
+	var lock sync.Mutex
+	receivedRequestIds := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		receivedRequestIds = append(receivedRequestIds, r.Header.Get(REQUEST_ID_HEADER))
+		lock.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	atomic.StoreInt32(&requestWasSent, 0)
+	if requestId := GetRequestIdIfSent(); requestId != "" {
+		t.Errorf("Test_NewHttpClient_SendsRequestId: expected no request id before a request was made but got %s", requestId)
+	}
+
+	// every client of an invocation shares the same id
+	for i := 0; i < 2; i++ {
+		if _, err := NewHttpClient().R().Get(server.URL + "/api/status"); err != nil {
+			t.Fatalf("Test_NewHttpClient_SendsRequestId: unexpected error [err=%v]", err)
+		}
+	}
+
+	requestId := GetRequestIdIfSent()
+	if requestId == "" {
+		t.Fatalf("Test_NewHttpClient_SendsRequestId: expected the request id once a request was made")
+	}
+
+	if len(receivedRequestIds) != 2 {
+		t.Fatalf("Test_NewHttpClient_SendsRequestId: expected 2 requests but got %d", len(receivedRequestIds))
+	}
+
+	for _, receivedRequestId := range receivedRequestIds {
+		if receivedRequestId != requestId {
+			t.Errorf("Test_NewHttpClient_SendsRequestId: expected the header %s to be [%s] but got [%s]", REQUEST_ID_HEADER, requestId, receivedRequestId)
+		}
+	}
+}
@@ -129,20 +129,21 @@ type GetWorkSpacesResponse struct {
 }
 
 type Secret struct {
-	SecretKeyCiphertext     string `json:"secretKeyCiphertext,omitempty"`
-	SecretKeyIV             string `json:"secretKeyIV,omitempty"`
-	SecretKeyTag            string `json:"secretKeyTag,omitempty"`
-	SecretKeyHash           string `json:"secretKeyHash,omitempty"`
-	SecretValueCiphertext   string `json:"secretValueCiphertext,omitempty"`
-	SecretValueIV           string `json:"secretValueIV,omitempty"`
-	SecretValueTag          string `json:"secretValueTag,omitempty"`
-	SecretValueHash         string `json:"secretValueHash,omitempty"`
-	SecretCommentCiphertext string `json:"secretCommentCiphertext,omitempty"`
-	SecretCommentIV         string `json:"secretCommentIV,omitempty"`
-	SecretCommentTag        string `json:"secretCommentTag,omitempty"`
-	SecretCommentHash       string `json:"secretCommentHash,omitempty"`
-	Type                    string `json:"type,omitempty"`
-	ID                      string `json:"id,omitempty"`
+	SecretKeyCiphertext     string   `json:"secretKeyCiphertext,omitempty"`
+	SecretKeyIV             string   `json:"secretKeyIV,omitempty"`
+	SecretKeyTag            string   `json:"secretKeyTag,omitempty"`
+	SecretKeyHash           string   `json:"secretKeyHash,omitempty"`
+	SecretValueCiphertext   string   `json:"secretValueCiphertext,omitempty"`
+	SecretValueIV           string   `json:"secretValueIV,omitempty"`
+	SecretValueTag          string   `json:"secretValueTag,omitempty"`
+	SecretValueHash         string   `json:"secretValueHash,omitempty"`
+	SecretCommentCiphertext string   `json:"secretCommentCiphertext,omitempty"`
+	SecretCommentIV         string   `json:"secretCommentIV,omitempty"`
+	SecretCommentTag        string   `json:"secretCommentTag,omitempty"`
+	SecretCommentHash       string   `json:"secretCommentHash,omitempty"`
+	Type                    string   `json:"type,omitempty"`
+	ID                      string   `json:"id,omitempty"`
+	Tags                    []string `json:"tags,omitempty"`
 }
 
 type BatchCreateSecretsByWorkspaceAndEnvRequest struct {
@@ -157,6 +158,35 @@ type BatchModifySecretsByWorkspaceAndEnvRequest struct {
 	Secrets     []Secret `json:"secrets"`
 }
 
+type BatchSecretsRequest struct {
+	WorkspaceId string               `json:"workspaceId"`
+	Environment string               `json:"environment"`
+	Requests    []BatchSecretRequest `json:"requests"`
+}
+
+type BatchSecretRequest struct {
+	// one of POST, PATCH or DELETE
+	Method string      `json:"method"`
+	Secret BatchSecret `json:"secret"`
+}
+
+type BatchSecret struct {
+	ID                      string   `json:"_id,omitempty"`
+	FolderId                string   `json:"folderId,omitempty"`
+	SecretName              string   `json:"secretName,omitempty"`
+	Type                    string   `json:"type,omitempty"`
+	SecretKeyCiphertext     string   `json:"secretKeyCiphertext,omitempty"`
+	SecretKeyIV             string   `json:"secretKeyIV,omitempty"`
+	SecretKeyTag            string   `json:"secretKeyTag,omitempty"`
+	SecretValueCiphertext   string   `json:"secretValueCiphertext,omitempty"`
+	SecretValueIV           string   `json:"secretValueIV,omitempty"`
+	SecretValueTag          string   `json:"secretValueTag,omitempty"`
+	SecretCommentCiphertext string   `json:"secretCommentCiphertext,omitempty"`
+	SecretCommentIV         string   `json:"secretCommentIV,omitempty"`
+	SecretCommentTag        string   `json:"secretCommentTag,omitempty"`
+	Tags                    []string `json:"tags,omitempty"`
+}
+
 type BatchDeleteSecretsBySecretIdsRequest struct {
 	EnvironmentName string   `json:"environmentName"`
 	WorkspaceId     string   `json:"workspaceId"`
@@ -198,6 +228,7 @@ type GetEncryptedSecretsV2Request struct {
 	Environment string `json:"environment"`
 	WorkspaceId string `json:"workspaceId"`
 	TagSlugs    string `json:"tagSlugs"`
+	SecretsPath string `json:"secretsPath"`
 }
 
 type GetEncryptedSecretsV2Response struct {
@@ -227,6 +258,11 @@ type GetEncryptedSecretsV2Response struct {
 			Workspace string `json:"workspace"`
 		} `json:"tags"`
 	} `json:"secrets"`
+	// folders directly inside the requested secrets path
+	Folders []struct {
+		ID   string `json:"_id"`
+		Name string `json:"name"`
+	} `json:"folders"`
 }
 
 type GetServiceTokenDetailsResponse struct {
@@ -255,6 +291,18 @@ type GetAccessibleEnvironmentsResponse struct {
 	} `json:"accessibleEnvironments"`
 }
 
+type GetWorkspaceTagsRequest struct {
+	WorkspaceId string `json:"workspaceId"`
+}
+
+type GetWorkspaceTagsResponse struct {
+	WorkspaceTags []struct {
+		ID   string `json:"_id"`
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"workspaceTags"`
+}
+
 type GetLoginOneV2Request struct {
 	Email           string `json:"email"`
 	ClientPublicKey string `json:"clientPublicKey"`
@@ -314,3 +362,18 @@ type VerifyMfaTokenErrorResponse struct {
 	Application string        `json:"application"`
 	Extra       []interface{} `json:"extra"`
 }
+
+type CreateFolderRequest struct {
+	WorkspaceId string `json:"workspaceId"`
+	Environment string `json:"environment"`
+	FolderName  string `json:"folderName"`
+	// empty for folders created at the root
+	ParentFolderId string `json:"parentFolderId,omitempty"`
+}
+
+type CreateFolderResponse struct {
+	Folder struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folder"`
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	MAX_RATE_LIMITED_RETRIES    = 3
+	rateLimitedRetryWaitTime    = 1 * time.Second
+	rateLimitedRetryMaxWaitTime = 30 * time.Second
+)
+
+var sharedRateLimiter *rateLimiter
+var createSharedRateLimiterOnce sync.Once
+
+// rateLimiter is a token bucket holding a single token, so requests are spaced at least 1/rate apart
+// without allowing bursts after a quiet period
+type rateLimiter struct {
+	lock     sync.Mutex
+	interval time.Duration
+	next     time.Time
+	now      func() time.Time
+	sleep    func(time.Duration)
+}
+
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / requestsPerSecond), now: time.Now, sleep: time.Sleep}
+}
+
+// Wait blocks until the caller may send its request
+func (limiter *rateLimiter) Wait() {
+	limiter.lock.Lock()
+	now := limiter.now()
+	if limiter.next.Before(now) {
+		limiter.next = now
+	}
+	wait := limiter.next.Sub(now)
+	limiter.next = limiter.next.Add(limiter.interval)
+	limiter.lock.Unlock()
+
+	if wait > 0 {
+		limiter.sleep(wait)
+	}
+}
+
+// All clients of an invocation share the limiter set with --rate-limit, since bulk operations may use several clients at once
+func getSharedRateLimiter() *rateLimiter {
+	createSharedRateLimiterOnce.Do(func() {
+		if config.INFISICAL_RATE_LIMIT > 0 {
+			sharedRateLimiter = newRateLimiter(config.INFISICAL_RATE_LIMIT)
+		}
+	})
+	return sharedRateLimiter
+}
+
+// Requests rejected with 429 are retried after the delay asked for by the server. Every attempt goes through
+// the rate limiter again, so retries never exceed the limit either
+func setRateLimitedRetries(client *resty.Client) *resty.Client {
+	return client.
+		SetRetryCount(MAX_RATE_LIMITED_RETRIES).
+		SetRetryWaitTime(rateLimitedRetryWaitTime).
+		SetRetryMaxWaitTime(rateLimitedRetryMaxWaitTime).
+		AddRetryCondition(func(response *resty.Response, err error) bool {
+			return response != nil && response.StatusCode() == http.StatusTooManyRequests
+		}).
+		SetRetryAfter(func(client *resty.Client, response *resty.Response) (time.Duration, error) {
+			// 0 falls back to exponential backoff
+			seconds, err := strconv.Atoi(response.Header().Get("Retry-After"))
+			if err != nil || seconds < 0 {
+				return 0, nil
+			}
+			return time.Duration(seconds) * time.Second, nil
+		})
+}
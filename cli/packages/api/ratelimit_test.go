@@ -0,0 +1,130 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/go-resty/resty/v2"
+)
+
+func Test_RateLimiter_SpacesRequests(t *testing.T) {
+	now := time.Unix(0, 0)
+	slept := []time.Duration{}
+	limiter := newRateLimiter(4)
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(duration time.Duration) { slept = append(slept, duration) }
+
+	for i := 0; i < 3; i++ {
+		limiter.Wait()
+	}
+
+	if len(slept) != 2 || slept[0] != 250*time.Millisecond || slept[1] != 500*time.Millisecond {
+		t.Errorf("Test_RateLimiter_SpacesRequests: expected the 2nd and 3rd request to wait 250ms and 500ms but got %v", slept)
+	}
+
+	// a quiet period does not let requests burst afterwards
+	now = now.Add(10 * time.Second)
+	slept = nil
+	limiter.Wait()
+	limiter.Wait()
+	if len(slept) != 1 || slept[0] != 250*time.Millisecond {
+		t.Errorf("Test_RateLimiter_SpacesRequests: expected a single token after a quiet period but got %v", slept)
+	}
+}
+
+func Test_RateLimiter_SpacesConcurrentRequests(t *testing.T) {
+	var lock sync.Mutex
+	sentAt := []time.Time{}
+	rejectedOnce := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.CompareAndSwapInt32(&rejectedOnce, 0, 1) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		lock.Lock()
+		sentAt = append(sentAt, time.Now())
+		lock.Unlock()
+	}))
+	defer server.Close()
+
+	limiter := newRateLimiter(20)
+	client := setRateLimitedRetries(NewHttpClient()).
+		SetRetryWaitTime(time.Millisecond).
+		OnBeforeRequest(func(client *resty.Client, request *resty.Request) error {
+			limiter.Wait()
+			return nil
+		})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.R().Get(server.URL)
+			if err != nil || response.IsError() {
+				t.Errorf("Test_RateLimiter_SpacesConcurrentRequests: expected the request to succeed after retrying [err=%v] [status=%v]", err, response.Status())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(sentAt) != 5 {
+		t.Fatalf("Test_RateLimiter_SpacesConcurrentRequests: expected 5 successful requests but got %d", len(sentAt))
+	}
+
+	// 6 attempts including the retried one, at most 20 per second
+	first, last := sentAt[0], sentAt[0]
+	for _, at := range sentAt {
+		if at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+
+	if elapsed := last.Sub(first); elapsed < 180*time.Millisecond {
+		t.Errorf("Test_RateLimiter_SpacesConcurrentRequests: expected the requests to be spread over at least 180ms but they took %v", elapsed)
+	}
+}
+
+func Test_NewHttpClient_UsesRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// the shared limiter is created once per invocation, reset it so that it picks up the value of --rate-limit
+	resetSharedRateLimiter := func(rateLimit float64) {
+		config.INFISICAL_RATE_LIMIT = rateLimit
+		sharedRateLimiter = nil
+		createSharedRateLimiterOnce = sync.Once{}
+	}
+	resetSharedRateLimiter(4)
+	defer resetSharedRateLimiter(0)
+
+	client := NewHttpClient()
+	if sharedRateLimiter == nil || sharedRateLimiter.interval != 250*time.Millisecond {
+		t.Fatalf("Test_NewHttpClient_UsesRateLimit: expected a limiter spacing requests 250ms apart but got %+v", sharedRateLimiter)
+	}
+
+	slept := []time.Duration{}
+	sharedRateLimiter.sleep = func(duration time.Duration) { slept = append(slept, duration) }
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.R().Get(server.URL); err != nil {
+			t.Fatalf("Test_NewHttpClient_UsesRateLimit: unexpected error [err=%v]", err)
+		}
+	}
+
+	if len(slept) != 1 || slept[0] <= 200*time.Millisecond {
+		t.Errorf("Test_NewHttpClient_UsesRateLimit: expected the 2nd request to wait for the limiter but got %v", slept)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// The connect timeout only covers DNS resolution and establishing the TCP connection, so that a hanging network can fail fast
+// while large responses still get the whole request timeout to download. 0 leaves either of them unlimited
+func setTimeouts(client *resty.Client, connectTimeout time.Duration, requestTimeout time.Duration) *resty.Client {
+	if connectTimeout > 0 {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		client.SetTransport(transport)
+	}
+
+	if requestTimeout > 0 {
+		client.SetTimeout(requestTimeout)
+	}
+
+	return client
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func Test_SetTimeouts_ConnectTimeout(t *testing.T) {
+	// 10.255.255.1 is not routable, so connecting to it hangs until the connect timeout fires
+	start := time.Now()
+	_, err := setTimeouts(resty.New(), 200*time.Millisecond, 0).R().Get("http://10.255.255.1/api/status")
+	if err == nil {
+		t.Fatalf("Test_SetTimeouts_ConnectTimeout: expected the connection to fail")
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Test_SetTimeouts_ConnectTimeout: expected the connect timeout to fire after 200ms but the request took %s [err=%v]", elapsed, err)
+	}
+}
+
+func Test_SetTimeouts_ConnectTimeoutDoesNotLimitSlowResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	response, err := setTimeouts(resty.New(), 100*time.Millisecond, 0).R().Get(server.URL)
+	if err != nil || response.String() != "ok" {
+		t.Errorf("Test_SetTimeouts_ConnectTimeoutDoesNotLimitSlowResponses: expected a response slower than the connect timeout to succeed but got [%s] [err=%v]", response, err)
+	}
+
+	_, err = setTimeouts(resty.New(), 100*time.Millisecond, 150*time.Millisecond).R().Get(server.URL)
+	if err == nil {
+		t.Errorf("Test_SetTimeouts_ConnectTimeoutDoesNotLimitSlowResponses: expected the request timeout to fire")
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const TRACE_REDACTED = "REDACTED"
+
+// keys of error responses whose values describe the error and never hold secrets
+var traceSafeErrorKeys = map[string]bool{"message": true, "error": true, "type": true, "reqId": true, "statusCode": true, "name": true}
+
+var traceOutput io.Writer = os.Stderr
+
+var openTraceFileOnce sync.Once
+
+// Sends the trace to the file of --trace-file, opened once for every client of the invocation. When the file cannot be
+// opened, the trace is written to stderr so that it is not lost
+func useTraceFile(path string) {
+	openTraceFileOnce.Do(func() {
+		traceFile, err := openTraceFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[trace] %v, writing the trace to stderr instead\n", err)
+			return
+		}
+		traceOutput = traceFile
+	})
+}
+
+// The trace is appended to the file, which is made readable by the current user only even when it already existed
+func openTraceFile(path string) (*os.File, error) {
+	traceFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open trace file [%s] [err=%v]", path, err)
+	}
+
+	if err := traceFile.Chmod(0600); err != nil {
+		traceFile.Close()
+		return nil, fmt.Errorf("unable to restrict the permissions of trace file [%s] [err=%v]", path, err)
+	}
+
+	return traceFile, nil
+}
+
+// Logs the method, url and status of every request with --trace. Query parameter values are redacted, headers are never
+// logged and of the response body only error responses are logged, with all values redacted but the error description
+func setTracing(client *resty.Client) *resty.Client {
+	return client.
+		OnAfterResponse(func(client *resty.Client, response *resty.Response) error {
+			fmt.Fprintf(traceOutput, "[trace] %s %s -> %s (%v)\n", response.Request.Method, redactURL(getRequestURL(response.Request)), response.Status(), response.Time().Round(time.Millisecond))
+			if response.IsError() {
+				fmt.Fprintf(traceOutput, "[trace]   response body: %s\n", redactErrorBody(response.Body()))
+			}
+			return nil
+		}).
+		OnError(func(request *resty.Request, err error) {
+			if _, ok := err.(*resty.ResponseError); ok {
+				return
+			}
+
+			// transport errors such as *url.Error quote the full url
+			requestURL := getRequestURL(request)
+			message := strings.ReplaceAll(err.Error(), requestURL, redactURL(requestURL))
+			fmt.Fprintf(traceOutput, "[trace] %s %s -> failed after %v: %s\n", request.Method, redactURL(requestURL), time.Since(request.Time).Round(time.Millisecond), message)
+		})
+}
+
+// The url with the query parameters once the request was built
+func getRequestURL(request *resty.Request) string {
+	if request.RawRequest != nil {
+		return request.RawRequest.URL.String()
+	}
+	return request.URL
+}
+
+func redactURL(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return TRACE_REDACTED
+	}
+
+	// credentials in the url are dropped as well
+	parsedURL.User = nil
+	if parsedURL.RawQuery != "" {
+		query := parsedURL.Query()
+		for key := range query {
+			query[key] = []string{TRACE_REDACTED}
+		}
+		parsedURL.RawQuery = query.Encode()
+	}
+
+	return parsedURL.String()
+}
+
+func redactErrorBody(body []byte) string {
+	var parsedBody interface{}
+	if err := json.Unmarshal(body, &parsedBody); err != nil {
+		return fmt.Sprintf("<%d bytes omitted, not JSON>", len(body))
+	}
+
+	redactedBody, err := json.Marshal(redactJSONValue("", parsedBody))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes omitted>", len(body))
+	}
+	return string(redactedBody)
+}
+
+func redactJSONValue(key string, value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for childKey, childValue := range typedValue {
+			typedValue[childKey] = redactJSONValue(childKey, childValue)
+		}
+		return typedValue
+	case []interface{}:
+		for idx, childValue := range typedValue {
+			typedValue[idx] = redactJSONValue(key, childValue)
+		}
+		return typedValue
+	case string:
+		if traceSafeErrorKeys[key] {
+			return typedValue
+		}
+		return TRACE_REDACTED
+	default:
+		return typedValue
+	}
+}
@@ -1,9 +1,19 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
 )
 
 func TestFilterReservedEnvVars(t *testing.T) {
@@ -22,7 +32,11 @@ func TestFilterReservedEnvVars(t *testing.T) {
 	}
 
 	// check to see if there are any reserved key words in secrets to inject
-	filterReservedEnvVars(env)
+	droppedKeys := filterReservedEnvVars(env)
+
+	if strings.Join(droppedKeys, ",") != "HOME,LC_CTYPE,PATH,XDG_SESSION_ID" {
+		t.Errorf("Expected the dropped keys to be returned sorted, got %v", droppedKeys)
+	}
 
 	if len(env) != 2 {
 		t.Errorf("Expected 2 secrets to be returned, got %d", len(env))
@@ -47,3 +61,674 @@ func TestFilterReservedEnvVars(t *testing.T) {
 	}
 
 }
+
+func TestBuildEnvironment_Order(t *testing.T) {
+	existingEnv := []string{"ZED=1", "PATH=/bin", "API_URL=old"}
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_PASSWORD", Value: "secret"},
+		{Key: "API_URL", Value: "new"},
+		{Key: "REMOVED", Value: "filtered out"},
+		{Key: "AUTH", Value: "token"},
+	}
+	secretsByKey := map[string]models.SingleEnvironmentVariable{
+		"DB_PASSWORD": {Key: "DB_PASSWORD", Value: "secret"},
+		"API_URL":     {Key: "API_URL", Value: "new"},
+		"AUTH":        {Key: "AUTH", Value: "token"},
+	}
+
+	var tests = []struct {
+		Order    string
+		Expected []string
+	}{
+		{Order: ENV_ORDER_SORTED, Expected: []string{"API_URL=new", "AUTH=token", "DB_PASSWORD=secret", "PATH=/bin", "ZED=1"}},
+		{Order: ENV_ORDER_AS_FETCHED, Expected: []string{"ZED=1", "PATH=/bin", "API_URL=new", "DB_PASSWORD=secret", "AUTH=token"}},
+	}
+
+	for _, test := range tests {
+		// map iteration order is random, so build the environment a few times to make sure the result is stable
+		for i := 0; i < 20; i++ {
+			env := buildEnvironment(existingEnv, secrets, secretsByKey, test.Order)
+			if strings.Join(env, "\n") != strings.Join(test.Expected, "\n") {
+				t.Fatalf("TestBuildEnvironment_Order: expected %v for order [%s] but got %v", test.Expected, test.Order, env)
+			}
+		}
+	}
+}
+
+func TestBuildEnvironment_EnvFileOrder(t *testing.T) {
+	existingEnv := []string{"ZED=1", "PATH=/bin"}
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_PASSWORD", Value: "secret"},
+		{Key: "API_URL", Value: "remote"},
+		{Key: "AUTH", Value: "token"},
+	}
+	envFileSecrets := []models.SingleEnvironmentVariable{
+		{Key: "LOG_LEVEL", Value: "debug"},
+		{Key: "API_URL", Value: "local"},
+	}
+
+	merged := orderSecretsByEnvFile(mergeEnvFileSecrets(secrets, envFileSecrets, false), envFileSecrets)
+	secretsByKey := make(map[string]models.SingleEnvironmentVariable, len(merged))
+	for _, secret := range merged {
+		secretsByKey[secret.Key] = secret
+	}
+
+	expected := []string{"ZED=1", "PATH=/bin", "LOG_LEVEL=debug", "API_URL=local", "DB_PASSWORD=secret", "AUTH=token"}
+	// map iteration order is random, so build the environment a few times to make sure the result is stable
+	for i := 0; i < 20; i++ {
+		env := buildEnvironment(existingEnv, merged, secretsByKey, ENV_ORDER_FILE)
+		if strings.Join(env, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("TestBuildEnvironment_EnvFileOrder: expected %v but got %v", expected, env)
+		}
+	}
+}
+
+func TestMergeEnvFileSecrets(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_HOST", Value: "db.internal"},
+		{Key: "DB_PORT", Value: "5432"},
+		{Key: "RAW", Value: "${DB_HOST}"},
+	}
+	envFileSecrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_PORT", Value: "6543"},
+		{Key: "CONN", Value: "host=${DB_HOST} port=${DB_PORT} user=${MISSING}"},
+	}
+
+	merged := mergeEnvFileSecrets(secrets, envFileSecrets, false)
+	if len(merged) != 4 || merged[1].Value != "6543" || merged[3].Value != envFileSecrets[1].Value {
+		t.Errorf("Expected the env file to override and extend the secrets without expansion, got %+v", merged)
+	}
+
+	merged = mergeEnvFileSecrets(secrets, envFileSecrets, true)
+	if merged[3].Key != "CONN" || merged[3].Value != "host=db.internal port=6543 user=${MISSING}" {
+		t.Errorf("Expected CONN to be expanded against the merged secrets, got [%s]", merged[3].Value)
+	}
+
+	if merged[2].Value != "${DB_HOST}" {
+		t.Errorf("Expected fetched secrets to be left unexpanded, got [%s]", merged[2].Value)
+	}
+}
+
+func TestMergeFetchHookSecrets(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{{Key: "DB_HOST", Value: "db.internal"}}
+	hookSecrets := []models.SingleEnvironmentVariable{{Key: "DB_HOST", Value: "db.local"}, {Key: "LICENSE_KEY", Value: "abc"}}
+
+	merged := mergeFetchHookSecrets(secrets, hookSecrets, false)
+	if len(merged) != 2 || merged[0].Value != "db.internal" || merged[1].Key != "LICENSE_KEY" {
+		t.Errorf("Expected the fetched secrets to win over the hook, got %+v", merged)
+	}
+
+	merged = mergeFetchHookSecrets(secrets, hookSecrets, true)
+	if len(merged) != 2 || merged[0].Value != "db.local" || merged[1].Key != "LICENSE_KEY" {
+		t.Errorf("Expected the hook to win with priority, got %+v", merged)
+	}
+}
+
+func TestRunFetchHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook is run with sh")
+	}
+	t.Setenv("SHELL", "sh")
+
+	secrets := []models.SingleEnvironmentVariable{{Key: "FETCHED_TOKEN", Value: "hunter2"}}
+	options := fetchHookOptions{command: `echo "{\"FROM_HOOK\": \"token=$FETCHED_TOKEN\"}"`, format: "auto"}
+
+	hookSecrets, err := runFetchHook(options, secrets)
+	if err != nil || len(hookSecrets) != 1 || hookSecrets[0].Key != "FROM_HOOK" || hookSecrets[0].Value != "token=" {
+		t.Errorf("Expected the hook to not receive the fetched secrets, got %+v [err=%v]", hookSecrets, err)
+	}
+
+	options.shouldInherit = true
+	hookSecrets, err = runFetchHook(options, secrets)
+	if err != nil || len(hookSecrets) != 1 || hookSecrets[0].Value != "token=hunter2" {
+		t.Errorf("Expected the hook to receive the fetched secrets with inherit, got %+v [err=%v]", hookSecrets, err)
+	}
+
+	if _, err := runFetchHook(fetchHookOptions{command: "exit 2", format: "dotenv"}, nil); err == nil {
+		t.Errorf("Expected a failing hook to return an error")
+	}
+}
+
+func TestRenameSecrets(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_HOST", Value: "db.internal"},
+		{Key: "DB_PASS", Value: "hunter2"},
+		{Key: "LOG_LEVEL", Value: "debug"},
+	}
+	renames := map[string]string{"DB_HOST": "DATABASE_HOST", "DB_PASS": "DATABASE_PASSWORD"}
+
+	renamed, err := renameSecrets(secrets, renames, false)
+	if err != nil || len(renamed) != 3 || renamed[0].Key != "DATABASE_HOST" || renamed[1].Value != "hunter2" || renamed[2].Key != "LOG_LEVEL" {
+		t.Errorf("Expected mapped secrets to be renamed and the others kept, got %+v [err=%v]", renamed, err)
+	}
+
+	if secrets[0].Key != "DB_HOST" {
+		t.Errorf("Expected the fetched secrets to be left untouched, got %+v", secrets)
+	}
+
+	renamed, err = renameSecrets(secrets, renames, true)
+	if err != nil || len(renamed) != 2 {
+		t.Errorf("Expected unmapped secrets to be dropped, got %+v [err=%v]", renamed, err)
+	}
+
+	if _, err := renameSecrets(secrets, map[string]string{"DB_HOST": "HOST", "DB_PASS": "DB_HOST", "LOG_LEVEL": "HOST"}, false); err == nil || !strings.Contains(err.Error(), "[DB_HOST] and [LOG_LEVEL]") {
+		t.Errorf("Expected the colliding secrets to be named, got [err=%v]", err)
+	}
+
+	if _, err := renameSecrets(secrets, map[string]string{"DB_HOST": "LOG_LEVEL"}, false); err == nil {
+		t.Errorf("Expected a secret renamed to the name of an unmapped secret to collide")
+	}
+
+	if swapped, err := renameSecrets(secrets, map[string]string{"DB_HOST": "DB_PASS", "DB_PASS": "DB_HOST"}, false); err != nil || swapped[0].Key != "DB_PASS" {
+		t.Errorf("Expected secrets to be able to swap names, got %+v [err=%v]", swapped, err)
+	}
+}
+
+func TestChangeSecretsCase(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_HOST", Value: "db.internal"},
+		{Key: "log_level", Value: "debug"},
+	}
+
+	lowered, err := changeSecretsCase(secrets, ENV_CASE_LOWER)
+	if err != nil || len(lowered) != 2 || lowered[0].Key != "db_host" || lowered[0].Value != "db.internal" || lowered[1].Key != "log_level" {
+		t.Errorf("Expected the names to be lower cased, got %+v [err=%v]", lowered, err)
+	}
+
+	uppered, err := changeSecretsCase(secrets, ENV_CASE_UPPER)
+	if err != nil || uppered[0].Key != "DB_HOST" || uppered[1].Key != "LOG_LEVEL" {
+		t.Errorf("Expected the names to be upper cased, got %+v [err=%v]", uppered, err)
+	}
+
+	if secrets[1].Key != "log_level" {
+		t.Errorf("Expected the fetched secrets to be left untouched, got %+v", secrets)
+	}
+
+	colliding := append(secrets, models.SingleEnvironmentVariable{Key: "Db_Host", Value: "other"})
+	for _, envCase := range []string{ENV_CASE_LOWER, ENV_CASE_UPPER} {
+		if _, err := changeSecretsCase(colliding, envCase); err == nil || !strings.Contains(err.Error(), "[DB_HOST] and [Db_Host]") {
+			t.Errorf("Expected the secrets folding to the same name with --env-case=%s to be named, got [err=%v]", envCase, err)
+		}
+	}
+}
+
+func TestClearSecretsFromMemory(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{{Key: "DB_PASSWORD", Value: "hunter2"}}
+	secretsByKey := getSecretsByKeys(secrets)
+	env := buildEnvironment([]string{"HOME=/home/app"}, secrets, secretsByKey, ENV_ORDER_SORTED)
+
+	command := exec.Command("sh", "-c", "test \"$DB_PASSWORD\" = hunter2")
+	command.Env = env
+
+	exitCode, err := execCmd(command, func(pid int) error {
+		clearSecretsFromMemory(secrets, secretsByKey, env)
+		return nil
+	})
+
+	if err != nil || exitCode != 0 {
+		t.Fatalf("TestClearSecretsFromMemory: expected the child to still get the secret [exitCode=%d] [err=%v]", exitCode, err)
+	}
+
+	if secrets[0].Value != "" || len(secretsByKey) != 0 {
+		t.Errorf("TestClearSecretsFromMemory: expected the secrets to be cleared but got %+v and %+v", secrets, secretsByKey)
+	}
+
+	for _, entry := range command.Env {
+		if entry != "" {
+			t.Errorf("TestClearSecretsFromMemory: expected the environment of the command to be cleared but got [%s]", entry)
+		}
+	}
+}
+
+func TestGetShellInvocation(t *testing.T) {
+	testCases := []struct {
+		shellOverride string
+		goos          string
+		currentShell  string
+		expected      [2]string
+	}{
+		{"", "linux", "", [2]string{"sh", "-c"}},
+		{"", "linux", "/usr/bin/zsh", [2]string{"/usr/bin/zsh", "-c"}},
+		{"", "windows", "/usr/bin/bash", [2]string{"cmd", "/C"}},
+		{"/bin/bash", "linux", "/usr/bin/zsh", [2]string{"/bin/bash", "-c"}},
+		{"sh", "linux", "/bin/bash", [2]string{"sh", "-c"}},
+		{"pwsh", "linux", "/bin/bash", [2]string{"pwsh", "-Command"}},
+		{`C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`, "windows", "", [2]string{`C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`, "-Command"}},
+		{"CMD.EXE", "windows", "", [2]string{"CMD.EXE", "/C"}},
+		{"bash", "windows", "", [2]string{"bash", "-c"}},
+	}
+
+	for _, testCase := range testCases {
+		shell := getShellInvocation(testCase.shellOverride, testCase.goos, testCase.currentShell)
+		if shell != testCase.expected {
+			t.Errorf("Expected --shell=%q on %s with SHELL=%q to run %v, got %v", testCase.shellOverride, testCase.goos, testCase.currentShell, testCase.expected, shell)
+		}
+	}
+}
+
+func TestGetShellInvocation_QuotingOfTheChosenShell(t *testing.T) {
+	// $'...' is expanded by bash but is a plain $ followed by a quoted string for a POSIX sh
+	const command = `printf '%s' $'a\tb'`
+	for shellName, expected := range map[string]string{"bash": "a\tb", "sh": `$a\tb`} {
+		shellPath, err := exec.LookPath(shellName)
+		if err != nil {
+			t.Logf("%s is not installed, skipping", shellName)
+			continue
+		}
+
+		if shellName == "sh" {
+			// some distributions link sh to bash, which keeps the bash quoting rules when run as sh
+			if resolved, err := filepath.EvalSymlinks(shellPath); err == nil && filepath.Base(resolved) == "bash" {
+				continue
+			}
+		}
+
+		shell := getShellInvocation(shellPath, runtime.GOOS, "")
+		output, err := exec.Command(shell[0], shell[1], command).Output()
+		if err != nil || string(output) != expected {
+			t.Errorf("Expected %s to print %q, got %q [err=%v]", shellName, expected, output, err)
+		}
+	}
+}
+
+func TestExecCmd_ExitCodes(t *testing.T) {
+	exitCode, err := execCmd(exec.Command("sh", "-c", "exit 7"), nil)
+	if err != nil || exitCode != 7 {
+		t.Errorf("Expected the exit code of the command to be returned, got %d [err=%v]", exitCode, err)
+	}
+
+	exitCode, err = execCmd(exec.Command("sh", "-c", "kill -TERM $$"), nil)
+	if err != nil || exitCode != 128+15 {
+		t.Errorf("Expected a command killed by SIGTERM to exit with 143, got %d [err=%v]", exitCode, err)
+	}
+}
+
+func TestExecutePostExecCommand(t *testing.T) {
+	var output strings.Builder
+	env := []string{"DB_PASSWORD=hunter2"}
+
+	exitCode, err := executePostExecCommand(`echo "$INFISICAL_CHILD_EXIT $DB_PASSWORD"; exit 3`, "sh", 143, env, "", &output, &output)
+	if err != nil || exitCode != 3 {
+		t.Errorf("Expected the exit code of the post exec command, got %d [err=%v]", exitCode, err)
+	}
+
+	if output.String() != "143 hunter2\n" {
+		t.Errorf("Expected the post exec command to get the exit code of the application and the secrets, got [%s]", output.String())
+	}
+
+	if len(env) != 1 {
+		t.Errorf("Expected the environment of the application to be left untouched, got %v", env)
+	}
+}
+
+func TestCheckExecutableExists(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on executables without a .exe suffix")
+	}
+
+	workingDirectory := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workingDirectory, "app"), []byte("#!/bin/sh\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkExecutableExists("sh", ""); err != nil {
+		t.Errorf("Expected sh to be found in PATH, got %v", err)
+	}
+
+	if err := checkExecutableExists("./app", workingDirectory); err != nil {
+		t.Errorf("Expected ./app to be found in the working directory, got %v", err)
+	}
+
+	err := checkExecutableExists("infisical-no-such-command", workingDirectory)
+	if err == nil || err.Error() != "command not found: infisical-no-such-command" {
+		t.Errorf("Expected a command not found error, got %v", err)
+	}
+
+	if err := checkExecutableExists("./missing", workingDirectory); err == nil {
+		t.Errorf("Expected ./missing to not be found")
+	}
+}
+
+func TestParseUmask(t *testing.T) {
+	for value, expected := range map[string]int{"0077": 0077, "077": 0077, "0": 0, "0777": 0777} {
+		if mask, err := parseUmask(value); err != nil || mask != expected {
+			t.Errorf("Expected %s to be parsed as %o, got %o [err=%v]", value, expected, mask, err)
+		}
+	}
+
+	for _, value := range []string{"", "0800", "1777", "u=rwx", "-1"} {
+		if _, err := parseUmask(value); err == nil {
+			t.Errorf("Expected %q to be refused", value)
+		}
+	}
+}
+
+func TestNewSecretsPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("--pass-fd is not supported on Windows")
+	}
+
+	// larger than the buffer of a pipe, so that the write only completes as the content is read
+	content := bytes.Repeat([]byte("SECRET=\"value\"\n"), 10000)
+	secretsPipe, err := newSecretsPipe(content)
+	if err != nil {
+		t.Fatalf("Expected a pipe, got %v", err)
+	}
+	defer secretsPipe.Close()
+
+	readContent, err := io.ReadAll(secretsPipe)
+	if err != nil || !bytes.Equal(readContent, content) {
+		t.Errorf("Expected the content to be read back followed by EOF, got %d bytes [err=%v]", len(readContent), err)
+	}
+}
+
+func TestRunAbortsBeforeStartingOnProcessingError(t *testing.T) {
+	// the run command exits, so it is run in a child process
+	if runArgs := os.Getenv("TEST_RUN_ARGS"); runArgs != "" {
+		rootCmd.SetArgs(strings.Split(runArgs, "\n"))
+		rootCmd.Execute()
+		return
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the application is started with sh")
+	}
+
+	var tests = []struct {
+		Name    string
+		Secrets string
+		Files   map[string]string
+		Args    []string
+	}{
+		{Name: "expansion cycle", Secrets: "A=${B}\nB=${A}\n"},
+		{Name: "env file expansion cycle", Secrets: "A=${B}\n", Files: map[string]string{"local.env": "B=${A}\n"}, Args: []string{"--env-file", "local.env", "--env-file-expand"}},
+		{Name: "invalid encoding", Secrets: "CERT=not-base64!\n", Args: []string{"--decode", "base64", "--decode-keys", "CERT"}},
+		{Name: "decoded NUL byte", Secrets: "CERT=AA==\n", Args: []string{"--decode", "base64", "--decode-keys", "CERT"}},
+		{Name: "failing fetch hook", Secrets: "A=1\n", Args: []string{"--fetch-hook", "exit 3"}},
+		{Name: "unparsable fetch hook output", Secrets: "A=1\n", Args: []string{"--fetch-hook", "echo '{'", "--fetch-hook-format", "json"}},
+		{Name: "rename collision", Secrets: "A=1\nB=2\n", Files: map[string]string{"renames.env": "A=B\n"}, Args: []string{"--rename-file", "renames.env"}},
+		{Name: "invalid env file", Secrets: "A=1\n", Files: map[string]string{"local.json": "{"}, Args: []string{"--env-file", "local.json", "--env-file-format", "json"}},
+		{Name: "case collision", Secrets: "db=1\nDB=2\n", Args: []string{"--env-case", "upper"}},
+		{Name: "strict reserved", Secrets: "PATH=/tmp\n", Args: []string{"--strict-reserved"}},
+		{Name: "reserved after suffix", Secrets: "PA=/tmp\n", Args: []string{"--secret-suffix", "TH", "--strict-reserved"}},
+		{Name: "oversized value", Secrets: "A=12345\n", Args: []string{"--max-value-size", "4", "--on-oversize", "error"}},
+		{Name: "invalid secret file name", Secrets: "..=1\n", Args: []string{"--secrets-as-files", "secrets"}},
+		{Name: "failing output capture", Secrets: "A=1\n", Args: []string{"--secrets-as-files", "secrets", "--capture-output", "missing/output.log"}},
+	}
+
+	for _, test := range tests {
+		workingDirectory := t.TempDir()
+		files := map[string]string{"secrets.env": test.Secrets}
+		for name, content := range test.Files {
+			files[name] = content
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(workingDirectory, name), []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		runArgs := append([]string{"run", "--secrets-from-json", "secrets.env", "--stdin-secrets-format", "dotenv"}, test.Args...)
+		runArgs = append(runArgs, "--", "sh", "-c", "touch started")
+
+		cmd := exec.Command(os.Args[0], "-test.run=^TestRunAbortsBeforeStartingOnProcessingError$")
+		cmd.Dir = workingDirectory
+		cmd.Env = append(os.Environ(), "TEST_RUN_ARGS="+strings.Join(runArgs, "\n"), "HOME="+workingDirectory, "INFISICAL_DISABLE_UPDATE_CHECK=1")
+		output, err := cmd.CombinedOutput()
+
+		exitErr, isExitErr := err.(*exec.ExitError)
+		if !isExitErr || exitErr.ExitCode() != 1 {
+			t.Errorf("Expected %s to exit with code 1, got [err=%v] with output [%s]", test.Name, err, output)
+		}
+
+		if _, err := os.Stat(filepath.Join(workingDirectory, "started")); err == nil {
+			t.Errorf("Expected the application to not be started on %s", test.Name)
+		}
+
+		if _, err := os.Stat(filepath.Join(workingDirectory, "secrets")); err == nil {
+			t.Errorf("Expected the secret files to be removed on %s", test.Name)
+		}
+	}
+}
+
+func TestAffixSecretNames(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_HOST", Value: "db.internal"},
+		{Key: "DB_HOST_PROD", Value: "db.prod"},
+	}
+
+	var tests = []struct {
+		Prefix       string
+		Suffix       string
+		ExpectedKeys string
+	}{
+		{Prefix: "APP_", ExpectedKeys: "APP_DB_HOST APP_DB_HOST_PROD"},
+		{Suffix: "_PROD", ExpectedKeys: "DB_HOST_PROD DB_HOST_PROD_PROD"},
+		{Prefix: "APP_", Suffix: "_PROD", ExpectedKeys: "APP_DB_HOST_PROD APP_DB_HOST_PROD_PROD"},
+	}
+
+	for _, test := range tests {
+		affixed, err := affixSecretNames(secrets, test.Prefix, test.Suffix)
+		keys := []string{}
+		for _, secret := range affixed {
+			keys = append(keys, secret.Key)
+		}
+
+		if err != nil || strings.Join(keys, " ") != test.ExpectedKeys || affixed[0].Value != "db.internal" {
+			t.Errorf("Expected [%s] with prefix [%s] and suffix [%s], got %+v [err=%v]", test.ExpectedKeys, test.Prefix, test.Suffix, affixed, err)
+		}
+	}
+
+	if secrets[0].Key != "DB_HOST" {
+		t.Errorf("Expected the fetched secrets to be left untouched, got %+v", secrets)
+	}
+
+	colliding := append(secrets, models.SingleEnvironmentVariable{Key: "DB_HOST", Value: "other"})
+	if _, err := affixSecretNames(colliding, "APP_", "_PROD"); err == nil || !strings.Contains(err.Error(), "[APP_DB_HOST_PROD]") {
+		t.Errorf("Expected the secrets ending up with the same name to be an error, got [err=%v]", err)
+	}
+
+	if _, err := affixSecretNames(secrets, "", "=X"); err == nil {
+		t.Errorf("Expected a suffix with = to be refused")
+	}
+}
+
+func TestFormatVersionInfo(t *testing.T) {
+	isUpdateAvailable := true
+	info := versionInfo{Version: "0.16.0", Commit: "abc1234", BuildDate: "2024-01-02T03:04:05Z", GoVersion: "go1.21.0", OS: "linux", Arch: "amd64", LatestVersion: "0.17.0", IsUpdateAvailable: &isUpdateAvailable}
+
+	formattedInfo, err := formatVersionInfo(info, VERSION_OUTPUT_JSON)
+	var parsedInfo map[string]interface{}
+	if err != nil || json.Unmarshal([]byte(formattedInfo), &parsedInfo) != nil || parsedInfo["commit"] != "abc1234" || parsedInfo["updateAvailable"] != true {
+		t.Errorf("Expected the version info as JSON, got [%s] [err=%v]", formattedInfo, err)
+	}
+
+	formattedInfo, err = formatVersionInfo(info, VERSION_OUTPUT_TEXT)
+	if err != nil || !strings.HasPrefix(formattedInfo, "infisical version 0.16.0\n") || !strings.Contains(formattedInfo, "platform: linux/amd64\n") || !strings.Contains(formattedInfo, "latest: 0.17.0, an update is available\n") {
+		t.Errorf("Expected the version info as text, got [%s] [err=%v]", formattedInfo, err)
+	}
+
+	info.LatestVersion, info.IsUpdateAvailable = "", nil
+	if formattedInfo, _ := formatVersionInfo(info, VERSION_OUTPUT_JSON); strings.Contains(formattedInfo, "latestVersion") || strings.Contains(formattedInfo, "updateAvailable") {
+		t.Errorf("Expected the latest version to be left out without --check, got [%s]", formattedInfo)
+	}
+}
+
+func TestFormatCountdown(t *testing.T) {
+	var tests = []struct {
+		Duration string
+		Expected string
+	}{
+		{Duration: "167h30m", Expected: "6d 23h"},
+		{Duration: "3h12m40s", Expected: "3h 12m"},
+		{Duration: "4m10s", Expected: "4m 10s"},
+		{Duration: "45s", Expected: "45s"},
+		{Duration: "0s", Expected: "0s"},
+	}
+
+	for _, test := range tests {
+		duration, _ := time.ParseDuration(test.Duration)
+		if formatted := formatCountdown(duration); formatted != test.Expected {
+			t.Errorf("Expected [%s] for %s, got [%s]", test.Expected, test.Duration, formatted)
+		}
+	}
+}
+
+func TestFormatLoginStatus(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(26 * time.Hour)
+
+	formatted := formatLoginStatus(util.LoginStatus{IsAuthenticated: true, AuthMethod: util.AUTH_METHOD_LOGGED_IN_USER, Identity: "user@example.com", ExpiresAt: &expiresAt}, now)
+	if !strings.HasPrefix(formatted, "Authenticated with the logged in user [user@example.com]\n") || !strings.Contains(formatted, "Expires: in 1d 2h (") {
+		t.Errorf("Expected the countdown to the expiry, got [%s]", formatted)
+	}
+
+	formatted = formatLoginStatus(util.LoginStatus{IsAuthenticated: true, AuthMethod: util.AUTH_METHOD_TOKEN_ENV, Identity: "ci"}, now)
+	if !strings.Contains(formatted, "Expires: never\n") {
+		t.Errorf("Expected a service token without expiry to never expire, got [%s]", formatted)
+	}
+
+	expiredAt := now.Add(-90 * time.Minute)
+	formatted = formatLoginStatus(util.LoginStatus{AuthMethod: util.AUTH_METHOD_LOGGED_IN_USER, ExpiresAt: &expiredAt, IsExpired: true, Reason: "your login expired"}, now)
+	if !strings.HasPrefix(formatted, "Not authenticated: your login expired\n") || !strings.Contains(formatted, "Expired: 1h 30m ago (") {
+		t.Errorf("Expected the time since the expiry, got [%s]", formatted)
+	}
+}
+
+func TestNamedDomains(t *testing.T) {
+	configFile := models.ConfigFile{
+		LoggedInUserEmail: "user@example.com",
+		LoggedInUsers:     []models.LoggedInUser{{Email: "user@example.com", Domain: util.INFISICAL_DEFAULT_API_URL}, {Email: "other@example.com", Domain: "staging"}},
+	}
+
+	if err := addNamedDomain(&configFile, "staging", "https://staging.example.com/api"); err != nil {
+		t.Fatalf("Expected the domain to be added [err=%v]", err)
+	}
+
+	if err := addNamedDomain(&configFile, "staging", "https://other.example.com/api"); err == nil {
+		t.Errorf("Expected a duplicate domain name to be rejected")
+	}
+
+	if len(configFile.Domains) != 1 || configFile.Domains[0].URL != "https://staging.example.com/api" {
+		t.Errorf("Expected the rejected domain to leave the registered one untouched, got %+v", configFile.Domains)
+	}
+
+	for _, invalidDomain := range [][2]string{{"my domain", "https://example.com/api"}, {"prod", "example.com/api"}, {"prod", "ftp://example.com"}} {
+		if err := addNamedDomain(&configFile, invalidDomain[0], invalidDomain[1]); err == nil {
+			t.Errorf("Expected the domain %v to be rejected", invalidDomain)
+		}
+	}
+
+	if err := useNamedDomain(&configFile, "missing"); err == nil {
+		t.Errorf("Expected using an unknown domain to be rejected")
+	}
+
+	if err := useNamedDomain(&configFile, "staging"); err != nil || configFile.LoggedInUserDomain != "staging" || configFile.LoggedInUsers[0].Domain != "staging" {
+		t.Fatalf("Expected the current profile to use the domain by name, got %+v [err=%v]", configFile, err)
+	}
+
+	if err := removeNamedDomain(&configFile, "missing"); err == nil {
+		t.Errorf("Expected removing an unknown domain to be rejected")
+	}
+
+	if err := removeNamedDomain(&configFile, "staging"); err != nil {
+		t.Fatalf("Expected the domain to be removed [err=%v]", err)
+	}
+
+	if len(configFile.Domains) != 0 || configFile.LoggedInUserDomain != util.INFISICAL_DEFAULT_API_URL {
+		t.Errorf("Expected removing the domain in use to fall back to the default url, got %+v", configFile)
+	}
+
+	for _, user := range configFile.LoggedInUsers {
+		if user.Domain != util.INFISICAL_DEFAULT_API_URL {
+			t.Errorf("Expected the profile of [%s] to fall back to the default url, got [%s]", user.Email, user.Domain)
+		}
+	}
+}
+
+func TestTruncateToValidUTF8(t *testing.T) {
+	// the euro sign is 3 bytes, so cutting at 3 or 4 bytes would split it
+	var tests = []struct {
+		Value    string
+		MaxBytes int
+		Expected string
+	}{
+		{Value: "ab€cd", MaxBytes: 2, Expected: "ab"},
+		{Value: "ab€cd", MaxBytes: 3, Expected: "ab"},
+		{Value: "ab€cd", MaxBytes: 4, Expected: "ab"},
+		{Value: "ab€cd", MaxBytes: 5, Expected: "ab€"},
+		{Value: "ab€cd", MaxBytes: 7, Expected: "ab€cd"},
+		{Value: "€", MaxBytes: 1, Expected: ""},
+	}
+
+	for _, test := range tests {
+		if truncated := truncateToValidUTF8(test.Value, test.MaxBytes); truncated != test.Expected {
+			t.Errorf("Expected [%s] cut at %d bytes to be [%s], got [%s]", test.Value, test.MaxBytes, test.Expected, truncated)
+		}
+	}
+}
+
+func TestEnforceMaxValueSize(t *testing.T) {
+	newEnv := func() map[string]models.SingleEnvironmentVariable {
+		return map[string]models.SingleEnvironmentVariable{
+			"AT_LIMIT":  {Key: "AT_LIMIT", Value: "12345"},
+			"OVERSIZED": {Key: "OVERSIZED", Value: "abcd€"},
+			"SMALL":     {Key: "SMALL", Value: "1"},
+		}
+	}
+
+	env := newEnv()
+	err := enforceMaxValueSize(env, 5, ON_OVERSIZE_ERROR)
+	if err == nil || !strings.Contains(err.Error(), "[OVERSIZED]") {
+		t.Errorf("Expected only the oversized secret to be reported, got [err=%v]", err)
+	}
+
+	env = newEnv()
+	if err := enforceMaxValueSize(env, 5, ON_OVERSIZE_WARN); err != nil || env["OVERSIZED"].Value != "abcd€" {
+		t.Errorf("Expected the oversized secret to be kept as is with a warning, got %+v [err=%v]", env["OVERSIZED"], err)
+	}
+
+	env = newEnv()
+	if err := enforceMaxValueSize(env, 5, ON_OVERSIZE_TRUNCATE); err != nil || env["OVERSIZED"].Value != "abcd" {
+		t.Errorf("Expected the oversized secret to be truncated before the euro sign, got %+v [err=%v]", env["OVERSIZED"], err)
+	}
+
+	if env["AT_LIMIT"].Value != "12345" || env["SMALL"].Value != "1" {
+		t.Errorf("Expected the secrets within the limit to be left untouched, got %+v", env)
+	}
+
+	env = newEnv()
+	if err := enforceMaxValueSize(env, 7, ON_OVERSIZE_ERROR); err != nil {
+		t.Errorf("Expected values exactly at the limit to be accepted [err=%v]", err)
+	}
+}
+
+func TestEnvironmentAliases(t *testing.T) {
+	configFile := models.ConfigFile{}
+
+	if isUpdate, err := addEnvironmentAlias(&configFile, "prod", "production-us-east"); err != nil || isUpdate {
+		t.Fatalf("Expected the alias to be added, got [isUpdate=%v] [err=%v]", isUpdate, err)
+	}
+
+	if isUpdate, err := addEnvironmentAlias(&configFile, "prod", "production-eu-west"); err != nil || !isUpdate || configFile.EnvironmentAliases[0].Slug != "production-eu-west" {
+		t.Errorf("Expected the existing alias to be pointed at the new slug, got %+v [err=%v]", configFile.EnvironmentAliases, err)
+	}
+
+	for _, invalidAlias := range [][2]string{{"my alias", "dev"}, {"", "dev"}, {"dev", "dev"}, {"stg", ""}, {"live", "prod"}} {
+		if _, err := addEnvironmentAlias(&configFile, invalidAlias[0], invalidAlias[1]); err == nil {
+			t.Errorf("Expected the alias %v to be rejected", invalidAlias)
+		}
+	}
+
+	if len(configFile.EnvironmentAliases) != 1 {
+		t.Errorf("Expected the rejected aliases to not be added, got %+v", configFile.EnvironmentAliases)
+	}
+
+	if err := removeEnvironmentAlias(&configFile, "missing"); err == nil {
+		t.Errorf("Expected removing an unknown alias to be rejected")
+	}
+
+	if err := removeEnvironmentAlias(&configFile, "prod"); err != nil || len(configFile.EnvironmentAliases) != 0 {
+		t.Errorf("Expected the alias to be removed, got %+v [err=%v]", configFile.EnvironmentAliases, err)
+	}
+}
@@ -0,0 +1,354 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:                   "config",
+	Short:                 "Used to manage the configuration of the Infisical CLI on your machine",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical config",
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var configDomainsCmd = &cobra.Command{
+	Use:                   "domains",
+	Short:                 "Used to list the named Infisical domains registered on your machine",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical config domains",
+	Args:                  cobra.NoArgs,
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get your config file")
+		}
+
+		if len(configFile.Domains) == 0 {
+			fmt.Println("No named domains registered yet. To add one, run [infisical config domains add <name> <url>]")
+			return
+		}
+
+		rows := [][3]string{}
+		for _, namedDomain := range configFile.Domains {
+			status := ""
+			if namedDomain.Name == configFile.LoggedInUserDomain || namedDomain.URL == configFile.LoggedInUserDomain {
+				status = "ACTIVE"
+			}
+			rows = append(rows, [...]string{namedDomain.Name, namedDomain.URL, status})
+		}
+
+		visualize.Table([...]string{"DOMAIN NAME", "DOMAIN URL", "STATUS"}, rows)
+	},
+}
+
+var configDomainsAddCmd = &cobra.Command{
+	Use:                   "add [name] [url]",
+	Short:                 "Used to register a named Infisical domain",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical config domains add prod https://my-self-hosted-instance.com/api",
+	Args:                  cobra.ExactArgs(2),
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		domainName := args[0]
+		domainUrl := strings.TrimSuffix(args[1], "/")
+
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get your config file")
+		}
+
+		err = addNamedDomain(&configFile, domainName, domainUrl)
+		if err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
+		err = util.WriteConfigFile(&configFile)
+		if err != nil {
+			util.HandleError(err, "Unable to save your named domain")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Added domain [%s] pointing to [%s]. To start using it, run [infisical config domains use %s]", domainName, domainUrl, domainName))
+	},
+}
+
+var configDomainsUseCmd = &cobra.Command{
+	Use:                   "use [name]",
+	Short:                 "Used to switch the active Infisical domain to a named domain",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical config domains use prod",
+	Args:                  cobra.ExactArgs(1),
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		domainName := args[0]
+
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get your config file")
+		}
+
+		err = useNamedDomain(&configFile, domainName)
+		if err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
+		err = util.WriteConfigFile(&configFile)
+		if err != nil {
+			util.HandleError(err, "Unable to switch your active domain")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Now using domain [%s] (%s)", domainName, util.ResolveDomain(configFile, domainName)))
+	},
+}
+
+var configDomainsRemoveCmd = &cobra.Command{
+	Use:                   "remove [name]",
+	Short:                 "Used to remove a named Infisical domain",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical config domains remove prod",
+	Args:                  cobra.ExactArgs(1),
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		domainName := args[0]
+
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get your config file")
+		}
+
+		err = removeNamedDomain(&configFile, domainName)
+		if err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
+		err = util.WriteConfigFile(&configFile)
+		if err != nil {
+			util.HandleError(err, "Unable to remove your named domain")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Removed domain [%s]", domainName))
+	},
+}
+
+// Registers a new named domain. An existing name is rejected so that a typo cannot silently repoint a domain that is in use
+func addNamedDomain(configFile *models.ConfigFile, domainName string, domainUrl string) error {
+	if domainName == "" || strings.ContainsAny(domainName, " /:") {
+		return fmt.Errorf("the domain name [%s] is invalid. Domain names cannot be empty or contain spaces, slashes or colons", domainName)
+	}
+
+	parsedUrl, err := url.ParseRequestURI(domainUrl)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return fmt.Errorf("the url [%s] is invalid. Example of a valid url: https://my-self-hosted-instance.com/api", domainUrl)
+	}
+
+	for _, namedDomain := range configFile.Domains {
+		if namedDomain.Name == domainName {
+			return fmt.Errorf("a domain named [%s] is already registered. To point it at another url, run [infisical config domains remove %s] first", domainName, domainName)
+		}
+	}
+
+	configFile.Domains = append(configFile.Domains, models.NamedDomain{Name: domainName, URL: domainUrl})
+	return nil
+}
+
+// Switches the current profile to a named domain
+func useNamedDomain(configFile *models.ConfigFile, domainName string) error {
+	if util.ResolveDomain(*configFile, domainName) == domainName {
+		return fmt.Errorf("no domain named [%s] is registered. To see all registered domains, run [infisical config domains]", domainName)
+	}
+
+	// the profile references the domain by name so that updating the url of a named domain is picked up automatically
+	configFile.LoggedInUserDomain = domainName
+	for idx, user := range configFile.LoggedInUsers {
+		if user.Email == configFile.LoggedInUserEmail {
+			configFile.LoggedInUsers[idx].Domain = domainName
+		}
+	}
+
+	return nil
+}
+
+// Removes a named domain. Profiles that referenced it by name fall back to the default Infisical url
+func removeNamedDomain(configFile *models.ConfigFile, domainName string) error {
+	remainingDomains := []models.NamedDomain{}
+	isRemoved := false
+	for _, namedDomain := range configFile.Domains {
+		if namedDomain.Name == domainName {
+			isRemoved = true
+			continue
+		}
+		remainingDomains = append(remainingDomains, namedDomain)
+	}
+
+	if !isRemoved {
+		return fmt.Errorf("no domain named [%s] is registered. To see all registered domains, run [infisical config domains]", domainName)
+	}
+
+	if configFile.LoggedInUserDomain == domainName {
+		configFile.LoggedInUserDomain = util.INFISICAL_DEFAULT_API_URL
+	}
+	for idx, user := range configFile.LoggedInUsers {
+		if user.Domain == domainName {
+			configFile.LoggedInUsers[idx].Domain = util.INFISICAL_DEFAULT_API_URL
+		}
+	}
+
+	configFile.Domains = remainingDomains
+	return nil
+}
+
+var configEnvAliasCmd = &cobra.Command{
+	Use:                   "env-alias",
+	Short:                 "Used to list the environment aliases registered on your machine",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical config env-alias",
+	Args:                  cobra.NoArgs,
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get your config file")
+		}
+
+		if len(configFile.EnvironmentAliases) == 0 {
+			fmt.Println("No environment aliases registered yet. To add one, run [infisical config env-alias add <alias> <environment slug>]")
+			return
+		}
+
+		for _, environmentAlias := range configFile.EnvironmentAliases {
+			fmt.Printf("%s -> %s\n", environmentAlias.Alias, environmentAlias.Slug)
+		}
+	},
+}
+
+var configEnvAliasAddCmd = &cobra.Command{
+	Use:                   "add [alias] [environment slug]",
+	Short:                 "Used to register a short alias for an environment slug, usable with --env",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical config env-alias add prod production-us-east",
+	Args:                  cobra.ExactArgs(2),
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, environmentSlug := args[0], args[1]
+
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get your config file")
+		}
+
+		isUpdate, err := addEnvironmentAlias(&configFile, alias, environmentSlug)
+		if err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
+		err = util.WriteConfigFile(&configFile)
+		if err != nil {
+			util.HandleError(err, "Unable to save your environment alias")
+		}
+
+		if isUpdate {
+			util.PrintSuccessMessage(fmt.Sprintf("Updated alias [%s] to point to environment [%s]", alias, environmentSlug))
+		} else {
+			util.PrintSuccessMessage(fmt.Sprintf("Added alias [%s] for environment [%s]. To use it, pass [--env %s]", alias, environmentSlug, alias))
+		}
+	},
+}
+
+var configEnvAliasRemoveCmd = &cobra.Command{
+	Use:                   "remove [alias]",
+	Short:                 "Used to remove an environment alias",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical config env-alias remove prod",
+	Args:                  cobra.ExactArgs(1),
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		alias := args[0]
+
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get your config file")
+		}
+
+		err = removeEnvironmentAlias(&configFile, alias)
+		if err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
+		err = util.WriteConfigFile(&configFile)
+		if err != nil {
+			util.HandleError(err, "Unable to remove your environment alias")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Removed alias [%s]", alias))
+	},
+}
+
+// Registers an alias for an environment slug, or points an existing alias at the new slug. Returns whether the alias already existed
+func addEnvironmentAlias(configFile *models.ConfigFile, alias string, environmentSlug string) (bool, error) {
+	if alias == "" || strings.ContainsAny(alias, " /:") {
+		return false, fmt.Errorf("the alias [%s] is invalid. Aliases cannot be empty or contain spaces, slashes or colons", alias)
+	}
+
+	if environmentSlug == "" || alias == environmentSlug {
+		return false, fmt.Errorf("the environment slug [%s] is invalid. It cannot be empty or the alias itself", environmentSlug)
+	}
+
+	// aliases are resolved once, an alias of an alias would silently point at the wrong environment
+	for _, environmentAlias := range configFile.EnvironmentAliases {
+		if environmentAlias.Alias == environmentSlug {
+			return false, fmt.Errorf("[%s] is an alias itself, point [%s] directly at [%s] instead", environmentSlug, alias, environmentAlias.Slug)
+		}
+	}
+
+	for idx, environmentAlias := range configFile.EnvironmentAliases {
+		if environmentAlias.Alias == alias {
+			configFile.EnvironmentAliases[idx].Slug = environmentSlug
+			return true, nil
+		}
+	}
+
+	configFile.EnvironmentAliases = append(configFile.EnvironmentAliases, models.EnvironmentAlias{Alias: alias, Slug: environmentSlug})
+	return false, nil
+}
+
+func removeEnvironmentAlias(configFile *models.ConfigFile, alias string) error {
+	remainingAliases := []models.EnvironmentAlias{}
+	for _, environmentAlias := range configFile.EnvironmentAliases {
+		if environmentAlias.Alias != alias {
+			remainingAliases = append(remainingAliases, environmentAlias)
+		}
+	}
+
+	if len(remainingAliases) == len(configFile.EnvironmentAliases) {
+		return fmt.Errorf("no alias named [%s] is registered. To see all registered aliases, run [infisical config env-alias]", alias)
+	}
+
+	configFile.EnvironmentAliases = remainingAliases
+	return nil
+}
+
+func init() {
+	configEnvAliasCmd.AddCommand(configEnvAliasAddCmd)
+	configEnvAliasCmd.AddCommand(configEnvAliasRemoveCmd)
+	configCmd.AddCommand(configEnvAliasCmd)
+
+	configDomainsCmd.AddCommand(configDomainsAddCmd)
+	configDomainsCmd.AddCommand(configDomainsUseCmd)
+	configDomainsCmd.AddCommand(configDomainsRemoveCmd)
+	configCmd.AddCommand(configDomainsCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// not defined by the syscall package, see https://learn.microsoft.com/en-us/windows/win32/procthread/process-creation-flags
+const detachedProcess = 0x00000008
+
+// without a console and in its own process group, your application does not receive the Ctrl+C of the console it was started from
+func getDetachedSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess, HideWindow: true}
+}
+
+// processes cannot be sent signals on Windows, so they are terminated instead
+func stopProcess(process *os.Process) error {
+	return process.Kill()
+}
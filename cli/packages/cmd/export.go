@@ -4,25 +4,116 @@ Copyright (c) 2023 Infisical Inc.
 package cmd
 
 import (
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
 
+	"github.com/Infisical/infisical-merge/packages/crypto"
 	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/openpgp"
+	"gopkg.in/yaml.v3"
 )
 
 const (
-	FormatDotenv       string = "dotenv"
-	FormatJson         string = "json"
-	FormatCSV          string = "csv"
-	FormatYaml         string = "yaml"
-	FormatDotEnvExport string = "dotenv-export"
+	FormatDotenv         string = "dotenv"
+	FormatJson           string = "json"
+	FormatCSV            string = "csv"
+	FormatYaml           string = "yaml"
+	FormatDotEnvExport   string = "dotenv-export"
+	FormatDotEnvDocker   string = "dotenv-docker"
+	FormatIni            string = "ini"
+	FormatSSM            string = "ssm"
+	FormatSecretsManager string = "secretsmanager"
+	FormatSystemdUnit    string = "systemd-unit"
+	FormatConsul         string = "consul"
+	FormatConsulJson     string = "consul-json"
+	FormatNginx          string = "nginx"
+	FormatFlyio          string = "flyio"
+	FormatPowershell     string = "powershell"
 )
 
+const (
+	EXPORT_SORT_KEYS   = "keys"
+	EXPORT_SORT_VALUES = "values"
+	EXPORT_SORT_NONE   = "none"
+)
+
+// Line endings of the output, set with --line-endings. platform is crlf on Windows and lf everywhere else
+const (
+	LINE_ENDINGS_LF       = "lf"
+	LINE_ENDINGS_CRLF     = "crlf"
+	LINE_ENDINGS_PLATFORM = "platform"
+)
+
+// What --output-template renders for a secret that does not exist, set with --on-secret-missing
+const (
+	MISSING_SECRET_SKIP  = "skip"
+	MISSING_SECRET_EMPTY = "empty"
+	MISSING_SECRET_FAIL  = "fail"
+)
+
+const (
+	DEFAULT_INI_SECTION_DELIMITER = "__"
+	DEFAULT_INI_SECTION_NAME      = "DEFAULT"
+	DEFAULT_SSM_PARAMETER_TYPE    = "SecureString"
+)
+
+// Options of the export formats that can be tuned with flags
+type exportFormatOptions struct {
+	iniSectionDelimiter string
+	iniNoDefaultSection bool
+	groupByPrefix       bool
+	ssmPrefix           string
+	ssmType             string
+	consulPrefix        string
+	nginxWithValues     bool
+	commentMetadata     bool
+	inferTypes          bool
+	// when set, types are only inferred for these secrets
+	inferKeys []string
+}
+
+// A parameter as accepted by the PutParameter API of AWS SSM Parameter Store
+type ssmParameter struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+	Type  string `json:"Type"`
+}
+
+// An entry of the file read by [consul kv import]
+type consulKVEntry struct {
+	Key   string `json:"key"`
+	Flags int    `json:"flags"`
+	Value string `json:"value"`
+}
+
+// A secret as accepted by the CreateSecret API of AWS Secrets Manager
+type secretsManagerSecret struct {
+	Name         string `json:"Name"`
+	SecretString string `json:"SecretString"`
+}
+
+// Flags set by --for <tool>, flags set explicitly take precedence
+var exportPresets = map[string]map[string]string{
+	"docker":  {"format": FormatDotEnvDocker},
+	"systemd": {"format": FormatSystemdUnit},
+	"consul":  {"format": FormatConsulJson},
+	"aws-ssm": {"format": FormatSSM},
+}
+
 // exportCmd represents the export command
 var exportCmd = &cobra.Command{
 	Use:                   "export",
@@ -36,6 +127,20 @@ var exportCmd = &cobra.Command{
 		// util.RequireLocalWorkspaceFile()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		preset, err := cmd.Flags().GetString("for")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		// the flags applied by the preset are not marked as changed, so that conflicts name --for instead of flags that were never given
+		var presetFlags map[string]string
+		if preset != "" {
+			presetFlags, err = applyExportPreset(cmd, preset)
+			if err != nil {
+				util.HandleError(err)
+			}
+		}
+
 		environmentName, _ := cmd.Flags().GetString("env")
 		if !cmd.Flags().Changed("env") {
 			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
@@ -49,6 +154,14 @@ var exportCmd = &cobra.Command{
 			util.HandleError(err)
 		}
 
+		expandSource := getExpandSource(cmd)
+
+		decodeEncoding, decodeKeys := getDecodeOptions(cmd)
+
+		fetchHook := getFetchHookOptions(cmd)
+
+		secretPrefix, secretSuffix := getSecretAffixes(cmd)
+
 		projectId, err := cmd.Flags().GetString("projectId")
 		if err != nil {
 			util.HandleError(err)
@@ -69,67 +182,741 @@ var exportCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		onFetchError, err := cmd.Flags().GetString("on-fetch-error")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		err = util.ValidateFetchErrorPolicy(onFetchError)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		tagSlugs, err := cmd.Flags().GetString("tags")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
 
-		secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs, WorkspaceId: projectId})
+		secretsPaths, err := cmd.Flags().GetStringArray("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		allowEmptyPathVariables, err := cmd.Flags().GetBool("allow-empty-path-vars")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		for idx, secretsPath := range secretsPaths {
+			secretsPaths[idx], err = util.ExpandSecretsPathVariables(secretsPath, allowEmptyPathVariables)
+			if err != nil {
+				util.HandleError(err, "Unable to parse flag")
+			}
+		}
+
+		keepGoing, err := cmd.Flags().GetBool("keep-going")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		failOnDuplicateKeys, err := cmd.Flags().GetBool("fail-on-duplicate-keys")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		iniSectionDelimiter, err := cmd.Flags().GetString("ini-section-delimiter")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if iniSectionDelimiter == "" {
+			util.PrintErrorMessageAndExit("--ini-section-delimiter cannot be empty")
+		}
+
+		iniNoDefaultSection, err := cmd.Flags().GetBool("ini-no-default-section")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		sortBy, err := cmd.Flags().GetString("sort")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if sortBy != EXPORT_SORT_KEYS && sortBy != EXPORT_SORT_VALUES && sortBy != EXPORT_SORT_NONE {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --sort. Available options are [%s]", sortBy, strings.Join([]string{EXPORT_SORT_KEYS, EXPORT_SORT_VALUES, EXPORT_SORT_NONE}, ", ")))
+		}
+
+		groupByPrefix, err := cmd.Flags().GetBool("group-by-prefix")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		ssmPrefix, err := cmd.Flags().GetString("ssm-prefix")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		ssmType, err := cmd.Flags().GetString("ssm-type")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if ssmType != "SecureString" && ssmType != "String" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --ssm-type. Available options are [SecureString, String]", ssmType))
+		}
+
+		inferTypes, err := cmd.Flags().GetBool("infer-types")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		inferKeys, err := cmd.Flags().GetStringSlice("infer-keys")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if len(inferKeys) > 0 && !inferTypes {
+			util.PrintErrorMessageAndExit("--infer-keys can only be used together with --infer-types")
+		}
+
+		if inferTypes && ((strings.ToLower(format) != FormatJson && strings.ToLower(format) != FormatYaml) || groupByPrefix) {
+			util.PrintErrorMessageAndExit("--infer-types is only supported by the json and yaml formats, without --group-by-prefix")
+		}
+
+		consulPrefix, err := cmd.Flags().GetString("consul-prefix")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		nginxWithValues, err := cmd.Flags().GetBool("nginx-with-values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if nginxWithValues && strings.ToLower(format) != FormatNginx {
+			util.PrintErrorMessageAndExit("--nginx-with-values can only be used together with --format=nginx")
+		}
+
+		injectIntoFile, err := cmd.Flags().GetString("inject-into-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		rawInjectPaths, err := cmd.Flags().GetStringArray("set-path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		injectPaths, err := util.ParseInjectPaths(rawInjectPaths)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if injectIntoFile != "" && len(injectPaths) == 0 {
+			util.PrintErrorMessageAndExit("--inject-into-file requires at least one --set-path, for example --set-path '$.db.password=DB_PASSWORD'")
+		}
+
+		if injectIntoFile == "" && len(injectPaths) > 0 {
+			util.PrintErrorMessageAndExit("--set-path can only be used together with --inject-into-file")
+		}
+
+		outputTemplate, err := cmd.Flags().GetString("output-template")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if outputTemplate != "" && presetFlags["format"] != "" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--output-template cannot be used together with --for %s, which sets the format", preset))
+		}
+
+		if outputTemplate != "" && (cmd.Flags().Changed("format") || groupByPrefix || injectIntoFile != "") {
+			util.PrintErrorMessageAndExit("--output-template cannot be used together with --format, --group-by-prefix or --inject-into-file")
+		}
+
+		onSecretMissing, err := cmd.Flags().GetString("on-secret-missing")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if onSecretMissing != MISSING_SECRET_SKIP && onSecretMissing != MISSING_SECRET_EMPTY && onSecretMissing != MISSING_SECRET_FAIL {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --on-secret-missing. Available options are [%s]", onSecretMissing, strings.Join([]string{MISSING_SECRET_SKIP, MISSING_SECRET_EMPTY, MISSING_SECRET_FAIL}, ", ")))
+		}
+
+		if cmd.Flags().Changed("on-secret-missing") && outputTemplate == "" {
+			util.PrintErrorMessageAndExit("--on-secret-missing can only be used together with --output-template")
+		}
+
+		shouldEncodeBase64, err := cmd.Flags().GetBool("base64")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldEncodeBase64Url, err := cmd.Flags().GetBool("base64-url")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldEncodeBase64 && shouldEncodeBase64Url {
+			util.PrintErrorMessageAndExit("--base64 and --base64-url cannot be used together")
+		}
+
+		if injectIntoFile != "" && (shouldEncodeBase64 || shouldEncodeBase64Url) {
+			util.PrintErrorMessageAndExit("--base64 and --base64-url cannot be used together with --inject-into-file")
+		}
+
+		lineEndings, err := cmd.Flags().GetString("line-endings")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if lineEndings != LINE_ENDINGS_LF && lineEndings != LINE_ENDINGS_CRLF && lineEndings != LINE_ENDINGS_PLATFORM {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --line-endings. Available options are [%s, %s, %s]", lineEndings, LINE_ENDINGS_LF, LINE_ENDINGS_CRLF, LINE_ENDINGS_PLATFORM))
+		}
+
+		// the injected file keeps the line endings it was written with
+		shouldConvertLineEndings := cmd.Flags().Changed("line-endings")
+		if shouldConvertLineEndings && injectIntoFile != "" {
+			util.PrintErrorMessageAndExit("--line-endings cannot be used together with --inject-into-file")
+		}
+
+		shouldEncrypt, err := cmd.Flags().GetBool("encrypt")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		recipientKeyFiles, err := cmd.Flags().GetStringArray("recipient")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldEncrypt != (len(recipientKeyFiles) != 0) {
+			util.PrintErrorMessageAndExit("--encrypt requires at least one --recipient, and --recipient can only be used together with --encrypt")
+		}
+
+		if shouldEncrypt && injectIntoFile != "" {
+			util.PrintErrorMessageAndExit("--encrypt cannot be used together with --inject-into-file")
+		}
+
+		// the keys are read before fetching so that a wrong recipient never leaves the secrets unencrypted
+		var recipients openpgp.EntityList
+		for _, recipientKeyFile := range recipientKeyFiles {
+			if strings.HasPrefix(recipientKeyFile, "age1") {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("age recipients such as [%s] are not supported, --recipient takes the path of an OpenPGP public key file", recipientKeyFile))
+			}
+
+			keys, err := crypto.ReadOpenPGPPublicKeys(recipientKeyFile)
+			if err != nil {
+				util.HandleError(err, "Unable to read --recipient")
+			}
+			recipients = append(recipients, keys...)
+		}
+
+		shouldIncludeMetadata, err := cmd.Flags().GetBool("include-metadata")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldIncludeMetadata && (strings.ToLower(format) != FormatJson || outputTemplate != "" || injectIntoFile != "") {
+			util.PrintErrorMessageAndExit("--include-metadata can only be used with --format=json, and not together with --output-template or --inject-into-file")
+		}
+
+		shouldParseCommentMetadata, err := cmd.Flags().GetBool("secret-comment-as-metadata")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldParseCommentMetadata && (strings.ToLower(format) != FormatJson || inferTypes || outputTemplate != "" || injectIntoFile != "") {
+			util.PrintErrorMessageAndExit("--secret-comment-as-metadata can only be used with --format=json, and not together with --infer-types, --output-template or --inject-into-file")
+		}
+
+		pathResults, err := util.GetAllEnvironmentVariablesOfPaths(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs, WorkspaceId: projectId, OnFetchError: onFetchError}, secretsPaths, keepGoing)
 		if err != nil {
 			util.HandleError(err, "Unable to fetch secrets")
 		}
 
+		if failOnDuplicateKeys {
+			if duplicateKeys := util.FindDuplicateKeysOfPaths(pathResults); len(duplicateKeys) != 0 {
+				util.PrintErrorMessageAndExit(formatDuplicateKeys(duplicateKeys))
+			}
+		}
+
+		fetchedAt := time.Now().UTC()
+		secrets := util.MergeSecretsOfPaths(pathResults)
+
 		if secretOverriding {
 			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
 		} else {
 			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
 		}
 
-		var output string
 		if shouldExpandSecrets {
-			substitutions := util.SubstituteSecrets(secrets)
-			output, err = formatEnvs(substitutions, format)
+			exitOnReferenceCycle(secrets, expandSource)
+			secrets = util.SubstituteSecretsFromSource(secrets, expandSource, os.LookupEnv)
+		}
+
+		if decodeEncoding != "" {
+			secrets, err = util.DecodeSecretValues(secrets, decodeEncoding, decodeKeys)
+			if err != nil {
+				util.HandleError(err, "Unable to decode your secrets with --decode")
+			}
+		}
+
+		if fetchHook.command != "" {
+			hookSecrets, err := runFetchHook(fetchHook, secrets)
 			if err != nil {
 				util.HandleError(err)
 			}
+
+			secrets = mergeFetchHookSecrets(secrets, hookSecrets, fetchHook.hasPriority)
+		}
+
+		if secretPrefix != "" || secretSuffix != "" {
+			secrets, err = affixSecretNames(secrets, secretPrefix, secretSuffix)
+			if err != nil {
+				util.HandleError(err, "Unable to name your secrets with --secret-prefix and --secret-suffix")
+			}
+		}
+
+		if injectIntoFile != "" {
+			err = util.InjectSecretsIntoFile(injectIntoFile, injectPaths, secrets)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to inject secrets into [%s]", injectIntoFile))
+			}
+
+			util.PrintSuccessMessage(fmt.Sprintf("Injected %d secret(s) into [%s]", len(injectPaths), injectIntoFile))
+			exitIfAnyPathFailed(pathResults)
+			return
+		}
+
+		secrets = sortSecrets(secrets, sortBy)
+
+		var output string
+		if outputTemplate != "" {
+			output, err = renderOutputTemplate(secrets, outputTemplate, onSecretMissing)
 		} else {
-			output, err = formatEnvs(secrets, format)
+			output, err = formatEnvs(secrets, format, exportFormatOptions{iniSectionDelimiter: iniSectionDelimiter, iniNoDefaultSection: iniNoDefaultSection, groupByPrefix: groupByPrefix, ssmPrefix: ssmPrefix, ssmType: ssmType, consulPrefix: consulPrefix, nginxWithValues: nginxWithValues, commentMetadata: shouldParseCommentMetadata, inferTypes: inferTypes, inferKeys: inferKeys})
+		}
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		if shouldIncludeMetadata {
+			output, err = wrapInMetadataEnvelope(output, exportMetadata{ProjectId: getExportProjectId(projectId, infisicalToken), Environment: environmentName, Paths: getFetchedPaths(pathResults), FetchedAt: fetchedAt})
 			if err != nil {
 				util.HandleError(err)
 			}
 		}
 
+		if shouldConvertLineEndings {
+			output = convertLineEndings(output, lineEndings, runtime.GOOS)
+		}
+
+		if shouldEncodeBase64 {
+			output = encodeExportOutput(output, base64.StdEncoding)
+		} else if shouldEncodeBase64Url {
+			output = encodeExportOutput(output, base64.URLEncoding)
+		}
+
+		if shouldEncrypt {
+			output, err = crypto.EncryptForOpenPGPRecipients([]byte(output), recipients)
+			if err != nil {
+				util.HandleError(err, "Unable to encrypt your secrets with --encrypt")
+			}
+		}
+
 		fmt.Print(output)
+
+		exitIfAnyPathFailed(pathResults)
 	},
 }
 
+// Where the secrets of --include-metadata come from. Never holds the token or any other credential
+type exportMetadata struct {
+	ProjectId   string    `json:"project,omitempty"`
+	Environment string    `json:"environment"`
+	Paths       []string  `json:"paths"`
+	FetchedAt   time.Time `json:"fetchedAt"`
+}
+
+// Wraps the json output of the secrets, as it would be printed without --include-metadata, next to their metadata
+func wrapInMetadataEnvelope(secretsJson string, metadata exportMetadata) (string, error) {
+	envelope := struct {
+		exportMetadata
+		Secrets json.RawMessage `json:"secrets"`
+	}{exportMetadata: metadata, Secrets: json.RawMessage(secretsJson)}
+
+	output, err := json.Marshal(envelope)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal the secrets and their metadata to JSON [err=%v]", err)
+	}
+	return string(output), nil
+}
+
+// The project is only known without a service token, which belongs to a project of its own, from --projectId or the
+// project file. Empty when it is not known
+func getExportProjectId(projectId string, infisicalToken string) string {
+	if projectId != "" {
+		return projectId
+	}
+
+	if infisicalToken != "" || os.Getenv(util.INFISICAL_TOKEN_NAME) != "" {
+		return ""
+	}
+
+	if storedServiceToken, _, err := util.GetStoredServiceToken(); err != nil || storedServiceToken != "" {
+		return ""
+	}
+
+	workspaceFile, err := util.GetWorkSpaceFromFile()
+	if err != nil {
+		return ""
+	}
+	return workspaceFile.WorkspaceId
+}
+
+// The paths whose secrets were exported, leaving out the ones that failed with --keep-going
+func getFetchedPaths(pathResults []util.PathFetchResult) []string {
+	paths := []string{}
+	for _, result := range pathResults {
+		if result.Err == nil {
+			paths = append(paths, result.Path)
+		}
+	}
+	return paths
+}
+
+// Functions available in --output-template in addition to the builtin ones of text/template
+var outputTemplateFuncs = template.FuncMap{
+	"upper":  strings.ToUpper,
+	"lower":  strings.ToLower,
+	"b64enc": func(value string) string { return base64.StdEncoding.EncodeToString([]byte(value)) },
+	// double quoted with Go escapes, like the quote function of helm
+	"quote": strconv.Quote,
+}
+
+// formatDuplicateKeys describes the secrets found in several folders, with the folders they were found in
+func formatDuplicateKeys(duplicateKeys []util.DuplicateKey) string {
+	lines := []string{fmt.Sprintf("%d secret(s) found in more than one path:", len(duplicateKeys))}
+	for _, duplicateKey := range duplicateKeys {
+		lines = append(lines, fmt.Sprintf("  - %s [%s]", duplicateKey.Key, strings.Join(duplicateKey.Paths, ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Renders the secrets with a text/template. The data is a map of secret names to values, which range iterates in key order.
+// onSecretMissing decides whether referencing a secret that does not exist fails, renders nothing or leaves the placeholder as is
+func renderOutputTemplate(envs []models.SingleEnvironmentVariable, templateText string, onSecretMissing string) (string, error) {
+	missingKeyOption := "missingkey=error"
+	if onSecretMissing != MISSING_SECRET_FAIL {
+		missingKeyOption = "missingkey=zero"
+	}
+
+	outputTemplate, err := template.New("output").Funcs(outputTemplateFuncs).Option(missingKeyOption).Parse(templateText)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse --output-template [err=%v]", err)
+	}
+
+	secrets := make(map[string]string)
+	for _, env := range envs {
+		secrets[env.Key] = env.Value
+	}
+
+	if onSecretMissing == MISSING_SECRET_SKIP {
+		for _, definedTemplate := range outputTemplate.Templates() {
+			if definedTemplate.Tree != nil {
+				leaveMissingSecretPlaceholders(definedTemplate.Tree.Root, secrets)
+			}
+		}
+	}
+
+	var output strings.Builder
+	if err := outputTemplate.Execute(&output, secrets); err != nil {
+		return "", fmt.Errorf("unable to render --output-template [err=%v]", err)
+	}
+
+	return output.String(), nil
+}
+
+// Replaces the actions referencing a secret that does not exist, such as {{ .MISSING | upper }}, with their own text
+func leaveMissingSecretPlaceholders(node parse.Node, secrets map[string]string) {
+	switch typedNode := node.(type) {
+	case *parse.ListNode:
+		if typedNode == nil {
+			return
+		}
+		for i, child := range typedNode.Nodes {
+			if action, ok := child.(*parse.ActionNode); ok && referencesMissingSecret(action.Pipe, secrets) {
+				typedNode.Nodes[i] = &parse.TextNode{NodeType: parse.NodeText, Pos: action.Pos, Text: []byte(action.String())}
+				continue
+			}
+			leaveMissingSecretPlaceholders(child, secrets)
+		}
+	case *parse.IfNode:
+		leaveMissingSecretPlaceholders(typedNode.List, secrets)
+		leaveMissingSecretPlaceholders(typedNode.ElseList, secrets)
+	case *parse.RangeNode:
+		leaveMissingSecretPlaceholders(typedNode.List, secrets)
+		leaveMissingSecretPlaceholders(typedNode.ElseList, secrets)
+	case *parse.WithNode:
+		leaveMissingSecretPlaceholders(typedNode.List, secrets)
+		leaveMissingSecretPlaceholders(typedNode.ElseList, secrets)
+	}
+}
+
+func referencesMissingSecret(pipe *parse.PipeNode, secrets map[string]string) bool {
+	if pipe == nil {
+		return false
+	}
+
+	for _, command := range pipe.Cmds {
+		for _, arg := range command.Args {
+			switch typedArg := arg.(type) {
+			case *parse.FieldNode:
+				if _, found := secrets[typedArg.Ident[0]]; !found {
+					return true
+				}
+			case *parse.PipeNode:
+				if referencesMissingSecret(typedArg, secrets) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Every format writes lf line endings, so the output only has to be converted when another one is asked for. Line
+// breaks inside multi-line values are converted too, the parsers reading the output cannot tell them apart
+func convertLineEndings(output string, lineEndings string, goos string) string {
+	if lineEndings == LINE_ENDINGS_PLATFORM {
+		lineEndings = LINE_ENDINGS_LF
+		if goos == "windows" {
+			lineEndings = LINE_ENDINGS_CRLF
+		}
+	}
+
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	if lineEndings == LINE_ENDINGS_CRLF {
+		output = strings.ReplaceAll(output, "\n", "\r\n")
+	}
+	return output
+}
+
+// Encodes the whole output as a single line so that it fits into a single CI secret or cloud-init field
+func encodeExportOutput(output string, encoding *base64.Encoding) string {
+	return encoding.EncodeToString([]byte(output)) + "\n"
+}
+
+// Set the flags of a --for preset that were not set explicitly and returns the values it applied. The flags are left unchanged
+// as far as cobra is concerned, so Changed keeps reporting only what was given on the command line
+func applyExportPreset(cmd *cobra.Command, preset string) (map[string]string, error) {
+	presetFlags, ok := exportPresets[strings.ToLower(preset)]
+	if !ok {
+		return nil, fmt.Errorf("invalid value [%s] for --for. Available options are [%s]", preset, strings.Join(getExportPresetNames(), ", "))
+	}
+
+	appliedFlags := map[string]string{}
+	for flagName, value := range presetFlags {
+		if cmd.Flags().Changed(flagName) {
+			continue
+		}
+
+		if err := cmd.Flags().Lookup(flagName).Value.Set(value); err != nil {
+			return nil, fmt.Errorf("unable to apply the %s preset to --%s [err=%v]", preset, flagName, err)
+		}
+		appliedFlags[flagName] = value
+	}
+
+	return appliedFlags, nil
+}
+
+func getExportPresetNames() []string {
+	names := []string{}
+	for name := range exportPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// With --keep-going the secrets of the paths that could be fetched are still exported, the failed ones are reported at the end
+func exitIfAnyPathFailed(pathResults []util.PathFetchResult) {
+	if util.PrintPathFetchErrors(pathResults) > 0 {
+		os.Exit(1)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(exportCmd)
 	exportCmd.Flags().StringP("env", "e", "dev", "Set the environment (dev, prod, etc.) from which your secrets should be pulled from")
 	exportCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets")
-	exportCmd.Flags().StringP("format", "f", "dotenv", "Set the format of the output file (dotenv, json, csv)")
+	exportCmd.Flags().String("expand-source", util.EXPAND_SOURCE_SECRET, "where the ${KEY} references of secrets are resolved from (secret, env, both). both looks up the secret of that name first and the environment variable otherwise")
+	exportCmd.Flags().Bool("expand-from-env", false, "resolve the ${KEY} references that are not secrets from the environment, same as --expand-source=both")
+	exportCmd.Flags().StringP("format", "f", "dotenv", "Set the format of the output file (dotenv, dotenv-export, dotenv-docker, json, csv, yaml, ini, ssm, secretsmanager, systemd-unit, consul, consul-json, nginx, flyio, powershell)")
+	exportCmd.Flags().String("for", "", "export for a tool (docker, systemd, consul, aws-ssm) using the format and flags it expects. Flags set explicitly override the preset")
+	exportCmd.Flags().String("ini-section-delimiter", DEFAULT_INI_SECTION_DELIMITER, "delimiter that splits secret names into a section and a key when using the ini format")
+	exportCmd.Flags().Bool("ini-no-default-section", false, "fail instead of writing secrets without a section to ["+DEFAULT_INI_SECTION_NAME+"] when using the ini format")
+	exportCmd.Flags().String("ssm-prefix", "", "prefix added to the secret names when using the ssm or secretsmanager format (e.g. /my-app/prod/)")
+	exportCmd.Flags().String("ssm-type", DEFAULT_SSM_PARAMETER_TYPE, "type of the parameters when using the ssm format (SecureString, String)")
+	exportCmd.Flags().Bool("infer-types", false, "write values looking like integers, floats, booleans or null as such instead of strings when using the json or yaml format. Beware that values such as 1.0 or true are meant as strings by some secrets")
+	exportCmd.Flags().StringSlice("infer-keys", []string{}, "only infer the types of these secrets (e.g. PORT,DEBUG), requires --infer-types")
+	exportCmd.Flags().Bool("nginx-with-values", false, "write set $KEY \"value\"; directives for server and location blocks instead of env KEY; directives when using the nginx format")
+	exportCmd.Flags().String("consul-prefix", "", "path prepended to the secret names when using the consul or consul-json format (e.g. config/my-app)")
+	exportCmd.Flags().String("sort", EXPORT_SORT_KEYS, "order of the exported secrets (keys, values, none). none keeps the order returned by Infisical")
+	exportCmd.Flags().Bool("group-by-prefix", false, "group secrets sharing a prefix (e.g. DB_) under a comment header when using the dotenv, dotenv-export, dotenv-docker or yaml format")
+	exportCmd.Flags().String("decode", "", "decode the values of the secrets set by --decode-keys before they are exported (base64), e.g. for binary certificates stored as text")
+	exportCmd.Flags().StringSlice("decode-keys", []string{}, "names of the secrets decoded by --decode, comma separated or repeated. * decodes every secret")
+	exportCmd.Flags().String("fetch-hook", "", "a command whose output is parsed as secrets and added to the fetched secrets before they are exported")
+	exportCmd.Flags().String("fetch-hook-format", util.SECRETS_INPUT_FORMAT_DOTENV, "the format of the output of --fetch-hook (auto, dotenv, json, yaml)")
+	exportCmd.Flags().Bool("hook-priority", false, "let the secrets of --fetch-hook override the fetched secrets of the same name")
+	exportCmd.Flags().Bool("hook-inherit", false, "pass the fetched secrets to --fetch-hook in its environment")
+	exportCmd.Flags().String("secret-prefix", "", "prepended to the names of the exported secrets (e.g. APP_)")
+	exportCmd.Flags().String("secret-suffix", "", "appended to the names of the exported secrets (e.g. _PROD)")
 	exportCmd.Flags().Bool("secret-overriding", true, "Prioritizes personal secrets, if any, with the same name over shared secrets")
 	exportCmd.Flags().String("token", "", "Fetch secrets using the Infisical Token")
+	exportCmd.Flags().StringArray("path", []string{"/"}, "folder to export the secrets of (can be repeated). ${VAR} is replaced with the environment variable VAR. Secrets of later paths override secrets of the same name of earlier ones")
+	exportCmd.Flags().Bool("allow-empty-path-vars", false, "remove the ${VAR} references of --path to environment variables that are not set or empty instead of failing")
+	exportCmd.Flags().String("output-template", "", "render the secrets with this Go template instead of a --format, e.g. '{{ range $k, $v := . }}{{ $k }}={{ quote $v }}{{ \"\\n\" }}{{ end }}'. upper, lower, b64enc and quote are available")
+	exportCmd.Flags().String("on-secret-missing", MISSING_SECRET_FAIL, "what --output-template renders for a secret that does not exist (skip, empty, fail). skip leaves the placeholder as is")
+	exportCmd.Flags().Bool("base64", false, "base64 encode the whole output, whatever the format, as a single line")
+	exportCmd.Flags().Bool("base64-url", false, "same as --base64 but with the URL and file name safe alphabet")
+	exportCmd.Flags().String("line-endings", LINE_ENDINGS_LF, "line endings of the output (lf, crlf, platform), whatever the OS it is written on. platform is crlf on Windows and lf everywhere else")
+	exportCmd.Flags().Bool("encrypt", false, "encrypt the output for the --recipient public keys as an armored OpenPGP message, to be decrypted with gpg --decrypt")
+	exportCmd.Flags().StringArray("recipient", []string{}, "path of an OpenPGP public key file (armored or binary) the output of --encrypt is encrypted for. Can be repeated, any recipient can decrypt it")
+	exportCmd.Flags().Bool("secret-comment-as-metadata", false, "add the key:value directives found in the comment of each secret (e.g. rotate:30d type:json) under a meta field of the json output")
+	exportCmd.Flags().Bool("include-metadata", false, "wrap the json output in an object holding the project, environment, paths and time of the fetch next to the secrets")
+	exportCmd.Flags().Bool("fail-on-duplicate-keys", false, "with several --path, fail instead of letting later paths override secrets of the same name of earlier ones, listing every such secret and its paths")
+	exportCmd.Flags().Bool("keep-going", false, "with several --path, export the secrets of the paths that could be fetched and report the failed ones instead of stopping at the first failure. Still exits non-zero if any path failed")
 	exportCmd.Flags().StringP("tags", "t", "", "filter secrets by tag slugs")
+	exportCmd.Flags().String("on-fetch-error", util.FETCH_ERROR_POLICY_FAIL, "what to do when secrets cannot be fetched (fail, warn, use-cache). use-cache falls back to the secrets of the last successful fetch")
 	exportCmd.Flags().String("projectId", "", "manually set the projectId to fetch secrets from")
+	exportCmd.Flags().String("inject-into-file", "", "Replace fields of a JSON or YAML file with secret values in place instead of printing them")
+	exportCmd.Flags().StringArray("set-path", []string{}, "Field to replace when using --inject-into-file, in the format $.path.to.field=SECRET_NAME. Can be repeated")
 }
 
 // Format according to the format flag
-func formatEnvs(envs []models.SingleEnvironmentVariable, format string) (string, error) {
+func formatEnvs(envs []models.SingleEnvironmentVariable, format string, options exportFormatOptions) (string, error) {
+	if options.groupByPrefix {
+		return formatGroupedByPrefix(envs, format)
+	}
+
 	switch strings.ToLower(format) {
 	case FormatDotenv:
 		return formatAsDotEnv(envs), nil
 	case FormatDotEnvExport:
 		return formatAsDotEnvExport(envs), nil
+	case FormatDotEnvDocker:
+		return formatAsDotEnvDocker(envs), nil
 	case FormatJson:
+		if options.inferTypes {
+			return formatAsJsonWithInferredTypes(envs, options.inferKeys)
+		}
+		if options.commentMetadata {
+			return formatAsJsonWithCommentMetadata(envs)
+		}
 		return formatAsJson(envs), nil
 	case FormatCSV:
 		return formatAsCSV(envs), nil
 	case FormatYaml:
+		if options.inferTypes {
+			return formatAsYamlWithInferredTypes(envs, options.inferKeys)
+		}
 		return formatAsYaml(envs), nil
+	case FormatIni:
+		return formatAsIni(envs, options.iniSectionDelimiter, options.iniNoDefaultSection)
+	case FormatSSM:
+		return formatAsSSM(envs, options.ssmPrefix, options.ssmType)
+	case FormatSecretsManager:
+		return formatAsSecretsManager(envs, options.ssmPrefix)
+	case FormatSystemdUnit:
+		return formatAsSystemdUnit(envs)
+	case FormatConsul:
+		return formatAsConsul(envs, options.consulPrefix)
+	case FormatConsulJson:
+		return formatAsConsulJson(envs, options.consulPrefix)
+	case FormatNginx:
+		return formatAsNginx(envs, options.nginxWithValues)
+	case FormatFlyio:
+		return formatAsFlyio(envs), nil
+	case FormatPowershell:
+		return formatAsPowershell(envs), nil
 	default:
-		return "", fmt.Errorf("invalid format type: %s. Available format types are [%s]", format, []string{FormatDotenv, FormatJson, FormatCSV, FormatYaml, FormatDotEnvExport})
+		return "", fmt.Errorf("invalid format type: %s. Available format types are [%s]", format, []string{FormatDotenv, FormatJson, FormatCSV, FormatYaml, FormatDotEnvExport, FormatDotEnvDocker, FormatIni, FormatSSM, FormatSecretsManager, FormatSystemdUnit, FormatConsul, FormatConsulJson, FormatNginx, FormatFlyio, FormatPowershell})
+	}
+}
+
+// Sorts the secrets by key or by value. Ties are broken by key so that the output is the same on every run
+func sortSecrets(envs []models.SingleEnvironmentVariable, sortBy string) []models.SingleEnvironmentVariable {
+	sortedEnvs := append([]models.SingleEnvironmentVariable{}, envs...)
+	switch sortBy {
+	case EXPORT_SORT_KEYS:
+		sort.SliceStable(sortedEnvs, func(i, j int) bool {
+			return sortedEnvs[i].Key < sortedEnvs[j].Key
+		})
+	case EXPORT_SORT_VALUES:
+		sort.SliceStable(sortedEnvs, func(i, j int) bool {
+			if sortedEnvs[i].Value == sortedEnvs[j].Value {
+				return sortedEnvs[i].Key < sortedEnvs[j].Key
+			}
+			return sortedEnvs[i].Value < sortedEnvs[j].Value
+		})
+	}
+
+	return sortedEnvs
+}
+
+// The prefix of a secret is everything up to and including its first underscore, DB_HOST and DB_PORT share the prefix DB_
+func getSecretPrefix(key string) string {
+	if idx := strings.Index(key, "_"); idx > 0 {
+		return key[:idx+1]
+	}
+	return ""
+}
+
+// Formats every group of secrets sharing a prefix on its own, below a "# PREFIX" comment. Groups are ordered by prefix and keep the order of their secrets.
+// Secrets without a prefix come first, without a header
+func formatGroupedByPrefix(envs []models.SingleEnvironmentVariable, format string) (string, error) {
+	var formatGroup func(envs []models.SingleEnvironmentVariable) string
+	switch strings.ToLower(format) {
+	case FormatDotenv:
+		formatGroup = formatAsDotEnv
+	case FormatDotEnvExport:
+		formatGroup = formatAsDotEnvExport
+	case FormatDotEnvDocker:
+		formatGroup = formatAsDotEnvDocker
+	case FormatYaml:
+		formatGroup = formatAsYaml
+	default:
+		return "", fmt.Errorf("--group-by-prefix is not supported by the %s format. Supported formats are [%s]", format, []string{FormatDotenv, FormatDotEnvExport, FormatDotEnvDocker, FormatYaml})
+	}
+
+	prefixes := []string{}
+	groups := make(map[string][]models.SingleEnvironmentVariable)
+	for _, env := range envs {
+		prefix := getSecretPrefix(env.Key)
+		if _, ok := groups[prefix]; !ok {
+			prefixes = append(prefixes, prefix)
+		}
+		groups[prefix] = append(groups[prefix], env)
+	}
+
+	sort.Strings(prefixes)
+
+	output := &strings.Builder{}
+	for _, prefix := range prefixes {
+		if prefix != "" {
+			if output.Len() > 0 {
+				output.WriteString("\n")
+			}
+			fmt.Fprintf(output, "# %s\n", prefix)
+		}
+		output.WriteString(formatGroup(groups[prefix]))
 	}
+
+	return output.String(), nil
 }
 
 // Format environment variables as a CSV file
@@ -162,6 +949,201 @@ func formatAsDotEnvExport(envs []models.SingleEnvironmentVariable) string {
 	return dotenv
 }
 
+// Format environment variables as an env file for docker's --env-file flag.
+// Docker takes everything after the first = literally (no quote processing), so values are written as is.
+// Docker has no way to represent multi-line values, so those secrets are skipped
+func formatAsDotEnvDocker(envs []models.SingleEnvironmentVariable) string {
+	var dotenv string
+	for _, env := range envs {
+		if strings.ContainsAny(env.Value, "\r\n") {
+			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been skipped because docker env files do not support multi-line values", env.Key))
+			continue
+		}
+
+		if strings.ContainsAny(env.Key, " \t") {
+			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been skipped because docker env files do not support whitespace in variable names", env.Key))
+			continue
+		}
+
+		dotenv += fmt.Sprintf("%s=%s\n", env.Key, env.Value)
+	}
+	return dotenv
+}
+
+// Format environment variables for [fly secrets import], which reads one KEY=value pair per line and takes the value as is.
+// Multi-line values and values starting with """, which fly reads as the start of a multi-line value, are skipped
+func formatAsFlyio(envs []models.SingleEnvironmentVariable) string {
+	var secrets string
+	for _, env := range envs {
+		if strings.ContainsAny(env.Value, "\r\n") || strings.HasPrefix(env.Value, `"""`) {
+			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been skipped because fly secrets import does not support multi-line values", env.Key))
+			continue
+		}
+
+		secrets += fmt.Sprintf("%s=%s\n", env.Key, env.Value)
+	}
+	return secrets
+}
+
+// Format environment variables as $env:KEY = 'value' lines to be passed to Invoke-Expression by PowerShell.
+// Every line is a statement on its own, since Invoke-Expression runs piped lines one by one, so line breaks in values are
+// written as "`n" and "`r" joined to the single quoted parts. Secrets that are not valid variable names are skipped
+func formatAsPowershell(envs []models.SingleEnvironmentVariable) string {
+	var secrets string
+	for _, env := range envs {
+		if !shellVariableNameRegex.MatchString(env.Key) {
+			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been skipped because it is not a valid PowerShell environment variable name", env.Key))
+			continue
+		}
+
+		secrets += fmt.Sprintf("$env:%s = %s\n", env.Key, quoteForPowershell(env.Value))
+	}
+	return secrets
+}
+
+// Single quoted strings are literal in PowerShell, so quotes are the only characters to escape, by doubling them.
+// PowerShell also ends single quoted strings with the typographic single quotes, so those are doubled too
+func quoteForPowershell(value string) string {
+	parts := []string{}
+	var quoted strings.Builder
+	flushQuoted := func() {
+		if quoted.Len() != 0 {
+			parts = append(parts, "'"+quoted.String()+"'")
+			quoted.Reset()
+		}
+	}
+
+	for _, character := range value {
+		switch character {
+		case '\n':
+			flushQuoted()
+			parts = append(parts, "\"`n\"")
+		case '\r':
+			flushQuoted()
+			parts = append(parts, "\"`r\"")
+		case '\'', '\u2018', '\u2019', '\u201A', '\u201B':
+			quoted.WriteRune(character)
+			quoted.WriteRune(character)
+		default:
+			quoted.WriteRune(character)
+		}
+	}
+	flushQuoted()
+
+	if len(parts) == 0 {
+		return "''"
+	}
+	return strings.Join(parts, " + ")
+}
+
+// Format environment variables as an INI file. Secret names are split on the first delimiter into a section and a key (DB__HOST becomes HOST in [DB]).
+// Values that would not survive as is are double quoted with the escapes used by git config (\\, \", \n, \r, \t)
+func formatAsIni(envs []models.SingleEnvironmentVariable, sectionDelimiter string, noDefaultSection bool) (string, error) {
+	sectionNames := []string{}
+	sections := make(map[string][]models.SingleEnvironmentVariable)
+	unsectionedKeys := []string{}
+
+	for _, env := range envs {
+		sectionName, key := DEFAULT_INI_SECTION_NAME, env.Key
+		if parts := strings.SplitN(env.Key, sectionDelimiter, 2); len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			sectionName, key = parts[0], parts[1]
+		} else {
+			unsectionedKeys = append(unsectionedKeys, env.Key)
+		}
+
+		if strings.ContainsAny(sectionName, "[]\r\n") || strings.ContainsAny(key, "=;#[]\r\n") {
+			return "", fmt.Errorf("the secret [%s] cannot be represented in an ini file", env.Key)
+		}
+
+		if _, ok := sections[sectionName]; !ok {
+			sectionNames = append(sectionNames, sectionName)
+		}
+		sections[sectionName] = append(sections[sectionName], models.SingleEnvironmentVariable{Key: key, Value: env.Value})
+	}
+
+	if noDefaultSection && len(unsectionedKeys) > 0 {
+		return "", fmt.Errorf("the following secrets do not contain the section delimiter [%s]: [%s]", sectionDelimiter, strings.Join(unsectionedKeys, ", "))
+	}
+
+	// the default section is written first as most parsers (such as python's configparser) expect
+	sort.SliceStable(sectionNames, func(i, j int) bool {
+		return sectionNames[i] == DEFAULT_INI_SECTION_NAME && sectionNames[j] != DEFAULT_INI_SECTION_NAME
+	})
+
+	ini := &strings.Builder{}
+	for idx, sectionName := range sectionNames {
+		if idx > 0 {
+			ini.WriteString("\n")
+		}
+		fmt.Fprintf(ini, "[%s]\n", sectionName)
+		for _, env := range sections[sectionName] {
+			fmt.Fprintf(ini, "%s = %s\n", env.Key, escapeIniValue(env.Value))
+		}
+	}
+
+	return ini.String(), nil
+}
+
+func escapeIniValue(value string) string {
+	if value != "" && value == strings.TrimSpace(value) && !strings.ContainsAny(value, "\"\\;#\r\n\t") {
+		return value
+	}
+
+	escaper := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\r", "\\r", "\t", "\\t")
+	return "\"" + escaper.Replace(value) + "\""
+}
+
+// Format environment variables as Environment= directives for the [Service] section of a systemd unit.
+// Following systemd.syntax(7) and systemd.exec(5), values are double quoted with C-style escapes and % is doubled since specifiers are expanded.
+// $ is kept as is because variables are not expanded in Environment=. Line breaks cannot be represented and are rejected
+func formatAsSystemdUnit(envs []models.SingleEnvironmentVariable) (string, error) {
+	escaper := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\t", "\\t", "%", "%%")
+
+	unit := &strings.Builder{}
+	for _, env := range envs {
+		if strings.ContainsAny(env.Key, "=\"\\%$ \t\r\n") || env.Key == "" {
+			return "", fmt.Errorf("the secret [%s] is not a valid systemd environment variable name", env.Key)
+		}
+
+		if strings.ContainsAny(env.Value, "\r\n") {
+			return "", fmt.Errorf("the secret [%s] cannot be written as a systemd Environment= directive because its value contains a line break", env.Key)
+		}
+
+		fmt.Fprintf(unit, "Environment=\"%s=%s\"\n", env.Key, escaper.Replace(env.Value))
+	}
+
+	return unit.String(), nil
+}
+
+// Format environment variables as env directives of the main nginx config, which pass the variables of the nginx process to its workers.
+// With values, set directives for server and location blocks are written instead, quoted with the escapes understood by nginx
+func formatAsNginx(envs []models.SingleEnvironmentVariable, withValues bool) (string, error) {
+	escaper := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\r", "\\r", "\t", "\\t")
+
+	config := &strings.Builder{}
+	for _, env := range envs {
+		if env.Key == "" || strings.IndexFunc(env.Key, func(r rune) bool {
+			return !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'))
+		}) != -1 {
+			return "", fmt.Errorf("the secret [%s] is not a valid nginx variable name, only letters, digits and _ are allowed", env.Key)
+		}
+
+		if !withValues {
+			fmt.Fprintf(config, "env %s;\n", env.Key)
+			continue
+		}
+
+		// nginx expands variables in set values and has no escape for $
+		if strings.Contains(env.Value, "$") {
+			return "", fmt.Errorf("the value of secret [%s] contains a $ which nginx would expand as a variable", env.Key)
+		}
+
+		fmt.Fprintf(config, "set $%s \"%s\";\n", env.Key, escaper.Replace(env.Value))
+	}
+
+	return config.String(), nil
+}
+
 func formatAsYaml(envs []models.SingleEnvironmentVariable) string {
 	var dotenv string
 	for _, env := range envs {
@@ -170,6 +1152,185 @@ func formatAsYaml(envs []models.SingleEnvironmentVariable) string {
 	return dotenv
 }
 
+var (
+	// leading zeros are not allowed so that values such as zip codes (01234) stay strings
+	inferredIntegerPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+	inferredFloatPattern   = regexp.MustCompile(`^-?(0|[1-9][0-9]*)\.[0-9]+([eE][-+]?[0-9]+)?$`)
+)
+
+// Returns the YAML tag of the type the value looks like: !!int, !!float, !!bool or !!null. Anything ambiguous,
+// such as True, yes, 1e5 or 0123, is a !!str. Types are only inferred for inferKeys when it is not empty
+func inferValueType(key string, value string, inferKeys []string) string {
+	if len(inferKeys) > 0 {
+		isInferred := false
+		for _, inferKey := range inferKeys {
+			isInferred = isInferred || inferKey == key
+		}
+
+		if !isInferred {
+			return "!!str"
+		}
+	}
+
+	switch {
+	case value == "true" || value == "false":
+		return "!!bool"
+	case value == "null":
+		return "!!null"
+	case inferredIntegerPattern.MatchString(value):
+		// integers that do not fit into 64 bits would lose precision in most JSON parsers
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return "!!int"
+		}
+	case inferredFloatPattern.MatchString(value):
+		return "!!float"
+	}
+
+	return "!!str"
+}
+
+// Same as formatAsJson but values looking like numbers, booleans or null are written as such instead of strings
+func formatAsJsonWithInferredTypes(envs []models.SingleEnvironmentVariable, inferKeys []string) (string, error) {
+	type inferredEnvironmentVariable struct {
+		models.SingleEnvironmentVariable
+		Value interface{} `json:"value"`
+	}
+
+	inferredEnvs := []inferredEnvironmentVariable{}
+	for _, env := range envs {
+		var value interface{}
+		switch inferValueType(env.Key, env.Value, inferKeys) {
+		case "!!bool":
+			value = env.Value == "true"
+		case "!!null":
+			value = nil
+		case "!!int", "!!float":
+			// written as is so that 1.50 is not turned into 1.5
+			value = json.Number(env.Value)
+		default:
+			value = env.Value
+		}
+		inferredEnvs = append(inferredEnvs, inferredEnvironmentVariable{SingleEnvironmentVariable: env, Value: value})
+	}
+
+	output, err := json.Marshal(inferredEnvs)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal environment variables to JSON [err=%v]", err)
+	}
+	return string(output), nil
+}
+
+// Same as formatAsYaml but only values looking like numbers, booleans or null are written as such. All other values are
+// double quoted, since YAML 1.1 parsers would otherwise read values such as yes or on as booleans
+func formatAsYamlWithInferredTypes(envs []models.SingleEnvironmentVariable, inferKeys []string) (string, error) {
+	document := &yaml.Node{Kind: yaml.MappingNode}
+	for _, env := range envs {
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: inferValueType(env.Key, env.Value, inferKeys), Value: env.Value}
+		if value.Tag == "!!str" {
+			value.Style = yaml.DoubleQuotedStyle
+		}
+
+		document.Content = append(document.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: env.Key}, value)
+	}
+
+	output, err := yaml.Marshal(document)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal environment variables to YAML [err=%v]", err)
+	}
+	return string(output), nil
+}
+
+// Hierarchical prefixes such as /my-app/prod are separated from the secret name with a slash, other prefixes are used as is
+func getAWSSecretName(prefix string, key string) string {
+	if strings.HasPrefix(prefix, "/") && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + key
+}
+
+// Format environment variables as a JSON array of SSM Parameter Store parameters
+func formatAsSSM(envs []models.SingleEnvironmentVariable, prefix string, parameterType string) (string, error) {
+	parameters := []ssmParameter{}
+	for _, env := range envs {
+		parameters = append(parameters, ssmParameter{Name: getAWSSecretName(prefix, env.Key), Value: env.Value, Type: parameterType})
+	}
+
+	output, err := json.Marshal(parameters)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal the parameters to JSON [err=%v]", err)
+	}
+	return string(output), nil
+}
+
+// Format environment variables as a JSON array of Secrets Manager secrets
+func formatAsSecretsManager(envs []models.SingleEnvironmentVariable, prefix string) (string, error) {
+	secrets := []secretsManagerSecret{}
+	for _, env := range envs {
+		secrets = append(secrets, secretsManagerSecret{Name: getAWSSecretName(prefix, env.Key), SecretString: env.Value})
+	}
+
+	output, err := json.Marshal(secrets)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal the secrets to JSON [err=%v]", err)
+	}
+	return string(output), nil
+}
+
+// Consul keys are slash separated paths, the prefix is joined with a single slash whichever slashes it starts or ends with
+func getConsulKey(prefix string, key string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+// Format environment variables as "key value" lines, the value being everything after the first space.
+// Line breaks cannot be represented and are rejected, consul-json supports any value
+func formatAsConsul(envs []models.SingleEnvironmentVariable, prefix string) (string, error) {
+	var output strings.Builder
+	for _, env := range envs {
+		if strings.ContainsAny(env.Value, "\r\n") {
+			return "", fmt.Errorf("the value of secret [%s] contains a line break which the consul format cannot represent. Use --format=consul-json instead", env.Key)
+		}
+		output.WriteString(fmt.Sprintf("%s %s\n", getConsulKey(prefix, env.Key), env.Value))
+	}
+	return output.String(), nil
+}
+
+// Format environment variables as the JSON array read by [consul kv import], values are base64 encoded as it expects
+func formatAsConsulJson(envs []models.SingleEnvironmentVariable, prefix string) (string, error) {
+	entries := []consulKVEntry{}
+	for _, env := range envs {
+		entries = append(entries, consulKVEntry{Key: getConsulKey(prefix, env.Key), Flags: 0, Value: base64.StdEncoding.EncodeToString([]byte(env.Value))})
+	}
+
+	output, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal the consul entries to JSON [err=%v]", err)
+	}
+	return string(output), nil
+}
+
+// Format environment variables as a JSON file, with the directives found in the comment of each secret under meta
+func formatAsJsonWithCommentMetadata(envs []models.SingleEnvironmentVariable) (string, error) {
+	type environmentVariableWithMetadata struct {
+		models.SingleEnvironmentVariable
+		Meta map[string]string `json:"meta"`
+	}
+
+	envsWithMetadata := []environmentVariableWithMetadata{}
+	for _, env := range envs {
+		envsWithMetadata = append(envsWithMetadata, environmentVariableWithMetadata{SingleEnvironmentVariable: env, Meta: util.ParseCommentMetadata(env.Comment)})
+	}
+
+	output, err := json.Marshal(envsWithMetadata)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal the secrets to JSON [err=%v]", err)
+	}
+	return string(output), nil
+}
+
 // Format environment variables as a JSON file
 func formatAsJson(envs []models.SingleEnvironmentVariable) string {
 	// Dump as a json array
@@ -0,0 +1,724 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/spf13/cobra"
+)
+
+// parseDockerEnvFile mirrors how docker parses the file given to --env-file (see parseKeyValueFile in docker/cli)
+func parseDockerEnvFile(t *testing.T, content string) map[string]string {
+	parsed := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		data := strings.SplitN(line, "=", 2)
+		variable := strings.TrimLeft(data[0], " \t")
+		if strings.ContainsAny(variable, " \t") {
+			t.Errorf("parseDockerEnvFile: variable [%s] contains whitespaces", variable)
+		}
+
+		if len(data) > 1 {
+			parsed[variable] = data[1]
+		}
+	}
+
+	return parsed
+}
+
+func TestFormatAsDotEnvDocker(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "PLAIN", Value: "value"},
+		{Key: "SINGLE_QUOTED", Value: "'quoted'"},
+		{Key: "DOUBLE_QUOTED", Value: `"quoted"`},
+		{Key: "WITH_EQUALS", Value: "a=b=c"},
+		{Key: "WITH_HASH", Value: "before # after"},
+		{Key: "WITH_SPACES", Value: "  padded  "},
+		{Key: "WITH_EXPANSION", Value: "${HOME} $PATH"},
+		{Key: "EMPTY", Value: ""},
+		{Key: "MULTI_LINE", Value: "line1\nline2"},
+		{Key: "CARRIAGE_RETURN", Value: "line1\rline2"},
+	}
+
+	output := formatAsDotEnvDocker(envs)
+	parsed := parseDockerEnvFile(t, output)
+
+	for _, env := range envs {
+		value, ok := parsed[env.Key]
+		if env.Key == "MULTI_LINE" || env.Key == "CARRIAGE_RETURN" {
+			if ok {
+				t.Errorf("TestFormatAsDotEnvDocker: expected %s to be skipped", env.Key)
+			}
+			continue
+		}
+
+		if !ok {
+			t.Errorf("TestFormatAsDotEnvDocker: expected %s to be present in output [%s]", env.Key, output)
+			continue
+		}
+
+		if value != env.Value {
+			t.Errorf("TestFormatAsDotEnvDocker: expected [%s] for %s but docker would read [%s]", env.Value, env.Key, value)
+		}
+	}
+
+	if strings.Contains(output, "export ") {
+		t.Errorf("TestFormatAsDotEnvDocker: expected no export keyword in output [%s]", output)
+	}
+}
+
+// parseIni reads the ini dialect written by formatAsIni: sections, key = value pairs, ; and # comments and double quoted values with backslash escapes
+func parseIni(t *testing.T, content string) map[string]map[string]string {
+	parsed := map[string]map[string]string{}
+	section := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			parsed[section] = map[string]string{}
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || section == "" {
+			t.Fatalf("parseIni: invalid line [%s]", line)
+		}
+
+		key, rawValue := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if !strings.HasPrefix(rawValue, `"`) {
+			if strings.ContainsAny(rawValue, ";#") {
+				t.Errorf("parseIni: unquoted value [%s] contains a comment character", rawValue)
+			}
+			parsed[section][key] = rawValue
+			continue
+		}
+
+		value := &strings.Builder{}
+		escapes := map[byte]byte{'\\': '\\', '"': '"', 'n': '\n', 'r': '\r', 't': '\t'}
+		closed := false
+		for i := 1; i < len(rawValue); i++ {
+			switch rawValue[i] {
+			case '\\':
+				i++
+				value.WriteByte(escapes[rawValue[i]])
+			case '"':
+				closed = true
+				if i != len(rawValue)-1 {
+					t.Errorf("parseIni: unexpected content after quoted value [%s]", rawValue)
+				}
+			default:
+				value.WriteByte(rawValue[i])
+			}
+		}
+
+		if !closed {
+			t.Errorf("parseIni: unterminated quoted value [%s]", rawValue)
+		}
+		parsed[section][key] = value.String()
+	}
+
+	return parsed
+}
+
+func TestFormatAsIni(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "DB__HOST", Value: "localhost"},
+		{Key: "APP_NAME", Value: "my app"},
+		{Key: "DB__PASSWORD", Value: `p@ss;word#"quoted"\`},
+		{Key: "CACHE__REDIS__URL", Value: "redis://cache:6379"},
+		{Key: "MULTI_LINE", Value: "line1\nline2\ttabbed"},
+		{Key: "PADDED", Value: "  padded  "},
+		{Key: "EMPTY", Value: ""},
+	}
+
+	output, err := formatAsIni(envs, DEFAULT_INI_SECTION_DELIMITER, false)
+	if err != nil {
+		t.Fatalf("TestFormatAsIni: unexpected error [err=%v]", err)
+	}
+
+	if !strings.HasPrefix(output, "["+DEFAULT_INI_SECTION_NAME+"]\n") {
+		t.Errorf("TestFormatAsIni: expected the default section to come first in [%s]", output)
+	}
+
+	parsed := parseIni(t, output)
+	expected := map[string]map[string]string{
+		DEFAULT_INI_SECTION_NAME: {"APP_NAME": "my app", "MULTI_LINE": "line1\nline2\ttabbed", "PADDED": "  padded  ", "EMPTY": ""},
+		"DB":                     {"HOST": "localhost", "PASSWORD": `p@ss;word#"quoted"\`},
+		"CACHE":                  {"REDIS__URL": "redis://cache:6379"},
+	}
+
+	for section, keys := range expected {
+		for key, value := range keys {
+			if parsed[section][key] != value {
+				t.Errorf("TestFormatAsIni: expected [%s] for %s.%s but got [%s]", value, section, key, parsed[section][key])
+			}
+		}
+		if len(parsed[section]) != len(keys) {
+			t.Errorf("TestFormatAsIni: expected %d keys in section %s but got %v", len(keys), section, parsed[section])
+		}
+	}
+
+	if _, err := formatAsIni(envs, DEFAULT_INI_SECTION_DELIMITER, true); err == nil {
+		t.Errorf("TestFormatAsIni: expected an error for unsectioned keys with --ini-no-default-section")
+	}
+
+	output, err = formatAsIni([]models.SingleEnvironmentVariable{{Key: "DB.HOST", Value: "localhost"}}, ".", true)
+	if err != nil || parseIni(t, output)["DB"]["HOST"] != "localhost" {
+		t.Errorf("TestFormatAsIni: expected a custom delimiter to be used, got [%s] [err=%v]", output, err)
+	}
+}
+
+func TestFormatGroupedByPrefix(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "DB_PORT", Value: "5432"},
+		{Key: "APP_NAME", Value: "web"},
+		{Key: "DEBUG", Value: "false"},
+		{Key: "DB_HOST", Value: "localhost"},
+		{Key: "APP_ENV", Value: "prod"},
+	}
+
+	expected := "DEBUG='false'\n\n# APP_\nAPP_ENV='prod'\nAPP_NAME='web'\n\n# DB_\nDB_HOST='localhost'\nDB_PORT='5432'\n"
+
+	for i := 0; i < 10; i++ {
+		output, err := formatEnvs(sortSecrets(envs, EXPORT_SORT_KEYS), FormatDotenv, exportFormatOptions{groupByPrefix: true})
+		if err != nil {
+			t.Fatalf("TestFormatGroupedByPrefix: unexpected error [err=%v]", err)
+		}
+
+		if output != expected {
+			t.Fatalf("TestFormatGroupedByPrefix: expected [%s] but got [%s]", expected, output)
+		}
+	}
+
+	output, _ := formatEnvs(sortSecrets(envs, EXPORT_SORT_NONE), FormatYaml, exportFormatOptions{groupByPrefix: true})
+	expected = "DEBUG: false\n\n# APP_\nAPP_NAME: web\nAPP_ENV: prod\n\n# DB_\nDB_PORT: 5432\nDB_HOST: localhost\n"
+	if output != expected {
+		t.Errorf("TestFormatGroupedByPrefix: expected groups to keep the server order with --sort none, got [%s]", output)
+	}
+
+	if _, err := formatEnvs(envs, FormatJson, exportFormatOptions{groupByPrefix: true}); err == nil {
+		t.Errorf("TestFormatGroupedByPrefix: expected an error for a format without comments")
+	}
+}
+
+func TestSortSecrets(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "B", Value: "1"},
+		{Key: "C", Value: "0"},
+		{Key: "A", Value: "1"},
+	}
+
+	keys := func(envs []models.SingleEnvironmentVariable) string {
+		joinedKeys := ""
+		for _, env := range envs {
+			joinedKeys += env.Key
+		}
+		return joinedKeys
+	}
+
+	if sorted := keys(sortSecrets(envs, EXPORT_SORT_KEYS)); sorted != "ABC" {
+		t.Errorf("TestSortSecrets: expected ABC when sorting by keys, got %s", sorted)
+	}
+
+	if sorted := keys(sortSecrets(envs, EXPORT_SORT_VALUES)); sorted != "CAB" {
+		t.Errorf("TestSortSecrets: expected CAB when sorting by values, got %s", sorted)
+	}
+
+	if sorted := keys(sortSecrets(envs, EXPORT_SORT_NONE)); sorted != "BCA" || keys(envs) != "BCA" {
+		t.Errorf("TestSortSecrets: expected the server order to be kept, got %s", sorted)
+	}
+}
+
+func TestFormatAsSSM(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "DB_PASSWORD", Value: `p@ss"word`},
+		{Key: "API_URL", Value: "https://example.com"},
+	}
+
+	output, err := formatEnvs(envs, FormatSSM, exportFormatOptions{ssmPrefix: "/my-app/prod", ssmType: DEFAULT_SSM_PARAMETER_TYPE})
+	if err != nil {
+		t.Fatalf("TestFormatAsSSM: unexpected error [err=%v]", err)
+	}
+
+	var parameters []map[string]string
+	if err := json.Unmarshal([]byte(output), &parameters); err != nil {
+		t.Fatalf("TestFormatAsSSM: invalid JSON [%s] [err=%v]", output, err)
+	}
+
+	if len(parameters) != 2 || parameters[0]["Name"] != "/my-app/prod/DB_PASSWORD" || parameters[0]["Value"] != `p@ss"word` || parameters[0]["Type"] != "SecureString" {
+		t.Errorf("TestFormatAsSSM: unexpected parameters %v", parameters)
+	}
+
+	output, err = formatEnvs(envs, FormatSecretsManager, exportFormatOptions{ssmPrefix: "my-app-"})
+	if err != nil {
+		t.Fatalf("TestFormatAsSSM: unexpected error [err=%v]", err)
+	}
+
+	var secrets []map[string]string
+	if err := json.Unmarshal([]byte(output), &secrets); err != nil {
+		t.Fatalf("TestFormatAsSSM: invalid JSON [%s] [err=%v]", output, err)
+	}
+
+	if len(secrets) != 2 || secrets[1]["Name"] != "my-app-API_URL" || secrets[1]["SecretString"] != "https://example.com" || len(secrets[1]) != 2 {
+		t.Errorf("TestFormatAsSSM: unexpected secrets %v", secrets)
+	}
+}
+
+// parseSystemdEnvironment reads Environment= lines the way systemd does: the double quoted word is unescaped with
+// C-style escapes (systemd.syntax(7)) and specifiers are resolved, where %% is a literal % (systemd.unit(5))
+func parseSystemdEnvironment(t *testing.T, content string) map[string]string {
+	parsed := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		if !strings.HasPrefix(line, `Environment="`) || !strings.HasSuffix(line, `"`) {
+			t.Fatalf("parseSystemdEnvironment: invalid line [%s]", line)
+		}
+
+		quoted := line[len(`Environment="`) : len(line)-1]
+		word := &strings.Builder{}
+		for i := 0; i < len(quoted); i++ {
+			switch quoted[i] {
+			case '\\':
+				i++
+				word.WriteByte(map[byte]byte{'\\': '\\', '"': '"', 't': '\t'}[quoted[i]])
+			case '"':
+				t.Fatalf("parseSystemdEnvironment: unescaped quote in [%s]", line)
+			case '%':
+				i++
+				if quoted[i] != '%' {
+					t.Fatalf("parseSystemdEnvironment: unexpected specifier %%%c in [%s]", quoted[i], line)
+				}
+				word.WriteByte('%')
+			default:
+				word.WriteByte(quoted[i])
+			}
+		}
+
+		kv := strings.SplitN(word.String(), "=", 2)
+		parsed[kv[0]] = kv[1]
+	}
+
+	return parsed
+}
+
+func TestFormatAsSystemdUnit(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "PLAIN", Value: "value"},
+		{Key: "WITH_SPACES", Value: " two words "},
+		{Key: "QUOTED", Value: `say "hi" and 'bye'`},
+		{Key: "BACKSLASH", Value: `C:\path\n`},
+		{Key: "DOLLAR", Value: "$HOME ${USER} $$"},
+		{Key: "PERCENT", Value: "100% %h"},
+		{Key: "TAB", Value: "a\tb"},
+		{Key: "EQUALS", Value: "a=b"},
+		{Key: "EMPTY", Value: ""},
+	}
+
+	output, err := formatAsSystemdUnit(envs)
+	if err != nil {
+		t.Fatalf("TestFormatAsSystemdUnit: unexpected error [err=%v]", err)
+	}
+
+	parsed := parseSystemdEnvironment(t, output)
+	for _, env := range envs {
+		if parsed[env.Key] != env.Value {
+			t.Errorf("TestFormatAsSystemdUnit: expected [%s] for %s but systemd would read [%s]", env.Value, env.Key, parsed[env.Key])
+		}
+	}
+
+	if !strings.Contains(output, `Environment="PERCENT=100%% %%h"`) {
+		t.Errorf("TestFormatAsSystemdUnit: expected specifiers to be escaped in [%s]", output)
+	}
+
+	if _, err := formatAsSystemdUnit([]models.SingleEnvironmentVariable{{Key: "MULTI_LINE", Value: "line1\nline2"}}); err == nil {
+		t.Errorf("TestFormatAsSystemdUnit: expected multi-line values to be rejected")
+	}
+
+	if _, err := formatAsSystemdUnit([]models.SingleEnvironmentVariable{{Key: "BAD KEY", Value: "value"}}); err == nil {
+		t.Errorf("TestFormatAsSystemdUnit: expected invalid names to be rejected")
+	}
+}
+
+func TestEncodeExportOutput(t *testing.T) {
+	output := "KEY='a?b>c'\n"
+
+	if encoded := encodeExportOutput(output, base64.StdEncoding); encoded != "S0VZPSdhP2I+YycK\n" {
+		t.Errorf("TestEncodeExportOutput: unexpected standard encoding [%s]", encoded)
+	}
+
+	if encoded := encodeExportOutput(output, base64.URLEncoding); encoded != "S0VZPSdhP2I-YycK\n" {
+		t.Errorf("TestEncodeExportOutput: unexpected URL safe encoding [%s]", encoded)
+	}
+}
+
+func TestFormatAsConsul(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "DB_URL", Value: "postgres://user:pass@db/app?ssl=true"},
+		{Key: "GREETING", Value: "hello world"},
+	}
+
+	output, err := formatAsConsul(envs, "/config/my-app/")
+	if err != nil {
+		t.Fatalf("TestFormatAsConsul: unexpected error [err=%v]", err)
+	}
+
+	expected := "config/my-app/DB_URL postgres://user:pass@db/app?ssl=true\nconfig/my-app/GREETING hello world\n"
+	if output != expected {
+		t.Errorf("TestFormatAsConsul: expected %q but got %q", expected, output)
+	}
+
+	if _, err := formatAsConsul([]models.SingleEnvironmentVariable{{Key: "CERT", Value: "line1\nline2"}}, ""); err == nil {
+		t.Errorf("TestFormatAsConsul: expected an error for a value with a line break")
+	}
+
+	output, err = formatAsConsulJson(append(envs, models.SingleEnvironmentVariable{Key: "CERT", Value: "line1\nline2"}), "")
+	if err != nil {
+		t.Fatalf("TestFormatAsConsul: unexpected error [err=%v]", err)
+	}
+
+	var entries []consulKVEntry
+	if err := json.Unmarshal([]byte(output), &entries); err != nil || len(entries) != 3 {
+		t.Fatalf("TestFormatAsConsul: expected 3 entries but got %s [err=%v]", output, err)
+	}
+
+	value, _ := base64.StdEncoding.DecodeString(entries[2].Value)
+	if entries[2].Key != "CERT" || string(value) != "line1\nline2" {
+		t.Errorf("TestFormatAsConsul: expected the value to be base64 encoded but got %+v", entries[2])
+	}
+}
+
+func TestRenderOutputTemplate(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "B_KEY", Value: "say \"hi\"\n\\o/"},
+		{Key: "A_KEY", Value: "Mixed Case"},
+	}
+
+	var tests = []struct {
+		Template string
+		Expected string
+	}{
+		{Template: `{{ range $k, $v := . }}{{ $k }}|{{ $v }};{{ end }}`, Expected: "A_KEY|Mixed Case;B_KEY|say \"hi\"\n\\o/;"},
+		{Template: `{{ upper .A_KEY }} {{ lower .A_KEY }}`, Expected: "MIXED CASE mixed case"},
+		{Template: `{{ quote .B_KEY }}`, Expected: `"say \"hi\"\n\\o/"`},
+		{Template: `{{ b64enc .B_KEY }}`, Expected: base64.StdEncoding.EncodeToString([]byte(envs[0].Value))},
+		{Template: `{{ range $k, $v := . }}{{ $k }}{{ "\n" }}{{ end }}`, Expected: "A_KEY\nB_KEY\n"},
+	}
+
+	for _, test := range tests {
+		output, err := renderOutputTemplate(envs, test.Template, MISSING_SECRET_FAIL)
+		if err != nil || output != test.Expected {
+			t.Errorf("TestRenderOutputTemplate: expected %q for %s but got %q [err=%v]", test.Expected, test.Template, output, err)
+		}
+	}
+
+	if _, err := renderOutputTemplate(envs, `{{ .MISSING }}`, MISSING_SECRET_FAIL); err == nil {
+		t.Errorf("TestRenderOutputTemplate: expected an error for a secret that does not exist")
+	}
+
+	if _, err := renderOutputTemplate(envs, `{{ range }}`, MISSING_SECRET_FAIL); err == nil {
+		t.Errorf("TestRenderOutputTemplate: expected an error for an invalid template")
+	}
+}
+
+func TestRenderOutputTemplate_OnSecretMissing(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{{Key: "HOST", Value: "db.internal"}}
+	templateText := `host={{ .HOST }} port={{ .PORT | upper }}{{ if .HOST }} user={{.USER}}{{ end }}`
+
+	output, err := renderOutputTemplate(envs, templateText, MISSING_SECRET_SKIP)
+	if err != nil || output != "host=db.internal port={{.PORT | upper}} user={{.USER}}" {
+		t.Errorf("TestRenderOutputTemplate_OnSecretMissing: expected skip to leave the placeholders but got %q [err=%v]", output, err)
+	}
+
+	output, err = renderOutputTemplate(envs, templateText, MISSING_SECRET_EMPTY)
+	if err != nil || output != "host=db.internal port= user=" {
+		t.Errorf("TestRenderOutputTemplate_OnSecretMissing: expected empty to render nothing but got %q [err=%v]", output, err)
+	}
+
+	_, err = renderOutputTemplate(envs, templateText, MISSING_SECRET_FAIL)
+	if err == nil || !strings.Contains(err.Error(), "PORT") {
+		t.Errorf("TestRenderOutputTemplate_OnSecretMissing: expected fail to name the missing secret [err=%v]", err)
+	}
+}
+
+func TestInferValueType(t *testing.T) {
+	var tests = []struct {
+		Value        string
+		ExpectedType string
+	}{
+		{Value: "8080", ExpectedType: "!!int"},
+		{Value: "-1", ExpectedType: "!!int"},
+		{Value: "0", ExpectedType: "!!int"},
+		{Value: "01234", ExpectedType: "!!str"},
+		{Value: "99999999999999999999", ExpectedType: "!!str"},
+		{Value: "1.50", ExpectedType: "!!float"},
+		{Value: "-0.5e-3", ExpectedType: "!!float"},
+		{Value: "1e5", ExpectedType: "!!str"},
+		{Value: ".5", ExpectedType: "!!str"},
+		{Value: "true", ExpectedType: "!!bool"},
+		{Value: "True", ExpectedType: "!!str"},
+		{Value: "yes", ExpectedType: "!!str"},
+		{Value: "null", ExpectedType: "!!null"},
+		{Value: "", ExpectedType: "!!str"},
+		{Value: " 42", ExpectedType: "!!str"},
+	}
+
+	for _, test := range tests {
+		if inferredType := inferValueType("KEY", test.Value, nil); inferredType != test.ExpectedType {
+			t.Errorf("TestInferValueType: expected %s for [%s] but got %s", test.ExpectedType, test.Value, inferredType)
+		}
+	}
+
+	if inferredType := inferValueType("ZIP", "8080", []string{"PORT"}); inferredType != "!!str" {
+		t.Errorf("TestInferValueType: expected keys that are not in --infer-keys to stay strings but got %s", inferredType)
+	}
+}
+
+func TestFormatWithInferredTypes(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "PORT", Value: "8080"},
+		{Key: "DEBUG", Value: "false"},
+		{Key: "RATIO", Value: "1.50"},
+		{Key: "ZIP", Value: "01234"},
+		{Key: "NAME", Value: "yes"},
+	}
+
+	output, err := formatAsJsonWithInferredTypes(envs, nil)
+	if err != nil {
+		t.Fatalf("TestFormatWithInferredTypes: unexpected error [err=%v]", err)
+	}
+
+	for _, expected := range []string{`"value":8080`, `"value":false`, `"value":1.50`, `"value":"01234"`, `"value":"yes"`} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("TestFormatWithInferredTypes: expected %s in the JSON output %s", expected, output)
+		}
+	}
+
+	output, err = formatAsYamlWithInferredTypes(envs, []string{"PORT", "NAME"})
+	if err != nil {
+		t.Fatalf("TestFormatWithInferredTypes: unexpected error [err=%v]", err)
+	}
+
+	expected := "PORT: 8080\nDEBUG: \"false\"\nRATIO: \"1.50\"\nZIP: \"01234\"\nNAME: \"yes\"\n"
+	if output != expected {
+		t.Errorf("TestFormatWithInferredTypes: expected %q but got %q", expected, output)
+	}
+}
+
+func TestWrapInMetadataEnvelope(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{{Key: "DB_PASSWORD", Value: "hunter2", Type: "shared"}}
+	fetchedAt := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	output, err := wrapInMetadataEnvelope(formatAsJson(envs), exportMetadata{ProjectId: "project-id", Environment: "prod", Paths: []string{"/", "/api"}, FetchedAt: fetchedAt})
+	if err != nil {
+		t.Fatalf("TestWrapInMetadataEnvelope: unexpected error [err=%v]", err)
+	}
+
+	var envelope struct {
+		Project     string                             `json:"project"`
+		Environment string                             `json:"environment"`
+		Paths       []string                           `json:"paths"`
+		FetchedAt   time.Time                          `json:"fetchedAt"`
+		Secrets     []models.SingleEnvironmentVariable `json:"secrets"`
+	}
+	if err := json.Unmarshal([]byte(output), &envelope); err != nil {
+		t.Fatalf("TestWrapInMetadataEnvelope: expected valid JSON but got %s [err=%v]", output, err)
+	}
+
+	if envelope.Project != "project-id" || envelope.Environment != "prod" || len(envelope.Paths) != 2 || !envelope.FetchedAt.Equal(fetchedAt) {
+		t.Errorf("TestWrapInMetadataEnvelope: unexpected metadata %s", output)
+	}
+
+	if len(envelope.Secrets) != 1 || envelope.Secrets[0].Value != "hunter2" {
+		t.Errorf("TestWrapInMetadataEnvelope: expected the secrets to be kept as without the envelope but got %s", output)
+	}
+
+	output, _ = wrapInMetadataEnvelope("[]", exportMetadata{Environment: "dev", Paths: []string{"/"}})
+	if strings.Contains(output, `"project"`) {
+		t.Errorf("TestWrapInMetadataEnvelope: expected an unknown project to be left out but got %s", output)
+	}
+}
+
+func TestApplyExportPreset(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringP("format", "f", FormatDotenv, "")
+		if err := cmd.Flags().Parse(args); err != nil {
+			t.Fatalf("TestApplyExportPreset: unable to parse flags [err=%v]", err)
+		}
+		return cmd
+	}
+
+	cmd := newCmd()
+	presetFlags, err := applyExportPreset(cmd, "Docker")
+	if err != nil {
+		t.Fatalf("TestApplyExportPreset: unexpected error [err=%v]", err)
+	}
+	if format, _ := cmd.Flags().GetString("format"); format != FormatDotEnvDocker || presetFlags["format"] != FormatDotEnvDocker {
+		t.Errorf("TestApplyExportPreset: expected the docker preset to set the format to [%s] but got [%s] and %v", FormatDotEnvDocker, format, presetFlags)
+	}
+	if cmd.Flags().Changed("format") {
+		t.Errorf("TestApplyExportPreset: expected the format set by the preset to not be marked as changed")
+	}
+
+	cmd = newCmd("--format", FormatJson)
+	presetFlags, err = applyExportPreset(cmd, "systemd")
+	if err != nil {
+		t.Fatalf("TestApplyExportPreset: unexpected error [err=%v]", err)
+	}
+	if format, _ := cmd.Flags().GetString("format"); format != FormatJson || len(presetFlags) != 0 {
+		t.Errorf("TestApplyExportPreset: expected an explicit --format to override the preset but got [%s] and %v", format, presetFlags)
+	}
+
+	if _, err := applyExportPreset(newCmd(), "heroku"); err == nil || !strings.Contains(err.Error(), "aws-ssm, consul, docker, systemd") {
+		t.Errorf("TestApplyExportPreset: expected an error listing the presets but got [err=%v]", err)
+	}
+}
+
+func TestFormatAsNginx(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "DB_HOST", Value: "db.internal"},
+		{Key: "GREETING", Value: "say \"hi\"\\n\tnow\n"},
+	}
+
+	output, err := formatAsNginx(envs, false)
+	if err != nil {
+		t.Fatalf("TestFormatAsNginx: unexpected error [err=%v]", err)
+	}
+
+	if output != "env DB_HOST;\nenv GREETING;\n" {
+		t.Errorf("TestFormatAsNginx: unexpected env directives %q", output)
+	}
+
+	output, err = formatAsNginx(envs, true)
+	if err != nil {
+		t.Fatalf("TestFormatAsNginx: unexpected error [err=%v]", err)
+	}
+
+	expected := "set $DB_HOST \"db.internal\";\nset $GREETING \"say \\\"hi\\\"\\\\n\\tnow\\n\";\n"
+	if output != expected {
+		t.Errorf("TestFormatAsNginx: expected %q but got %q", expected, output)
+	}
+
+	if _, err := formatAsNginx([]models.SingleEnvironmentVariable{{Key: "PRICE", Value: "$5"}}, true); err == nil {
+		t.Errorf("TestFormatAsNginx: expected an error for a value with a $")
+	}
+
+	if _, err := formatAsNginx([]models.SingleEnvironmentVariable{{Key: "MY-KEY", Value: "value"}}, false); err == nil {
+		t.Errorf("TestFormatAsNginx: expected an error for an invalid variable name")
+	}
+}
+
+func TestFormatAsJsonWithCommentMetadata(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "API_KEY", Value: "abc", Type: "shared", Comment: "# rotate:30d used by the billing job"},
+		{Key: "PORT", Value: "8080", Type: "shared"},
+	}
+
+	output, err := formatAsJsonWithCommentMetadata(envs)
+	if err != nil {
+		t.Fatalf("TestFormatAsJsonWithCommentMetadata: unexpected error [err=%v]", err)
+	}
+
+	var exported []struct {
+		Key     string            `json:"key"`
+		Comment string            `json:"comment"`
+		Meta    map[string]string `json:"meta"`
+	}
+	if err := json.Unmarshal([]byte(output), &exported); err != nil || len(exported) != 2 {
+		t.Fatalf("TestFormatAsJsonWithCommentMetadata: expected 2 secrets but got %s [err=%v]", output, err)
+	}
+
+	if exported[0].Meta["rotate"] != "30d" || len(exported[0].Meta) != 1 || exported[0].Comment != envs[0].Comment {
+		t.Errorf("TestFormatAsJsonWithCommentMetadata: unexpected metadata for API_KEY %s", output)
+	}
+
+	if exported[1].Meta == nil || len(exported[1].Meta) != 0 {
+		t.Errorf("TestFormatAsJsonWithCommentMetadata: expected an empty meta object for PORT %s", output)
+	}
+}
+
+func TestFormatAsFlyio(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "DATABASE_URL", Value: "postgres://user:p'a\"ss@db/app?sslmode=require"},
+		{Key: "EMPTY", Value: ""},
+		{Key: "WITH_EQUALS", Value: "a=b"},
+		{Key: "MULTI_LINE", Value: "line1\nline2"},
+		{Key: "TRIPLE_QUOTED", Value: `"""not multi-line`},
+	}
+
+	expected := "DATABASE_URL=postgres://user:p'a\"ss@db/app?sslmode=require\nEMPTY=\nWITH_EQUALS=a=b\n"
+	if output := formatAsFlyio(envs); output != expected {
+		t.Errorf("TestFormatAsFlyio: expected [%s] but got [%s]", expected, output)
+	}
+}
+
+func TestFormatAsPowershell(t *testing.T) {
+	envs := []models.SingleEnvironmentVariable{
+		{Key: "QUOTES", Value: "it's ‘quoted’"},
+		{Key: "NO_EXPANSION", Value: "$env:PATH `$(Remove-Item x)` ${HOME}"},
+		{Key: "EMPTY", Value: ""},
+		{Key: "MULTI_LINE", Value: "line1\r\n'; Remove-Item x; '\n"},
+		{Key: "NOT-A-NAME", Value: "skipped"},
+	}
+
+	expected := "$env:QUOTES = 'it''s ‘‘quoted’’'\n" +
+		"$env:NO_EXPANSION = '$env:PATH `$(Remove-Item x)` ${HOME}'\n" +
+		"$env:EMPTY = ''\n" +
+		"$env:MULTI_LINE = 'line1' + \"`r\" + \"`n\" + '''; Remove-Item x; ''' + \"`n\"\n"
+	if output := formatAsPowershell(envs); output != expected {
+		t.Errorf("TestFormatAsPowershell: expected [%s] but got [%s]", expected, output)
+	}
+}
+
+func TestConvertLineEndings(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{{Key: "DB_HOST", Value: "localhost"}, {Key: "CERT", Value: "line1\r\nline2"}}
+	output, err := formatEnvs(secrets, FormatDotenv, exportFormatOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		LINE_ENDINGS_LF:   "DB_HOST='localhost'\nCERT='line1\nline2'\n",
+		LINE_ENDINGS_CRLF: "DB_HOST='localhost'\r\nCERT='line1\r\nline2'\r\n",
+	}
+
+	for lineEndings, expectedOutput := range expected {
+		if converted := convertLineEndings(output, lineEndings, "linux"); converted != expectedOutput {
+			t.Errorf("Expected %q with %s line endings but got %q", expectedOutput, lineEndings, converted)
+		}
+	}
+
+	if converted := convertLineEndings(output, LINE_ENDINGS_PLATFORM, "windows"); converted != expected[LINE_ENDINGS_CRLF] {
+		t.Errorf("Expected crlf line endings on Windows but got %q", converted)
+	}
+
+	if converted := convertLineEndings(output, LINE_ENDINGS_PLATFORM, "darwin"); converted != expected[LINE_ENDINGS_LF] {
+		t.Errorf("Expected lf line endings on macOS but got %q", converted)
+	}
+}
+
+func TestExportPresetConflictNamesFor(t *testing.T) {
+	if executeInfisicalIfChild() {
+		return
+	}
+
+	output, err := runInfisicalForTest(t, t.TempDir(), "export", "--for", "docker", "--output-template", "{{.DB_PASSWORD}}")
+	exitErr, isExitErr := err.(*exec.ExitError)
+	if !isExitErr || exitErr.ExitCode() != 1 || !strings.Contains(string(output), "--output-template cannot be used together with --for docker") {
+		t.Errorf("TestExportPresetConflictNamesFor: expected the conflict to name --for, got [err=%v] with output [%s]", err, output)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
-	"github.com/go-resty/resty/v2"
 	"github.com/manifoldco/promptui"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -45,7 +44,7 @@ var initCmd = &cobra.Command{
 			util.HandleError(err, "Unable to get your login details")
 		}
 
-		httpClient := resty.New()
+		httpClient := api.NewHttpClient()
 		httpClient.SetAuthToken(userCreds.UserCredentials.JTWToken)
 		workspaceResponse, err := api.CallGetAllWorkSpacesUserBelongsTo(httpClient)
 		if err != nil {
@@ -95,7 +94,7 @@ func writeWorkspaceFile(selectedWorkspace models.Workspace) error {
 		return err
 	}
 
-	err = util.WriteToFile(util.INFISICAL_WORKSPACE_CONFIG_FILE_NAME, marshalledWorkspaceFile, 0600)
+	err = util.WriteToFile(util.GetWorkspaceConfigFileName(), marshalledWorkspaceFile, 0600)
 	if err != nil {
 		return err
 	}
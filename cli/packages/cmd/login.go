@@ -6,13 +6,17 @@ package cmd
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"os"
 	"strings"
+	"time"
 
 	"errors"
 	"fmt"
 	"net/url"
 	"regexp"
 
+	"github.com/99designs/keyring"
 	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/Infisical/infisical-merge/packages/config"
 	"github.com/Infisical/infisical-merge/packages/crypto"
@@ -20,7 +24,6 @@ import (
 	"github.com/Infisical/infisical-merge/packages/srp"
 	"github.com/Infisical/infisical-merge/packages/util"
 	"github.com/fatih/color"
-	"github.com/go-resty/resty/v2"
 	"github.com/manifoldco/promptui"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -39,6 +42,11 @@ const ADD_USER = "Add a new account login"
 const REPLACE_USER = "Override current logged in user"
 const EXIT_USER_MENU = "Exit"
 
+const (
+	LOGIN_METHOD_USER  = "user"
+	LOGIN_METHOD_TOKEN = "token"
+)
+
 // loginCmd represents the login command
 var loginCmd = &cobra.Command{
 	Use:                   "login",
@@ -46,6 +54,33 @@ var loginCmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 	PreRun:                toggleDebug,
 	Run: func(cmd *cobra.Command, args []string) {
+		loginMethod, err := cmd.Flags().GetString("method")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		serviceToken, err := cmd.Flags().GetString("token")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tokenStore, err := cmd.Flags().GetString("store")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		switch loginMethod {
+		case LOGIN_METHOD_TOKEN:
+			loginWithServiceToken(serviceToken, tokenStore)
+			return
+		case LOGIN_METHOD_USER:
+			if serviceToken != "" || cmd.Flags().Changed("store") {
+				util.PrintErrorMessageAndExit("--token and --store can only be used with --method token")
+			}
+		default:
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid login method [%s]. Available options are [%s, %s]", loginMethod, LOGIN_METHOD_USER, LOGIN_METHOD_TOKEN))
+		}
+
 		currentLoggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
 		// if the key can't be found or there is an error getting current credentials from key ring, allow them to override
 		if err != nil && (strings.Contains(err.Error(), "The specified item could not be found in the keyring") || strings.Contains(err.Error(), "unable to get key from Keyring") || strings.Contains(err.Error(), "GetUserCredsFromKeyRing")) {
@@ -107,7 +142,7 @@ var loginCmd = &cobra.Command{
 			for i < 6 {
 				mfaVerifyCode := askForMFACode()
 
-				httpClient := resty.New()
+				httpClient := api.NewHttpClient()
 				httpClient.SetAuthToken(loginTwoResponse.Token)
 				verifyMFAresponse, mfaErrorResponse, requestError := api.CallVerifyMfaToken(httpClient, api.VerifyMfaTokenRequest{
 					Email:    email,
@@ -258,6 +293,12 @@ var loginCmd = &cobra.Command{
 			return
 		}
 
+		// a service token saved earlier would otherwise take precedence over this login
+		err = util.DeleteStoredServiceToken()
+		if err != nil {
+			log.Debugf("Unable to remove the stored service token [err=%s]", err)
+		}
+
 		err = util.WriteInitalConfig(userCredentialsToBeStored)
 		if err != nil {
 			util.HandleError(err, "Unable to write write to Infisical Config file. Please try again")
@@ -279,8 +320,164 @@ var loginCmd = &cobra.Command{
 	},
 }
 
+const (
+	LOGIN_STATUS_OUTPUT_TEXT = "text"
+	LOGIN_STATUS_OUTPUT_JSON = "json"
+)
+
+var loginStatusCmd = &cobra.Command{
+	Use:                   "status",
+	Short:                 "Used to check whether you are authenticated and when your login expires",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical login status --output json",
+	Args:                  cobra.NoArgs,
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if output != LOGIN_STATUS_OUTPUT_TEXT && output != LOGIN_STATUS_OUTPUT_JSON {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --output. Available options are [%s, %s]", output, LOGIN_STATUS_OUTPUT_TEXT, LOGIN_STATUS_OUTPUT_JSON))
+		}
+
+		status, err := util.GetLoginStatus()
+		if err != nil {
+			util.HandleError(err, "Unable to check your login")
+		}
+
+		if output == LOGIN_STATUS_OUTPUT_JSON {
+			statusJson, err := json.MarshalIndent(status, "", "    ")
+			if err != nil {
+				util.HandleError(err, "Unable to print your login status as JSON")
+			}
+			fmt.Println(string(statusJson))
+		} else {
+			fmt.Print(formatLoginStatus(status, time.Now()))
+		}
+
+		if status.IsExpired {
+			os.Exit(util.EXIT_CODE_LOGIN_EXPIRED)
+		}
+
+		if !status.IsAuthenticated {
+			os.Exit(1)
+		}
+	},
+}
+
+func formatLoginStatus(status util.LoginStatus, now time.Time) string {
+	var builder strings.Builder
+	if status.IsAuthenticated {
+		fmt.Fprintf(&builder, "Authenticated with the %s [%s]\n", status.AuthMethod, status.Identity)
+	} else {
+		fmt.Fprintf(&builder, "Not authenticated: %s\n", status.Reason)
+		if status.AuthMethod != "" {
+			fmt.Fprintf(&builder, "Auth method: %s\n", status.AuthMethod)
+		}
+	}
+
+	switch {
+	case status.ExpiresAt == nil && status.IsAuthenticated:
+		builder.WriteString("Expires: never\n")
+	case status.ExpiresAt == nil:
+	case status.ExpiresAt.After(now):
+		fmt.Fprintf(&builder, "Expires: in %s (%s)\n", formatCountdown(status.ExpiresAt.Sub(now)), status.ExpiresAt.Local().Format(time.RFC1123))
+	default:
+		fmt.Fprintf(&builder, "Expired: %s ago (%s)\n", formatCountdown(now.Sub(*status.ExpiresAt)), status.ExpiresAt.Local().Format(time.RFC1123))
+	}
+
+	return builder.String()
+}
+
+// Formats a duration with its two largest units, such as 6d 23h or 4m 10s
+func formatCountdown(duration time.Duration) string {
+	seconds := int64(duration.Round(time.Second) / time.Second)
+	units := []struct {
+		suffix  string
+		seconds int64
+	}{{"d", 24 * 60 * 60}, {"h", 60 * 60}, {"m", 60}, {"s", 1}}
+
+	for idx, unit := range units {
+		if seconds < unit.seconds && idx != len(units)-1 {
+			continue
+		}
+
+		formatted := fmt.Sprintf("%d%s", seconds/unit.seconds, unit.suffix)
+		if idx != len(units)-1 {
+			next := units[idx+1]
+			formatted += fmt.Sprintf(" %d%s", seconds%unit.seconds/next.seconds, next.suffix)
+		}
+		return formatted
+	}
+
+	return ""
+}
+
 func init() {
 	rootCmd.AddCommand(loginCmd)
+	loginStatusCmd.Flags().String("output", LOGIN_STATUS_OUTPUT_TEXT, "The format to print your login status in (text, json)")
+	loginCmd.AddCommand(loginStatusCmd)
+	loginCmd.Flags().String("method", LOGIN_METHOD_USER, "how to login (user, token). token saves an Infisical service token that other commands use when no token is given")
+	loginCmd.Flags().String("token", "", "the service token to login with when using --method token. You will be prompted for it when not set")
+	loginCmd.Flags().String("store", "", "where to save the service token when using --method token (keyring, file, none). Defaults to the keyring when available, otherwise the config file")
+}
+
+// Validates the service token and saves it so that it is picked up by commands that fetch secrets
+func loginWithServiceToken(serviceToken string, requestedStore string) {
+	store, err := util.ResolveServiceTokenStore(requestedStore, keyring.AvailableBackends(), getConfiguredVaultBackend())
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if serviceToken == "" {
+		tokenPrompt := promptui.Prompt{
+			Label: "Service token",
+			Mask:  '*',
+		}
+
+		serviceToken, err = tokenPrompt.Run()
+		if err != nil {
+			util.HandleError(err)
+		}
+	}
+
+	serviceToken = strings.TrimSpace(serviceToken)
+
+	serviceTokenDetails, err := util.GetServiceTokenDetails(serviceToken)
+	if err != nil {
+		util.HandleError(err, "Unable to validate your service token. Please double check it and try again")
+	}
+
+	err = util.StoreServiceToken(serviceToken, store, config.INFISICAL_URL)
+	if err != nil && requestedStore == "" && store == util.SERVICE_TOKEN_STORE_KEYRING {
+		log.Debugf("Unable to store service token in keyring, falling back to the config file [err=%s]", err)
+		store = util.SERVICE_TOKEN_STORE_FILE
+		err = util.StoreServiceToken(serviceToken, store, config.INFISICAL_URL)
+	}
+
+	if err != nil {
+		util.HandleError(err, "Unable to save your service token")
+	}
+
+	switch store {
+	case util.SERVICE_TOKEN_STORE_NONE:
+		util.PrintSuccessMessage(fmt.Sprintf("Your service token for environment [%s] is valid. It was not saved, so pass it with --token or the INFISICAL_TOKEN environment variable", serviceTokenDetails.Environment))
+	case util.SERVICE_TOKEN_STORE_FILE:
+		util.PrintSuccessMessage(fmt.Sprintf("Logged in with service token [%s] for environment [%s]. The token is saved in your Infisical config file", serviceTokenDetails.Name, serviceTokenDetails.Environment))
+	default:
+		util.PrintSuccessMessage(fmt.Sprintf("Logged in with service token [%s] for environment [%s]. The token is saved in your system vault", serviceTokenDetails.Name, serviceTokenDetails.Environment))
+	}
+}
+
+func getConfiguredVaultBackend() keyring.BackendType {
+	configFile, err := util.GetConfigFile()
+	if err != nil {
+		return ""
+	}
+
+	return configFile.VaultBackendType
 }
 
 func DomainOverridePrompt() (bool, error) {
@@ -398,7 +595,7 @@ func askForLoginCredentials() (email string, password string, err error) {
 
 func getFreshUserCredentials(email string, password string) (*api.GetLoginOneV2Response, *api.GetLoginTwoV2Response, error) {
 	log.Debugln("getFreshUserCredentials:", "email", email, "password", password)
-	httpClient := resty.New()
+	httpClient := api.NewHttpClient()
 	httpClient.SetRetryCount(5)
 
 	params := srp.GetParams(4096)
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+)
+
+// Returns the read end of a pipe holding content, to be passed to your application with exec.Cmd.ExtraFiles. content is
+// written from a goroutine since a pipe only buffers about 64KB: it never blocks the CLI when your application does not
+// read it, and ends with an EPIPE error once your application exited. The read end has to be closed once your
+// application started, so that the write fails instead of blocking forever when your application never reads
+func newSecretsPipe(content []byte) (*os.File, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer writer.Close()
+		writer.Write(content)
+	}()
+
+	return reader, nil
+}
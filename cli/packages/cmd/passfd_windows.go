@@ -0,0 +1,13 @@
+//go:build windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+// file descriptors other than stdin, stdout and stderr cannot be inherited by processes on Windows
+func newSecretsPipe(content []byte) (*os.File, error) {
+	return nil, errors.New("--pass-fd is only supported on Linux and macOS")
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package cmd
+
+// outside of Windows environment variable names are case-sensitive and the shared reservedEnvVars are enough
+const envNamesAreCaseInsensitive = false
+
+var platformReservedEnvVars = []string{}
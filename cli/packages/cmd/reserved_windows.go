@@ -0,0 +1,15 @@
+//go:build windows
+
+package cmd
+
+// Windows looks up environment variables regardless of case, so a secret named path would replace Path
+const envNamesAreCaseInsensitive = true
+
+// Variables Windows and its shells rely on to locate programs and user folders, in addition to reservedEnvVars
+var platformReservedEnvVars = []string{
+	"COMSPEC", "PATHEXT", "SYSTEMROOT", "SYSTEMDRIVE",
+	"WINDIR", "TEMP", "TMP", "USERPROFILE",
+	"APPDATA", "LOCALAPPDATA", "PROGRAMDATA", "PROGRAMFILES",
+	"PROGRAMFILES(X86)", "HOMEDRIVE", "HOMEPATH", "USERNAME",
+	"PSMODULEPATH",
+}
@@ -0,0 +1,48 @@
+//go:build windows
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func TestFilterReservedEnvVars_Windows(t *testing.T) {
+	env := map[string]models.SingleEnvironmentVariable{
+		"path":          {},
+		"ComSpec":       {},
+		"SystemRoot":    {},
+		"xdg_data_dirs": {},
+		"DB_PASSWORD":   {},
+	}
+
+	droppedKeys := filterReservedEnvVars(env)
+
+	if strings.Join(droppedKeys, ",") != "ComSpec,SystemRoot,path,xdg_data_dirs" {
+		t.Errorf("TestFilterReservedEnvVars_Windows: expected reserved names to match regardless of case, got %v", droppedKeys)
+	}
+
+	if _, ok := env["DB_PASSWORD"]; !ok || len(env) != 1 {
+		t.Errorf("TestFilterReservedEnvVars_Windows: expected only DB_PASSWORD to be kept, got %v", env)
+	}
+}
+
+func TestBuildEnvironment_Windows(t *testing.T) {
+	existingEnv := []string{"Path=C:\\Windows", "Api_Url=old"}
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "API_URL", Value: "new"},
+		{Key: "DB_PASSWORD", Value: "secret"},
+	}
+	secretsByKey := map[string]models.SingleEnvironmentVariable{
+		"API_URL":     secrets[0],
+		"DB_PASSWORD": secrets[1],
+	}
+
+	env := buildEnvironment(existingEnv, secrets, secretsByKey, ENV_ORDER_AS_FETCHED)
+	expected := []string{"Path=C:\\Windows", "Api_Url=new", "DB_PASSWORD=secret"}
+	if strings.Join(env, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("TestBuildEnvironment_Windows: expected the secret to replace the variable of another case, got %v", env)
+	}
+}
@@ -35,6 +35,7 @@ var resetCmd = &cobra.Command{
 		}
 
 		keyringInstance.Remove(util.KEYRING_SERVICE_NAME)
+		keyringInstance.Remove(util.SERVICE_TOKEN_KEYRING_KEY)
 
 		// delete secrets backup
 		util.DeleteBackupSecrets()
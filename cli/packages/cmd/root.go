@@ -32,9 +32,22 @@ func Execute() {
 func init() {
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.PersistentFlags().BoolVarP(&debugLogging, "debug", "d", false, "Enable verbose logging")
+	rootCmd.PersistentFlags().StringVar(&config.INFISICAL_REQUEST_ID, "request-id", "", "Set the X-Request-ID header sent with every request to Infisical, useful to find an invocation in your server logs. A random id is used by default")
+	rootCmd.PersistentFlags().Float64Var(&config.INFISICAL_RATE_LIMIT, "rate-limit", 0, "Max number of requests per second sent to Infisical, useful with strict rate limits on self-hosted instances. Requests rejected with a 429 status are retried either way")
+	rootCmd.PersistentFlags().DurationVar(&config.INFISICAL_CONNECT_TIMEOUT, "connect-timeout", 0, "Max time to resolve and connect to Infisical (e.g. 5s), useful on networks where connecting hangs. 0 means no limit")
+	rootCmd.PersistentFlags().DurationVar(&config.INFISICAL_REQUEST_TIMEOUT, "timeout", 0, "Max time of a request sent to Infisical including downloading the response (e.g. 1m). 0 means no limit")
+	rootCmd.PersistentFlags().BoolVar(&config.INFISICAL_TRACE, "trace", false, "Log the method, url, status and timing of every request sent to Infisical to stderr. Tokens, headers, query parameter values and secret values are never logged")
+	rootCmd.PersistentFlags().StringVar(&config.INFISICAL_TRACE_FILE, "trace-file", "", "Write the trace of --trace to this file instead of stderr, readable by the current user only. Implies --trace and applies the same redaction")
+	rootCmd.PersistentFlags().BoolVar(&config.INFISICAL_PRINT_AUTH_METHOD, "print-auth-method", false, "Print the auth method and identity that fetched the secrets to stderr, useful when several credentials are set. Credentials are never printed")
+	rootCmd.PersistentFlags().StringVar(&config.INFISICAL_WORKSPACE_CONFIG_FILE, "config-file", "", "Load the project config from this file instead of looking up .infisical.json in the current and parent directories [can also set via environment variable name: INFISICAL_CONFIG_FILE]")
 	rootCmd.PersistentFlags().StringVar(&config.INFISICAL_URL, "domain", util.INFISICAL_DEFAULT_API_URL, "Point the CLI to your own backend [can also set via environment variable name: INFISICAL_API_URL]")
 	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
-		util.CheckForUpdate()
+		resolveConfiguredNames(cmd)
+
+		// [infisical version --check] reports it itself
+		if cmd != versionCmd {
+			util.CheckForUpdate()
+		}
 	}
 
 	// if config.INFISICAL_URL is set to the default value, check if INFISICAL_URL is set in the environment
@@ -45,4 +58,28 @@ func init() {
 		}
 	}
 
+	if !rootCmd.Flag("config-file").Changed {
+		if envWorkspaceConfigFile, ok := os.LookupEnv("INFISICAL_CONFIG_FILE"); ok {
+			config.INFISICAL_WORKSPACE_CONFIG_FILE = envWorkspaceConfigFile
+		}
+	}
+
+}
+
+// Replaces the names registered via [infisical config domains add] given to --domain and the aliases registered via [infisical config env-alias add]
+// given to --env, --from-env and --to-env by what they stand for, before any command reads them. Cobra only runs the closest PersistentPreRun,
+// so commands that set their own have to call it as well
+func resolveConfiguredNames(cmd *cobra.Command) {
+	configFile, err := util.GetConfigFile()
+	if err != nil {
+		return
+	}
+
+	config.INFISICAL_URL = util.ResolveDomain(configFile, config.INFISICAL_URL)
+
+	for _, flagName := range []string{"env", "from-env", "to-env"} {
+		if environmentFlag := cmd.Flags().Lookup(flagName); environmentFlag != nil && environmentFlag.Changed {
+			environmentFlag.Value.Set(util.ResolveEnvironmentAlias(configFile, environmentFlag.Value.String()))
+		}
+	}
 }
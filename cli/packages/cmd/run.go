@@ -5,12 +5,19 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
@@ -77,70 +84,1001 @@ var runCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		expandSource := getExpandSource(cmd)
+
+		decodeEncoding, decodeKeys := getDecodeOptions(cmd)
+
+		fetchHook := getFetchHookOptions(cmd)
+
+		secretPrefix, secretSuffix := getSecretAffixes(cmd)
+
+		onFetchError, err := cmd.Flags().GetString("on-fetch-error")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		err = util.ValidateFetchErrorPolicy(onFetchError)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		tagSlugs, err := cmd.Flags().GetString("tags")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
 
-		secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs})
+		secretsPaths, err := cmd.Flags().GetStringArray("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		allowEmptyPathVariables, err := cmd.Flags().GetBool("allow-empty-path-vars")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		for idx, secretsPath := range secretsPaths {
+			secretsPaths[idx], err = util.ExpandSecretsPathVariables(secretsPath, allowEmptyPathVariables)
+			if err != nil {
+				util.HandleError(err, "Unable to parse flag")
+			}
+		}
+
+		maxValueSize, err := cmd.Flags().GetInt("max-value-size")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		onOversize, err := cmd.Flags().GetString("on-oversize")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if onOversize != ON_OVERSIZE_WARN && onOversize != ON_OVERSIZE_ERROR && onOversize != ON_OVERSIZE_TRUNCATE {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --on-oversize. Available options are [%s]", onOversize, strings.Join([]string{ON_OVERSIZE_WARN, ON_OVERSIZE_ERROR, ON_OVERSIZE_TRUNCATE}, ", ")))
+		}
+
+		workingDirectory, err := cmd.Flags().GetString("chdir")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if workingDirectory != "" {
+			directoryInfo, err := os.Stat(workingDirectory)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to use [%s] as the working directory of your application", workingDirectory))
+			}
+
+			if !directoryInfo.IsDir() {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("Unable to use [%s] as the working directory of your application because it is not a directory", workingDirectory))
+			}
+		}
+
+		envOrder, err := cmd.Flags().GetString("env-order")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		preserveEnvOrder, err := cmd.Flags().GetBool("preserve-env-order")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if preserveEnvOrder {
+			if cmd.Flags().Changed("env-order") && envOrder != ENV_ORDER_AS_FETCHED {
+				util.PrintErrorMessageAndExit("--preserve-env-order cannot be combined with --env-order=" + envOrder)
+			}
+			envOrder = ENV_ORDER_AS_FETCHED
+		}
+
+		envOrders := []string{ENV_ORDER_SORTED, ENV_ORDER_AS_FETCHED, ENV_ORDER_FILE}
+		if envOrder != ENV_ORDER_SORTED && envOrder != ENV_ORDER_AS_FETCHED && envOrder != ENV_ORDER_FILE {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --env-order. Available options are [%s]", envOrder, strings.Join(envOrders, ", ")))
+		}
+
+		envCase, err := cmd.Flags().GetString("env-case")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if envCase != ENV_CASE_PRESERVE && envCase != ENV_CASE_UPPER && envCase != ENV_CASE_LOWER {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --env-case. Available options are [%s]", envCase, strings.Join([]string{ENV_CASE_PRESERVE, ENV_CASE_UPPER, ENV_CASE_LOWER}, ", ")))
+		}
+
+		secretsFromJson, err := cmd.Flags().GetString("secrets-from-json")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		stdinSecretsFormat, err := cmd.Flags().GetString("stdin-secrets-format")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if cmd.Flags().Changed("stdin-secrets-format") && secretsFromJson == "" {
+			util.PrintErrorMessageAndExit("--stdin-secrets-format requires --secrets-from-json")
+		}
+
+		if stdinSecretsFormat != util.SECRETS_INPUT_FORMAT_JSON && stdinSecretsFormat != util.SECRETS_INPUT_FORMAT_YAML && stdinSecretsFormat != util.SECRETS_INPUT_FORMAT_DOTENV {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --stdin-secrets-format. Available options are [%s]", stdinSecretsFormat, strings.Join([]string{util.SECRETS_INPUT_FORMAT_JSON, util.SECRETS_INPUT_FORMAT_YAML, util.SECRETS_INPUT_FORMAT_DOTENV}, ", ")))
+		}
+
+		envFile, err := cmd.Flags().GetString("env-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldExpandEnvFile, err := cmd.Flags().GetBool("env-file-expand")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if envOrder == ENV_ORDER_FILE && envFile == "" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --env-order without --env-file. Available options are [%s]", envOrder, strings.Join([]string{ENV_ORDER_SORTED, ENV_ORDER_AS_FETCHED}, ", ")))
+		}
+
+		if shouldExpandEnvFile && envFile == "" {
+			util.PrintErrorMessageAndExit("--env-file-expand can only be used together with --env-file")
+		}
+
+		envFileFormat, err := cmd.Flags().GetString("env-file-format")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if cmd.Flags().Changed("env-file-format") && envFile == "" {
+			util.PrintErrorMessageAndExit("--env-file-format can only be used together with --env-file")
+		}
+
+		envFileFormats := []string{util.SECRETS_INPUT_FORMAT_AUTO, util.SECRETS_INPUT_FORMAT_DOTENV, util.SECRETS_INPUT_FORMAT_JSON, util.SECRETS_INPUT_FORMAT_YAML}
+		if envFileFormat != util.SECRETS_INPUT_FORMAT_AUTO && envFileFormat != util.SECRETS_INPUT_FORMAT_DOTENV && envFileFormat != util.SECRETS_INPUT_FORMAT_JSON && envFileFormat != util.SECRETS_INPUT_FORMAT_YAML {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --env-file-format. Available options are [%s]", envFileFormat, strings.Join(envFileFormats, ", ")))
+		}
+
+		captureOutput, err := cmd.Flags().GetString("capture-output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		captureMode, err := cmd.Flags().GetString("capture-mode")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if captureMode != util.CAPTURE_MODE_COMBINED && captureMode != util.CAPTURE_MODE_SEPARATE {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --capture-mode. Available options are [%s]", captureMode, strings.Join([]string{util.CAPTURE_MODE_COMBINED, util.CAPTURE_MODE_SEPARATE}, ", ")))
+		}
+
+		shouldRedactOutput, err := cmd.Flags().GetBool("redact-output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldRedactOutput && captureOutput == "" {
+			util.PrintErrorMessageAndExit("--redact-output can only be used together with --capture-output")
+		}
+
+		rawLabels, err := cmd.Flags().GetStringArray("label")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if len(rawLabels) > 0 && captureOutput == "" {
+			util.PrintErrorMessageAndExit("--label can only be used together with --capture-output")
+		}
+
+		labels, err := util.ParseCaptureLabels(rawLabels)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		renameFile, err := cmd.Flags().GetString("rename-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldRenameOnlyMapped, err := cmd.Flags().GetBool("rename-only-mapped")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldRenameOnlyMapped && renameFile == "" {
+			util.PrintErrorMessageAndExit("--rename-only-mapped can only be used together with --rename-file")
+		}
+
+		// read before fetching so that a broken mapping fails fast
+		var renames map[string]string
+		if renameFile != "" {
+			renames, err = readRenameFile(renameFile)
+			if err != nil {
+				util.HandleError(err, "Unable to read --rename-file")
+			}
+		}
+
+		shellOverride, err := cmd.Flags().GetString("shell")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shellOverride != "" && !cmd.Flags().Changed("command") && !cmd.Flags().Changed("post-exec") {
+			util.PrintErrorMessageAndExit("--shell can only be used together with --command or --post-exec, a single command is run without a shell")
+		}
+
+		// a typo in the command fails before any secret is fetched. Commands run by a shell are only checked by the shell
+		executableToCheck := ""
+		if cmd.Flags().Changed("command") || cmd.Flags().Changed("post-exec") {
+			executableToCheck = getShellInvocation(shellOverride, runtime.GOOS, os.Getenv("SHELL"))[0]
+		}
+		if !cmd.Flags().Changed("command") && len(args) > 0 {
+			executableToCheck = args[0]
+		}
+
+		if executableToCheck != "" {
+			if err := checkExecutableExists(executableToCheck, workingDirectory); err != nil {
+				util.PrintErrorAndExit(util.EXIT_CODE_COMMAND_NOT_FOUND, err)
+			}
+		}
+
+		strictReserved, err := cmd.Flags().GetBool("strict-reserved")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldFailOnReservedCollision, err := cmd.Flags().GetBool("fail-on-reserved-collision")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldFailOnReservedCollision && strictReserved {
+			util.PrintErrorMessageAndExit("--fail-on-reserved-collision and --strict-reserved cannot be used together")
+		}
+
+		postExecCommand, err := cmd.Flags().GetString("post-exec")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldPostExecAffectExit, err := cmd.Flags().GetBool("post-exec-affects-exit")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldPostExecAffectExit && postExecCommand == "" {
+			util.PrintErrorMessageAndExit("--post-exec-affects-exit can only be used together with --post-exec")
+		}
+
+		pidFile, err := cmd.Flags().GetString("pid-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		isPidFileRequired, err := cmd.Flags().GetBool("pid-file-required")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if isPidFileRequired && pidFile == "" {
+			util.PrintErrorMessageAndExit("--pid-file-required can only be used together with --pid-file")
+		}
+
+		shouldClearSecrets, err := cmd.Flags().GetBool("clear-secrets-after-spawn")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldClearSecrets && postExecCommand != "" {
+			util.PrintErrorMessageAndExit("--clear-secrets-after-spawn cannot be used together with --post-exec, which needs the secrets once your application exited")
+		}
+
+		shouldDetach, err := cmd.Flags().GetBool("detach")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		logFile, err := cmd.Flags().GetString("log-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsDir, err := cmd.Flags().GetString("secrets-as-files")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldPassFd, err := cmd.Flags().GetBool("pass-fd")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldPassFd && secretsDir != "" {
+			util.PrintErrorMessageAndExit("--pass-fd and --secrets-as-files cannot be used together")
+		}
+
+		umask, err := cmd.Flags().GetString("umask")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		// set before anything is written, so that it covers the files of --secrets-as-files, --capture-output and --pid-file too
+		if umask != "" {
+			mask, err := parseUmask(umask)
+			if err != nil {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --umask, %v", umask, err))
+			}
+			setUmask(mask)
+		}
+
+		if logFile != "" && !shouldDetach {
+			util.PrintErrorMessageAndExit("--log-file can only be used together with --detach")
+		}
+
+		if shouldDetach {
+			// the CLI exits right after starting your application, so nothing can happen once it exited
+			if captureOutput != "" || postExecCommand != "" || shouldFailOnReservedCollision || secretsDir != "" || shouldPassFd {
+				util.PrintErrorMessageAndExit("--detach cannot be used together with --capture-output, --post-exec, --fail-on-reserved-collision, --secrets-as-files or --pass-fd, the CLI does not wait for your application to exit")
+			}
+
+			if pidFile == "" {
+				pidFile = DETACH_DEFAULT_PID_FILE
+			}
+
+			if logFile == "" {
+				logFile = DETACH_DEFAULT_LOG_FILE
+			}
+		}
+
+		waitForAddresses, err := cmd.Flags().GetStringSlice("wait-for")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		waitForHttpUrls, err := cmd.Flags().GetStringSlice("wait-for-http")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		waitTimeout, err := cmd.Flags().GetDuration("wait-timeout")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if len(waitForAddresses) > 0 || len(waitForHttpUrls) > 0 {
+			err = util.ValidateWaitForTargets(waitForAddresses, waitForHttpUrls)
+			if err != nil {
+				util.HandleError(err, "Unable to parse flag")
+			}
+
+			err = util.WaitForDependencies(waitForAddresses, waitForHttpUrls, waitTimeout)
+			if err != nil {
+				util.PrintErrorAndExit(util.EXIT_CODE_WAIT_FOR_TIMEOUT, err, "Your dependencies did not become reachable in time, so your application was not started")
+			}
+		}
+
+		var secrets []models.SingleEnvironmentVariable
+		if secretsFromJson != "" {
+			// the secrets were fetched by an earlier step, so Infisical is not called at all
+			secrets, err = util.ReadSecretsFromFile(secretsFromJson, stdinSecretsFormat)
+			if err != nil {
+				util.HandleError(err, "Unable to load secrets from --secrets-from-json")
+			}
+		} else {
+			// your application is never started with the secrets of only some of the paths
+			pathResults, err := util.GetAllEnvironmentVariablesOfPaths(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs, OnFetchError: onFetchError}, secretsPaths, false)
+
+			if err != nil {
+				util.HandleError(err, "Could not fetch secrets", "If you are using a service token to fetch secrets, please ensure it is valid")
+			}
+
+			secrets = util.MergeSecretsOfPaths(pathResults)
+		}
+
+		// from here on, any error exits before your application is started, so that it never runs with only some of its secrets
+		if secretOverriding {
+			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
+		} else {
+			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+		}
+
+		if shouldExpandSecrets {
+			exitOnReferenceCycle(secrets, expandSource)
+			secrets = util.SubstituteSecretsFromSource(secrets, expandSource, os.LookupEnv)
+		}
+
+		if decodeEncoding != "" {
+			secrets, err = util.DecodeSecretValues(secrets, decodeEncoding, decodeKeys)
+			if err != nil {
+				util.HandleError(err, "Unable to decode your secrets with --decode")
+			}
+		}
+
+		if fetchHook.command != "" {
+			hookSecrets, err := runFetchHook(fetchHook, secrets)
+			if err != nil {
+				util.HandleError(err)
+			}
+
+			secrets = mergeFetchHookSecrets(secrets, hookSecrets, fetchHook.hasPriority)
+		}
+
+		if renameFile != "" {
+			secrets, err = renameSecrets(secrets, renames, shouldRenameOnlyMapped)
+			if err != nil {
+				util.HandleError(err, "Unable to rename your secrets with --rename-file")
+			}
+		}
+
+		if envFile != "" {
+			envFileSecrets, err := util.ReadSecretsFromFile(envFile, envFileFormat)
+			if err != nil {
+				util.HandleError(err)
+			}
+
+			if shouldExpandEnvFile {
+				exitOnReferenceCycle(mergeEnvFileSecrets(secrets, envFileSecrets, false), util.EXPAND_SOURCE_SECRET)
+			}
+
+			secrets = mergeEnvFileSecrets(secrets, envFileSecrets, shouldExpandEnvFile)
+
+			if envOrder == ENV_ORDER_FILE {
+				secrets = orderSecretsByEnvFile(secrets, envFileSecrets)
+			}
+		}
+
+		if envCase != ENV_CASE_PRESERVE {
+			secrets, err = changeSecretsCase(secrets, envCase)
+			if err != nil {
+				util.HandleError(err, "Unable to change the case of your secrets with --env-case")
+			}
+		}
+
+		if secretPrefix != "" || secretSuffix != "" {
+			secrets, err = affixSecretNames(secrets, secretPrefix, secretSuffix)
+			if err != nil {
+				util.HandleError(err, "Unable to name your secrets with --secret-prefix and --secret-suffix")
+			}
+		}
+
+		// the environment of a process cannot hold NUL bytes, which decoded values, the fetch hook and the env file can hold
+		for _, secret := range secrets {
+			if strings.ContainsRune(secret.Value, 0) {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("the value of secret [%s] contains a NUL byte, which cannot be passed in an environment variable", secret.Key))
+			}
+		}
+
+		secretsByKey := getSecretsByKeys(secrets)
+
+		// check to see if there are any reserved key words in secrets to inject
+		reservedKeys := filterReservedEnvVars(secretsByKey)
+		if len(reservedKeys) > 0 && strictReserved {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("Infisical secret(s) named [%v] use a reserved secret name or prefix and cannot be injected. Please rename them", strings.Join(reservedKeys, ", ")))
+		}
+
+		for _, reservedKey := range reservedKeys {
+			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been removed because it is a reserved secret name or has a reserved prefix", reservedKey))
+		}
+
+		if maxValueSize > 0 {
+			err = enforceMaxValueSize(secretsByKey, maxValueSize, onOversize)
+			if err != nil {
+				util.HandleError(err, "To inject the secret anyway, raise the limit with --max-value-size or use --on-oversize=truncate")
+			}
+		}
+
+		// with --secrets-as-files, your application only gets the directory holding the files of the secrets
+		var secretFiles *util.SecretFiles
+		var secretsPipe *os.File
+		env := []string{}
+		if secretsDir != "" {
+			secretsToWrite := []models.SingleEnvironmentVariable{}
+			for _, secret := range secrets {
+				if filteredSecret, ok := secretsByKey[secret.Key]; ok {
+					secretsToWrite = append(secretsToWrite, filteredSecret)
+				}
+			}
+
+			secretFiles, err = util.WriteSecretsAsFiles(secretsDir, secretsToWrite)
+			if err != nil {
+				util.HandleError(err, "Unable to write your secrets to --secrets-as-files")
+			}
+
+			secretsDirEnv := models.SingleEnvironmentVariable{Key: SECRETS_DIR_ENV_NAME, Value: secretFiles.Dir}
+			env = buildEnvironment(os.Environ(), []models.SingleEnvironmentVariable{secretsDirEnv}, map[string]models.SingleEnvironmentVariable{SECRETS_DIR_ENV_NAME: secretsDirEnv}, envOrder)
+		} else if shouldPassFd {
+			// with --pass-fd, your application reads the secrets as a dotenv file from the descriptor in INFISICAL_SECRETS_FD
+			secretsToPass := []models.SingleEnvironmentVariable{}
+			for _, secret := range secrets {
+				if filteredSecret, ok := secretsByKey[secret.Key]; ok {
+					secretsToPass = append(secretsToPass, filteredSecret)
+				}
+			}
+
+			secretsPipe, err = newSecretsPipe([]byte(util.FormatEnvFile(secretsToPass)))
+			if err != nil {
+				util.HandleError(err, "Unable to pass your secrets with --pass-fd")
+			}
+
+			secretsFdEnv := models.SingleEnvironmentVariable{Key: SECRETS_FD_ENV_NAME, Value: strconv.Itoa(SECRETS_FD)}
+			env = buildEnvironment(os.Environ(), []models.SingleEnvironmentVariable{secretsFdEnv}, map[string]models.SingleEnvironmentVariable{SECRETS_FD_ENV_NAME: secretsFdEnv}, envOrder)
+		} else {
+			env = buildEnvironment(os.Environ(), secrets, secretsByKey, envOrder)
+		}
+
+		warnIfEnvironmentTooLarge(env)
+
+		log.Debugf("injecting the following environment variables into shell: %v", env)
+
+		if shouldDetach {
+			var childCmd *exec.Cmd
+			if cmd.Flags().Changed("command") {
+				shell := getShellInvocation(shellOverride, runtime.GOOS, os.Getenv("SHELL"))
+				childCmd = exec.Command(shell[0], shell[1], cmd.Flag("command").Value.String())
+			} else {
+				childCmd = exec.Command(args[0], args[1:]...)
+			}
+			childCmd.Env = env
+			childCmd.Dir = workingDirectory
+
+			pid, err := startDetachedCmd(childCmd, logFile, pidFile)
+			if err != nil {
+				util.HandleError(err, "Unable to start your application in the background")
+			}
+
+			color.Green("Injected %v Infisical secrets into your application process running in the background with pid %d", len(secretsByKey), pid)
+			util.PrintSuccessMessage(fmt.Sprintf("Its output is written to [%s], stop it with [infisical stop --pid-file %s]", logFile, pidFile))
+			return
+		}
+
+		var stdout, stderr io.Writer = os.Stdout, os.Stderr
+		var outputCapture *util.OutputCapture
+		if captureOutput != "" {
+			valuesToRedact := []string{}
+			if shouldRedactOutput {
+				for _, secret := range secretsByKey {
+					valuesToRedact = append(valuesToRedact, secret.Value)
+				}
+			}
+
+			outputCapture, err = util.StartOutputCapture(captureOutput, captureMode, os.Stdout, os.Stderr, valuesToRedact, labels)
+			if err != nil {
+				// your application is not started, so the secret files are not left behind for it
+				if secretFiles != nil {
+					secretFiles.Remove()
+				}
+				util.HandleError(err, "Unable to capture the output of your application")
+			}
+			stdout, stderr = outputCapture.Stdout, outputCapture.Stderr
+		}
+
+		onStarted := func(pid int) error {
+			// your application holds its own copy of the read end, see newSecretsPipe
+			if secretsPipe != nil {
+				secretsPipe.Close()
+			}
+
+			if shouldClearSecrets {
+				clearSecretsFromMemory(secrets, secretsByKey, env)
+			}
+
+			if pidFile == "" {
+				return nil
+			}
+
+			err := util.WritePidFile(pidFile, pid)
+			if err != nil && isPidFileRequired {
+				return err
+			}
+
+			if err != nil {
+				util.PrintWarning(err.Error())
+			}
+			return nil
+		}
+
+		var exitCode int
+		errorMessage := "Unable to execute your single command"
+		if cmd.Flags().Changed("command") {
+			command := cmd.Flag("command").Value.String()
+			errorMessage = "Unable to execute your chained command"
+
+			exitCode, err = executeMultipleCommandWithEnvs(command, shellOverride, len(secretsByKey), env, workingDirectory, stdout, stderr, secretsPipe, onStarted)
+		} else {
+			exitCode, err = executeSingleCommandWithEnvs(args, len(secretsByKey), env, workingDirectory, stdout, stderr, secretsPipe, onStarted)
+		}
+
+		// when your application failed to start, onStarted was never called
+		if secretsPipe != nil {
+			secretsPipe.Close()
+		}
+
+		if pidFile != "" {
+			if pidFileErr := util.RemovePidFile(pidFile); pidFileErr != nil {
+				util.PrintWarning(pidFileErr.Error())
+			}
+		}
+
+		// only run once your application ran, whether it exited on its own or was killed by a signal
+		if postExecCommand != "" && err == nil {
+			postExecExitCode, postExecErr := executePostExecCommand(postExecCommand, shellOverride, exitCode, env, workingDirectory, stdout, stderr)
+			if postExecErr != nil {
+				util.PrintWarning(fmt.Sprintf("Unable to run your --post-exec command [err=%v]", postExecErr))
+				postExecExitCode = 1
+			} else if postExecExitCode != 0 {
+				util.PrintWarning(fmt.Sprintf("Your --post-exec command exited with code %d", postExecExitCode))
+			}
+
+			if shouldPostExecAffectExit && exitCode == 0 {
+				exitCode = postExecExitCode
+			}
+		}
+
+		if secretFiles != nil {
+			if removeErr := secretFiles.Remove(); removeErr != nil {
+				util.PrintWarning(removeErr.Error())
+			}
+		}
+
+		// the capture is closed before exiting so that buffered output is not lost
+		if outputCapture != nil {
+			if captureErr := outputCapture.Close(); captureErr != nil {
+				util.PrintWarning(fmt.Sprintf("The output of your application could not be fully captured to [%s] [err=%v]", captureOutput, captureErr))
+			}
+		}
 
 		if err != nil {
-			util.HandleError(err, "Could not fetch secrets", "If you are using a service token to fetch secrets, please ensure it is valid")
+			util.HandleError(err, errorMessage)
 		}
 
-		if secretOverriding {
-			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
+		// reported once more after your application exited, so that it is not buried in its output. A failing
+		// application keeps its own exit code
+		if shouldFailOnReservedCollision && len(reservedKeys) > 0 {
+			util.PrintWarning(fmt.Sprintf("Infisical secret(s) named [%v] were not injected because they use a reserved secret name or prefix", strings.Join(reservedKeys, ", ")))
+			if exitCode == 0 {
+				exitCode = util.EXIT_CODE_RESERVED_COLLISION
+			}
+		}
+
+		os.Exit(exitCode)
+	},
+}
+
+// Reads the OLD_KEY=NEW_KEY lines of a rename file. A key renamed twice or to an empty name is an error
+func readRenameFile(filePath string) (map[string]string, error) {
+	lines, err := util.ReadEnvFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	renames := make(map[string]string, len(lines))
+	for _, line := range lines {
+		if line.Value == "" {
+			return nil, fmt.Errorf("the secret [%s] is renamed to an empty name in [%s]", line.Key, filePath)
+		}
+
+		if previousName, ok := renames[line.Key]; ok {
+			return nil, fmt.Errorf("the secret [%s] is renamed twice in [%s], to [%s] and [%s]", line.Key, filePath, previousName, line.Value)
+		}
+
+		renames[line.Key] = line.Value
+	}
+
+	return renames, nil
+}
+
+// Renames the secrets found in renames and keeps the others as they are, or drops them when onlyMapped is set.
+// Two secrets ending up with the same name is an error naming both of them
+func renameSecrets(secrets []models.SingleEnvironmentVariable, renames map[string]string, onlyMapped bool) ([]models.SingleEnvironmentVariable, error) {
+	renamedSecrets := []models.SingleEnvironmentVariable{}
+	originalKeyByKey := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		originalKey := secret.Key
+		if newKey, ok := renames[secret.Key]; ok {
+			secret.Key = newKey
+		} else if onlyMapped {
+			continue
+		}
+
+		if otherOriginalKey, ok := originalKeyByKey[secret.Key]; ok {
+			return nil, fmt.Errorf("the secrets [%s] and [%s] would both be injected as [%s]", otherOriginalKey, originalKey, secret.Key)
+		}
+
+		originalKeyByKey[secret.Key] = originalKey
+		renamedSecrets = append(renamedSecrets, secret)
+	}
+
+	return renamedSecrets, nil
+}
+
+// Upper or lower cases the names of the secrets. Two secrets ending up with the same name is an error naming both of them
+func changeSecretsCase(secrets []models.SingleEnvironmentVariable, envCase string) ([]models.SingleEnvironmentVariable, error) {
+	changedSecrets := make([]models.SingleEnvironmentVariable, 0, len(secrets))
+	originalKeyByKey := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		originalKey := secret.Key
+		if envCase == ENV_CASE_UPPER {
+			secret.Key = strings.ToUpper(secret.Key)
 		} else {
-			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+			secret.Key = strings.ToLower(secret.Key)
 		}
 
-		if shouldExpandSecrets {
-			secrets = util.SubstituteSecrets(secrets)
+		if otherOriginalKey, ok := originalKeyByKey[secret.Key]; ok {
+			return nil, fmt.Errorf("the secrets [%s] and [%s] would both be injected as [%s]", otherOriginalKey, originalKey, secret.Key)
 		}
 
-		secretsByKey := getSecretsByKeys(secrets)
-		environmentVariables := make(map[string]string)
+		originalKeyByKey[secret.Key] = originalKey
+		changedSecrets = append(changedSecrets, secret)
+	}
+
+	return changedSecrets, nil
+}
+
+// Names the secrets prefix + name + suffix, for example to load two sets of secrets into the same process. Two secrets ending
+// up with the same name, which only happens when they already shared their name, is an error naming both of them
+func affixSecretNames(secrets []models.SingleEnvironmentVariable, prefix string, suffix string) ([]models.SingleEnvironmentVariable, error) {
+	if strings.Contains(prefix+suffix, "=") {
+		return nil, fmt.Errorf("--secret-prefix and --secret-suffix cannot contain =, which cannot be part of an environment variable name")
+	}
+
+	affixedSecrets := make([]models.SingleEnvironmentVariable, 0, len(secrets))
+	originalKeyByKey := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		originalKey := secret.Key
+		secret.Key = prefix + secret.Key + suffix
 
-		// add all existing environment vars
-		for _, s := range os.Environ() {
-			kv := strings.SplitN(s, "=", 2)
-			key := kv[0]
-			value := kv[1]
-			environmentVariables[key] = value
+		if otherOriginalKey, ok := originalKeyByKey[secret.Key]; ok {
+			return nil, fmt.Errorf("the secrets [%s] and [%s] would both be named [%s]", otherOriginalKey, originalKey, secret.Key)
 		}
 
-		// check to see if there are any reserved key words in secrets to inject
-		filterReservedEnvVars(secretsByKey)
+		originalKeyByKey[secret.Key] = originalKey
+		affixedSecrets = append(affixedSecrets, secret)
+	}
+
+	return affixedSecrets, nil
+}
+
+// Reads --secret-prefix and --secret-suffix
+func getSecretAffixes(cmd *cobra.Command) (string, string) {
+	prefix, err := cmd.Flags().GetString("secret-prefix")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	suffix, err := cmd.Flags().GetString("secret-suffix")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	return prefix, suffix
+}
+
+// Reads --expand-source, of which --expand-from-env is a shorthand for both
+func getExpandSource(cmd *cobra.Command) string {
+	expandSource, err := cmd.Flags().GetString("expand-source")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	shouldExpandFromEnv, err := cmd.Flags().GetBool("expand-from-env")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if shouldExpandFromEnv {
+		if cmd.Flags().Changed("expand-source") && expandSource != util.EXPAND_SOURCE_BOTH {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--expand-from-env cannot be used together with --expand-source=%s", expandSource))
+		}
+		expandSource = util.EXPAND_SOURCE_BOTH
+	}
+
+	if expandSource != util.EXPAND_SOURCE_SECRET && expandSource != util.EXPAND_SOURCE_ENV && expandSource != util.EXPAND_SOURCE_BOTH {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --expand-source. Available options are [%s]", expandSource, strings.Join([]string{util.EXPAND_SOURCE_SECRET, util.EXPAND_SOURCE_ENV, util.EXPAND_SOURCE_BOTH}, ", ")))
+	}
+
+	return expandSource
+}
+
+// Exits when the ${KEY} references of secrets form a cycle, which cannot be expanded. References are only followed between
+// secrets, not with --expand-source=env
+func exitOnReferenceCycle(secrets []models.SingleEnvironmentVariable, expandSource string) {
+	if expandSource == util.EXPAND_SOURCE_ENV {
+		return
+	}
+
+	if cycle := util.FindReferenceCycle(secrets); cycle != nil {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("the references of secrets [%s] form a cycle and cannot be expanded, remove one of the references", strings.Join(cycle, " -> ")))
+	}
+}
 
-		// now add infisical secrets
-		for k, v := range secretsByKey {
-			environmentVariables[k] = v.Value
+// Reads --decode and --decode-keys. No keys are decoded when --decode is not set
+func getDecodeOptions(cmd *cobra.Command) (string, []string) {
+	encoding, err := cmd.Flags().GetString("decode")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	decodeKeys, err := cmd.Flags().GetStringSlice("decode-keys")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if encoding == "" && len(decodeKeys) > 0 {
+		util.PrintErrorMessageAndExit("--decode-keys can only be used together with --decode")
+	}
+
+	if encoding == "" {
+		return "", nil
+	}
+
+	if encoding != util.SECRET_VALUE_ENCODING_BASE64 {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --decode. Available options are [%s]", encoding, util.SECRET_VALUE_ENCODING_BASE64))
+	}
+
+	if len(decodeKeys) == 0 {
+		util.PrintErrorMessageAndExit("--decode requires the secrets to decode, set them with --decode-keys (e.g. --decode-keys CERT,KEY or --decode-keys '*')")
+	}
+
+	return encoding, decodeKeys
+}
+
+// The --fetch-hook command whose output is merged into the fetched secrets, and how
+type fetchHookOptions struct {
+	command string
+	format  string
+	// the secrets of the hook override the fetched secrets of the same name
+	hasPriority bool
+	// the fetched secrets are passed to the hook in its environment
+	shouldInherit bool
+}
+
+// Reads --fetch-hook, --fetch-hook-format, --hook-priority and --hook-inherit. The command is empty when --fetch-hook is not set
+func getFetchHookOptions(cmd *cobra.Command) fetchHookOptions {
+	command, err := cmd.Flags().GetString("fetch-hook")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	format, err := cmd.Flags().GetString("fetch-hook-format")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	hasPriority, err := cmd.Flags().GetBool("hook-priority")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	shouldInherit, err := cmd.Flags().GetBool("hook-inherit")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if command == "" && (cmd.Flags().Changed("fetch-hook-format") || hasPriority || shouldInherit) {
+		util.PrintErrorMessageAndExit("--fetch-hook-format, --hook-priority and --hook-inherit can only be used together with --fetch-hook")
+	}
+
+	hookFormats := []string{util.SECRETS_INPUT_FORMAT_AUTO, util.SECRETS_INPUT_FORMAT_DOTENV, util.SECRETS_INPUT_FORMAT_JSON, util.SECRETS_INPUT_FORMAT_YAML}
+	if format != util.SECRETS_INPUT_FORMAT_AUTO && format != util.SECRETS_INPUT_FORMAT_DOTENV && format != util.SECRETS_INPUT_FORMAT_JSON && format != util.SECRETS_INPUT_FORMAT_YAML {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --fetch-hook-format. Available options are [%s]", format, strings.Join(hookFormats, ", ")))
+	}
+
+	return fetchHookOptions{command: command, format: format, hasPriority: hasPriority, shouldInherit: shouldInherit}
+}
+
+// Runs the --fetch-hook command with $SHELL, or cmd on Windows, and parses its stdout as secrets. Its stderr is passed through.
+// The hook gets the environment of the CLI, with the fetched secrets added only when they are inherited
+func runFetchHook(options fetchHookOptions, secrets []models.SingleEnvironmentVariable) ([]models.SingleEnvironmentVariable, error) {
+	shell := getShellInvocation("", runtime.GOOS, os.Getenv("SHELL"))
+
+	cmd := exec.Command(shell[0], shell[1], options.command)
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if options.shouldInherit {
+		for _, secret := range secrets {
+			cmd.Env = append(cmd.Env, secret.Key+"="+secret.Value)
 		}
+	}
+
+	log.Debugf("executing fetch hook: %s %s %s \n", shell[0], shell[1], options.command)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("the --fetch-hook command failed [err=%v]", err)
+	}
+
+	format := options.format
+	if format == util.SECRETS_INPUT_FORMAT_AUTO {
+		format = util.DetectSecretsFormat("", output)
+	}
+
+	hookSecrets, err := util.ParseSecrets(output, format)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the output of the --fetch-hook command as %s [err=%v]", format, err)
+	}
 
-		// turn it back into a list of envs
-		var env []string
-		for key, value := range environmentVariables {
-			s := key + "=" + value
-			env = append(env, s)
+	return hookSecrets, nil
+}
+
+// Adds the secrets of the fetch hook to the fetched secrets. A fetched secret keeps its value over the hook secret of the same name,
+// unless the hook has priority
+func mergeFetchHookSecrets(secrets []models.SingleEnvironmentVariable, hookSecrets []models.SingleEnvironmentVariable, hasPriority bool) []models.SingleEnvironmentVariable {
+	if hasPriority {
+		return mergeEnvFileSecrets(secrets, hookSecrets, false)
+	}
+
+	mergedSecrets := append([]models.SingleEnvironmentVariable{}, secrets...)
+	secretsByKey := getSecretsByKeys(mergedSecrets)
+	for _, hookSecret := range hookSecrets {
+		if _, ok := secretsByKey[hookSecret.Key]; ok {
+			continue
 		}
 
-		log.Debugf("injecting the following environment variables into shell: %v", env)
+		secretsByKey[hookSecret.Key] = hookSecret
+		mergedSecrets = append(mergedSecrets, hookSecret)
+	}
 
-		if cmd.Flags().Changed("command") {
-			command := cmd.Flag("command").Value.String()
+	return mergedSecrets
+}
 
-			err = executeMultipleCommandWithEnvs(command, len(secretsByKey), env)
-			if err != nil {
-				util.HandleError(err, "Unable to execute your chained command")
-			}
+// Overrides the fetched secrets with the values of the env file. When expanding, ${KEY} references in the env file values are resolved
+// against the merged secrets so that local overrides can be built from fetched secrets. Fetched secrets are never expanded here
+func mergeEnvFileSecrets(secrets []models.SingleEnvironmentVariable, envFileSecrets []models.SingleEnvironmentVariable, shouldExpand bool) []models.SingleEnvironmentVariable {
+	mergedSecrets := append([]models.SingleEnvironmentVariable{}, secrets...)
+	isFromEnvFile := make([]bool, len(mergedSecrets))
+	indexByKey := make(map[string]int, len(mergedSecrets))
+	for idx, secret := range mergedSecrets {
+		indexByKey[secret.Key] = idx
+	}
 
-		} else {
-			err = executeSingleCommandWithEnvs(args, len(secretsByKey), env)
-			if err != nil {
-				util.HandleError(err, "Unable to execute your single command")
-			}
+	for _, envFileSecret := range envFileSecrets {
+		if idx, ok := indexByKey[envFileSecret.Key]; ok {
+			mergedSecrets[idx].Value = envFileSecret.Value
+			isFromEnvFile[idx] = true
+			continue
 		}
-	},
+
+		indexByKey[envFileSecret.Key] = len(mergedSecrets)
+		mergedSecrets = append(mergedSecrets, envFileSecret)
+		isFromEnvFile = append(isFromEnvFile, true)
+	}
+
+	if !shouldExpand {
+		return mergedSecrets
+	}
+
+	expandedSecrets := util.SubstituteSecrets(mergedSecrets)
+	for idx := range mergedSecrets {
+		if isFromEnvFile[idx] {
+			mergedSecrets[idx].Value = expandedSecrets[idx].Value
+		}
+	}
+
+	return mergedSecrets
+}
+
+// Moves the keys of the env file to the front in the order of its lines, followed by the remaining secrets in the order they were fetched
+func orderSecretsByEnvFile(secrets []models.SingleEnvironmentVariable, envFileSecrets []models.SingleEnvironmentVariable) []models.SingleEnvironmentVariable {
+	secretsByKey := make(map[string]models.SingleEnvironmentVariable, len(secrets))
+	for _, secret := range secrets {
+		secretsByKey[secret.Key] = secret
+	}
+
+	orderedSecrets := make([]models.SingleEnvironmentVariable, 0, len(secrets))
+	isOrdered := make(map[string]bool, len(envFileSecrets))
+	for _, envFileSecret := range envFileSecrets {
+		secret, ok := secretsByKey[envFileSecret.Key]
+		if !ok || isOrdered[envFileSecret.Key] {
+			continue
+		}
+		orderedSecrets = append(orderedSecrets, secret)
+		isOrdered[envFileSecret.Key] = true
+	}
+
+	for _, secret := range secrets {
+		if !isOrdered[secret.Key] {
+			orderedSecrets = append(orderedSecrets, secret)
+		}
+	}
+
+	return orderedSecrets
 }
 
 var (
@@ -156,22 +1094,218 @@ var (
 	}
 )
 
-func filterReservedEnvVars(env map[string]models.SingleEnvironmentVariable) {
-	for _, reservedEnvName := range reservedEnvVars {
-		if _, ok := env[reservedEnvName]; ok {
-			delete(env, reservedEnvName)
-			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been removed because it is a reserved secret name", reservedEnvName))
+// Removes the secrets that would override reserved environment variables and returns their names sorted
+func filterReservedEnvVars(env map[string]models.SingleEnvironmentVariable) []string {
+	reservedNames := make(map[string]bool)
+	for _, reservedEnvName := range append(append([]string{}, reservedEnvVars...), platformReservedEnvVars...) {
+		reservedNames[normalizeEnvName(reservedEnvName)] = true
+	}
+
+	droppedKeys := []string{}
+	for envName := range env {
+		isReserved := reservedNames[normalizeEnvName(envName)]
+		for _, reservedEnvPrefix := range reservedEnvVarPrefixes {
+			isReserved = isReserved || strings.HasPrefix(normalizeEnvName(envName), normalizeEnvName(reservedEnvPrefix))
+		}
+
+		if isReserved {
+			delete(env, envName)
+			droppedKeys = append(droppedKeys, envName)
 		}
 	}
 
-	for _, reservedEnvPrefix := range reservedEnvVarPrefixes {
-		for envName := range env {
-			if strings.HasPrefix(envName, reservedEnvPrefix) {
-				delete(env, envName)
-				util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been removed because it contains a reserved prefix", envName))
-			}
+	sort.Strings(droppedKeys)
+	return droppedKeys
+}
+
+// Returns the name under which the OS looks up an environment variable
+func normalizeEnvName(name string) string {
+	if envNamesAreCaseInsensitive {
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+const (
+	ON_OVERSIZE_WARN     = "warn"
+	ON_OVERSIZE_ERROR    = "error"
+	ON_OVERSIZE_TRUNCATE = "truncate"
+)
+
+const (
+	ENV_ORDER_SORTED     = "sorted"
+	ENV_ORDER_AS_FETCHED = "as-fetched"
+	ENV_ORDER_FILE       = "file"
+)
+
+const (
+	ENV_CASE_PRESERVE = "preserve"
+	ENV_CASE_UPPER    = "upper"
+	ENV_CASE_LOWER    = "lower"
+)
+
+// where --detach writes the pid and the output of your application when --pid-file and --log-file are not set
+const (
+	DETACH_DEFAULT_PID_FILE = "infisical-run.pid"
+	DETACH_DEFAULT_LOG_FILE = "infisical-run.log"
+)
+
+// holds the directory of the files written by --secrets-as-files
+const SECRETS_DIR_ENV_NAME = "INFISICAL_SECRETS_DIR"
+
+// holds the file descriptor your application reads the secrets from with --pass-fd
+const SECRETS_FD_ENV_NAME = "INFISICAL_SECRETS_FD"
+
+// the descriptor of the first of exec.Cmd.ExtraFiles, after stdin, stdout and stderr
+const SECRETS_FD = 3
+
+// holds the exit code of your application for the --post-exec command
+const POST_EXEC_CHILD_EXIT_ENV_NAME = "INFISICAL_CHILD_EXIT"
+
+// Merges the secrets into the existing environment and returns it as a KEY=value list in a deterministic order.
+// Go randomizes map iteration, so the order is always rebuilt from the inputs instead of from secretsByKey.
+// secretsByKey holds the secrets that survived filtering and is the source of truth for their values
+func buildEnvironment(existingEnv []string, secrets []models.SingleEnvironmentVariable, secretsByKey map[string]models.SingleEnvironmentVariable, order string) []string {
+	keys := []string{}
+	names := make(map[string]string)
+	values := make(map[string]string)
+
+	for _, entry := range existingEnv {
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key := normalizeEnvName(kv[0])
+		if _, exists := values[key]; !exists {
+			keys = append(keys, key)
+			names[key] = kv[0]
+		}
+		values[key] = kv[1]
+	}
+
+	// secrets overriding an existing variable keep the position and, where names are case-insensitive, the spelling of that variable
+	for _, secret := range secrets {
+		filteredSecret, ok := secretsByKey[secret.Key]
+		if !ok {
+			continue
+		}
+
+		key := normalizeEnvName(secret.Key)
+		if _, exists := values[key]; !exists {
+			keys = append(keys, key)
+			names[key] = secret.Key
+		}
+		values[key] = filteredSecret.Value
+	}
+
+	if order == ENV_ORDER_SORTED {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return names[keys[i]] < names[keys[j]]
+		})
+	}
+
+	env := make([]string, 0, len(keys))
+	for _, key := range keys {
+		env = append(env, names[key]+"="+values[key])
+	}
+
+	return env
+}
+
+// Drops every reference the CLI holds to the secrets once the child process has its own copy, and hands the memory back to
+// the OS. Go strings are immutable so the values cannot be overwritten in place: they stay in memory until the garbage
+// collector reuses it, and the child process still has them in its environment
+func clearSecretsFromMemory(secrets []models.SingleEnvironmentVariable, secretsByKey map[string]models.SingleEnvironmentVariable, env []string) {
+	for idx := range secrets {
+		secrets[idx] = models.SingleEnvironmentVariable{}
+	}
+
+	for key := range secretsByKey {
+		delete(secretsByKey, key)
+	}
+
+	// env is the backing array of the exec.Cmd environment, which is no longer needed once the process started
+	for idx := range env {
+		env[idx] = ""
+	}
+
+	debug.FreeOSMemory()
+}
+
+// Checks every secret value against the max size and warns, errors or truncates depending on the policy
+func enforceMaxValueSize(env map[string]models.SingleEnvironmentVariable, maxValueSize int, policy string) error {
+	oversizedKeys := []string{}
+	for key, secret := range env {
+		if len(secret.Value) <= maxValueSize {
+			continue
+		}
+
+		switch policy {
+		case ON_OVERSIZE_ERROR:
+			oversizedKeys = append(oversizedKeys, key)
+		case ON_OVERSIZE_TRUNCATE:
+			truncatedValue := truncateToValidUTF8(secret.Value, maxValueSize)
+			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] has been truncated from %d to %d bytes because it exceeds the max value size", key, len(secret.Value), len(truncatedValue)))
+			secret.Value = truncatedValue
+			env[key] = secret
+		default:
+			util.PrintWarning(fmt.Sprintf("Infisical secret named [%v] is %d bytes which exceeds the max value size of %d bytes", key, len(secret.Value), maxValueSize))
 		}
 	}
+
+	if len(oversizedKeys) > 0 {
+		sort.Strings(oversizedKeys)
+		return fmt.Errorf("the following secrets exceed the max value size of %d bytes: [%s]", maxValueSize, strings.Join(oversizedKeys, ", "))
+	}
+
+	return nil
+}
+
+// Cuts the string down to at most maxBytes without splitting a multi-byte character
+func truncateToValidUTF8(value string, maxBytes int) string {
+	if len(value) <= maxBytes {
+		return value
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+
+	return value[:cut]
+}
+
+// Warns when the environment we are about to pass to the child process gets close to what the OS allows
+func warnIfEnvironmentTooLarge(env []string) {
+	limit := getEnvironmentSizeLimit()
+	if limit == 0 {
+		return
+	}
+
+	size := 0
+	for _, entry := range env {
+		// each entry is null terminated and referenced by a pointer in envp
+		size += len(entry) + 1 + 8
+	}
+
+	if size > limit*9/10 {
+		util.PrintWarning(fmt.Sprintf("The environment passed to your application is %d bytes which is close to the limit of roughly %d bytes on %s. Your application may fail to start", size, limit, runtime.GOOS))
+	}
+}
+
+// Returns an approximation of the max combined size of arguments and environment (ARG_MAX). 0 means we do not know the limit
+func getEnvironmentSizeLimit() int {
+	switch runtime.GOOS {
+	case "linux":
+		return 2097152
+	case "darwin":
+		return 1048576
+	case "freebsd", "openbsd", "netbsd":
+		return 262144
+	default:
+		return 0
+	}
 }
 
 func init() {
@@ -179,71 +1313,238 @@ func init() {
 	runCmd.Flags().String("token", "", "Fetch secrets using the Infisical Token")
 	runCmd.Flags().StringP("env", "e", "dev", "Set the environment (dev, prod, etc.) from which your secrets should be pulled from")
 	runCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets")
+	runCmd.Flags().String("expand-source", util.EXPAND_SOURCE_SECRET, "where the ${KEY} references of secrets are resolved from (secret, env, both). both looks up the secret of that name first and the environment variable otherwise")
+	runCmd.Flags().Bool("expand-from-env", false, "resolve the ${KEY} references that are not secrets from the environment, same as --expand-source=both")
+	runCmd.Flags().String("decode", "", "decode the values of the secrets set by --decode-keys before they are injected (base64), e.g. for binary certificates stored as text")
+	runCmd.Flags().StringSlice("decode-keys", []string{}, "names of the secrets decoded by --decode, comma separated or repeated. * decodes every secret")
+	runCmd.Flags().String("fetch-hook", "", "a command whose output is parsed as secrets and added to the fetched secrets, run with $SHELL, or cmd on Windows")
+	runCmd.Flags().String("fetch-hook-format", util.SECRETS_INPUT_FORMAT_DOTENV, "the format of the output of --fetch-hook (auto, dotenv, json, yaml)")
+	runCmd.Flags().Bool("hook-priority", false, "let the secrets of --fetch-hook override the fetched secrets of the same name")
+	runCmd.Flags().Bool("hook-inherit", false, "pass the fetched secrets to --fetch-hook in its environment")
 	runCmd.Flags().Bool("secret-overriding", true, "Prioritizes personal secrets, if any, with the same name over shared secrets")
 	runCmd.Flags().StringP("command", "c", "", "chained commands to execute (e.g. \"npm install && npm run dev; echo ...\")")
+	runCmd.Flags().String("post-exec", "", "command to run with the same shell and secrets once your application exited, e.g. to flush logs. The exit code of your application is in $"+POST_EXEC_CHILD_EXIT_ENV_NAME)
+	runCmd.Flags().Bool("post-exec-affects-exit", false, "exit with the code of a failing --post-exec command when your application exited successfully")
+	runCmd.Flags().String("shell", "", "the shell that runs --command (e.g. /bin/bash, pwsh). Defaults to $SHELL, or cmd on Windows")
 	runCmd.Flags().StringP("tags", "t", "", "filter secrets by tag slugs ")
+	runCmd.Flags().StringArray("path", []string{"/"}, "folder to fetch the secrets of (can be repeated). ${VAR} is replaced with the environment variable VAR. Secrets of later paths override secrets of the same name of earlier ones")
+	runCmd.Flags().Bool("allow-empty-path-vars", false, "remove the ${VAR} references of --path to environment variables that are not set or empty instead of failing")
+	runCmd.Flags().String("on-fetch-error", util.FETCH_ERROR_POLICY_FAIL, "what to do when secrets cannot be fetched (fail, warn, use-cache). use-cache falls back to the secrets of the last successful fetch")
+	runCmd.Flags().String("chdir", "", "change the working directory of your application before it is started. Does not affect the directory the CLI runs in")
+	runCmd.Flags().Int("max-value-size", 0, "max size in bytes of a single secret value. Secrets exceeding it are handled according to --on-oversize (0 disables the check)")
+	runCmd.Flags().String("on-oversize", ON_OVERSIZE_WARN, "what to do with secrets exceeding --max-value-size (warn, error, truncate)")
+	runCmd.Flags().String("env-order", ENV_ORDER_SORTED, "order in which environment variables are passed to your application (sorted, as-fetched, file). file requires --env-file")
+	runCmd.Flags().String("env-case", ENV_CASE_PRESERVE, "case of the names of the secrets injected into your application (preserve, upper, lower). Secrets whose names only differ by case are an error with upper and lower")
+	runCmd.Flags().String("secret-prefix", "", "prepended to the names of the secrets injected into your application, after --env-case (e.g. APP_)")
+	runCmd.Flags().String("secret-suffix", "", "appended to the names of the secrets injected into your application, after --env-case (e.g. _PROD)")
+	runCmd.Flags().Bool("preserve-env-order", false, "pass environment variables in the order they were inherited and fetched. Same as --env-order=as-fetched")
+	runCmd.Flags().String("secrets-from-json", "", "inject the secrets of a {\"KEY\": \"value\"} JSON file instead of fetching them from Infisical. Use - to read from stdin")
+	runCmd.Flags().String("stdin-secrets-format", util.SECRETS_INPUT_FORMAT_JSON, "format of the secrets given to --secrets-from-json (json, yaml, dotenv)")
+	runCmd.Flags().String("env-file", "", "path to a dotenv file whose values override the fetched secrets, useful for local overrides")
+	runCmd.Flags().String("env-file-format", util.SECRETS_INPUT_FORMAT_DOTENV, "the format of --env-file (auto, dotenv, json, yaml). auto infers it from the extension of the file, or from its content")
+	runCmd.Flags().Bool("env-file-expand", false, "resolve ${KEY} references in the values of --env-file against the fetched secrets and the env file itself")
+	runCmd.Flags().String("capture-output", "", "also write the stdout and stderr of your application to this file, created readable by the current user only")
+	runCmd.Flags().String("capture-mode", util.CAPTURE_MODE_COMBINED, "how --capture-output stores the output (combined, separate). separate writes to <file>.stdout and <file>.stderr")
+	runCmd.Flags().Bool("redact-output", false, "mask the values of your secrets in the output written to --capture-output")
+	runCmd.Flags().StringArray("label", []string{}, "label in the KEY=value format prepended to every line written to --capture-output, e.g. --label deploy=abc123. Can be repeated")
+	runCmd.Flags().String("rename-file", "", "rename secrets before they are injected using a file of OLD_KEY=NEW_KEY lines")
+	runCmd.Flags().Bool("rename-only-mapped", false, "only inject the secrets listed in --rename-file")
+	runCmd.Flags().Bool("strict-reserved", false, "fail instead of dropping secrets that use a reserved environment variable name (e.g. PATH) or prefix (e.g. XDG_)")
+	runCmd.Flags().Bool("fail-on-reserved-collision", false, "still run your application when secrets use a reserved name, but exit with code 4 once it exited successfully")
+	runCmd.Flags().String("pid-file", "", "write the pid of your application to this file once it started so that it can be signaled by other tools. The file is removed when your application exits")
+	runCmd.Flags().Bool("pid-file-required", false, "fail and stop your application when --pid-file cannot be written instead of only warning")
+	runCmd.Flags().String("secrets-as-files", "", "write every secret to a file named after it in this directory (e.g. /run/secrets) instead of injecting it as an environment variable. "+SECRETS_DIR_ENV_NAME+" holds the directory, the files are removed once your application exited")
+	runCmd.Flags().Bool("pass-fd", false, "pass the secrets to your application as a dotenv file it reads from the file descriptor in "+SECRETS_FD_ENV_NAME+", instead of injecting them as environment variables. Linux and macOS only")
+	runCmd.Flags().Bool("detach", false, "start your application in the background and return once it started. Its pid is written to --pid-file ("+DETACH_DEFAULT_PID_FILE+" by default) so that it can be stopped with [infisical stop]")
+	runCmd.Flags().String("log-file", "", "file the output of your application started with --detach is appended to, created readable by the current user only ("+DETACH_DEFAULT_LOG_FILE+" by default)")
+	runCmd.Flags().String("umask", "", "set the umask of your application and of the files the CLI writes, e.g. 0077 so that nothing is readable by other users. The inherited umask is kept by default. Has no effect on Windows")
+	runCmd.Flags().Bool("clear-secrets-after-spawn", false, "drop the secrets held by the CLI once your application started, so they do not stay in its memory while your application runs. Your application keeps its own copy")
+	runCmd.Flags().StringSlice("wait-for", []string{}, "wait until the given host:port accepts TCP connections before starting your application (can be repeated)")
+	runCmd.Flags().StringSlice("wait-for-http", []string{}, "wait until the given url responds with a successful status code before starting your application (can be repeated)")
+	runCmd.Flags().Duration("wait-timeout", 30*time.Second, "maximum time to wait for the dependencies set by --wait-for and --wait-for-http")
+}
+
+// Parses an octal umask such as 0077 or 077
+func parseUmask(value string) (int, error) {
+	mask, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mask > 0777 {
+		return 0, fmt.Errorf("it must be an octal number between 000 and 777, e.g. 0077")
+	}
+
+	return int(mask), nil
+}
+
+// Returns an error when command cannot be started. Like exec.Cmd, a command containing a path separator is resolved from
+// workingDirectory when relative, other commands are looked up in PATH
+func checkExecutableExists(command string, workingDirectory string) error {
+	executablePath := command
+	if strings.ContainsAny(command, `/\`) && !filepath.IsAbs(command) && workingDirectory != "" {
+		executablePath = filepath.Join(workingDirectory, command)
+	}
+
+	if _, err := exec.LookPath(executablePath); err != nil {
+		log.Debugf("unable to find executable [%s] [err=%v]", executablePath, err)
+		return fmt.Errorf("command not found: %s", command)
+	}
+
+	return nil
 }
 
 // Will execute a single command and pass in the given secrets into the process
-func executeSingleCommandWithEnvs(args []string, secretsCount int, env []string) error {
+func executeSingleCommandWithEnvs(args []string, secretsCount int, env []string, workingDirectory string, stdout io.Writer, stderr io.Writer, secretsPipe *os.File, onStarted func(pid int) error) (int, error) {
 	command := args[0]
 	argsForCommand := args[1:]
 	color.Green("Injecting %v Infisical secrets into your application process", secretsCount)
 
 	cmd := exec.Command(command, argsForCommand...)
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Env = env
+	cmd.Dir = workingDirectory
+	passSecretsPipe(cmd, secretsPipe)
 
-	return execCmd(cmd)
+	return execCmd(cmd, onStarted)
 }
 
-func executeMultipleCommandWithEnvs(fullCommand string, secretsCount int, env []string) error {
-	shell := [2]string{"sh", "-c"}
-	if runtime.GOOS == "windows" {
-		shell = [2]string{"cmd", "/C"}
-	} else {
-		currentShell := os.Getenv("SHELL")
-		if currentShell != "" {
-			shell[0] = currentShell
-		}
-	}
+func executeMultipleCommandWithEnvs(fullCommand string, shellOverride string, secretsCount int, env []string, workingDirectory string, stdout io.Writer, stderr io.Writer, secretsPipe *os.File, onStarted func(pid int) error) (int, error) {
+	shell := getShellInvocation(shellOverride, runtime.GOOS, os.Getenv("SHELL"))
 
 	cmd := exec.Command(shell[0], shell[1], fullCommand)
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Env = env
+	cmd.Dir = workingDirectory
+	passSecretsPipe(cmd, secretsPipe)
 
 	color.Green("Injecting %v Infisical secrets into your application process", secretsCount)
 	log.Debugf("executing command: %s %s %s \n", shell[0], shell[1], fullCommand)
 
-	return execCmd(cmd)
+	return execCmd(cmd, onStarted)
+}
+
+// The first of ExtraFiles is file descriptor 3 of the process, see SECRETS_FD
+func passSecretsPipe(cmd *exec.Cmd, secretsPipe *os.File) {
+	if secretsPipe != nil {
+		cmd.ExtraFiles = []*os.File{secretsPipe}
+	}
+}
+
+// Returns the shell that runs --command and the argument after which it expects the command. Without --shell, $SHELL is
+// used and sh when it is not set, or cmd on Windows. The command is passed as a single argument and never escaped, so it
+// is parsed with the quoting rules of whichever shell runs it
+func getShellInvocation(shellOverride string, goos string, currentShell string) [2]string {
+	shell := shellOverride
+	if shell == "" {
+		if goos == "windows" {
+			return [2]string{"cmd", "/C"}
+		}
+
+		shell = currentShell
+		if shell == "" {
+			shell = "sh"
+		}
+	}
+
+	// the shell may be given as a path, with the .exe suffix on Windows
+	shellName := strings.TrimSuffix(strings.ToLower(shell[strings.LastIndexAny(shell, `/\`)+1:]), ".exe")
+	switch shellName {
+	case "cmd":
+		return [2]string{shell, "/C"}
+	case "pwsh", "powershell":
+		return [2]string{shell, "-Command"}
+	default:
+		return [2]string{shell, "-c"}
+	}
+}
+
+// Runs the --post-exec command with the same shell and secrets as --command, plus the exit code of your application in INFISICAL_CHILD_EXIT
+func executePostExecCommand(postExecCommand string, shellOverride string, childExitCode int, env []string, workingDirectory string, stdout io.Writer, stderr io.Writer) (int, error) {
+	shell := getShellInvocation(shellOverride, runtime.GOOS, os.Getenv("SHELL"))
+
+	cmd := exec.Command(shell[0], shell[1], postExecCommand)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(append([]string{}, env...), fmt.Sprintf("%s=%d", POST_EXEC_CHILD_EXIT_ENV_NAME, childExitCode))
+	cmd.Dir = workingDirectory
+
+	log.Debugf("executing post exec command: %s %s %s \n", shell[0], shell[1], postExecCommand)
+
+	return execCmd(cmd, nil)
+}
+
+// Starts cmd in its own session without waiting for it, with its output appended to logFile and its pid written to pidFile.
+// The process is killed when its pid cannot be written, as it could not be stopped with [infisical stop] otherwise
+func startDetachedCmd(cmd *exec.Cmd, logFile string, pidFile string) (int, error) {
+	// readable by the current user only, the output of your application may contain secrets
+	output, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open log file [%s] [err=%v]", logFile, err)
+	}
+	defer output.Close()
+
+	cmd.Stdin = nil
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.SysProcAttr = getDetachedSysProcAttr()
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	pid := cmd.Process.Pid
+	if err := util.WritePidFile(pidFile, pid); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return 0, err
+	}
+
+	return pid, cmd.Process.Release()
 }
 
-// Credit: inspired by AWS Valut
-func execCmd(cmd *exec.Cmd) error {
+// Credit: inspired by AWS Valut. Returns the exit code of the command so that the caller can clean up before exiting with it.
+// onStarted, if set, is called with the pid of the process once it runs. The process is killed when it returns an error
+func execCmd(cmd *exec.Cmd, onStarted func(pid int) error) (int, error) {
 	sigChannel := make(chan os.Signal, 1)
 	signal.Notify(sigChannel)
 
 	if err := cmd.Start(); err != nil {
-		return err
+		return 0, err
+	}
+
+	if onStarted != nil {
+		if err := onStarted(cmd.Process.Pid); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return 0, err
+		}
 	}
 
 	go func() {
 		for {
 			sig := <-sigChannel
+			// raised by writes of the CLI to a closed pipe, such as the one of --pass-fd, it is not meant for your application
+			if sig == syscall.SIGPIPE {
+				continue
+			}
 			_ = cmd.Process.Signal(sig) // process all sigs
 		}
 	}()
 
+	// a process exiting with a non zero code or killed by a signal is not a failure to run it
 	if err := cmd.Wait(); err != nil {
-		_ = cmd.Process.Signal(os.Kill)
-		return fmt.Errorf("failed to wait for command termination: %v", err)
+		if _, isExitError := err.(*exec.ExitError); !isExitError {
+			_ = cmd.Process.Signal(os.Kill)
+			return 0, fmt.Errorf("failed to wait for command termination: %v", err)
+		}
 	}
 
 	waitStatus := cmd.ProcessState.Sys().(syscall.WaitStatus)
-	os.Exit(waitStatus.ExitStatus())
-	return nil
+	if waitStatus.Signaled() {
+		// same exit code as a shell reports for a process killed by a signal
+		return 128 + int(waitStatus.Signal()), nil
+	}
+	return waitStatus.ExitStatus(), nil
 }
@@ -0,0 +1,117 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/fatih/color"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var scanCmd = &cobra.Command{
+	Use:                   "scan [directory]",
+	Short:                 "Used to scan files for hardcoded secrets",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical scan\ninfisical scan ./src --fail",
+	Args:                  cobra.MaximumNArgs(1),
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		directory := "."
+		if len(args) == 1 {
+			directory = args[0]
+		}
+
+		if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("the path [%s] is not a directory", directory))
+		}
+
+		shouldFail, err := cmd.Flags().GetBool("fail")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldMatchSecrets, err := cmd.Flags().GetBool("match-secrets")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		infisicalToken, err := cmd.Flags().GetString("token")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		knownSecrets := []models.SingleEnvironmentVariable{}
+		if shouldMatchSecrets {
+			knownSecrets = getSecretsToScanFor(environmentName, infisicalToken)
+		}
+
+		findings, err := util.ScanDirectory(directory, knownSecrets)
+		if err != nil {
+			util.HandleError(err, "Unable to scan directory")
+		}
+
+		util.SortScanFindings(findings)
+
+		for _, finding := range findings {
+			fmt.Printf("%s:%d: %s (%s)\n", finding.File, finding.Line, finding.Description, color.YellowString(finding.RuleId))
+		}
+
+		if len(findings) == 0 {
+			util.PrintSuccessMessage("No secrets found")
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "\n%d potential secret(s) found. To ignore a false positive, add its location (for example %s) to %s\n", len(findings), findings[0].Fingerprint(), util.INFISICAL_SCAN_IGNORE_FILE_NAME)
+
+		if shouldFail {
+			os.Exit(1)
+		}
+	},
+}
+
+// Fetches the secrets of the current project so that their values can be looked for. Scanning still works for users that are not logged in
+func getSecretsToScanFor(environmentName string, infisicalToken string) []models.SingleEnvironmentVariable {
+	configFile, _ := util.GetConfigFile()
+	if infisicalToken == "" && os.Getenv(util.INFISICAL_TOKEN_NAME) == "" && configFile.ServiceTokenStore == "" {
+		if _, err := util.FindWorkspaceConfigFile(); err != nil || !util.ConfigFileExists() {
+			log.Debug("getSecretsToScanFor: no Infisical token or project found, skipping matching against Infisical secrets")
+			return []models.SingleEnvironmentVariable{}
+		}
+
+		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+		if err != nil || !loggedInUserDetails.IsUserLoggedIn || loggedInUserDetails.LoginExpired {
+			log.Debugf("getSecretsToScanFor: not logged in, skipping matching against Infisical secrets [err=%v]", err)
+			return []models.SingleEnvironmentVariable{}
+		}
+	}
+
+	secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken})
+	if err != nil {
+		util.PrintWarning(fmt.Sprintf("Unable to fetch your Infisical secrets, only common secret patterns will be reported [err=%v]", err))
+		return []models.SingleEnvironmentVariable{}
+	}
+
+	return secrets
+}
+
+func init() {
+	scanCmd.Flags().Bool("fail", false, "Exit with a non zero code when secrets are found, useful in a pre-commit hook")
+	scanCmd.Flags().Bool("match-secrets", true, "Also report values of your Infisical secrets that are found in the scanned files")
+	scanCmd.Flags().StringP("env", "e", "dev", "Set the environment (dev, prod, etc.) from which your secrets should be pulled from")
+	scanCmd.Flags().String("token", "", "Fetch secrets using the Infisical Token")
+	rootCmd.AddCommand(scanCmd)
+}
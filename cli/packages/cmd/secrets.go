@@ -4,11 +4,22 @@ Copyright (c) 2023 Infisical Inc.
 package cmd
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
 	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 	"unicode"
 
 	"crypto/sha256"
@@ -18,6 +29,7 @@ import (
 	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
 	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/fatih/color"
 	"github.com/go-resty/resty/v2"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -49,18 +61,164 @@ var secretsCmd = &cobra.Command{
 			util.HandleError(err)
 		}
 
+		expandSource := getExpandSource(cmd)
+
 		tagSlugs, err := cmd.Flags().GetString("tags")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
 
-		secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs})
+		shouldPrintDigest, err := cmd.Flags().GetBool("digest")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		keysOnly, err := cmd.Flags().GetBool("keys-only")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if outputFormat != "table" && outputFormat != "json" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --output. Available options are [table, json]", outputFormat))
+		}
+
+		if keysOnly && shouldPrintDigest {
+			util.PrintErrorMessageAndExit("--digest cannot be used together with --keys-only, the digest is computed over the values of the secrets")
+		}
+
+		columns, err := cmd.Flags().GetStringSlice("columns")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		maxColumnWidth, err := cmd.Flags().GetInt("max-column-width")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldMask, err := cmd.Flags().GetBool("mask")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if (len(columns) != 0 || cmd.Flags().Changed("max-column-width") || shouldMask) && (outputFormat != "table" || shouldPrintDigest) {
+			util.PrintErrorMessageAndExit("--columns, --max-column-width and --mask can only be used with --output table")
+		}
+
+		columns, err = normalizeSecretColumns(columns)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		if keysOnly && hasSecretColumn(columns, SECRET_COLUMN_VALUE) {
+			util.PrintErrorMessageAndExit("the value column cannot be shown with --keys-only, the values of the secrets are not fetched")
+		}
+
+		grepValue, err := cmd.Flags().GetString("grep-value")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		isReadingValuesAcknowledged, err := cmd.Flags().GetBool("i-understand-this-reads-values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldShowValues, err := cmd.Flags().GetBool("show-values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if (isReadingValuesAcknowledged || shouldShowValues) && grepValue == "" {
+			util.PrintErrorMessageAndExit("--i-understand-this-reads-values and --show-values can only be used together with --grep-value")
+		}
+
+		var valueRegex *regexp.Regexp
+		if grepValue != "" {
+			if keysOnly || shouldPrintDigest {
+				util.PrintErrorMessageAndExit("--grep-value cannot be used together with --keys-only or --digest")
+			}
+
+			if !isReadingValuesAcknowledged {
+				util.PrintErrorMessageAndExit("--grep-value decrypts and searches the values of all your secrets, confirm it with --i-understand-this-reads-values")
+			}
+
+			valueRegex, err = regexp.Compile(grepValue)
+			if err != nil {
+				util.HandleError(err, "Unable to parse --grep-value as a regular expression")
+			}
+		}
+
+		secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs, KeysOnly: keysOnly})
 		if err != nil {
 			util.HandleError(err)
 		}
 
-		if shouldExpandSecrets {
-			secrets = util.SubstituteSecrets(secrets)
+		// there is nothing to expand without values
+		if shouldExpandSecrets && !keysOnly {
+			exitOnReferenceCycle(secrets, expandSource)
+			secrets = util.SubstituteSecretsFromSource(secrets, expandSource, os.LookupEnv)
+		}
+
+		// the matched values are only printed when asked for, the secrets are then printed like with --keys-only
+		if valueRegex != nil {
+			secrets = filterSecretsByValue(secrets, valueRegex)
+			if !shouldShowValues {
+				for i := range secrets {
+					secrets[i].Value = SECRET_VALUE_MASK
+				}
+				keysOnly = true
+			}
+		}
+
+		if shouldPrintDigest {
+			fmt.Println(getSecretsDigest(secrets))
+			return
+		}
+
+		if outputFormat == "json" {
+			output, err := formatSecretsListAsJSON(secrets, keysOnly)
+			if err != nil {
+				util.HandleError(err)
+			}
+			fmt.Println(output)
+			return
+		}
+
+		if shouldMask {
+			for i := range secrets {
+				secrets[i].Value = SECRET_VALUE_MASK
+			}
+		}
+
+		if len(columns) != 0 || maxColumnWidth > 0 {
+			if len(columns) == 0 {
+				columns = []string{SECRET_COLUMN_KEY, SECRET_COLUMN_VALUE, SECRET_COLUMN_TYPE}
+				if keysOnly {
+					columns = []string{SECRET_COLUMN_KEY, SECRET_COLUMN_COMMENT, SECRET_COLUMN_TYPE}
+				}
+			}
+
+			headers, rows := getSecretsTable(secrets, columns)
+			truncatedColumn := -1
+			for i, column := range columns {
+				if column == SECRET_COLUMN_VALUE {
+					truncatedColumn = i
+				}
+			}
+
+			visualize.TableWithColumns(headers, rows, truncatedColumn, maxColumnWidth)
+			return
+		}
+
+		if keysOnly {
+			visualize.PrintAllSecretKeys(secrets)
+			return
 		}
 
 		visualize.PrintAllSecretDetails(secrets)
@@ -72,7 +230,7 @@ var secretsGetCmd = &cobra.Command{
 	Short:                 "Used to retrieve secrets by name",
 	Use:                   "get [secrets]",
 	DisableFlagsInUseLine: true,
-	Args:                  cobra.MinimumNArgs(1),
+	Args:                  cobra.ArbitraryArgs,
 	PreRun:                toggleDebug,
 	Run:                   getSecretsByNames,
 }
@@ -93,7 +251,7 @@ var secretsSetCmd = &cobra.Command{
 	Use:                   "set [secrets]",
 	DisableFlagsInUseLine: true,
 	PreRun:                toggleDebug,
-	Args:                  cobra.MinimumNArgs(1),
+	Args:                  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		util.RequireLocalWorkspaceFile()
 
@@ -105,6 +263,69 @@ var secretsSetCmd = &cobra.Command{
 			}
 		}
 
+		valueCommand, err := cmd.Flags().GetString("from-command")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldNotTrim, err := cmd.Flags().GetBool("no-trim")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldNotTrim && valueCommand == "" {
+			util.PrintErrorMessageAndExit("--no-trim can only be used together with --from-command")
+		}
+
+		// -t/--tags is inherited from [infisical secrets] where it filters the secrets that are read, which set does not do
+		if cmd.Flags().Changed("tags") {
+			util.PrintErrorMessageAndExit("--tags filters secrets by tag and cannot be used when setting secrets. Use --set-tags to set the tags of the secrets")
+		}
+
+		shouldReadStdinJson, err := cmd.Flags().GetBool("stdin-json")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldStringifyNested, err := cmd.Flags().GetBool("stringify-nested")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldStringifyNested && !shouldReadStdinJson {
+			util.PrintErrorMessageAndExit("--stringify-nested can only be used together with --stdin-json")
+		}
+
+		if shouldReadStdinJson {
+			if len(args) != 0 || valueCommand != "" {
+				util.PrintErrorMessageAndExit("--stdin-json reads every secret from stdin and cannot be used together with secrets given as arguments or --from-command")
+			}
+
+			args, err = readSecretArgsFromJSON(os.Stdin, shouldStringifyNested)
+			if err != nil {
+				util.HandleError(err, "Unable to read your secrets from stdin")
+			}
+
+			if len(args) == 0 {
+				util.PrintErrorMessageAndExit("the JSON read from stdin has no secrets to set")
+			}
+		} else if len(args) == 0 {
+			util.PrintErrorMessageAndExit("requires at least 1 secret, as an argument or with --stdin-json")
+		}
+
+		// the command runs before anything is fetched, so it never sees your secrets
+		if valueCommand != "" {
+			if len(args) != 1 || strings.Contains(args[0], "=") {
+				util.PrintErrorMessageAndExit("--from-command takes the name of a single secret without a value, e.g. [infisical secrets set API_KEY --from-command 'openssl rand -hex 16']")
+			}
+
+			value, err := runValueCommand(valueCommand, !shouldNotTrim)
+			if err != nil {
+				util.HandleError(err, "Unable to get the value of your secret with --from-command")
+			}
+			args = []string{args[0] + "=" + value}
+		}
+
 		workspaceFile, err := util.GetWorkSpaceFromFile()
 		if err != nil {
 			util.HandleError(err, "Unable to get your local config details")
@@ -115,7 +336,7 @@ var secretsSetCmd = &cobra.Command{
 			util.HandleError(err, "Unable to authenticate")
 		}
 
-		httpClient := resty.New().
+		httpClient := api.NewHttpClient().
 			SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
 			SetHeader("Accept", "application/json")
 
@@ -141,8 +362,47 @@ var secretsSetCmd = &cobra.Command{
 		// decrypt workspace key
 		plainTextEncryptionKey := crypto.DecryptAsymmetric(encryptedWorkspaceKey, encryptedWorkspaceKeyNonce, encryptedWorkspaceKeySenderPublicKey, currentUsersPrivateKey)
 
-		// pull current secrets
-		secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName})
+		comment, err := cmd.Flags().GetString("comment")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		shouldSetComment := cmd.Flags().Changed("comment")
+
+		// the same comment fields are sent for every secret, encrypting them once is enough
+		var encryptedComment api.Secret
+		if shouldSetComment {
+			encryptedComment.SecretCommentCiphertext, encryptedComment.SecretCommentIV, encryptedComment.SecretCommentTag, err = encryptForBatchRequest(comment, []byte(plainTextEncryptionKey))
+			if err != nil {
+				util.HandleError(err, "unable to encrypt your comment")
+			}
+			encryptedComment.SecretCommentHash = fmt.Sprintf("%x", sha256.Sum256([]byte(comment)))
+		}
+
+		tagSlugs, err := cmd.Flags().GetString("set-tags")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		shouldSetTags := cmd.Flags().Changed("set-tags")
+
+		tagIds := []string{}
+		if shouldSetTags {
+			workspaceTags, err := api.CallGetWorkspaceTags(httpClient, api.GetWorkspaceTagsRequest{WorkspaceId: workspaceFile.WorkspaceId})
+			if err != nil {
+				util.HandleError(err, "Unable to get the tags of your project")
+			}
+
+			tagIds, err = getTagIdsBySlugs(workspaceTags, tagSlugs)
+			if err != nil {
+				util.HandleError(err)
+			}
+
+			if len(tagIds) == 0 {
+				util.PrintErrorMessageAndExit("--set-tags requires at least one tag slug")
+			}
+		}
+
+		// pull current secrets with the same login that writes them, INFISICAL_TOKEN or a stored service token could be for another environment
+		_, secrets, err := getSecretsAtPath(httpClient, []byte(plainTextEncryptionKey), workspaceFile.WorkspaceId, environmentName, "/")
 		if err != nil {
 			util.HandleError(err, "unable to retrieve secrets")
 		}
@@ -161,7 +421,7 @@ var secretsSetCmd = &cobra.Command{
 
 		for _, arg := range args {
 			splitKeyValueFromArg := strings.SplitN(arg, "=", 2)
-			if splitKeyValueFromArg[0] == "" || splitKeyValueFromArg[1] == "" {
+			if len(splitKeyValueFromArg) != 2 || splitKeyValueFromArg[0] == "" || splitKeyValueFromArg[1] == "" {
 				util.PrintErrorMessageAndExit("ensure that each secret has a none empty key and value. Modify the input and try again")
 			}
 
@@ -195,6 +455,15 @@ var secretsSetCmd = &cobra.Command{
 					SecretValueHash:       hashedValue,
 				}
 
+				isCommentModified := shouldSetComment && existingSecret.Comment != comment
+				isTagsModified := shouldSetTags && !haveSameTagIds(existingSecret, tagIds)
+				if isCommentModified {
+					setEncryptedComment(&encryptedSecretDetails, encryptedComment)
+				}
+				if isTagsModified {
+					encryptedSecretDetails.Tags = tagIds
+				}
+
 				// Only add to modifications if the value is different
 				if existingSecret.Value != value {
 					secretsToModify = append(secretsToModify, encryptedSecretDetails)
@@ -203,6 +472,13 @@ var secretsSetCmd = &cobra.Command{
 						SecretValue:     value,
 						SecretOperation: "SECRET VALUE MODIFIED",
 					})
+				} else if isCommentModified || isTagsModified {
+					secretsToModify = append(secretsToModify, encryptedSecretDetails)
+					secretOperations = append(secretOperations, SecretSetOperation{
+						SecretKey:       key,
+						SecretValue:     value,
+						SecretOperation: "SECRET METADATA MODIFIED",
+					})
 				} else {
 					// Current value is same as exisitng so no change
 					secretOperations = append(secretOperations, SecretSetOperation{
@@ -225,6 +501,12 @@ var secretsSetCmd = &cobra.Command{
 					SecretValueHash:       hashedValue,
 					Type:                  util.SECRET_TYPE_SHARED,
 				}
+				if shouldSetComment {
+					setEncryptedComment(&encryptedSecretDetails, encryptedComment)
+				}
+				if shouldSetTags {
+					encryptedSecretDetails.Tags = tagIds
+				}
 				secretsToCreate = append(secretsToCreate, encryptedSecretDetails)
 				secretOperations = append(secretOperations, SecretSetOperation{
 					SecretKey:       key,
@@ -265,14 +547,50 @@ var secretsSetCmd = &cobra.Command{
 		// Print secret operations
 		headers := [...]string{"SECRET NAME", "SECRET VALUE", "STATUS"}
 		rows := [][3]string{}
+		unchangedCount := 0
 		for _, secretOperation := range secretOperations {
 			rows = append(rows, [...]string{secretOperation.SecretKey, secretOperation.SecretValue, secretOperation.SecretOperation})
+			if secretOperation.SecretOperation == "SECRET VALUE UNCHANGED" {
+				unchangedCount++
+			}
 		}
 
 		visualize.Table(headers, rows)
+
+		// unchanged secrets are never written so that they do not add versions to their history
+		if unchangedCount > 0 {
+			fmt.Printf("%d secret(s) skipped as unchanged\n", unchangedCount)
+		}
+
+		if shouldReadStdinJson {
+			fmt.Printf("%d secret(s) created, %d updated\n", len(secretsToCreate), len(secretsToModify))
+		}
 	},
 }
 
+// Reads the secrets of a JSON object, or of the array written by [infisical export --format json], as KEY=value arguments
+func readSecretArgsFromJSON(reader io.Reader, stringifyNested bool) ([]string, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the JSON of your secrets [err=%v]", err)
+	}
+
+	secrets, err := util.ParseSecretsFromJSON(content, stringifyNested)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the JSON of your secrets [err=%v]", err)
+	}
+
+	args := []string{}
+	for _, secret := range secrets {
+		if strings.Contains(secret.Key, "=") {
+			return nil, fmt.Errorf("the secret name [%s] cannot contain =", secret.Key)
+		}
+		args = append(args, secret.Key+"="+secret.Value)
+	}
+
+	return args, nil
+}
+
 var secretsDeleteCmd = &cobra.Command{
 	Example:               `secrets delete <secret name A> <secret name B>..."`,
 	Short:                 "Used to delete secrets by name",
@@ -299,7 +617,17 @@ var secretsDeleteCmd = &cobra.Command{
 			util.HandleError(err, "Unable to get local project details")
 		}
 
-		secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName})
+		httpClient := api.NewHttpClient().
+			SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+			SetHeader("Accept", "application/json")
+
+		plainTextWorkspaceKey, err := util.GetPlainTextWorkspaceKey(httpClient, loggedInUserDetails.UserCredentials.PrivateKey, workspaceFile.WorkspaceId)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		// the ids are looked up with the same login that deletes them, INFISICAL_TOKEN or a stored service token could be for another environment
+		_, secrets, err := getSecretsAtPath(httpClient, plainTextWorkspaceKey, workspaceFile.WorkspaceId, environmentName, "/")
 		if err != nil {
 			util.HandleError(err, "Unable to fetch secrets")
 		}
@@ -327,10 +655,6 @@ var secretsDeleteCmd = &cobra.Command{
 			SecretIds:       validSecretIdsToDelete,
 		}
 
-		httpClient := resty.New().
-			SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
-			SetHeader("Accept", "application/json")
-
 		err = api.CallBatchDeleteSecretsByWorkspaceAndEnv(httpClient, request)
 		if err != nil {
 			util.HandleError(err, "Unable to complete your batch delete request")
@@ -360,86 +684,526 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
-	secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs})
+	multipleSecretNames, err := cmd.Flags().GetStringSlice("multiple")
 	if err != nil {
-		util.HandleError(err, "To fetch all secrets")
+		util.HandleError(err, "Unable to parse flag")
 	}
 
-	requestedSecrets := []models.SingleEnvironmentVariable{}
+	args = append(args, multipleSecretNames...)
+	if len(args) == 0 {
+		util.PrintErrorMessageAndExit("requires at least 1 secret name, as an argument or with --multiple")
+	}
 
-	secretsMap := getSecretsByKeys(secrets)
+	shouldGetAllEnvs, err := cmd.Flags().GetBool("all-envs")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
 
-	for _, secretKeyFromArg := range args {
-		if value, ok := secretsMap[strings.ToUpper(secretKeyFromArg)]; ok {
-			requestedSecrets = append(requestedSecrets, value)
-		} else {
-			requestedSecrets = append(requestedSecrets, models.SingleEnvironmentVariable{
-				Key:   secretKeyFromArg,
-				Type:  "*not found*",
-				Value: "*not found*",
-			})
-		}
+	if shouldGetAllEnvs && (cmd.Flags().Changed("allow-missing") || cmd.Flags().Changed("parse-json") || cmd.Flags().Changed("jq") || cmd.Flags().Changed("default") || cmd.Flags().Changed("format")) {
+		util.PrintErrorMessageAndExit("--allow-missing, --parse-json, --jq, --default and --format cannot be used together with --all-envs")
 	}
 
-	visualize.PrintAllSecretDetails(requestedSecrets)
-}
+	shouldWaitForValue, err := cmd.Flags().GetBool("wait-for-value")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
 
-func generateExampleEnv(cmd *cobra.Command, args []string) {
-	environmentName, _ := cmd.Flags().GetString("env")
-	if !cmd.Flags().Changed("env") {
-		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
-		if environmentFromWorkspace != "" {
-			environmentName = environmentFromWorkspace
-		}
+	if !shouldWaitForValue && (cmd.Flags().Changed("wait-timeout") || cmd.Flags().Changed("wait-interval")) {
+		util.PrintErrorMessageAndExit("--wait-timeout and --wait-interval can only be used together with --wait-for-value")
 	}
 
-	infisicalToken, err := cmd.Flags().GetString("token")
+	if shouldWaitForValue && (shouldGetAllEnvs || cmd.Flags().Changed("default") || cmd.Flags().Changed("allow-missing")) {
+		util.PrintErrorMessageAndExit("--wait-for-value cannot be used together with --all-envs, --default or --allow-missing")
+	}
+
+	if shouldGetAllEnvs {
+		getSecretAcrossEnvironments(cmd, args, infisicalToken, tagSlugs)
+		return
+	}
+
+	if cmd.Flags().Changed("show-values") || cmd.Flags().Changed("parallel-env-fetch") || cmd.Flags().Changed("keep-going") {
+		util.PrintErrorMessageAndExit("--show-values, --parallel-env-fetch and --keep-going can only be used together with --all-envs")
+	}
+
+	output, err := cmd.Flags().GetString("output")
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
-	tagSlugs, err := cmd.Flags().GetString("tags")
+	if output != GET_OUTPUT_TABLE && output != GET_OUTPUT_JSON && output != GET_OUTPUT_SHELL {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --output. Available options are [%s, %s, %s]", output, GET_OUTPUT_TABLE, GET_OUTPUT_JSON, GET_OUTPUT_SHELL))
+	}
+
+	allowMissing, err := cmd.Flags().GetBool("allow-missing")
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
-	secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs})
+	shouldParseJson, err := cmd.Flags().GetBool("parse-json")
 	if err != nil {
-		util.HandleError(err, "To fetch all secrets")
+		util.HandleError(err, "Unable to parse flag")
 	}
 
-	tagsHashToSecretKey := make(map[string]int)
-	slugsToFilerBy := make(map[string]int)
+	jsonPath, err := cmd.Flags().GetString("jq")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
 
-	for _, slug := range strings.Split(tagSlugs, ",") {
-		slugsToFilerBy[slug] = 1
+	defaultValue, err := cmd.Flags().GetString("default")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
 	}
 
-	type TagsAndSecrets struct {
-		Secrets []models.SingleEnvironmentVariable
-		Tags    []struct {
-			ID        string `json:"_id"`
-			Name      string `json:"name"`
-			Slug      string `json:"slug"`
-			Workspace string `json:"workspace"`
-		}
+	hasDefaultValue := cmd.Flags().Changed("default")
+	if hasDefaultValue && len(args) != 1 {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("--default takes a single secret name, received %d", len(args)))
 	}
 
-	// sort secrets by associated tags (most number of tags to least tags)
-	sort.Slice(secrets, func(i, j int) bool {
-		return len(secrets[i].Tags) > len(secrets[j].Tags)
-	})
+	outputFormat, err := cmd.Flags().GetString("format")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
 
-	for i, secret := range secrets {
-		filteredTag := []struct {
-			ID        string "json:\"_id\""
-			Name      string "json:\"name\""
-			Slug      string "json:\"slug\""
-			Workspace string "json:\"workspace\""
-		}{}
+	if outputFormat != "" && outputFormat != GET_FORMAT_SHELL && outputFormat != GET_FORMAT_EXPORT {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --format. Available options are [%s, %s]", outputFormat, GET_FORMAT_SHELL, GET_FORMAT_EXPORT))
+	}
 
-		for _, secretTag := range secret.Tags {
-			_, exists := slugsToFilerBy[secretTag.Slug]
+	if outputFormat != "" && (shouldParseJson || cmd.Flags().Changed("jq")) {
+		util.PrintErrorMessageAndExit("--format cannot be used together with --parse-json or --jq")
+	}
+
+	if output != GET_OUTPUT_TABLE && (outputFormat != "" || shouldParseJson || cmd.Flags().Changed("jq") || hasDefaultValue) {
+		util.PrintErrorMessageAndExit("--output cannot be used together with --format, --parse-json, --jq or --default")
+	}
+
+	if allowMissing && outputFormat == "" && output == GET_OUTPUT_TABLE {
+		util.PrintErrorMessageAndExit("--allow-missing can only be used together with --format or --output json or shell")
+	}
+
+	fetchSecrets := func() ([]models.SingleEnvironmentVariable, error) {
+		return util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs})
+	}
+
+	var secrets []models.SingleEnvironmentVariable
+	if shouldWaitForValue {
+		waitTimeout, err := cmd.Flags().GetDuration("wait-timeout")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		waitInterval, err := cmd.Flags().GetDuration("wait-interval")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if waitInterval <= 0 {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --wait-interval. It must be greater than 0", waitInterval))
+		}
+
+		secrets, err = waitForSecretValues(fetchSecrets, args, waitTimeout, waitInterval)
+		if errors.Is(err, errWaitForSecretValuesTimeout) {
+			util.PrintErrorAndExit(util.EXIT_CODE_WAIT_FOR_TIMEOUT, err)
+		}
+	} else {
+		secrets, err = fetchSecrets()
+	}
+	if err != nil {
+		util.HandleError(err, "To fetch all secrets")
+	}
+
+	requestedSecrets := []models.SingleEnvironmentVariable{}
+
+	secretsMap := getSecretsByKeys(secrets)
+
+	if outputFormat != "" || output != GET_OUTPUT_TABLE {
+		foundSecrets, missingSecretNames := pickSecretsByNames(secretsMap, args)
+		if hasDefaultValue {
+			secret, _ := getSecretOrDefault(secretsMap, args[0], defaultValue)
+			foundSecrets, missingSecretNames = []models.SingleEnvironmentVariable{secret}, nil
+		}
+
+		missingMessages := []string{}
+		for _, secretName := range missingSecretNames {
+			missingMessages = append(missingMessages, fmt.Sprintf("the secret [%s] was not found", secretName))
+		}
+
+		if len(missingMessages) != 0 && !allowMissing {
+			util.PrintErrorMessageAndExit(missingMessages...)
+		}
+
+		for _, missingMessage := range missingMessages {
+			util.PrintWarning(missingMessage)
+		}
+
+		if output == GET_OUTPUT_JSON {
+			jsonOutput, err := formatRequestedSecretsAsJSON(foundSecrets)
+			if err != nil {
+				util.HandleError(err, "Unable to print the secrets as JSON")
+			}
+			fmt.Println(jsonOutput)
+			return
+		}
+
+		// --output shell is the same as --format export
+		if output == GET_OUTPUT_SHELL {
+			outputFormat = GET_FORMAT_EXPORT
+		}
+
+		for _, secret := range foundSecrets {
+			line, err := formatSecretForShell(secret.Key, secret.Value, outputFormat)
+			if err != nil {
+				util.HandleError(err)
+			}
+			fmt.Println(line)
+		}
+		return
+	}
+
+	// only a secret that does not exist falls back to the default, failing to fetch the secrets still exits above
+	if hasDefaultValue {
+		secret, isDefault := getSecretOrDefault(secretsMap, args[0], defaultValue)
+		if isDefault || (!shouldParseJson && !cmd.Flags().Changed("jq")) {
+			fmt.Println(secret.Value)
+			return
+		}
+	}
+
+	if shouldParseJson || cmd.Flags().Changed("jq") {
+		if len(args) != 1 {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--parse-json and --jq take a single secret name, received %d", len(args)))
+		}
+
+		secret, ok := secretsMap[strings.ToUpper(args[0])]
+		if !ok {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("the secret [%s] was not found", args[0]))
+		}
+
+		output, err := getJsonSecretField(secret.Value, jsonPath)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to read the secret [%s] as JSON", secret.Key))
+		}
+
+		fmt.Println(output)
+		return
+	}
+
+	for _, secretKeyFromArg := range args {
+		if value, ok := secretsMap[strings.ToUpper(secretKeyFromArg)]; ok {
+			requestedSecrets = append(requestedSecrets, value)
+		} else {
+			requestedSecrets = append(requestedSecrets, models.SingleEnvironmentVariable{
+				Key:   secretKeyFromArg,
+				Type:  "*not found*",
+				Value: "*not found*",
+			})
+		}
+	}
+
+	visualize.PrintAllSecretDetails(requestedSecrets)
+}
+
+const (
+	GET_FORMAT_SHELL  = "shell"
+	GET_FORMAT_EXPORT = "export"
+)
+
+const (
+	GET_OUTPUT_TABLE = "table"
+	GET_OUTPUT_JSON  = "json"
+	GET_OUTPUT_SHELL = "shell"
+)
+
+// Returns the secrets of the given names in the order they were asked for, and the names of the secrets that do not exist
+func pickSecretsByNames(secretsMap map[string]models.SingleEnvironmentVariable, secretNames []string) ([]models.SingleEnvironmentVariable, []string) {
+	foundSecrets := []models.SingleEnvironmentVariable{}
+	missingSecretNames := []string{}
+	for _, secretName := range secretNames {
+		secret, ok := secretsMap[strings.ToUpper(secretName)]
+		if !ok {
+			missingSecretNames = append(missingSecretNames, secretName)
+			continue
+		}
+
+		foundSecrets = append(foundSecrets, secret)
+	}
+
+	return foundSecrets, missingSecretNames
+}
+
+// Returns the secret named secretName, or a secret holding defaultValue when it does not exist. The bool reports whether the default was used
+func getSecretOrDefault(secretsMap map[string]models.SingleEnvironmentVariable, secretName string, defaultValue string) (models.SingleEnvironmentVariable, bool) {
+	if secret, ok := secretsMap[strings.ToUpper(secretName)]; ok {
+		return secret, false
+	}
+
+	return models.SingleEnvironmentVariable{Key: secretName, Value: defaultValue}, true
+}
+
+var errWaitForSecretValuesTimeout = errors.New("timed out waiting for the secrets to have a value")
+
+// Fetches the secrets until every one of secretNames exists with a non-empty value, waiting interval between the fetches.
+// Returns an error wrapping errWaitForSecretValuesTimeout with the names still missing once timeout has elapsed
+func waitForSecretValues(fetch func() ([]models.SingleEnvironmentVariable, error), secretNames []string, timeout time.Duration, interval time.Duration) ([]models.SingleEnvironmentVariable, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		secrets, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		secretsMap := getSecretsByKeys(secrets)
+		missingSecretNames := []string{}
+		for _, secretName := range secretNames {
+			if secret, ok := secretsMap[strings.ToUpper(secretName)]; !ok || secret.Value == "" {
+				missingSecretNames = append(missingSecretNames, secretName)
+			}
+		}
+
+		if len(missingSecretNames) == 0 {
+			return secrets, nil
+		}
+
+		if !time.Now().Add(interval).Before(deadline) {
+			return nil, fmt.Errorf("%w after %s, still missing or empty [%s]", errWaitForSecretValuesTimeout, timeout, strings.Join(missingSecretNames, ", "))
+		}
+
+		log.Debugf("waitForSecretValues: [%s] missing or empty, fetching again in %s", strings.Join(missingSecretNames, ", "), interval)
+		time.Sleep(interval)
+	}
+}
+
+// Returns the secrets as a JSON object of their names to their values
+func formatRequestedSecretsAsJSON(secrets []models.SingleEnvironmentVariable) (string, error) {
+	valuesByName := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		valuesByName[secret.Key] = secret.Value
+	}
+
+	output, err := json.MarshalIndent(valuesByName, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(output), nil
+}
+
+// Returns KEY='value', prefixed with export for the export format, so that the output can be passed to eval by POSIX shells.
+// Keys that are not valid shell variable names are an error since they cannot be assigned and would be run as a command instead
+func formatSecretForShell(key string, value string, format string) (string, error) {
+	if !shellVariableNameRegex.MatchString(key) {
+		return "", fmt.Errorf("the secret [%s] cannot be assigned by a shell, its name is not a valid shell variable name", key)
+	}
+
+	assignment := key + "=" + quoteForPosixShell(value)
+	if format == GET_FORMAT_EXPORT {
+		return "export " + assignment, nil
+	}
+	return assignment, nil
+}
+
+var shellVariableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Single quotes keep everything literal in POSIX shells, so quotes in the value are the only characters to escape.
+// Each one closes the quoted string, adds an escaped quote and opens a new quoted string
+func quoteForPosixShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Returns the field of a JSON valued secret at the given path, pretty printed. Strings are returned as is so they can be used in scripts
+func getJsonSecretField(value string, jsonPath string) (string, error) {
+	field, err := util.GetJSONPathValue([]byte(value), jsonPath)
+	if err != nil {
+		return "", err
+	}
+
+	if stringField, ok := field.(string); ok {
+		return stringField, nil
+	}
+
+	output, err := json.MarshalIndent(field, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal the field to JSON [err=%v]", err)
+	}
+
+	return string(output), nil
+}
+
+// Prints the value of a single secret in every environment of the project the user can access
+func getSecretAcrossEnvironments(cmd *cobra.Command, args []string, infisicalToken string, tagSlugs string) {
+	shouldShowValues, err := cmd.Flags().GetBool("show-values")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	outputFormat, err := cmd.Flags().GetString("output")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if outputFormat != "table" && outputFormat != "json" {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --output. Available options are [table, json]", outputFormat))
+	}
+
+	parallelEnvFetch, err := cmd.Flags().GetInt("parallel-env-fetch")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if parallelEnvFetch < 1 {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%d] for --parallel-env-fetch. It must be at least 1", parallelEnvFetch))
+	}
+
+	keepGoing, err := cmd.Flags().GetBool("keep-going")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if len(args) != 1 {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("--all-envs takes a single secret name, received %d", len(args)))
+	}
+
+	// a service token would return the secrets of its own environment for every environment
+	serviceToken, authMethod, err := util.GetServiceTokenToUse(infisicalToken)
+	if err != nil {
+		util.HandleError(err, "Unable to get your credentials")
+	}
+
+	if serviceToken != "" {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("--all-envs requires you to be logged in since service tokens only give access to a single environment, but the %s would be used", authMethod))
+	}
+
+	util.RequireLogin()
+	util.RequireLocalWorkspaceFile()
+
+	loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+	if err != nil {
+		util.HandleError(err, "Unable to get your login details")
+	}
+
+	workspaceFile, err := util.GetWorkSpaceFromFile()
+	if err != nil {
+		util.HandleError(err, "Unable to read your workspace file")
+	}
+
+	environmentSlugs, err := util.GetAccessibleEnvironmentSlugs(workspaceFile.WorkspaceId, loggedInUserDetails.UserCredentials)
+	if err != nil {
+		util.HandleError(err, "Unable to list the environments of your project")
+	}
+
+	environmentResults, err := util.GetAllEnvironmentVariablesOfEnvironments(models.GetAllSecretsParameters{TagSlugs: tagSlugs}, environmentSlugs, parallelEnvFetch, keepGoing)
+	if err != nil {
+		util.HandleError(err, "Unable to fetch the secrets of every environment")
+	}
+
+	secretName := strings.ToUpper(args[0])
+	valuesByEnvironment := make(map[string]*string)
+	for _, result := range environmentResults {
+		// environments that failed with --keep-going are left out and reported at the end
+		if result.Err != nil {
+			continue
+		}
+
+		// personal overrides differ from user to user, so the shared values are compared
+		secrets := util.OverrideSecrets(result.Secrets, util.SECRET_TYPE_SHARED)
+		if secret, ok := getSecretsByKeys(secrets)[secretName]; ok {
+			value := maskSecretValue(secret.Value, shouldShowValues)
+			valuesByEnvironment[result.Environment] = &value
+		} else {
+			valuesByEnvironment[result.Environment] = nil
+		}
+	}
+
+	if outputFormat == "json" {
+		output, err := json.MarshalIndent(valuesByEnvironment, "", "  ")
+		if err != nil {
+			util.HandleError(err, "Unable to format the secret as JSON")
+		}
+		fmt.Println(string(output))
+	} else {
+		rows := [][3]string{}
+		for _, result := range environmentResults {
+			value := "*not found*"
+			if result.Err != nil {
+				value = "*fetch failed*"
+			} else if valuesByEnvironment[result.Environment] != nil {
+				value = *valuesByEnvironment[result.Environment]
+			}
+			rows = append(rows, [...]string{result.Environment, secretName, value})
+		}
+
+		visualize.Table([...]string{"ENVIRONMENT", "SECRET NAME", "SECRET VALUE"}, rows)
+	}
+
+	if util.PrintEnvironmentFetchErrors(environmentResults) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Masked values are replaced by a short fingerprint, so that environments with the same value can still be spotted
+func maskSecretValue(value string, shouldShowValue bool) string {
+	if shouldShowValue {
+		return value
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(value)))[:15]
+}
+
+func generateExampleEnv(cmd *cobra.Command, args []string) {
+	environmentName, _ := cmd.Flags().GetString("env")
+	if !cmd.Flags().Changed("env") {
+		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+		if environmentFromWorkspace != "" {
+			environmentName = environmentFromWorkspace
+		}
+	}
+
+	infisicalToken, err := cmd.Flags().GetString("token")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	tagSlugs, err := cmd.Flags().GetString("tags")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs})
+	if err != nil {
+		util.HandleError(err, "To fetch all secrets")
+	}
+
+	tagsHashToSecretKey := make(map[string]int)
+	slugsToFilerBy := make(map[string]int)
+
+	for _, slug := range strings.Split(tagSlugs, ",") {
+		slugsToFilerBy[slug] = 1
+	}
+
+	type TagsAndSecrets struct {
+		Secrets []models.SingleEnvironmentVariable
+		Tags    []struct {
+			ID        string `json:"_id"`
+			Name      string `json:"name"`
+			Slug      string `json:"slug"`
+			Workspace string `json:"workspace"`
+		}
+	}
+
+	// sort secrets by associated tags (most number of tags to least tags)
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i].Tags) > len(secrets[j].Tags)
+	})
+
+	for i, secret := range secrets {
+		filteredTag := []struct {
+			ID        string "json:\"_id\""
+			Name      string "json:\"name\""
+			Slug      string "json:\"slug\""
+			Workspace string "json:\"workspace\""
+		}{}
+
+		for _, secretTag := range secret.Tags {
+			_, exists := slugsToFilerBy[secretTag.Slug]
 			if !exists {
 				filteredTag = append(filteredTag, secretTag)
 			}
@@ -572,17 +1336,2326 @@ func generateExampleEnv(cmd *cobra.Command, args []string) {
 	fmt.Println(strings.Join(fullyGeneratedDocuments, ""))
 }
 
-func CenterString(s string, numStars int) string {
-	stars := strings.Repeat("*", numStars)
-	padding := (numStars - len(s)) / 2
-	cenetredTextWithStar := stars[:padding] + " " + strings.ToUpper(s) + " " + stars[padding:]
-
-	hashes := strings.Repeat("#", len(cenetredTextWithStar)+2)
-	return fmt.Sprintf("%s \n# %s \n%s", hashes, cenetredTextWithStar, hashes)
-}
-
-func addHash(input string) string {
-	lines := strings.Split(input, "\n")
+var secretsMoveCmd = &cobra.Command{
+	Example:               `secrets move <secret name A> <secret name B>... --from-path /old --to-path /new"`,
+	Short:                 "Used to move secrets to another folder",
+	Use:                   "move [secrets]",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		fromPath, err := cmd.Flags().GetString("from-path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		toPath, err := cmd.Flags().GetString("to-path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		prefix, err := cmd.Flags().GetString("prefix")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldOverwrite, err := cmd.Flags().GetBool("overwrite")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		isDryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if len(args) == 0 && prefix == "" {
+			util.PrintErrorMessageAndExit("specify the names of the secrets to move or use --prefix to move all secrets starting with a prefix")
+		}
+
+		if len(args) != 0 && prefix != "" {
+			util.PrintErrorMessageAndExit("secret names and --prefix cannot be used together")
+		}
+
+		fromPath = util.NormalizeSecretsPath(fromPath)
+		toPath = util.NormalizeSecretsPath(toPath)
+		if fromPath == toPath {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("the source and destination paths are the same [%s]", fromPath))
+		}
+
+		workspaceFile, err := util.GetWorkSpaceFromFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get local project details")
+		}
+
+		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+		if err != nil {
+			util.HandleError(err, "Unable to authenticate")
+		}
+
+		httpClient := api.NewHttpClient().
+			SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+			SetHeader("Accept", "application/json")
+
+		plainTextWorkspaceKey, err := util.GetPlainTextWorkspaceKey(httpClient, loggedInUserDetails.UserCredentials.PrivateKey, workspaceFile.WorkspaceId)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		encryptedSourceSecrets, sourceSecrets, err := getSecretsAtPath(httpClient, plainTextWorkspaceKey, workspaceFile.WorkspaceId, environmentName, fromPath)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to fetch the secrets at [%s]", fromPath))
+		}
+
+		_, destinationSecrets, err := getSecretsAtPath(httpClient, plainTextWorkspaceKey, workspaceFile.WorkspaceId, environmentName, toPath)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to fetch the secrets at [%s]", toPath))
+		}
+
+		destinationFolderId, err := getFolderIdOfPath(httpClient, workspaceFile.WorkspaceId, environmentName, toPath)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		secretsToMove, err := selectSecretsToMove(sourceSecrets, args, prefix)
+		if err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
+		requests, err := planSecretsMove(encryptedSourceSecrets, secretsToMove, destinationSecrets, destinationFolderId, shouldOverwrite)
+		if err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
+		headers := [...]string{"SECRET NAME", "SECRET TYPE", "STATUS"}
+		rows := [][3]string{}
+		for _, secret := range secretsToMove {
+			status := fmt.Sprintf("MOVED TO %s", toPath)
+			if isDryRun {
+				status = fmt.Sprintf("WILL MOVE TO %s", toPath)
+			}
+			rows = append(rows, [...]string{secret.Key, secret.Type, status})
+		}
+
+		if isDryRun {
+			visualize.Table(headers, rows)
+			fmt.Println("Dry run, no secrets have been moved")
+			return
+		}
+
+		// a single batch request so that the copies and deletions are applied together
+		err = api.CallBatchSecrets(httpClient, api.BatchSecretsRequest{
+			WorkspaceId: workspaceFile.WorkspaceId,
+			Environment: environmentName,
+			Requests:    requests,
+		})
+		if err != nil {
+			util.HandleError(err, "Unable to move your secrets")
+		}
+
+		visualize.Table(headers, rows)
+	},
+}
+
+// Returns the encrypted and decrypted secrets found directly in the given folder
+func getSecretsAtPath(httpClient *resty.Client, workspaceKey []byte, workspaceId string, environmentName string, secretsPath string) (api.GetEncryptedSecretsV2Response, []models.SingleEnvironmentVariable, error) {
+	encryptedSecrets, err := api.CallGetSecretsV2(httpClient, api.GetEncryptedSecretsV2Request{
+		WorkspaceId: workspaceId,
+		Environment: environmentName,
+		SecretsPath: secretsPath,
+	})
+	if err != nil {
+		return api.GetEncryptedSecretsV2Response{}, nil, err
+	}
+
+	plainTextSecrets, err := util.GetPlainTextSecrets(workspaceKey, encryptedSecrets)
+	if err != nil {
+		return api.GetEncryptedSecretsV2Response{}, nil, fmt.Errorf("unable to decrypt your secrets [err=%v]", err)
+	}
+
+	return encryptedSecrets, plainTextSecrets, nil
+}
+
+// Looks up the id of the folder at the given path by listing the folders of its parent. Secrets at the root have no folder id
+func getFolderIdOfPath(httpClient *resty.Client, workspaceId string, environmentName string, secretsPath string) (string, error) {
+	if secretsPath == "/" {
+		return "", nil
+	}
+
+	lastSeparatorIndex := strings.LastIndex(secretsPath, "/")
+	parentPath, folderName := util.NormalizeSecretsPath(secretsPath[:lastSeparatorIndex]), secretsPath[lastSeparatorIndex+1:]
+
+	parentFolder, err := api.CallGetSecretsV2(httpClient, api.GetEncryptedSecretsV2Request{
+		WorkspaceId: workspaceId,
+		Environment: environmentName,
+		SecretsPath: parentPath,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, folder := range parentFolder.Folders {
+		if folder.Name == folderName {
+			return folder.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("the folder [%s] does not exist in environment [%s]", secretsPath, environmentName)
+}
+
+// Picks the secrets with the given names, or all secrets starting with the prefix. Both the shared and personal version of a secret are selected
+func selectSecretsToMove(secrets []models.SingleEnvironmentVariable, secretNames []string, prefix string) ([]models.SingleEnvironmentVariable, error) {
+	selectedSecrets := []models.SingleEnvironmentVariable{}
+	if prefix != "" {
+		for _, secret := range secrets {
+			if strings.HasPrefix(secret.Key, prefix) {
+				selectedSecrets = append(selectedSecrets, secret)
+			}
+		}
+
+		if len(selectedSecrets) == 0 {
+			return nil, fmt.Errorf("no secrets starting with [%s] were found", prefix)
+		}
+
+		return selectedSecrets, nil
+	}
+
+	invalidSecretNamesThatDoNotExist := []string{}
+	for _, secretName := range secretNames {
+		found := false
+		for _, secret := range secrets {
+			if secret.Key == strings.ToUpper(secretName) {
+				selectedSecrets = append(selectedSecrets, secret)
+				found = true
+			}
+		}
+
+		if !found {
+			invalidSecretNamesThatDoNotExist = append(invalidSecretNamesThatDoNotExist, secretName)
+		}
+	}
+
+	if len(invalidSecretNamesThatDoNotExist) != 0 {
+		return nil, fmt.Errorf("secret name(s) [%v] do not exist in the source folder. To see which secrets exist run [infisical secrets]", strings.Join(invalidSecretNamesThatDoNotExist, ", "))
+	}
+
+	return selectedSecrets, nil
+}
+
+// Builds the batch that recreates every secret in the destination folder and deletes it from the source folder.
+// The encrypted key, value and comment are copied as is so that the secrets never have to be re-encrypted
+func planSecretsMove(encryptedSourceSecrets api.GetEncryptedSecretsV2Response, secretsToMove []models.SingleEnvironmentVariable, destinationSecrets []models.SingleEnvironmentVariable, destinationFolderId string, shouldOverwrite bool) ([]api.BatchSecretRequest, error) {
+	destinationSecretIds := map[string]string{}
+	for _, secret := range destinationSecrets {
+		destinationSecretIds[secret.Type+"/"+secret.Key] = secret.ID
+	}
+
+	requests := []api.BatchSecretRequest{}
+	conflictingSecretNames := []string{}
+	for _, secretToMove := range secretsToMove {
+		if existingSecretId, ok := destinationSecretIds[secretToMove.Type+"/"+secretToMove.Key]; ok {
+			if !shouldOverwrite {
+				conflictingSecretNames = append(conflictingSecretNames, secretToMove.Key)
+				continue
+			}
+			requests = append(requests, api.BatchSecretRequest{Method: "DELETE", Secret: api.BatchSecret{ID: existingSecretId}})
+		}
+
+		for _, encryptedSecret := range encryptedSourceSecrets.Secrets {
+			if encryptedSecret.ID != secretToMove.ID {
+				continue
+			}
+
+			tagIds := []string{}
+			for _, tag := range encryptedSecret.Tags {
+				tagIds = append(tagIds, tag.ID)
+			}
+
+			requests = append(requests,
+				api.BatchSecretRequest{Method: "POST", Secret: api.BatchSecret{
+					FolderId:                destinationFolderId,
+					SecretName:              secretToMove.Key,
+					Type:                    encryptedSecret.Type,
+					SecretKeyCiphertext:     encryptedSecret.SecretKeyCiphertext,
+					SecretKeyIV:             encryptedSecret.SecretKeyIV,
+					SecretKeyTag:            encryptedSecret.SecretKeyTag,
+					SecretValueCiphertext:   encryptedSecret.SecretValueCiphertext,
+					SecretValueIV:           encryptedSecret.SecretValueIV,
+					SecretValueTag:          encryptedSecret.SecretValueTag,
+					SecretCommentCiphertext: encryptedSecret.SecretCommentCiphertext,
+					SecretCommentIV:         encryptedSecret.SecretCommentIV,
+					SecretCommentTag:        encryptedSecret.SecretCommentTag,
+					Tags:                    tagIds,
+				}},
+				api.BatchSecretRequest{Method: "DELETE", Secret: api.BatchSecret{ID: encryptedSecret.ID}},
+			)
+		}
+	}
+
+	if len(conflictingSecretNames) != 0 {
+		return nil, fmt.Errorf("secret name(s) [%v] already exist in the destination folder. Use --overwrite to replace them", strings.Join(conflictingSecretNames, ", "))
+	}
+
+	return requests, nil
+}
+
+var secretsPromoteCmd = &cobra.Command{
+	Example:               `secrets promote --from-env staging --to-env prod --path / --no-approval`,
+	Short:                 "Used to copy the secrets of a folder from one environment to another",
+	Use:                   "promote",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fromEnvironment, err := cmd.Flags().GetString("from-env")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		toEnvironment, err := cmd.Flags().GetString("to-env")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		isDryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldSkipApproval, err := cmd.Flags().GetBool("no-approval")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if fromEnvironment == toEnvironment {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("the source and destination environments are the same [%s]", fromEnvironment))
+		}
+
+		// the API has no endpoint to submit changes for approval yet, so they are only written with explicit consent
+		if !isDryRun && !shouldSkipApproval {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("your Infisical instance does not support submitting changes for approval. Review them with --dry-run, then use --no-approval to write them to [%s] directly", toEnvironment))
+		}
+
+		secretsPath = util.NormalizeSecretsPath(secretsPath)
+
+		workspaceFile, err := util.GetWorkSpaceFromFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get local project details")
+		}
+
+		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+		if err != nil {
+			util.HandleError(err, "Unable to authenticate")
+		}
+
+		httpClient := api.NewHttpClient().
+			SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+			SetHeader("Accept", "application/json")
+
+		plainTextWorkspaceKey, err := util.GetPlainTextWorkspaceKey(httpClient, loggedInUserDetails.UserCredentials.PrivateKey, workspaceFile.WorkspaceId)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		_, sourceSecrets, err := getSecretsAtPath(httpClient, plainTextWorkspaceKey, workspaceFile.WorkspaceId, fromEnvironment, secretsPath)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to fetch the secrets of [%s] at [%s]", fromEnvironment, secretsPath))
+		}
+
+		_, destinationSecrets, err := getSecretsAtPath(httpClient, plainTextWorkspaceKey, workspaceFile.WorkspaceId, toEnvironment, secretsPath)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to fetch the secrets of [%s] at [%s]", toEnvironment, secretsPath))
+		}
+
+		operations := planSecretsPromotion(sourceSecrets, destinationSecrets)
+		if len(operations) == 0 {
+			fmt.Printf("The secrets of [%s] at [%s] are already the same as in [%s]\n", toEnvironment, secretsPath, fromEnvironment)
+			return
+		}
+
+		// values are shown as fingerprints so that the diff can be reviewed without revealing them
+		destinationValues := getSecretsByKeys(destinationSecrets)
+		headers := []string{"SECRET NAME", "OPERATION", "CURRENT VALUE", "PROMOTED VALUE"}
+		rows := [][]string{}
+		for _, operation := range operations {
+			currentValue := "*not found*"
+			if operation.method == "PATCH" {
+				currentValue = maskSecretValue(destinationValues[operation.key].Value, false)
+			}
+			rows = append(rows, []string{operation.key, operation.method, currentValue, maskSecretValue(operation.value, false)})
+		}
+
+		visualize.TableWithColumns(headers, rows, -1, 0)
+		if isDryRun {
+			fmt.Println("Dry run, no secrets have been promoted")
+			return
+		}
+
+		destinationFolderId, err := getFolderIdOfPath(httpClient, workspaceFile.WorkspaceId, toEnvironment, secretsPath)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		requests := []api.BatchSecretRequest{}
+		for _, operation := range operations {
+			request, err := operation.toBatchRequest(plainTextWorkspaceKey)
+			if err != nil {
+				util.HandleError(err, "Unable to encrypt your secrets")
+			}
+			request.Secret.FolderId = destinationFolderId
+			requests = append(requests, request)
+		}
+
+		// a single batch request so that the environment is never left half promoted
+		err = api.CallBatchSecrets(httpClient, api.BatchSecretsRequest{
+			WorkspaceId: workspaceFile.WorkspaceId,
+			Environment: toEnvironment,
+			Requests:    requests,
+		})
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to promote your secrets to [%s]", toEnvironment))
+		}
+
+		fmt.Printf("%d secret(s) promoted from [%s] to [%s]\n", len(operations), fromEnvironment, toEnvironment)
+	},
+}
+
+// Plans the creation of the shared secrets missing from the destination and the update of the ones whose value differs.
+// Secrets only found in the destination are left untouched, and personal secrets are never promoted
+func planSecretsPromotion(sourceSecrets []models.SingleEnvironmentVariable, destinationSecrets []models.SingleEnvironmentVariable) []secretsPatchOperation {
+	sharedSourceSecrets := getSharedSecrets(sourceSecrets)
+	sharedDestinationSecrets := getSharedSecrets(destinationSecrets)
+	destinationSecretsByKey := getSecretsByKeys(sharedDestinationSecrets)
+
+	operations := []secretsPatchOperation{}
+	for _, drift := range compareSecrets(sharedSourceSecrets, sharedDestinationSecrets) {
+		switch drift.Status {
+		case SECRET_DRIFT_ONLY_LOCAL:
+			operations = append(operations, secretsPatchOperation{method: "POST", key: drift.Key, value: drift.LocalValue})
+		case SECRET_DRIFT_VALUE_MISMATCH:
+			operations = append(operations, secretsPatchOperation{method: "PATCH", key: drift.Key, value: drift.LocalValue, id: destinationSecretsByKey[drift.Key].ID})
+		}
+	}
+
+	return operations
+}
+
+func getSharedSecrets(secrets []models.SingleEnvironmentVariable) []models.SingleEnvironmentVariable {
+	sharedSecrets := []models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		if secret.Type == util.SECRET_TYPE_SHARED {
+			sharedSecrets = append(sharedSecrets, secret)
+		}
+	}
+	return sharedSecrets
+}
+
+var secretsLintCmd = &cobra.Command{
+	Example:               `secrets lint --env=prod --fail`,
+	Short:                 "Used to check secrets against naming and value rules",
+	Use:                   "lint",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		infisicalToken, err := cmd.Flags().GetString("token")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tagSlugs, err := cmd.Flags().GetString("tags")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		configPath, err := cmd.Flags().GetString("config")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldFail, err := cmd.Flags().GetBool("fail")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		lintConfig, err := util.ReadLintConfig(configPath, !cmd.Flags().Changed("config"))
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs, SecretsPath: secretsPath})
+		if err != nil {
+			util.HandleError(err, "Unable to fetch secrets")
+		}
+
+		// the rules are about the secrets shared with the team, personal overrides are not checked
+		secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+
+		violations, err := util.LintSecrets(secrets, lintConfig)
+		if err != nil {
+			util.HandleError(err, "Unable to lint your secrets")
+		}
+
+		for _, violation := range violations {
+			fmt.Printf("%s: %s (%s)\n", violation.Key, violation.Description, color.YellowString(violation.RuleId))
+		}
+
+		if len(violations) == 0 {
+			util.PrintSuccessMessage(fmt.Sprintf("No issues found in %d secret(s)", len(secrets)))
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "\n%d issue(s) found. To change the rules, edit %s\n", len(violations), configPath)
+
+		if shouldFail {
+			os.Exit(1)
+		}
+	},
+}
+
+var secretsBulkUpdateCmd = &cobra.Command{
+	Example:               `secrets bulk-update --file patch.json`,
+	Short:                 "Used to create, update and delete secrets from a JSON patch",
+	Use:                   "bulk-update",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		patchFile, err := cmd.Flags().GetString("file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		isDryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		patchContent, err := os.ReadFile(patchFile)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to read the patch file [%s]", patchFile))
+		}
+
+		patch, err := parseSecretsPatch(patchContent)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Invalid patch file [%s]", patchFile))
+		}
+
+		workspaceFile, err := util.GetWorkSpaceFromFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get local project details")
+		}
+
+		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+		if err != nil {
+			util.HandleError(err, "Unable to authenticate")
+		}
+
+		httpClient := api.NewHttpClient().
+			SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+			SetHeader("Accept", "application/json")
+
+		plainTextWorkspaceKey, err := util.GetPlainTextWorkspaceKey(httpClient, loggedInUserDetails.UserCredentials.PrivateKey, workspaceFile.WorkspaceId)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		// the ids are looked up with the same login that writes the patch, INFISICAL_TOKEN or a stored service token could be for another environment
+		_, secrets, err := getSecretsAtPath(httpClient, plainTextWorkspaceKey, workspaceFile.WorkspaceId, environmentName, "/")
+		if err != nil {
+			util.HandleError(err, "Unable to fetch secrets")
+		}
+
+		operations, err := planSecretsPatch(patch, secrets)
+		if err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
+		headers := [...]string{"SECRET NAME", "OPERATION", "STATUS"}
+		if isDryRun {
+			rows := [][3]string{}
+			for _, operation := range operations {
+				rows = append(rows, [...]string{operation.key, operation.method, "PLANNED"})
+			}
+
+			visualize.Table(headers, rows)
+			fmt.Println("Dry run, no secrets have been changed")
+			return
+		}
+
+		requests := []api.BatchSecretRequest{}
+		for _, operation := range operations {
+			request, err := operation.toBatchRequest(plainTextWorkspaceKey)
+			if err != nil {
+				util.HandleError(err, "Unable to encrypt your secrets")
+			}
+			requests = append(requests, request)
+		}
+
+		// creations, updates and deletions are sent as separate batches so that the result of each can be reported
+		statuses := make([]string, len(operations))
+		var batchErr error
+		for _, method := range []string{"POST", "PATCH", "DELETE"} {
+			batch := []api.BatchSecretRequest{}
+			for idx, operation := range operations {
+				if operation.method == method {
+					batch = append(batch, requests[idx])
+				}
+			}
+
+			if len(batch) == 0 {
+				continue
+			}
+
+			status := "DONE"
+			if batchErr != nil {
+				status = "SKIPPED"
+			} else if batchErr = api.CallBatchSecrets(httpClient, api.BatchSecretsRequest{WorkspaceId: workspaceFile.WorkspaceId, Environment: environmentName, Requests: batch}); batchErr != nil {
+				status = "FAILED"
+			}
+
+			for idx, operation := range operations {
+				if operation.method == method {
+					statuses[idx] = status
+				}
+			}
+		}
+
+		rows := [][3]string{}
+		for idx, operation := range operations {
+			rows = append(rows, [...]string{operation.key, operation.method, statuses[idx]})
+		}
+
+		visualize.Table(headers, rows)
+
+		if batchErr != nil {
+			util.HandleError(batchErr, "Some operations of your patch could not be applied")
+		}
+	},
+}
+
+// The patch accepted by secrets bulk-update. Secrets are created, updated and deleted by name
+type secretsPatch struct {
+	Create map[string]string `json:"create"`
+	Update map[string]string `json:"update"`
+	Delete []string          `json:"delete"`
+}
+
+type secretsPatchOperation struct {
+	// POST, PATCH or DELETE as expected by the batch API
+	method string
+	key    string
+	value  string
+	// id of the existing secret for updates and deletions
+	id string
+}
+
+func parseSecretsPatch(content []byte) (secretsPatch, error) {
+	var patch secretsPatch
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		return secretsPatch{}, fmt.Errorf(`expected {"create": {"KEY": "value"}, "update": {"KEY": "value"}, "delete": ["KEY"]} [err=%v]`, err)
+	}
+
+	if len(patch.Create) == 0 && len(patch.Update) == 0 && len(patch.Delete) == 0 {
+		return secretsPatch{}, fmt.Errorf("the patch contains no operations")
+	}
+
+	return patch, nil
+}
+
+// Checks the patch against the current shared secrets: created secrets must not exist yet, updated and deleted ones must exist
+// and a secret can only be part of one operation. Operations are sorted by name so that the same patch is always applied the same way
+func planSecretsPatch(patch secretsPatch, secrets []models.SingleEnvironmentVariable) ([]secretsPatchOperation, error) {
+	sharedSecretsByKey := make(map[string]models.SingleEnvironmentVariable)
+	for _, secret := range secrets {
+		if secret.Type == util.SECRET_TYPE_SHARED {
+			sharedSecretsByKey[secret.Key] = secret
+		}
+	}
+
+	operations := []secretsPatchOperation{}
+	problems := []string{}
+	seenKeys := make(map[string]bool)
+
+	addOperation := func(method string, rawKey string, value string) {
+		key := strings.ToUpper(rawKey)
+		existingSecret, exists := sharedSecretsByKey[key]
+
+		switch {
+		case key == "" || unicode.IsNumber(rune(key[0])):
+			problems = append(problems, fmt.Sprintf("[%s] is not a valid secret name", rawKey))
+		case seenKeys[key]:
+			problems = append(problems, fmt.Sprintf("[%s] is part of more than one operation", key))
+		case method != "DELETE" && value == "":
+			problems = append(problems, fmt.Sprintf("[%s] cannot be set to an empty value", key))
+		case method == "POST" && exists:
+			problems = append(problems, fmt.Sprintf("[%s] cannot be created because it already exists", key))
+		case method != "POST" && !exists:
+			problems = append(problems, fmt.Sprintf("[%s] does not exist", key))
+		}
+
+		seenKeys[key] = true
+		operations = append(operations, secretsPatchOperation{method: method, key: key, value: value, id: existingSecret.ID})
+	}
+
+	for key, value := range patch.Create {
+		addOperation("POST", key, value)
+	}
+
+	for key, value := range patch.Update {
+		addOperation("PATCH", key, value)
+	}
+
+	for _, key := range patch.Delete {
+		addOperation("DELETE", key, "")
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("the patch cannot be applied:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	methodOrder := map[string]int{"POST": 0, "PATCH": 1, "DELETE": 2}
+	sort.SliceStable(operations, func(i, j int) bool {
+		if operations[i].method != operations[j].method {
+			return methodOrder[operations[i].method] < methodOrder[operations[j].method]
+		}
+		return operations[i].key < operations[j].key
+	})
+
+	return operations, nil
+}
+
+func (operation secretsPatchOperation) toBatchRequest(workspaceKey []byte) (api.BatchSecretRequest, error) {
+	if operation.method == "DELETE" {
+		return api.BatchSecretRequest{Method: operation.method, Secret: api.BatchSecret{ID: operation.id}}, nil
+	}
+
+	encryptedValue, err := crypto.EncryptSymmetric([]byte(operation.value), workspaceKey)
+	if err != nil {
+		return api.BatchSecretRequest{}, err
+	}
+
+	secret := api.BatchSecret{
+		ID:                    operation.id,
+		SecretName:            operation.key,
+		SecretValueCiphertext: base64.StdEncoding.EncodeToString(encryptedValue.CipherText),
+		SecretValueIV:         base64.StdEncoding.EncodeToString(encryptedValue.Nonce),
+		SecretValueTag:        base64.StdEncoding.EncodeToString(encryptedValue.AuthTag),
+	}
+
+	if operation.method == "POST" {
+		encryptedKey, err := crypto.EncryptSymmetric([]byte(operation.key), workspaceKey)
+		if err != nil {
+			return api.BatchSecretRequest{}, err
+		}
+
+		secret.Type = util.SECRET_TYPE_SHARED
+		secret.SecretKeyCiphertext = base64.StdEncoding.EncodeToString(encryptedKey.CipherText)
+		secret.SecretKeyIV = base64.StdEncoding.EncodeToString(encryptedKey.Nonce)
+		secret.SecretKeyTag = base64.StdEncoding.EncodeToString(encryptedKey.AuthTag)
+	}
+
+	return api.BatchSecretRequest{Method: operation.method, Secret: secret}, nil
+}
+
+const (
+	SECRET_DRIFT_ONLY_LOCAL     = "only-local"
+	SECRET_DRIFT_ONLY_SERVER    = "only-server"
+	SECRET_DRIFT_VALUE_MISMATCH = "value-mismatch"
+)
+
+var secretsCompareCmd = &cobra.Command{
+	Example:               `secrets compare --file .env --env=prod`,
+	Short:                 "Used to compare a local dotenv file with the secrets in Infisical, or two dotenv files",
+	Use:                   "compare",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		infisicalToken, err := cmd.Flags().GetString("token")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tagSlugs, err := cmd.Flags().GetString("tags")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		envFiles, err := cmd.Flags().GetStringArray("file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if len(envFiles) > 2 {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--file can be set once to compare a file with Infisical or twice to compare two files, received %d", len(envFiles)))
+		}
+
+		// a second file takes the place of Infisical, nothing is fetched then
+		isComparingFiles := len(envFiles) == 2
+		if isComparingFiles && (cmd.Flags().Changed("path") || cmd.Flags().Changed("token") || cmd.Flags().Changed("env") || cmd.Flags().Changed("tags")) {
+			util.PrintErrorMessageAndExit("--path, --token, --env and --tags cannot be used when comparing two files")
+		}
+
+		envFile := envFiles[0]
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if outputFormat != "text" && outputFormat != "json" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --output. Available options are [text, json]", outputFormat))
+		}
+
+		shouldShowValues, err := cmd.Flags().GetBool("show-values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		localSecrets, err := util.ReadEnvFile(envFile)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		var secrets []models.SingleEnvironmentVariable
+		otherSideName := "Infisical"
+		if isComparingFiles {
+			otherSideName = envFiles[1]
+			secrets, err = util.ReadEnvFile(envFiles[1])
+			if err != nil {
+				util.HandleError(err)
+			}
+		} else {
+			secrets, err = util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs, SecretsPath: secretsPath})
+			if err != nil {
+				util.HandleError(err, "Unable to fetch secrets")
+			}
+
+			// the file is compared with the secrets shared with the team, personal overrides are not taken into account
+			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+		}
+
+		drifts := compareSecrets(localSecrets, secrets)
+
+		if outputFormat == "json" {
+			output, err := formatSecretDriftsAsJSON(drifts, shouldShowValues)
+			if err != nil {
+				util.HandleError(err)
+			}
+			fmt.Println(output)
+		} else {
+			for _, drift := range drifts {
+				switch drift.Status {
+				case SECRET_DRIFT_ONLY_LOCAL:
+					fmt.Printf("%s %s only in %s\n", color.GreenString("+"), drift.Key, envFile)
+				case SECRET_DRIFT_ONLY_SERVER:
+					fmt.Printf("%s %s only in %s\n", color.RedString("-"), drift.Key, otherSideName)
+				default:
+					fmt.Printf("%s %s value differs\n", color.YellowString("~"), drift.Key)
+				}
+
+				if shouldShowValues && drift.Status == SECRET_DRIFT_VALUE_MISMATCH && isComparingFiles {
+					fmt.Printf("    %s: %s\n    %s: %s\n", envFile, drift.LocalValue, otherSideName, drift.ServerValue)
+				} else if shouldShowValues && drift.Status == SECRET_DRIFT_VALUE_MISMATCH {
+					fmt.Printf("    local:     %s\n    infisical: %s\n", drift.LocalValue, drift.ServerValue)
+				}
+			}
+
+			if len(drifts) == 0 && isComparingFiles {
+				util.PrintSuccessMessage(fmt.Sprintf("%s matches %s", envFile, otherSideName))
+			} else if len(drifts) == 0 {
+				util.PrintSuccessMessage(fmt.Sprintf("%s matches the secrets of [%s] at [%s]", envFile, environmentName, util.NormalizeSecretsPath(secretsPath)))
+			}
+		}
+
+		if len(drifts) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+type secretDrift struct {
+	Key         string
+	Status      string
+	LocalValue  string
+	ServerValue string
+}
+
+// Returns the secrets that are only local, only on the server or whose values differ, sorted by name
+func compareSecrets(localSecrets []models.SingleEnvironmentVariable, serverSecrets []models.SingleEnvironmentVariable) []secretDrift {
+	localValues := make(map[string]string)
+	for _, secret := range localSecrets {
+		localValues[secret.Key] = secret.Value
+	}
+
+	serverValues := make(map[string]string)
+	for _, secret := range serverSecrets {
+		serverValues[secret.Key] = secret.Value
+	}
+
+	drifts := []secretDrift{}
+	for key, localValue := range localValues {
+		serverValue, ok := serverValues[key]
+		if !ok {
+			drifts = append(drifts, secretDrift{Key: key, Status: SECRET_DRIFT_ONLY_LOCAL, LocalValue: localValue})
+		} else if serverValue != localValue {
+			drifts = append(drifts, secretDrift{Key: key, Status: SECRET_DRIFT_VALUE_MISMATCH, LocalValue: localValue, ServerValue: serverValue})
+		}
+	}
+
+	for key, serverValue := range serverValues {
+		if _, ok := localValues[key]; !ok {
+			drifts = append(drifts, secretDrift{Key: key, Status: SECRET_DRIFT_ONLY_SERVER, ServerValue: serverValue})
+		}
+	}
+
+	sort.Slice(drifts, func(i, j int) bool {
+		return drifts[i].Key < drifts[j].Key
+	})
+
+	return drifts
+}
+
+// Values are left out unless asked for so that the report can be stored as a CI artifact
+func formatSecretDriftsAsJSON(drifts []secretDrift, shouldShowValues bool) (string, error) {
+	type jsonSecretDrift struct {
+		Key         string  `json:"key"`
+		Status      string  `json:"status"`
+		LocalValue  *string `json:"localValue,omitempty"`
+		ServerValue *string `json:"serverValue,omitempty"`
+	}
+
+	jsonDrifts := []jsonSecretDrift{}
+	for _, drift := range drifts {
+		jsonDrift := jsonSecretDrift{Key: drift.Key, Status: drift.Status}
+		if shouldShowValues {
+			localValue, serverValue := drift.LocalValue, drift.ServerValue
+			if drift.Status != SECRET_DRIFT_ONLY_SERVER {
+				jsonDrift.LocalValue = &localValue
+			}
+			if drift.Status != SECRET_DRIFT_ONLY_LOCAL {
+				jsonDrift.ServerValue = &serverValue
+			}
+		}
+		jsonDrifts = append(jsonDrifts, jsonDrift)
+	}
+
+	output, err := json.MarshalIndent(map[string]interface{}{"drift": len(jsonDrifts) > 0, "secrets": jsonDrifts}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to format the comparison as JSON [err=%v]", err)
+	}
+
+	return string(output), nil
+}
+
+const (
+	SECRET_EVENT_ADDED   = "added"
+	SECRET_EVENT_REMOVED = "removed"
+	SECRET_EVENT_CHANGED = "changed"
+)
+
+var secretsWatchCmd = &cobra.Command{
+	Example:               `secrets watch --env=prod --path=/ --output=json`,
+	Short:                 "Used to print an event each time a secret is added, removed or changed",
+	Use:                   "watch",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		infisicalToken, err := cmd.Flags().GetString("token")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tagSlugs, err := cmd.Flags().GetString("tags")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		interval, err := cmd.Flags().GetDuration("interval")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if interval <= 0 {
+			util.PrintErrorMessageAndExit("--interval must be greater than 0")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if outputFormat != "text" && outputFormat != "json" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --output. Available options are [text, json]", outputFormat))
+		}
+
+		fetchSecrets := func() ([]models.SingleEnvironmentVariable, error) {
+			secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs, SecretsPath: secretsPath})
+			if err != nil {
+				return nil, err
+			}
+			return util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED), nil
+		}
+
+		secrets, err := fetchSecrets()
+		if err != nil {
+			util.HandleError(err, "Unable to fetch secrets")
+		}
+
+		fmt.Fprintf(os.Stderr, "Watching %d secret(s) of [%s] at [%s] every %v, press Ctrl+C to stop\n", len(secrets), environmentName, util.NormalizeSecretsPath(secretsPath), interval)
+
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		watchSecrets(secrets, fetchSecrets, ticker.C, stop, func(event secretChangeEvent) {
+			if outputFormat == "json" {
+				output, _ := json.Marshal(event)
+				fmt.Println(string(output))
+				return
+			}
+
+			switch event.Event {
+			case SECRET_EVENT_ADDED:
+				fmt.Printf("%s %s %s added\n", event.Time.Format(time.RFC3339), color.GreenString("+"), event.Key)
+			case SECRET_EVENT_REMOVED:
+				fmt.Printf("%s %s %s removed\n", event.Time.Format(time.RFC3339), color.RedString("-"), event.Key)
+			default:
+				fmt.Printf("%s %s %s changed\n", event.Time.Format(time.RFC3339), color.YellowString("~"), event.Key)
+			}
+		})
+	},
+}
+
+type secretChangeEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Key   string    `json:"key"`
+}
+
+// Fetches the secrets on every tick and emits an event per secret that differs from the previous fetch, until stop receives.
+// A poll in progress when stop receives is finished and its events emitted first. Failed polls are reported and retried on the next tick
+func watchSecrets(secrets []models.SingleEnvironmentVariable, fetchSecrets func() ([]models.SingleEnvironmentVariable, error), ticks <-chan time.Time, stop <-chan os.Signal, emit func(event secretChangeEvent)) {
+	for {
+		select {
+		case <-stop:
+			return
+		case tick := <-ticks:
+			latestSecrets, err := fetchSecrets()
+			if err != nil {
+				util.PrintWarning(fmt.Sprintf("Unable to fetch secrets, retrying in the next interval [err=%v]", err))
+				continue
+			}
+
+			// compared with the latest fetch, secrets only in the latest are new ones and secrets only in the previous are gone
+			for _, drift := range compareSecrets(latestSecrets, secrets) {
+				event := SECRET_EVENT_CHANGED
+				if drift.Status == SECRET_DRIFT_ONLY_LOCAL {
+					event = SECRET_EVENT_ADDED
+				} else if drift.Status == SECRET_DRIFT_ONLY_SERVER {
+					event = SECRET_EVENT_REMOVED
+				}
+				emit(secretChangeEvent{Time: tick, Event: event, Key: drift.Key})
+			}
+
+			secrets = latestSecrets
+		}
+	}
+}
+
+var secretsCountCmd = &cobra.Command{
+	Example:               `secrets count --env=prod --path=/ --recursive --by-type`,
+	Short:                 "Used to print the number of secrets without printing them",
+	Use:                   "count",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		infisicalToken, err := cmd.Flags().GetString("token")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tagSlugs, err := cmd.Flags().GetString("tags")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		isRecursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldCountByType, err := cmd.Flags().GetBool("by-type")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if outputFormat != "text" && outputFormat != "json" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --output. Available options are [text, json]", outputFormat))
+		}
+
+		secretsPath = util.NormalizeSecretsPath(secretsPath)
+
+		var secrets []models.SingleEnvironmentVariable
+		if isRecursive {
+			// service tokens cannot list the folders of an environment
+			serviceToken, authMethod, err := util.GetServiceTokenToUse(infisicalToken)
+			if err != nil {
+				util.HandleError(err, "Unable to get your credentials")
+			}
+
+			if serviceToken != "" {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("--recursive requires you to be logged in and cannot be used with an Infisical Token, but the %s would be used", authMethod))
+			}
+
+			util.RequireLocalWorkspaceFile()
+			util.RequireLogin()
+
+			workspaceFile, err := util.GetWorkSpaceFromFile()
+			if err != nil {
+				util.HandleError(err, "Unable to get local project details")
+			}
+
+			loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+			if err != nil {
+				util.HandleError(err, "Unable to authenticate")
+			}
+
+			httpClient := api.NewHttpClient().
+				SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+				SetHeader("Accept", "application/json")
+
+			plainTextWorkspaceKey, err := util.GetPlainTextWorkspaceKey(httpClient, loggedInUserDetails.UserCredentials.PrivateKey, workspaceFile.WorkspaceId)
+			if err != nil {
+				util.HandleError(err)
+			}
+
+			var folderResults []util.PathFetchResult
+			folderResults, err = getSecretsOfFolderTree(secretsPath, newFolderFetcher(httpClient, plainTextWorkspaceKey, workspaceFile.WorkspaceId, environmentName, tagSlugs))
+			for _, folderResult := range folderResults {
+				secrets = append(secrets, folderResult.Secrets...)
+			}
+		} else {
+			secrets, err = util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{Environment: environmentName, InfisicalToken: infisicalToken, TagSlugs: tagSlugs, SecretsPath: secretsPath})
+		}
+
+		if err != nil {
+			util.HandleError(err, "Unable to fetch secrets")
+		}
+
+		count := countSecrets(secrets)
+
+		if outputFormat == "json" {
+			report := secretCountReport{Environment: environmentName, Path: secretsPath, Recursive: isRecursive, Total: count.Total}
+			if shouldCountByType {
+				report.Shared, report.Personal = &count.Shared, &count.Personal
+			}
+
+			output, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				util.HandleError(err, "Unable to format the count as json")
+			}
+
+			fmt.Println(string(output))
+			return
+		}
+
+		if !shouldCountByType {
+			fmt.Println(count.Total)
+			return
+		}
+
+		visualize.Table([...]string{"ENVIRONMENT", "SECRET TYPE", "COUNT"}, [][3]string{
+			{environmentName, util.SECRET_TYPE_SHARED, fmt.Sprint(count.Shared)},
+			{environmentName, util.SECRET_TYPE_PERSONAL, fmt.Sprint(count.Personal)},
+			{environmentName, "total", fmt.Sprint(count.Total)},
+		})
+	},
+}
+
+type secretCount struct {
+	Total    int
+	Shared   int
+	Personal int
+}
+
+type secretCountReport struct {
+	Environment string `json:"environment"`
+	Path        string `json:"path"`
+	Recursive   bool   `json:"recursive"`
+	Total       int    `json:"total"`
+	Shared      *int   `json:"shared,omitempty"`
+	Personal    *int   `json:"personal,omitempty"`
+}
+
+// Counts the shared and personal secrets. A personal override counts on its own, next to the shared secret it overrides
+func countSecrets(secrets []models.SingleEnvironmentVariable) secretCount {
+	count := secretCount{Total: len(secrets)}
+	for _, secret := range secrets {
+		if secret.Type == util.SECRET_TYPE_PERSONAL {
+			count.Personal++
+		} else {
+			count.Shared++
+		}
+	}
+
+	return count
+}
+
+// Returns the secrets found directly in a folder and the names of its sub folders
+type folderFetcherFunc func(folderPath string) ([]models.SingleEnvironmentVariable, []string, error)
+
+// Fetches folders with the credentials of the logged in user, decrypting their secrets with the workspace key
+func newFolderFetcher(httpClient *resty.Client, workspaceKey []byte, workspaceId string, environmentName string, tagSlugs string) folderFetcherFunc {
+	return func(folderPath string) ([]models.SingleEnvironmentVariable, []string, error) {
+		encryptedSecrets, err := api.CallGetSecretsV2(httpClient, api.GetEncryptedSecretsV2Request{
+			WorkspaceId: workspaceId,
+			Environment: environmentName,
+			TagSlugs:    tagSlugs,
+			SecretsPath: folderPath,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		plainTextSecrets, err := util.GetPlainTextSecrets(workspaceKey, encryptedSecrets)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to decrypt your secrets [err=%v]", err)
+		}
+
+		folderNames := []string{}
+		for _, folder := range encryptedSecrets.Folders {
+			folderNames = append(folderNames, folder.Name)
+		}
+
+		return plainTextSecrets, folderNames, nil
+	}
+}
+
+// Fetches the folder at rootPath and all folders nested inside it, parents before their sub folders
+func getSecretsOfFolderTree(rootPath string, fetchFolder folderFetcherFunc) ([]util.PathFetchResult, error) {
+	folderResults := []util.PathFetchResult{}
+	pendingPaths := []string{util.NormalizeSecretsPath(rootPath)}
+	for len(pendingPaths) > 0 {
+		folderPath := pendingPaths[0]
+		pendingPaths = pendingPaths[1:]
+
+		secrets, folderNames, err := fetchFolder(folderPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch secrets of path [%s] [err=%v]", folderPath, err)
+		}
+
+		folderResults = append(folderResults, util.PathFetchResult{Path: folderPath, Secrets: secrets})
+		for _, folderName := range folderNames {
+			pendingPaths = append(pendingPaths, util.NormalizeSecretsPath(folderPath+"/"+folderName))
+		}
+	}
+
+	return folderResults, nil
+}
+
+// Formats the secrets as a JSON array sorted by name. The value field is left out entirely with keysOnly, rather than printed empty,
+// so that tooling cannot mistake it for an empty secret
+func formatSecretsListAsJSON(secrets []models.SingleEnvironmentVariable, keysOnly bool) (string, error) {
+	type jsonSecret struct {
+		Key     string  `json:"key"`
+		Value   *string `json:"value,omitempty"`
+		Type    string  `json:"type"`
+		Comment string  `json:"comment"`
+	}
+
+	jsonSecrets := []jsonSecret{}
+	for _, secret := range secrets {
+		jsonSecret := jsonSecret{Key: secret.Key, Type: secret.Type, Comment: secret.Comment}
+		if !keysOnly {
+			value := secret.Value
+			jsonSecret.Value = &value
+		}
+		jsonSecrets = append(jsonSecrets, jsonSecret)
+	}
+
+	sort.Slice(jsonSecrets, func(i, j int) bool {
+		return jsonSecrets[i].Key < jsonSecrets[j].Key
+	})
+
+	output, err := json.MarshalIndent(jsonSecrets, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("unable to format the secrets as JSON [err=%v]", err)
+	}
+
+	return string(output), nil
+}
+
+// Hashes the type, name and value of every secret in an order that does not depend on the order the secrets were fetched in.
+// Each field is prefixed with its length so that moving characters between the name and the value changes the digest
+func getSecretsDigest(secrets []models.SingleEnvironmentVariable) string {
+	sortedSecrets := append([]models.SingleEnvironmentVariable{}, secrets...)
+	sort.SliceStable(sortedSecrets, func(i, j int) bool {
+		if sortedSecrets[i].Key != sortedSecrets[j].Key {
+			return sortedSecrets[i].Key < sortedSecrets[j].Key
+		}
+		if sortedSecrets[i].Type != sortedSecrets[j].Type {
+			return sortedSecrets[i].Type < sortedSecrets[j].Type
+		}
+		return sortedSecrets[i].Value < sortedSecrets[j].Value
+	})
+
+	hash := sha256.New()
+	for _, secret := range sortedSecrets {
+		for _, field := range []string{secret.Type, secret.Key, secret.Value} {
+			fmt.Fprintf(hash, "%d:%s", len(field), field)
+		}
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+const (
+	SECRETS_SNAPSHOT_VERSION             = 1
+	SECRETS_SNAPSHOT_PASSPHRASE_ENV_NAME = "INFISICAL_BACKUP_PASSPHRASE"
+	SECRETS_SNAPSHOT_KEY_DERIVATION      = "argon2id"
+	RESTORE_CONFLICT_FAIL                = "fail"
+	RESTORE_CONFLICT_SKIP                = "skip"
+	RESTORE_CONFLICT_OVERWRITE           = "overwrite"
+	RESTORE_OPERATION_CREATE_FOLDER      = "CREATE FOLDER"
+	RESTORE_OPERATION_SKIP               = "SKIP"
+)
+
+var secretsAccessCmd = &cobra.Command{
+	Example:               `secrets access --env=prod`,
+	Short:                 "Used to check whether you can read and write the secrets of an environment before using them",
+	Use:                   "access",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		infisicalToken, err := cmd.Flags().GetString("token")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if outputFormat != "text" && outputFormat != "json" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --output. Available options are [text, json]", outputFormat))
+		}
+
+		// check the same credentials that fetching the secrets would use
+		infisicalToken, _, err = util.GetServiceTokenToUse(infisicalToken)
+		if err != nil {
+			util.HandleError(err, "Unable to get your credentials")
+		}
+
+		var access secretsAccess
+		if infisicalToken != "" {
+			serviceTokenParts := strings.SplitN(infisicalToken, ".", 4)
+			if len(serviceTokenParts) < 4 {
+				util.PrintErrorMessageAndExit("invalid service token entered. Please double check your service token and try again")
+			}
+
+			httpClient := api.NewHttpClient().
+				SetAuthToken(strings.Join(serviceTokenParts[:3], ".")).
+				SetHeader("Accept", "application/json")
+
+			serviceTokenDetails, err := api.CallGetServiceTokenDetailsV2(httpClient)
+			if err != nil {
+				util.HandleError(err, "Unable to get the details of your service token")
+			}
+
+			// service tokens fetch the secrets of their own environment unless another one is asked for
+			if !cmd.Flags().Changed("env") {
+				environmentName = serviceTokenDetails.Environment
+			}
+
+			access = getServiceTokenAccess(serviceTokenDetails, environmentName)
+		} else {
+			util.RequireLocalWorkspaceFile()
+			util.RequireLogin()
+
+			workspaceFile, err := util.GetWorkSpaceFromFile()
+			if err != nil {
+				util.HandleError(err, "Unable to get local project details")
+			}
+
+			loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+			if err != nil {
+				util.HandleError(err, "Unable to authenticate")
+			}
+
+			httpClient := api.NewHttpClient().
+				SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+				SetHeader("Accept", "application/json")
+
+			accessibleEnvironments, err := api.CallGetAccessibleEnvironments(httpClient, api.GetAccessibleEnvironmentsRequest{WorkspaceId: workspaceFile.WorkspaceId})
+			if err != nil {
+				util.HandleError(err, "Unable to get the environments you have access to")
+			}
+
+			access = getUserAccess(accessibleEnvironments, loggedInUserDetails.UserCredentials.Email, environmentName)
+		}
+
+		if outputFormat == "json" {
+			output, err := json.MarshalIndent(access, "", "  ")
+			if err != nil {
+				util.HandleError(err, "Unable to format the access summary as json")
+			}
+			fmt.Println(string(output))
+		} else {
+			write := "unknown"
+			if access.Write != nil && *access.Write {
+				write = "yes"
+			} else if access.Write != nil {
+				write = "no"
+			}
+
+			read := "no"
+			if access.Read {
+				read = "yes"
+			}
+
+			fmt.Printf("Identity:    %s\nEnvironment: %s\nRead:        %s\nWrite:       %s\n", access.Identity, access.Environment, read, write)
+		}
+
+		if !access.Read {
+			os.Exit(1)
+		}
+	},
+}
+
+var secretsBackupCmd = &cobra.Command{
+	Example:               `secrets backup --env=prod --path=/ --recursive --out=backup.json --encrypt`,
+	Short:                 "Used to save the secrets and folders of an environment to a file that secrets restore can recreate them from",
+	Use:                   "backup",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		util.RequireLocalWorkspaceFile()
+		util.RequireLogin()
+
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		tagSlugs, err := cmd.Flags().GetString("tags")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		isRecursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFile, err := cmd.Flags().GetString("out")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldEncrypt, err := cmd.Flags().GetBool("encrypt")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		passphrase := ""
+		if shouldEncrypt {
+			passphrase, err = util.ReadPassphrase(SECRETS_SNAPSHOT_PASSPHRASE_ENV_NAME, "Passphrase to encrypt the backup with", true)
+			if err != nil {
+				util.HandleError(err, "Unable to read the passphrase")
+			}
+		}
+
+		workspaceFile, err := util.GetWorkSpaceFromFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get local project details")
+		}
+
+		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+		if err != nil {
+			util.HandleError(err, "Unable to authenticate")
+		}
+
+		httpClient := api.NewHttpClient().
+			SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+			SetHeader("Accept", "application/json")
+
+		plainTextWorkspaceKey, err := util.GetPlainTextWorkspaceKey(httpClient, loggedInUserDetails.UserCredentials.PrivateKey, workspaceFile.WorkspaceId)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		secretsPath = util.NormalizeSecretsPath(secretsPath)
+		fetchFolder := newFolderFetcher(httpClient, plainTextWorkspaceKey, workspaceFile.WorkspaceId, environmentName, tagSlugs)
+
+		var folderResults []util.PathFetchResult
+		if isRecursive {
+			folderResults, err = getSecretsOfFolderTree(secretsPath, fetchFolder)
+		} else {
+			var secrets []models.SingleEnvironmentVariable
+			secrets, _, err = fetchFolder(secretsPath)
+			folderResults = []util.PathFetchResult{{Path: secretsPath, Secrets: secrets}}
+		}
+		if err != nil {
+			util.HandleError(err, "Unable to fetch secrets")
+		}
+
+		snapshot := buildSecretsSnapshot(folderResults, workspaceFile.WorkspaceId, environmentName, secretsPath, time.Now().UTC())
+		content, err := encodeSecretsSnapshot(snapshot, passphrase)
+		if err != nil {
+			util.HandleError(err, "Unable to create the backup")
+		}
+
+		// the backup holds the plain text values unless encrypted, so only the current user may read it
+		err = util.WriteToFileAtomically(outputFile, content, 0600)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to write the backup to [%s]", outputFile))
+		}
+
+		secretsCount := 0
+		for _, folder := range snapshot.Folders {
+			secretsCount += len(folder.Secrets)
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Saved %d secret(s) of %d folder(s) of [%s] to [%s]", secretsCount, len(snapshot.Folders), environmentName, outputFile))
+	},
+}
+
+var secretsRestoreCmd = &cobra.Command{
+	Example:               `secrets restore --in=backup.json --env=prod --dry-run`,
+	Short:                 "Used to recreate the secrets and folders saved by secrets backup",
+	Use:                   "restore",
+	DisableFlagsInUseLine: true,
+	PreRun:                toggleDebug,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		util.RequireLocalWorkspaceFile()
+		util.RequireLogin()
+
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		inputFile, err := cmd.Flags().GetString("in")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		onConflict, err := cmd.Flags().GetString("on-conflict")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if onConflict != RESTORE_CONFLICT_FAIL && onConflict != RESTORE_CONFLICT_SKIP && onConflict != RESTORE_CONFLICT_OVERWRITE {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --on-conflict. Available options are [%s, %s, %s]", onConflict, RESTORE_CONFLICT_FAIL, RESTORE_CONFLICT_SKIP, RESTORE_CONFLICT_OVERWRITE))
+		}
+
+		isDryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		content, err := os.ReadFile(inputFile)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to read the backup [%s]", inputFile))
+		}
+
+		snapshot, err := decodeSecretsSnapshot(content, func() (string, error) {
+			return util.ReadPassphrase(SECRETS_SNAPSHOT_PASSPHRASE_ENV_NAME, "Passphrase the backup was encrypted with", false)
+		})
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to read the backup [%s]", inputFile))
+		}
+
+		workspaceFile, err := util.GetWorkSpaceFromFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get local project details")
+		}
+
+		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails()
+		if err != nil {
+			util.HandleError(err, "Unable to authenticate")
+		}
+
+		httpClient := api.NewHttpClient().
+			SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+			SetHeader("Accept", "application/json")
+
+		plainTextWorkspaceKey, err := util.GetPlainTextWorkspaceKey(httpClient, loggedInUserDetails.UserCredentials.PrivateKey, workspaceFile.WorkspaceId)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		// tags are referenced by id, which only exists in the project the backup was taken from
+		shouldRestoreTags := snapshot.Project == workspaceFile.WorkspaceId
+		if !shouldRestoreTags && snapshotHasTags(snapshot) {
+			util.PrintWarning(fmt.Sprintf("The backup was taken from another project [%s], the tags of its secrets will not be restored", snapshot.Project))
+		}
+
+		existingFolders := map[string]existingFolder{}
+		for _, folderPath := range getSnapshotFolderPaths(snapshot) {
+			folder := existingFolder{}
+			parent, hasParent := existingFolders[getParentFolderPath(folderPath)]
+			if folderPath == "/" {
+				folder.exists = true
+			} else if hasParent && parent.exists {
+				folder.id, folder.exists = parent.subFolderIds[getFolderName(folderPath)]
+			}
+
+			if folder.exists {
+				encryptedSecrets, secrets, err := getSecretsAtPath(httpClient, plainTextWorkspaceKey, workspaceFile.WorkspaceId, environmentName, folderPath)
+				if err != nil {
+					util.HandleError(err, fmt.Sprintf("Unable to fetch the secrets of [%s]", folderPath))
+				}
+
+				folder.secrets = secrets
+				folder.subFolderIds = map[string]string{}
+				for _, subFolder := range encryptedSecrets.Folders {
+					folder.subFolderIds[subFolder.Name] = subFolder.ID
+				}
+			}
+
+			existingFolders[folderPath] = folder
+		}
+
+		operations, err := planSecretsRestore(snapshot, existingFolders, onConflict)
+		if err != nil {
+			util.PrintErrorMessageAndExit(err.Error())
+		}
+
+		if isDryRun {
+			rows := [][3]string{}
+			for _, operation := range operations {
+				rows = append(rows, [...]string{operation.folderPath, operation.secret.Key, operation.method})
+			}
+
+			visualize.Table([...]string{"FOLDER", "SECRET NAME", "OPERATION"}, rows)
+			fmt.Println("Dry run, no secrets have been changed")
+			return
+		}
+
+		folderIds := map[string]string{}
+		for folderPath, folder := range existingFolders {
+			if folder.exists {
+				folderIds[folderPath] = folder.id
+			}
+		}
+
+		statuses := make([]string, len(operations))
+		var restoreErr error
+		for idx, operation := range operations {
+			if operation.method != RESTORE_OPERATION_CREATE_FOLDER || restoreErr != nil {
+				continue
+			}
+
+			createdFolder, err := api.CallCreateFolder(httpClient, api.CreateFolderRequest{
+				WorkspaceId:    workspaceFile.WorkspaceId,
+				Environment:    environmentName,
+				FolderName:     getFolderName(operation.folderPath),
+				ParentFolderId: folderIds[getParentFolderPath(operation.folderPath)],
+			})
+			if err != nil {
+				restoreErr = fmt.Errorf("unable to create the folder [%s] [err=%v]", operation.folderPath, err)
+				statuses[idx] = "FAILED"
+				continue
+			}
+
+			folderIds[operation.folderPath] = createdFolder.Folder.ID
+			statuses[idx] = "DONE"
+		}
+
+		// the secrets of each folder are sent as a separate batch so that the result of each folder can be reported
+		for _, folder := range snapshot.Folders {
+			batch := []api.BatchSecretRequest{}
+			batchIndexes := []int{}
+			for idx, operation := range operations {
+				if operation.folderPath != folder.Path || (operation.method != "POST" && operation.method != "PATCH") {
+					continue
+				}
+
+				request, err := operation.toBatchRequest(plainTextWorkspaceKey, folderIds[folder.Path], shouldRestoreTags)
+				if err != nil {
+					util.HandleError(err, "Unable to encrypt your secrets")
+				}
+
+				batch = append(batch, request)
+				batchIndexes = append(batchIndexes, idx)
+			}
+
+			if len(batch) == 0 {
+				continue
+			}
+
+			status := "DONE"
+			if restoreErr != nil {
+				status = "SKIPPED"
+			} else if err := api.CallBatchSecrets(httpClient, api.BatchSecretsRequest{WorkspaceId: workspaceFile.WorkspaceId, Environment: environmentName, Requests: batch}); err != nil {
+				restoreErr = fmt.Errorf("unable to restore the secrets of [%s] [err=%v]", folder.Path, err)
+				status = "FAILED"
+			}
+
+			for _, idx := range batchIndexes {
+				statuses[idx] = status
+			}
+		}
+
+		rows := [][3]string{}
+		for idx, operation := range operations {
+			// operations skipped because of a conflict or an earlier failure have no status
+			status := statuses[idx]
+			if status == "" {
+				status = "SKIPPED"
+			}
+			rows = append(rows, [...]string{operation.folderPath, operation.secret.Key, status})
+		}
+
+		visualize.Table([...]string{"FOLDER", "SECRET NAME", "STATUS"}, rows)
+
+		if restoreErr != nil {
+			util.HandleError(restoreErr, "The backup could only be partially restored")
+		}
+	},
+}
+
+// The file written by secrets backup. It holds either the snapshot or the snapshot encrypted with a passphrase
+type secretsSnapshotFile struct {
+	Version   int                       `json:"version"`
+	Snapshot  *secretsSnapshot          `json:"snapshot,omitempty"`
+	Encrypted *encryptedSecretsSnapshot `json:"encrypted,omitempty"`
+}
+
+// A restorable copy of the secrets and folders of an environment. SECRETS_SNAPSHOT_VERSION must be raised whenever
+// the format changes in a way older versions of the CLI would restore wrongly
+type secretsSnapshot struct {
+	CreatedAt   time.Time        `json:"createdAt"`
+	Project     string           `json:"project"`
+	Environment string           `json:"environment"`
+	Path        string           `json:"path"`
+	Folders     []snapshotFolder `json:"folders"`
+}
+
+type snapshotFolder struct {
+	Path    string           `json:"path"`
+	Secrets []snapshotSecret `json:"secrets"`
+}
+
+type snapshotSecret struct {
+	Key     string        `json:"key"`
+	Value   string        `json:"value"`
+	Type    string        `json:"type"`
+	Comment string        `json:"comment,omitempty"`
+	Tags    []snapshotTag `json:"tags,omitempty"`
+}
+
+type snapshotTag struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// The json encoded secretsSnapshot encrypted with a key derived from a passphrase
+type encryptedSecretsSnapshot struct {
+	KeyDerivation string `json:"keyDerivation"`
+	Salt          string `json:"salt"`
+	IV            string `json:"iv"`
+	Tag           string `json:"tag"`
+	CipherText    string `json:"cipherText"`
+}
+
+// What a folder targeted by secrets restore looks like before restoring
+type existingFolder struct {
+	exists bool
+	// empty for the root folder
+	id           string
+	secrets      []models.SingleEnvironmentVariable
+	subFolderIds map[string]string
+}
+
+type secretsRestoreOperation struct {
+	folderPath string
+	// POST, PATCH, RESTORE_OPERATION_CREATE_FOLDER or RESTORE_OPERATION_SKIP
+	method string
+	secret snapshotSecret
+	// the secret replaced by a PATCH
+	id string
+}
+
+func buildSecretsSnapshot(folderResults []util.PathFetchResult, workspaceId string, environmentName string, secretsPath string, createdAt time.Time) secretsSnapshot {
+	snapshot := secretsSnapshot{CreatedAt: createdAt, Project: workspaceId, Environment: environmentName, Path: secretsPath, Folders: []snapshotFolder{}}
+	for _, folderResult := range folderResults {
+		folder := snapshotFolder{Path: folderResult.Path, Secrets: []snapshotSecret{}}
+		for _, secret := range folderResult.Secrets {
+			snapshotSecret := snapshotSecret{Key: secret.Key, Value: secret.Value, Type: secret.Type, Comment: secret.Comment}
+			for _, tag := range secret.Tags {
+				snapshotSecret.Tags = append(snapshotSecret.Tags, snapshotTag{ID: tag.ID, Name: tag.Name, Slug: tag.Slug})
+			}
+			folder.Secrets = append(folder.Secrets, snapshotSecret)
+		}
+		snapshot.Folders = append(snapshot.Folders, folder)
+	}
+
+	return snapshot
+}
+
+// Encodes the snapshot as json, encrypted when a passphrase is given
+func encodeSecretsSnapshot(snapshot secretsSnapshot, passphrase string) ([]byte, error) {
+	snapshotFile := secretsSnapshotFile{Version: SECRETS_SNAPSHOT_VERSION, Snapshot: &snapshot}
+	if passphrase != "" {
+		plainText, err := json.Marshal(snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal the backup to JSON [err=%v]", err)
+		}
+
+		salt, err := crypto.GenerateNewKey()
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate a salt [err=%v]", err)
+		}
+
+		encrypted, err := crypto.EncryptSymmetric(plainText, crypto.DeriveKeyFromPassphrase(passphrase, salt))
+		if err != nil {
+			return nil, fmt.Errorf("unable to encrypt the backup [err=%v]", err)
+		}
+
+		snapshotFile = secretsSnapshotFile{Version: SECRETS_SNAPSHOT_VERSION, Encrypted: &encryptedSecretsSnapshot{
+			KeyDerivation: SECRETS_SNAPSHOT_KEY_DERIVATION,
+			Salt:          base64.StdEncoding.EncodeToString(salt),
+			IV:            base64.StdEncoding.EncodeToString(encrypted.Nonce),
+			Tag:           base64.StdEncoding.EncodeToString(encrypted.AuthTag),
+			CipherText:    base64.StdEncoding.EncodeToString(encrypted.CipherText),
+		}}
+	}
+
+	content, err := json.MarshalIndent(snapshotFile, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the backup to JSON [err=%v]", err)
+	}
+
+	return append(content, '\n'), nil
+}
+
+// Decodes a file written by encodeSecretsSnapshot. readPassphrase is only called when the snapshot is encrypted
+func decodeSecretsSnapshot(content []byte, readPassphrase func() (string, error)) (secretsSnapshot, error) {
+	var snapshotFile secretsSnapshotFile
+	if err := json.Unmarshal(content, &snapshotFile); err != nil {
+		return secretsSnapshot{}, fmt.Errorf("the file is not a backup written by secrets backup [err=%v]", err)
+	}
+
+	if snapshotFile.Version < 1 || snapshotFile.Version > SECRETS_SNAPSHOT_VERSION {
+		return secretsSnapshot{}, fmt.Errorf("the backup has version [%d] but this version of the CLI only reads versions up to [%d]. Please update the CLI", snapshotFile.Version, SECRETS_SNAPSHOT_VERSION)
+	}
+
+	if snapshotFile.Encrypted == nil {
+		if snapshotFile.Snapshot == nil {
+			return secretsSnapshot{}, fmt.Errorf("the backup holds no secrets")
+		}
+		return *snapshotFile.Snapshot, nil
+	}
+
+	encrypted := snapshotFile.Encrypted
+	if encrypted.KeyDerivation != SECRETS_SNAPSHOT_KEY_DERIVATION {
+		return secretsSnapshot{}, fmt.Errorf("the backup is encrypted with an unsupported key derivation [%s]", encrypted.KeyDerivation)
+	}
+
+	passphrase, err := readPassphrase()
+	if err != nil {
+		return secretsSnapshot{}, err
+	}
+
+	decodedFields := [][]byte{}
+	for _, field := range []string{encrypted.Salt, encrypted.IV, encrypted.Tag, encrypted.CipherText} {
+		decodedField, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			return secretsSnapshot{}, fmt.Errorf("the encrypted backup is corrupted [err=%v]", err)
+		}
+		decodedFields = append(decodedFields, decodedField)
+	}
+
+	plainText, err := crypto.DecryptSymmetric(crypto.DeriveKeyFromPassphrase(passphrase, decodedFields[0]), decodedFields[3], decodedFields[2], decodedFields[1])
+	if err != nil {
+		return secretsSnapshot{}, fmt.Errorf("unable to decrypt the backup, the passphrase is probably wrong")
+	}
+
+	var snapshot secretsSnapshot
+	if err := json.Unmarshal(plainText, &snapshot); err != nil {
+		return secretsSnapshot{}, fmt.Errorf("the encrypted backup is corrupted [err=%v]", err)
+	}
+
+	return snapshot, nil
+}
+
+func snapshotHasTags(snapshot secretsSnapshot) bool {
+	for _, folder := range snapshot.Folders {
+		for _, secret := range folder.Secrets {
+			if len(secret.Tags) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The folders of the snapshot and all of their parents, parents first
+func getSnapshotFolderPaths(snapshot secretsSnapshot) []string {
+	isListed := map[string]bool{}
+	folderPaths := []string{}
+	for _, folder := range snapshot.Folders {
+		for folderPath := util.NormalizeSecretsPath(folder.Path); !isListed[folderPath]; folderPath = getParentFolderPath(folderPath) {
+			isListed[folderPath] = true
+			folderPaths = append(folderPaths, folderPath)
+			if folderPath == "/" {
+				break
+			}
+		}
+	}
+
+	getDepth := func(folderPath string) int {
+		if folderPath == "/" {
+			return 0
+		}
+		return strings.Count(folderPath, "/")
+	}
+
+	sort.SliceStable(folderPaths, func(i, j int) bool {
+		if getDepth(folderPaths[i]) != getDepth(folderPaths[j]) {
+			return getDepth(folderPaths[i]) < getDepth(folderPaths[j])
+		}
+		return folderPaths[i] < folderPaths[j]
+	})
+
+	return folderPaths
+}
+
+func getParentFolderPath(folderPath string) string {
+	return util.NormalizeSecretsPath(folderPath[:strings.LastIndex(folderPath, "/")])
+}
+
+func getFolderName(folderPath string) string {
+	return folderPath[strings.LastIndex(folderPath, "/")+1:]
+}
+
+// Plans the folders to create and the secrets to create or overwrite. A secret conflicts with an existing secret of
+// the same name and type in the same folder. With RESTORE_CONFLICT_FAIL, all conflicts are listed and nothing is planned
+func planSecretsRestore(snapshot secretsSnapshot, existingFolders map[string]existingFolder, onConflict string) ([]secretsRestoreOperation, error) {
+	operations := []secretsRestoreOperation{}
+	for _, folderPath := range getSnapshotFolderPaths(snapshot) {
+		if !existingFolders[folderPath].exists {
+			operations = append(operations, secretsRestoreOperation{folderPath: folderPath, method: RESTORE_OPERATION_CREATE_FOLDER})
+		}
+	}
+
+	conflicts := []string{}
+	for _, folder := range snapshot.Folders {
+		folderPath := util.NormalizeSecretsPath(folder.Path)
+		existingSecretIds := map[string]string{}
+		for _, secret := range existingFolders[folderPath].secrets {
+			existingSecretIds[secret.Type+"/"+secret.Key] = secret.ID
+		}
+
+		for _, secret := range folder.Secrets {
+			existingSecretId, isConflict := existingSecretIds[secret.Type+"/"+secret.Key]
+			switch {
+			case !isConflict:
+				operations = append(operations, secretsRestoreOperation{folderPath: folderPath, method: "POST", secret: secret})
+			case onConflict == RESTORE_CONFLICT_SKIP:
+				operations = append(operations, secretsRestoreOperation{folderPath: folderPath, method: RESTORE_OPERATION_SKIP, secret: secret})
+			case onConflict == RESTORE_CONFLICT_OVERWRITE:
+				operations = append(operations, secretsRestoreOperation{folderPath: folderPath, method: "PATCH", secret: secret, id: existingSecretId})
+			default:
+				conflicts = append(conflicts, util.NormalizeSecretsPath(folderPath+"/"+secret.Key))
+			}
+		}
+	}
+
+	if len(conflicts) != 0 {
+		return nil, fmt.Errorf("secret(s) [%v] already exist. Use --on-conflict=skip to keep them or --on-conflict=overwrite to replace them", strings.Join(conflicts, ", "))
+	}
+
+	return operations, nil
+}
+
+func (operation secretsRestoreOperation) toBatchRequest(workspaceKey []byte, folderId string, shouldRestoreTags bool) (api.BatchSecretRequest, error) {
+	secret := api.BatchSecret{ID: operation.id, FolderId: folderId, SecretName: operation.secret.Key}
+
+	var err error
+	secret.SecretValueCiphertext, secret.SecretValueIV, secret.SecretValueTag, err = encryptForBatchRequest(operation.secret.Value, workspaceKey)
+	if err != nil {
+		return api.BatchSecretRequest{}, err
+	}
+
+	secret.SecretCommentCiphertext, secret.SecretCommentIV, secret.SecretCommentTag, err = encryptForBatchRequest(operation.secret.Comment, workspaceKey)
+	if err != nil {
+		return api.BatchSecretRequest{}, err
+	}
+
+	if operation.method == "POST" {
+		secret.Type = operation.secret.Type
+		secret.SecretKeyCiphertext, secret.SecretKeyIV, secret.SecretKeyTag, err = encryptForBatchRequest(operation.secret.Key, workspaceKey)
+		if err != nil {
+			return api.BatchSecretRequest{}, err
+		}
+	}
+
+	if shouldRestoreTags {
+		for _, tag := range operation.secret.Tags {
+			secret.Tags = append(secret.Tags, tag.ID)
+		}
+	}
+
+	return api.BatchSecretRequest{Method: operation.method, Secret: secret}, nil
+}
+
+func setEncryptedComment(secret *api.Secret, encryptedComment api.Secret) {
+	secret.SecretCommentCiphertext = encryptedComment.SecretCommentCiphertext
+	secret.SecretCommentIV = encryptedComment.SecretCommentIV
+	secret.SecretCommentTag = encryptedComment.SecretCommentTag
+	secret.SecretCommentHash = encryptedComment.SecretCommentHash
+}
+
+// Resolves comma separated tag slugs to the ids of the tags of the project
+func getTagIdsBySlugs(workspaceTags api.GetWorkspaceTagsResponse, tagSlugs string) ([]string, error) {
+	tagIdsBySlug := make(map[string]string)
+	availableSlugs := []string{}
+	for _, tag := range workspaceTags.WorkspaceTags {
+		tagIdsBySlug[tag.Slug] = tag.ID
+		availableSlugs = append(availableSlugs, tag.Slug)
+	}
+
+	tagIds := []string{}
+	for _, tagSlug := range strings.Split(tagSlugs, ",") {
+		tagSlug = strings.TrimSpace(tagSlug)
+		if tagSlug == "" {
+			continue
+		}
+
+		tagId, ok := tagIdsBySlug[tagSlug]
+		if !ok {
+			return nil, fmt.Errorf("the tag [%s] does not exist in your project. Available tags are [%s]", tagSlug, strings.Join(availableSlugs, ", "))
+		}
+		tagIds = append(tagIds, tagId)
+	}
+
+	return tagIds, nil
+}
+
+func haveSameTagIds(secret models.SingleEnvironmentVariable, tagIds []string) bool {
+	if len(secret.Tags) != len(tagIds) {
+		return false
+	}
+
+	existingTagIds := make(map[string]bool)
+	for _, tag := range secret.Tags {
+		existingTagIds[tag.ID] = true
+	}
+
+	for _, tagId := range tagIds {
+		if !existingTagIds[tagId] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func encryptForBatchRequest(plainText string, workspaceKey []byte) (cipherText string, iv string, tag string, err error) {
+	encrypted, err := crypto.EncryptSymmetric([]byte(plainText), workspaceKey)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(encrypted.CipherText), base64.StdEncoding.EncodeToString(encrypted.Nonce), base64.StdEncoding.EncodeToString(encrypted.AuthTag), nil
+}
+
+// Permissions apply to a whole environment, the API has no permissions per folder. Write is nil when it cannot be known
+type secretsAccess struct {
+	Identity    string `json:"identity"`
+	Environment string `json:"environment"`
+	Read        bool   `json:"read"`
+	Write       *bool  `json:"write"`
+}
+
+// Environments the user is not a member of are left out of the accessible environments
+func getUserAccess(accessibleEnvironments api.GetAccessibleEnvironmentsResponse, email string, environmentName string) secretsAccess {
+	access := secretsAccess{Identity: email, Environment: environmentName, Write: new(bool)}
+	for _, environment := range accessibleEnvironments.AccessibleEnvironments {
+		if environment.Slug == environmentName {
+			access.Read = true
+			*access.Write = !environment.IsWriteDenied
+		}
+	}
+	return access
+}
+
+// Service tokens can read the one environment they were created for. Whether they can write is not returned by the API
+func getServiceTokenAccess(serviceTokenDetails api.GetServiceTokenDetailsResponse, environmentName string) secretsAccess {
+	access := secretsAccess{Identity: "service token " + serviceTokenDetails.Name, Environment: environmentName}
+	if serviceTokenDetails.Environment == environmentName {
+		access.Read = true
+	} else {
+		access.Write = new(bool)
+	}
+	return access
+}
+
+// Runs the --from-command of [infisical secrets set] with the shell used by [infisical run --command] and returns its
+// output, without surrounding whitespace when shouldTrim is set. A failing command is an error holding its stderr
+func runValueCommand(command string, shouldTrim bool) (string, error) {
+	shell := getShellInvocation("", runtime.GOOS, os.Getenv("SHELL"))
+
+	var stdout, stderr bytes.Buffer
+	valueCmd := exec.Command(shell[0], shell[1], command)
+	valueCmd.Stdin = os.Stdin
+	valueCmd.Stdout = &stdout
+	valueCmd.Stderr = &stderr
+
+	if err := valueCmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("the command exited with code %d: %s", exitErr.ExitCode(), strings.TrimSpace(stderr.String()))
+		}
+		return "", err
+	}
+
+	if shouldTrim {
+		return strings.TrimSpace(stdout.String()), nil
+	}
+	return stdout.String(), nil
+}
+
+// Returns the secrets whose values match valueRegex
+func filterSecretsByValue(secrets []models.SingleEnvironmentVariable, valueRegex *regexp.Regexp) []models.SingleEnvironmentVariable {
+	matchingSecrets := []models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		if valueRegex.MatchString(secret.Value) {
+			matchingSecrets = append(matchingSecrets, secret)
+		}
+	}
+	return matchingSecrets
+}
+
+// the columns [infisical secrets --columns] can show, in the order they are listed in errors
+const (
+	SECRET_COLUMN_KEY        = "key"
+	SECRET_COLUMN_VALUE      = "value"
+	SECRET_COLUMN_TYPE       = "type"
+	SECRET_COLUMN_COMMENT    = "comment"
+	SECRET_COLUMN_TAGS       = "tags"
+	SECRET_COLUMN_VERSION    = "version"
+	SECRET_COLUMN_UPDATED_AT = "updatedAt"
+)
+
+var secretColumns = []string{SECRET_COLUMN_KEY, SECRET_COLUMN_VALUE, SECRET_COLUMN_TYPE, SECRET_COLUMN_COMMENT, SECRET_COLUMN_TAGS, SECRET_COLUMN_VERSION, SECRET_COLUMN_UPDATED_AT}
+
+var secretColumnHeaders = map[string]string{
+	SECRET_COLUMN_KEY:        "SECRET NAME",
+	SECRET_COLUMN_VALUE:      "SECRET VALUE",
+	SECRET_COLUMN_TYPE:       "SECRET TYPE",
+	SECRET_COLUMN_COMMENT:    "SECRET COMMENT",
+	SECRET_COLUMN_TAGS:       "TAGS",
+	SECRET_COLUMN_VERSION:    "VERSION",
+	SECRET_COLUMN_UPDATED_AT: "UPDATED AT",
+}
+
+// printed instead of the values of the secrets with [infisical secrets --mask]
+const SECRET_VALUE_MASK = "********"
+
+// Returns the columns with the spelling of secretColumns whatever their case. Unknown columns are an error listing the valid ones
+func normalizeSecretColumns(columns []string) ([]string, error) {
+	normalizedColumns := []string{}
+	for _, column := range columns {
+		normalizedColumn := ""
+		for _, secretColumn := range secretColumns {
+			if strings.EqualFold(strings.TrimSpace(column), secretColumn) {
+				normalizedColumn = secretColumn
+			}
+		}
+
+		if normalizedColumn == "" {
+			return nil, fmt.Errorf("unknown column [%s]. Available columns are [%s]", column, strings.Join(secretColumns, ", "))
+		}
+		normalizedColumns = append(normalizedColumns, normalizedColumn)
+	}
+
+	return normalizedColumns, nil
+}
+
+func hasSecretColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the headers and rows of a table of the secrets with the given columns, in that order
+func getSecretsTable(secrets []models.SingleEnvironmentVariable, columns []string) ([]string, [][]string) {
+	headers := []string{}
+	for _, column := range columns {
+		headers = append(headers, secretColumnHeaders[column])
+	}
+
+	rows := [][]string{}
+	for _, secret := range secrets {
+		row := []string{}
+		for _, column := range columns {
+			row = append(row, getSecretColumnValue(secret, column))
+		}
+		rows = append(rows, row)
+	}
+
+	return headers, rows
+}
+
+func getSecretColumnValue(secret models.SingleEnvironmentVariable, column string) string {
+	switch column {
+	case SECRET_COLUMN_KEY:
+		return secret.Key
+	case SECRET_COLUMN_VALUE:
+		return secret.Value
+	case SECRET_COLUMN_TYPE:
+		return secret.Type
+	case SECRET_COLUMN_COMMENT:
+		return secret.Comment
+	case SECRET_COLUMN_TAGS:
+		tagSlugs := []string{}
+		for _, tag := range secret.Tags {
+			tagSlugs = append(tagSlugs, tag.Slug)
+		}
+		return strings.Join(tagSlugs, ", ")
+	case SECRET_COLUMN_VERSION:
+		if secret.Version == 0 {
+			return ""
+		}
+		return strconv.Itoa(secret.Version)
+	case SECRET_COLUMN_UPDATED_AT:
+		if secret.UpdatedAt.IsZero() {
+			return ""
+		}
+		return secret.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+func CenterString(s string, numStars int) string {
+	stars := strings.Repeat("*", numStars)
+	padding := (numStars - len(s)) / 2
+	cenetredTextWithStar := stars[:padding] + " " + strings.ToUpper(s) + " " + stars[padding:]
+
+	hashes := strings.Repeat("#", len(cenetredTextWithStar)+2)
+	return fmt.Sprintf("%s \n# %s \n%s", hashes, cenetredTextWithStar, hashes)
+}
+
+func addHash(input string) string {
+	lines := strings.Split(input, "\n")
 	for i, line := range lines {
 		lines[i] = "# " + line
 	}
@@ -605,23 +3678,137 @@ func init() {
 	secretsCmd.AddCommand(secretsGenerateExampleEnvCmd)
 
 	secretsGetCmd.Flags().String("token", "", "Fetch secrets using the Infisical Token")
+	secretsGetCmd.Flags().Bool("all-envs", false, "Get the secret from every environment of the project you have access to")
+	secretsGetCmd.Flags().Bool("show-values", false, "Print the values with --all-envs instead of a fingerprint of them")
+	secretsGetCmd.Flags().Int("parallel-env-fetch", util.MAX_CONCURRENT_FETCHES, "The number of environments fetched at the same time with --all-envs. 1 fetches them one after the other")
+	secretsGetCmd.Flags().Bool("keep-going", false, "With --all-envs, print the environments that could be fetched and report the failed ones instead of stopping at the first failure. Still exits non-zero if any environment failed")
+	secretsGetCmd.Flags().String("output", GET_OUTPUT_TABLE, "The format to print the secrets in (table, json, shell). json prints an object of the names to the values, shell prints export KEY='value' lines. Only table and json can be used with --all-envs")
+	secretsGetCmd.Flags().StringSlice("multiple", []string{}, "Names of the secrets to get, comma separated, in addition to the ones given as arguments (e.g. DB_USER,DB_PASS)")
+	secretsGetCmd.Flags().Bool("allow-missing", false, "With --format or --output json or shell, print the secrets that exist and warn about the missing ones instead of failing")
+	secretsGetCmd.Flags().Bool("parse-json", false, "Check that the value of the secret is JSON and pretty print it instead of printing a table")
+	secretsGetCmd.Flags().String("default", "", "Print the value of the secret, or this value when the secret does not exist, instead of a table. Takes a single secret name")
+	secretsGetCmd.Flags().String("format", "", "Print the secrets as KEY='value' lines that can be passed to eval instead of a table (shell, export). export prefixes every line with export")
+	secretsGetCmd.Flags().Bool("wait-for-value", false, "Fetch the secrets again until they all exist with a non-empty value, then print them. Exits with code 3 when --wait-timeout elapses first")
+	secretsGetCmd.Flags().Duration("wait-timeout", 60*time.Second, "maximum time to wait for the secrets with --wait-for-value")
+	secretsGetCmd.Flags().Duration("wait-interval", 2*time.Second, "time between the fetches with --wait-for-value")
+	secretsGetCmd.Flags().String("jq", "", "Print the field at this path of a JSON valued secret, for example .db.hosts[0]. Implies --parse-json")
 	secretsCmd.AddCommand(secretsGetCmd)
 
+	secretsSetCmd.Flags().String("from-command", "", "Set the value of a single secret to the output of this command, run by your shell (e.g. 'openssl rand -hex 16')")
+	secretsSetCmd.Flags().Bool("stdin-json", false, "Read the secrets to set from a JSON object of names to values on stdin, e.g. the output of [infisical export --format json]")
+	secretsSetCmd.Flags().Bool("stringify-nested", false, "With --stdin-json, set nested objects and arrays as their JSON instead of refusing them")
+	secretsSetCmd.Flags().Bool("no-trim", false, "Keep the whitespace and trailing newline around the output of --from-command")
+	secretsSetCmd.Flags().String("comment", "", "Set the comment of the created or updated secrets")
+	secretsSetCmd.Flags().String("set-tags", "", "Set the tags of the created or updated secrets to these comma separated tag slugs, replacing their current tags")
 	secretsCmd.AddCommand(secretsSetCmd)
 	secretsSetCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		resolveConfiguredNames(cmd)
 		util.RequireLogin()
 		util.RequireLocalWorkspaceFile()
 	}
 
 	secretsCmd.AddCommand(secretsDeleteCmd)
 	secretsDeleteCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		resolveConfiguredNames(cmd)
+		util.RequireLogin()
+		util.RequireLocalWorkspaceFile()
+	}
+
+	secretsMoveCmd.Flags().String("from-path", "/", "The folder to move the secrets from")
+	secretsMoveCmd.Flags().String("to-path", "", "The folder to move the secrets to")
+	secretsMoveCmd.Flags().String("prefix", "", "Move all secrets whose name starts with this prefix")
+	secretsMoveCmd.Flags().Bool("overwrite", false, "Replace secrets that already exist in the destination folder")
+	secretsMoveCmd.Flags().Bool("dry-run", false, "Print the secrets that would be moved without moving them")
+	secretsMoveCmd.MarkFlagRequired("to-path")
+	secretsCmd.AddCommand(secretsMoveCmd)
+	secretsMoveCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		resolveConfiguredNames(cmd)
+		util.RequireLogin()
+		util.RequireLocalWorkspaceFile()
+	}
+
+	secretsPromoteCmd.Flags().String("from-env", "", "The environment to promote the secrets from")
+	secretsPromoteCmd.Flags().String("to-env", "", "The environment to promote the secrets to")
+	secretsPromoteCmd.Flags().String("path", "/", "The folder whose secrets are promoted, the same in both environments")
+	secretsPromoteCmd.Flags().Bool("dry-run", false, "Print the changes that would be made without making them")
+	secretsPromoteCmd.Flags().Bool("no-approval", false, "Write the changes to the destination environment directly instead of submitting them for approval")
+	secretsPromoteCmd.MarkFlagRequired("from-env")
+	secretsPromoteCmd.MarkFlagRequired("to-env")
+	secretsCmd.AddCommand(secretsPromoteCmd)
+	secretsPromoteCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		resolveConfiguredNames(cmd)
+		util.RequireLogin()
+		util.RequireLocalWorkspaceFile()
+	}
+
+	secretsBulkUpdateCmd.Flags().String("file", "", "The JSON patch with the secrets to create, update and delete")
+	secretsBulkUpdateCmd.Flags().Bool("dry-run", false, "Validate the patch and print the planned operations without applying them")
+	secretsBulkUpdateCmd.MarkFlagRequired("file")
+	secretsCmd.AddCommand(secretsBulkUpdateCmd)
+	secretsBulkUpdateCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		resolveConfiguredNames(cmd)
 		util.RequireLogin()
 		util.RequireLocalWorkspaceFile()
 	}
 
+	secretsLintCmd.Flags().String("token", "", "Fetch secrets using the Infisical Token")
+	secretsLintCmd.Flags().String("path", "/", "The folder to lint the secrets of")
+	secretsLintCmd.Flags().String("config", util.INFISICAL_LINT_CONFIG_FILE_NAME, "The file to read the lint rules from")
+	secretsLintCmd.Flags().Bool("fail", false, "Exit with a non zero code when issues are found")
+	secretsCmd.AddCommand(secretsLintCmd)
+
+	secretsCompareCmd.Flags().String("token", "", "Fetch secrets using the Infisical Token")
+	secretsCompareCmd.Flags().StringArray("file", []string{}, "The dotenv file to compare with the secrets in Infisical. Set it twice to compare two files with each other instead, without calling Infisical")
+	secretsCompareCmd.Flags().String("path", "/", "The folder to compare the file with")
+	secretsCompareCmd.Flags().String("output", "text", "The format of the report (text, json)")
+	secretsCompareCmd.Flags().Bool("show-values", false, "Include the differing values in the report instead of masking them")
+	secretsCompareCmd.MarkFlagRequired("file")
+	secretsCmd.AddCommand(secretsCompareCmd)
+
+	secretsWatchCmd.Flags().String("token", "", "Fetch secrets using the Infisical Token")
+	secretsWatchCmd.Flags().String("path", "/", "The folder to watch the secrets of")
+	secretsWatchCmd.Flags().Duration("interval", 30*time.Second, "How often the secrets are fetched")
+	secretsWatchCmd.Flags().String("output", "text", "The format of the events (text, json). json prints one event per line")
+	secretsCmd.AddCommand(secretsWatchCmd)
+
+	secretsCountCmd.Flags().String("token", "", "Fetch secrets using the Infisical Token")
+	secretsCountCmd.Flags().String("path", "/", "The folder to count the secrets of")
+	secretsCountCmd.Flags().Bool("recursive", false, "Also count the secrets of all folders nested inside --path. Requires you to be logged in")
+	secretsCountCmd.Flags().Bool("by-type", false, "Break the count down into shared and personal secrets")
+	secretsCountCmd.Flags().String("output", "text", "The format of the count (text, json)")
+	secretsCmd.AddCommand(secretsCountCmd)
+
+	secretsAccessCmd.Flags().String("token", "", "Check the access of this Infisical Token instead of your login")
+	secretsAccessCmd.Flags().String("output", "text", "The format of the summary (text, json)")
+	secretsCmd.AddCommand(secretsAccessCmd)
+
+	secretsBackupCmd.Flags().String("path", "/", "The folder to back up the secrets of")
+	secretsBackupCmd.Flags().Bool("recursive", false, "Also back up all folders nested inside --path")
+	secretsBackupCmd.Flags().String("out", "", "The file to write the backup to")
+	secretsBackupCmd.MarkFlagRequired("out")
+	secretsBackupCmd.Flags().Bool("encrypt", false, "Encrypt the backup with a passphrase, read from "+SECRETS_SNAPSHOT_PASSPHRASE_ENV_NAME+" or prompted for")
+	secretsCmd.AddCommand(secretsBackupCmd)
+
+	secretsRestoreCmd.Flags().String("in", "", "The backup to restore, as written by secrets backup")
+	secretsRestoreCmd.MarkFlagRequired("in")
+	secretsRestoreCmd.Flags().String("on-conflict", RESTORE_CONFLICT_FAIL, "What to do with secrets that already exist (fail, skip, overwrite). fail restores nothing when any secret exists")
+	secretsRestoreCmd.Flags().Bool("dry-run", false, "Print the folders and secrets that would be restored without restoring them")
+	secretsCmd.AddCommand(secretsRestoreCmd)
+
 	secretsCmd.Flags().String("token", "", "Fetch secrets using the Infisical Token")
 	secretsCmd.PersistentFlags().String("env", "dev", "Used to select the environment name on which actions should be taken on")
 	secretsCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets")
+	secretsCmd.Flags().String("expand-source", util.EXPAND_SOURCE_SECRET, "where the ${KEY} references of secrets are resolved from (secret, env, both). both looks up the secret of that name first and the environment variable otherwise")
+	secretsCmd.Flags().Bool("expand-from-env", false, "resolve the ${KEY} references that are not secrets from the environment, same as --expand-source=both")
+	secretsCmd.Flags().Bool("digest", false, "Print a SHA-256 digest of the secrets instead of the secrets, to detect changes between runs")
+	secretsCmd.Flags().Bool("keys-only", false, "Only list the names, types and comments of the secrets. Their values are never decrypted")
+	secretsCmd.Flags().String("output", "table", "The format of the secrets (table, json)")
+	secretsCmd.Flags().StringSlice("columns", []string{}, "The columns of the table and their order, comma separated (key, value, type, comment, tags, version, updatedAt)")
+	secretsCmd.Flags().Int("max-column-width", 0, "Truncate the cells of the table wider than this many characters. 0 only truncates the values to fit your terminal")
+	secretsCmd.Flags().String("grep-value", "", "Only list the secrets whose value matches this regular expression (e.g. 'old-db\\.internal'). The values are not printed unless --show-values is set")
+	secretsCmd.Flags().Bool("i-understand-this-reads-values", false, "Confirm that --grep-value decrypts and searches the values of all your secrets")
+	secretsCmd.Flags().Bool("show-values", false, "Print the values of the secrets matched by --grep-value")
+	secretsCmd.Flags().Bool("mask", false, "Print "+SECRET_VALUE_MASK+" instead of the values of the secrets in the table")
 	secretsCmd.PersistentFlags().StringP("tags", "t", "", "filter secrets by tag slugs")
 	rootCmd.AddCommand(secretsCmd)
 }
@@ -0,0 +1,954 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+)
+
+func TestPlanSecretsMove(t *testing.T) {
+	var encryptedSourceSecrets api.GetEncryptedSecretsV2Response
+	err := json.Unmarshal([]byte(`{"secrets": [
+		{"_id": "shared-db", "type": "shared", "secretKeyCiphertext": "key", "secretValueCiphertext": "value", "secretCommentCiphertext": "comment", "tags": [{"_id": "tag-1"}]},
+		{"_id": "personal-db", "type": "personal", "secretKeyCiphertext": "key", "secretValueCiphertext": "personal-value"},
+		{"_id": "shared-api", "type": "shared", "secretKeyCiphertext": "api-key"}
+	]}`), &encryptedSourceSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sourceSecrets := []models.SingleEnvironmentVariable{
+		{ID: "shared-db", Key: "DB_PASSWORD", Type: "shared"},
+		{ID: "personal-db", Key: "DB_PASSWORD", Type: "personal"},
+		{ID: "shared-api", Key: "API_KEY", Type: "shared"},
+	}
+	destinationSecrets := []models.SingleEnvironmentVariable{{ID: "existing-db", Key: "DB_PASSWORD", Type: "shared"}}
+
+	secretsToMove, err := selectSecretsToMove(sourceSecrets, []string{"db_password"}, "")
+	if err != nil || len(secretsToMove) != 2 {
+		t.Fatalf("TestPlanSecretsMove: expected the shared and personal secret to be selected, got %v [err=%v]", secretsToMove, err)
+	}
+
+	if _, err := selectSecretsToMove(sourceSecrets, []string{"MISSING"}, ""); err == nil {
+		t.Errorf("TestPlanSecretsMove: expected an error for a secret that does not exist")
+	}
+
+	if _, err := planSecretsMove(encryptedSourceSecrets, secretsToMove, destinationSecrets, "folder-id", false); err == nil {
+		t.Errorf("TestPlanSecretsMove: expected an error when the destination already has the secret")
+	}
+
+	requests, err := planSecretsMove(encryptedSourceSecrets, secretsToMove, destinationSecrets, "folder-id", true)
+	if err != nil {
+		t.Fatalf("TestPlanSecretsMove: unexpected error [err=%v]", err)
+	}
+
+	expected := []struct{ method, id, value string }{
+		{"DELETE", "existing-db", ""},
+		{"POST", "", "value"},
+		{"DELETE", "shared-db", ""},
+		{"POST", "", "personal-value"},
+		{"DELETE", "personal-db", ""},
+	}
+
+	if len(requests) != len(expected) {
+		t.Fatalf("TestPlanSecretsMove: expected %d requests but got %+v", len(expected), requests)
+	}
+
+	for i, request := range requests {
+		if request.Method != expected[i].method || request.Secret.ID != expected[i].id || request.Secret.SecretValueCiphertext != expected[i].value {
+			t.Errorf("TestPlanSecretsMove: unexpected request %d %+v", i, request)
+		}
+
+		if request.Method == "POST" && (request.Secret.FolderId != "folder-id" || request.Secret.SecretName != "DB_PASSWORD") {
+			t.Errorf("TestPlanSecretsMove: expected the copy to be created in the destination folder %+v", request)
+		}
+	}
+
+	if requests[1].Secret.SecretCommentCiphertext != "comment" || len(requests[1].Secret.Tags) != 1 || requests[1].Secret.Tags[0] != "tag-1" {
+		t.Errorf("TestPlanSecretsMove: expected the comment and tags to be preserved %+v", requests[1])
+	}
+}
+
+func TestPlanSecretsPatch(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{ID: "id-db", Key: "DB_PASSWORD", Type: "shared"},
+		{ID: "id-old", Key: "OLD_KEY", Type: "shared"},
+		{ID: "id-personal", Key: "PERSONAL_ONLY", Type: "personal"},
+	}
+
+	patch, err := parseSecretsPatch([]byte(`{"create": {"b_new": "2", "A_NEW": "1"}, "update": {"DB_PASSWORD": "rotated"}, "delete": ["old_key"]}`))
+	if err != nil {
+		t.Fatalf("TestPlanSecretsPatch: unexpected error [err=%v]", err)
+	}
+
+	operations, err := planSecretsPatch(patch, secrets)
+	if err != nil {
+		t.Fatalf("TestPlanSecretsPatch: unexpected error [err=%v]", err)
+	}
+
+	expected := []secretsPatchOperation{
+		{method: "POST", key: "A_NEW", value: "1"},
+		{method: "POST", key: "B_NEW", value: "2"},
+		{method: "PATCH", key: "DB_PASSWORD", value: "rotated", id: "id-db"},
+		{method: "DELETE", key: "OLD_KEY", id: "id-old"},
+	}
+
+	if len(operations) != len(expected) {
+		t.Fatalf("TestPlanSecretsPatch: expected %d operations but got %+v", len(expected), operations)
+	}
+
+	for i, operation := range operations {
+		if operation != expected[i] {
+			t.Errorf("TestPlanSecretsPatch: expected %+v but got %+v", expected[i], operation)
+		}
+	}
+
+	invalidPatches := []string{
+		`{"create": {"DB_PASSWORD": "exists"}}`,
+		`{"update": {"MISSING": "value"}}`,
+		`{"update": {"PERSONAL_ONLY": "value"}}`,
+		`{"delete": ["MISSING"]}`,
+		`{"update": {"DB_PASSWORD": "value"}, "delete": ["DB_PASSWORD"]}`,
+		`{"create": {"EMPTY": ""}}`,
+		`{"create": {"1_STARTS_WITH_NUMBER": "value"}}`,
+	}
+
+	for _, invalidPatch := range invalidPatches {
+		patch, err := parseSecretsPatch([]byte(invalidPatch))
+		if err != nil {
+			t.Fatalf("TestPlanSecretsPatch: unexpected error for [%s] [err=%v]", invalidPatch, err)
+		}
+
+		if _, err := planSecretsPatch(patch, secrets); err == nil {
+			t.Errorf("TestPlanSecretsPatch: expected [%s] to be rejected", invalidPatch)
+		}
+	}
+
+	for _, invalidSchema := range []string{`{}`, `{"upsert": {"KEY": "value"}}`, `{"create": {"KEY": 1}}`, `{"delete": "KEY"}`} {
+		if _, err := parseSecretsPatch([]byte(invalidSchema)); err == nil {
+			t.Errorf("TestPlanSecretsPatch: expected the schema of [%s] to be rejected", invalidSchema)
+		}
+	}
+}
+
+func TestPlanSecretsPromotion(t *testing.T) {
+	var tests = []struct {
+		Name               string
+		SourceSecrets      []models.SingleEnvironmentVariable
+		DestinationSecrets []models.SingleEnvironmentVariable
+		Expected           []secretsPatchOperation
+	}{
+		{
+			Name:          "only in source",
+			SourceSecrets: []models.SingleEnvironmentVariable{{ID: "id-src", Key: "FEATURE_X", Value: "on", Type: "shared"}},
+			Expected:      []secretsPatchOperation{{method: "POST", key: "FEATURE_X", value: "on"}},
+		},
+		{
+			Name:               "different values",
+			SourceSecrets:      []models.SingleEnvironmentVariable{{ID: "id-src", Key: "DB_PASSWORD", Value: "new", Type: "shared"}},
+			DestinationSecrets: []models.SingleEnvironmentVariable{{ID: "id-dst", Key: "DB_PASSWORD", Value: "old", Type: "shared"}},
+			Expected:           []secretsPatchOperation{{method: "PATCH", key: "DB_PASSWORD", value: "new", id: "id-dst"}},
+		},
+		{
+			Name:               "only in destination",
+			DestinationSecrets: []models.SingleEnvironmentVariable{{ID: "id-dst", Key: "PROD_ONLY", Value: "keep", Type: "shared"}},
+		},
+		{
+			Name:               "personal secrets",
+			SourceSecrets:      []models.SingleEnvironmentVariable{{ID: "id-src", Key: "MY_TOKEN", Value: "mine", Type: "personal"}},
+			DestinationSecrets: []models.SingleEnvironmentVariable{{ID: "id-dst", Key: "DB_PASSWORD", Value: "old", Type: "personal"}},
+		},
+		{
+			Name: "identical folders",
+			SourceSecrets: []models.SingleEnvironmentVariable{
+				{ID: "id-src-1", Key: "DB_PASSWORD", Value: "same", Type: "shared"},
+				{ID: "id-src-2", Key: "API_URL", Value: "https://api", Type: "shared"},
+			},
+			DestinationSecrets: []models.SingleEnvironmentVariable{
+				{ID: "id-dst-1", Key: "API_URL", Value: "https://api", Type: "shared"},
+				{ID: "id-dst-2", Key: "DB_PASSWORD", Value: "same", Type: "shared"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		operations := planSecretsPromotion(test.SourceSecrets, test.DestinationSecrets)
+		if len(operations) != len(test.Expected) {
+			t.Errorf("TestPlanSecretsPromotion: expected %+v for [%s] but got %+v", test.Expected, test.Name, operations)
+			continue
+		}
+
+		for i, operation := range operations {
+			if operation != test.Expected[i] {
+				t.Errorf("TestPlanSecretsPromotion: expected %+v for [%s] but got %+v", test.Expected[i], test.Name, operation)
+			}
+		}
+	}
+}
+
+func TestCompareSecrets(t *testing.T) {
+	localSecrets := []models.SingleEnvironmentVariable{
+		{Key: "SAME", Value: "1"},
+		{Key: "LOCAL_ONLY", Value: "2"},
+		{Key: "CHANGED", Value: "local"},
+	}
+	serverSecrets := []models.SingleEnvironmentVariable{
+		{Key: "SERVER_ONLY", Value: "3"},
+		{Key: "CHANGED", Value: "server"},
+		{Key: "SAME", Value: "1"},
+	}
+
+	drifts := compareSecrets(localSecrets, serverSecrets)
+	expected := []secretDrift{
+		{Key: "CHANGED", Status: SECRET_DRIFT_VALUE_MISMATCH, LocalValue: "local", ServerValue: "server"},
+		{Key: "LOCAL_ONLY", Status: SECRET_DRIFT_ONLY_LOCAL, LocalValue: "2"},
+		{Key: "SERVER_ONLY", Status: SECRET_DRIFT_ONLY_SERVER, ServerValue: "3"},
+	}
+
+	if len(drifts) != len(expected) {
+		t.Fatalf("TestCompareSecrets: expected %+v but got %+v", expected, drifts)
+	}
+
+	for i, drift := range drifts {
+		if drift != expected[i] {
+			t.Errorf("TestCompareSecrets: expected %+v but got %+v", expected[i], drift)
+		}
+	}
+
+	maskedOutput, _ := formatSecretDriftsAsJSON(drifts, false)
+	if strings.Contains(maskedOutput, "Value") {
+		t.Errorf("TestCompareSecrets: expected the values to be masked but got %s", maskedOutput)
+	}
+
+	output, _ := formatSecretDriftsAsJSON(drifts, true)
+	if !strings.Contains(output, `"localValue": "local"`) || !strings.Contains(output, `"serverValue": "3"`) {
+		t.Errorf("TestCompareSecrets: expected the values to be included but got %s", output)
+	}
+}
+
+func TestWatchSecrets(t *testing.T) {
+	polls := [][]models.SingleEnvironmentVariable{
+		{{Key: "A", Value: "1"}, {Key: "B", Value: "2"}, {Key: "C", Value: "3"}},
+		nil,
+		{{Key: "A", Value: "1"}, {Key: "B", Value: "changed"}, {Key: "D", Value: "4"}},
+	}
+
+	ticks := make(chan time.Time)
+	stop := make(chan os.Signal, 1)
+	pollCount := 0
+	fetchSecrets := func() ([]models.SingleEnvironmentVariable, error) {
+		secrets := polls[pollCount]
+		pollCount++
+		if pollCount == len(polls) {
+			// the signal arrives while the last poll is in flight
+			stop <- os.Interrupt
+		}
+
+		if secrets == nil {
+			return nil, errors.New("unavailable")
+		}
+		return secrets, nil
+	}
+
+	events := []string{}
+	done := make(chan struct{})
+	go func() {
+		watchSecrets([]models.SingleEnvironmentVariable{{Key: "A", Value: "1"}, {Key: "B", Value: "2"}}, fetchSecrets, ticks, stop, func(event secretChangeEvent) {
+			events = append(events, event.Event+" "+event.Key)
+		})
+		close(done)
+	}()
+
+	for range polls {
+		ticks <- time.Now()
+	}
+	<-done
+
+	expected := []string{"added C", "changed B", "removed C", "added D"}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("TestWatchSecrets: expected the events %v but got %v", expected, events)
+	}
+}
+
+func TestMaskSecretValue(t *testing.T) {
+	masked := maskSecretValue("hunter2", false)
+	if masked != "sha256:f52fbd32" {
+		t.Errorf("TestMaskSecretValue: expected a short fingerprint but got [%s]", masked)
+	}
+
+	if maskSecretValue("hunter2", false) == maskSecretValue("hunter3", false) {
+		t.Errorf("TestMaskSecretValue: expected different values to have different fingerprints")
+	}
+
+	if value := maskSecretValue("hunter2", true); value != "hunter2" {
+		t.Errorf("TestMaskSecretValue: expected the value to be shown but got [%s]", value)
+	}
+}
+
+func TestGetSecretsOfFolderTree(t *testing.T) {
+	folders := map[string][]string{"/": {"api", "web"}, "/api": {"internal"}, "/api/internal": {}, "/web": {}}
+	secretsOfFolders := map[string][]models.SingleEnvironmentVariable{
+		"/":             {{Key: "A", Type: util.SECRET_TYPE_SHARED}, {Key: "A", Type: util.SECRET_TYPE_PERSONAL}},
+		"/api":          {{Key: "B", Type: util.SECRET_TYPE_SHARED}},
+		"/api/internal": {{Key: "C", Type: util.SECRET_TYPE_SHARED}},
+		"/web":          {{Key: "D", Type: util.SECRET_TYPE_PERSONAL}},
+	}
+
+	fetchFolder := func(folderPath string) ([]models.SingleEnvironmentVariable, []string, error) {
+		subFolders, ok := folders[folderPath]
+		if !ok {
+			return nil, nil, errors.New("not found")
+		}
+		return secretsOfFolders[folderPath], subFolders, nil
+	}
+
+	folderResults, err := getSecretsOfFolderTree("/", fetchFolder)
+	if err != nil {
+		t.Fatalf("TestGetSecretsOfFolderTree: unexpected error [err=%v]", err)
+	}
+
+	expectedPaths := []string{"/", "/api", "/web", "/api/internal"}
+	secrets := []models.SingleEnvironmentVariable{}
+	for idx, folderResult := range folderResults {
+		if idx >= len(expectedPaths) || folderResult.Path != expectedPaths[idx] {
+			t.Errorf("TestGetSecretsOfFolderTree: expected the folders %v, parents first, but got %+v", expectedPaths, folderResults)
+			break
+		}
+		secrets = append(secrets, folderResult.Secrets...)
+	}
+
+	if count := countSecrets(secrets); count != (secretCount{Total: 5, Shared: 3, Personal: 2}) {
+		t.Errorf("TestGetSecretsOfFolderTree: expected 5 secrets of which 2 personal but got %+v", count)
+	}
+
+	folderResults, err = getSecretsOfFolderTree("api/", fetchFolder)
+	if err != nil || len(folderResults) != 2 || folderResults[1].Path != "/api/internal" {
+		t.Errorf("TestGetSecretsOfFolderTree: expected the folders /api and /api/internal but got %+v [err=%v]", folderResults, err)
+	}
+
+	folders["/api"] = []string{"missing"}
+	if _, err := getSecretsOfFolderTree("/", fetchFolder); err == nil || !strings.Contains(err.Error(), "/api/missing") {
+		t.Errorf("TestGetSecretsOfFolderTree: expected the failing folder to be reported but got [err=%v]", err)
+	}
+}
+
+func TestGetSecretsDigest(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "A", Value: "1", Type: util.SECRET_TYPE_SHARED},
+		{Key: "B", Value: "2", Type: util.SECRET_TYPE_SHARED},
+		{Key: "A", Value: "3", Type: util.SECRET_TYPE_PERSONAL},
+	}
+	reordered := []models.SingleEnvironmentVariable{secrets[2], secrets[1], secrets[0]}
+
+	digest := getSecretsDigest(secrets)
+	if digest != getSecretsDigest(reordered) {
+		t.Errorf("TestGetSecretsDigest: expected the digest not to depend on the order of the secrets")
+	}
+
+	if len(digest) != 64 {
+		t.Errorf("TestGetSecretsDigest: expected a hex encoded SHA-256 but got [%s]", digest)
+	}
+
+	changed := append([]models.SingleEnvironmentVariable{}, secrets...)
+	changed[1].Value = "22"
+	if digest == getSecretsDigest(changed) {
+		t.Errorf("TestGetSecretsDigest: expected a changed value to change the digest")
+	}
+
+	shifted := []models.SingleEnvironmentVariable{{Key: "AB", Value: "C"}}
+	if getSecretsDigest(shifted) == getSecretsDigest([]models.SingleEnvironmentVariable{{Key: "A", Value: "BC"}}) {
+		t.Errorf("TestGetSecretsDigest: expected characters moved between name and value to change the digest")
+	}
+}
+
+func TestSecretsSnapshotRoundTrip(t *testing.T) {
+	folderResults := []util.PathFetchResult{
+		{Path: "/", Secrets: []models.SingleEnvironmentVariable{{Key: "DB_PASSWORD", Value: "hunter2", Type: util.SECRET_TYPE_SHARED, Comment: "rotated monthly"}}},
+		{Path: "/api", Secrets: []models.SingleEnvironmentVariable{{Key: "API_KEY", Value: "abc", Type: util.SECRET_TYPE_PERSONAL}}},
+	}
+	snapshot := buildSecretsSnapshot(folderResults, "workspace-id", "prod", "/", time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC))
+
+	noPassphrase := func() (string, error) {
+		t.Errorf("TestSecretsSnapshotRoundTrip: expected no passphrase to be asked for a plain backup")
+		return "", nil
+	}
+
+	content, err := encodeSecretsSnapshot(snapshot, "")
+	if err != nil {
+		t.Fatalf("TestSecretsSnapshotRoundTrip: unexpected error [err=%v]", err)
+	}
+
+	decoded, err := decodeSecretsSnapshot(content, noPassphrase)
+	if err != nil || len(decoded.Folders) != 2 || decoded.Folders[0].Secrets[0].Comment != "rotated monthly" || decoded.Folders[1].Secrets[0].Type != util.SECRET_TYPE_PERSONAL {
+		t.Errorf("TestSecretsSnapshotRoundTrip: expected the plain backup to round trip but got %+v [err=%v]", decoded, err)
+	}
+
+	encryptedContent, err := encodeSecretsSnapshot(snapshot, "correct horse")
+	if err != nil {
+		t.Fatalf("TestSecretsSnapshotRoundTrip: unexpected error [err=%v]", err)
+	}
+
+	if strings.Contains(string(encryptedContent), "hunter2") || strings.Contains(string(encryptedContent), "DB_PASSWORD") || strings.Contains(string(encryptedContent), "workspace-id") {
+		t.Errorf("TestSecretsSnapshotRoundTrip: expected the encrypted backup not to reveal its content but got %s", encryptedContent)
+	}
+
+	decoded, err = decodeSecretsSnapshot(encryptedContent, func() (string, error) { return "correct horse", nil })
+	if err != nil || decoded.Project != "workspace-id" || decoded.Folders[0].Secrets[0].Value != "hunter2" {
+		t.Errorf("TestSecretsSnapshotRoundTrip: expected the encrypted backup to round trip but got %+v [err=%v]", decoded, err)
+	}
+
+	if _, err := decodeSecretsSnapshot(encryptedContent, func() (string, error) { return "wrong", nil }); err == nil {
+		t.Errorf("TestSecretsSnapshotRoundTrip: expected a wrong passphrase to fail")
+	}
+
+	if _, err := decodeSecretsSnapshot([]byte(`{"version": 99, "snapshot": {}}`), noPassphrase); err == nil || !strings.Contains(err.Error(), "[99]") {
+		t.Errorf("TestSecretsSnapshotRoundTrip: expected a newer backup version to be rejected but got [err=%v]", err)
+	}
+}
+
+func TestPlanSecretsRestore(t *testing.T) {
+	snapshot := secretsSnapshot{Path: "/apps/api", Folders: []snapshotFolder{
+		{Path: "/apps/api", Secrets: []snapshotSecret{{Key: "A", Value: "1", Type: util.SECRET_TYPE_SHARED}, {Key: "B", Value: "2", Type: util.SECRET_TYPE_SHARED}}},
+		{Path: "/apps/api/internal", Secrets: []snapshotSecret{{Key: "C", Value: "3", Type: util.SECRET_TYPE_SHARED}}},
+	}}
+
+	if folderPaths := getSnapshotFolderPaths(snapshot); strings.Join(folderPaths, ",") != "/,/apps,/apps/api,/apps/api/internal" {
+		t.Errorf("TestPlanSecretsRestore: expected the folders and their parents, parents first, but got %v", folderPaths)
+	}
+
+	existingFolders := map[string]existingFolder{
+		"/":         {exists: true},
+		"/apps":     {exists: true, id: "apps-id"},
+		"/apps/api": {exists: true, id: "api-id", secrets: []models.SingleEnvironmentVariable{{ID: "existing-a", Key: "A", Type: util.SECRET_TYPE_SHARED}}},
+	}
+
+	if _, err := planSecretsRestore(snapshot, existingFolders, RESTORE_CONFLICT_FAIL); err == nil || !strings.Contains(err.Error(), "/apps/api/A") {
+		t.Errorf("TestPlanSecretsRestore: expected the conflicting secret to be reported but got [err=%v]", err)
+	}
+
+	describe := func(operations []secretsRestoreOperation) string {
+		descriptions := []string{}
+		for _, operation := range operations {
+			descriptions = append(descriptions, operation.method+" "+operation.folderPath+" "+operation.secret.Key+" "+operation.id)
+		}
+		return strings.Join(descriptions, ",")
+	}
+
+	operations, err := planSecretsRestore(snapshot, existingFolders, RESTORE_CONFLICT_SKIP)
+	expected := "CREATE FOLDER /apps/api/internal  ,SKIP /apps/api A ,POST /apps/api B ,POST /apps/api/internal C "
+	if err != nil || describe(operations) != expected {
+		t.Errorf("TestPlanSecretsRestore: expected [%s] but got [%s] [err=%v]", expected, describe(operations), err)
+	}
+
+	operations, err = planSecretsRestore(snapshot, existingFolders, RESTORE_CONFLICT_OVERWRITE)
+	if err != nil || operations[1].method != "PATCH" || operations[1].id != "existing-a" {
+		t.Errorf("TestPlanSecretsRestore: expected the existing secret to be overwritten but got [%s] [err=%v]", describe(operations), err)
+	}
+}
+
+func TestGetTagIdsBySlugs(t *testing.T) {
+	var workspaceTags api.GetWorkspaceTagsResponse
+	err := json.Unmarshal([]byte(`{"workspaceTags": [{"_id": "tag-1", "name": "Backend", "slug": "backend"}, {"_id": "tag-2", "name": "Payments", "slug": "payments"}]}`), &workspaceTags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tagIds, err := getTagIdsBySlugs(workspaceTags, "payments, backend")
+	if err != nil || len(tagIds) != 2 || tagIds[0] != "tag-2" || tagIds[1] != "tag-1" {
+		t.Errorf("TestGetTagIdsBySlugs: expected [tag-2 tag-1] but got %v [err=%v]", tagIds, err)
+	}
+
+	if _, err := getTagIdsBySlugs(workspaceTags, "backend,frontend"); err == nil || !strings.Contains(err.Error(), "frontend") {
+		t.Errorf("TestGetTagIdsBySlugs: expected an error naming the unknown tag [err=%v]", err)
+	}
+
+	var secret models.SingleEnvironmentVariable
+	json.Unmarshal([]byte(`{"key": "DB_HOST", "tags": [{"_id": "tag-1"}, {"_id": "tag-2"}]}`), &secret)
+	if !haveSameTagIds(secret, []string{"tag-2", "tag-1"}) {
+		t.Errorf("TestGetTagIdsBySlugs: expected the tags to be the same regardless of their order")
+	}
+
+	if haveSameTagIds(secret, []string{"tag-1"}) {
+		t.Errorf("TestGetTagIdsBySlugs: expected a removed tag to be detected")
+	}
+}
+
+func TestFormatSecretsListAsJSON(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "B", Value: "two", Type: "shared"},
+		{Key: "A", Value: "", Type: "personal", Comment: "owner:infra"},
+	}
+
+	output, err := formatSecretsListAsJSON(secrets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var withValues []map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &withValues); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(withValues) != 2 || withValues[0]["key"] != "A" || withValues[0]["value"] != "" || withValues[1]["value"] != "two" {
+		t.Errorf("Expected the secrets sorted by key with their values, got %s", output)
+	}
+
+	output, err = formatSecretsListAsJSON(secrets, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(output, `"value"`) || !strings.Contains(output, `"comment": "owner:infra"`) {
+		t.Errorf("Expected --keys-only to leave out the value field, got %s", output)
+	}
+}
+
+func TestFormatSecretForShell(t *testing.T) {
+	line, err := formatSecretForShell("TOKEN", "abc", GET_FORMAT_EXPORT)
+	if err != nil || line != "export TOKEN='abc'" {
+		t.Errorf("Expected an export line, got [%s] [err=%v]", line, err)
+	}
+
+	if _, err := formatSecretForShell("TOKEN;touch pwned", "abc", GET_FORMAT_SHELL); err == nil {
+		t.Errorf("Expected names that are not shell variable names to be rejected")
+	}
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	// none of these may run a command or expand anything once passed to eval
+	for _, value := range []string{"it's", "'; touch pwned; '", "$(touch pwned)", "`touch pwned`", "$HOME", "a\nb\\", "multi\nline", ""} {
+		line, err := formatSecretForShell("VALUE", value, GET_FORMAT_SHELL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		evalCmd := exec.Command("sh", "-c", "eval \"$1\"; printf '%s' \"$VALUE\"", "sh", line)
+		evalCmd.Dir = t.TempDir()
+		output, err := evalCmd.Output()
+		if err != nil || string(output) != value {
+			t.Errorf("Expected eval of [%s] to give back %q, got %q [err=%v]", line, value, output, err)
+		}
+	}
+}
+
+func TestGetUserAccess(t *testing.T) {
+	var accessibleEnvironments api.GetAccessibleEnvironmentsResponse
+	err := json.Unmarshal([]byte(`{"accessibleEnvironments": [{"slug": "dev"}, {"slug": "prod", "isWriteDenied": true}]}`), &accessibleEnvironments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if access := getUserAccess(accessibleEnvironments, "jane@example.com", "dev"); !access.Read || access.Write == nil || !*access.Write {
+		t.Errorf("Expected read and write access to dev, got %+v", access)
+	}
+
+	if access := getUserAccess(accessibleEnvironments, "jane@example.com", "prod"); !access.Read || access.Write == nil || *access.Write {
+		t.Errorf("Expected read only access to prod, got %+v", access)
+	}
+
+	if access := getUserAccess(accessibleEnvironments, "jane@example.com", "staging"); access.Read || access.Write == nil || *access.Write {
+		t.Errorf("Expected no access to staging, got %+v", access)
+	}
+
+	serviceTokenDetails := api.GetServiceTokenDetailsResponse{Name: "ci", Environment: "prod"}
+	if access := getServiceTokenAccess(serviceTokenDetails, "prod"); !access.Read || access.Write != nil {
+		t.Errorf("Expected a service token to read its environment with unknown write access, got %+v", access)
+	}
+
+	if access := getServiceTokenAccess(serviceTokenDetails, "dev"); access.Read {
+		t.Errorf("Expected a service token to not read other environments, got %+v", access)
+	}
+}
+
+func TestPickSecretsByNames(t *testing.T) {
+	secretsMap := getSecretsByKeys([]models.SingleEnvironmentVariable{{Key: "DB_USER", Value: "admin"}, {Key: "DB_PASS", Value: "hunter2"}})
+
+	foundSecrets, missingSecretNames := pickSecretsByNames(secretsMap, []string{"db_pass", "DB_HOST", "DB_USER", "DB_PORT"})
+	if len(foundSecrets) != 2 || foundSecrets[0].Key != "DB_PASS" || foundSecrets[1].Key != "DB_USER" {
+		t.Errorf("Expected DB_PASS and DB_USER in the order asked for, got %+v", foundSecrets)
+	}
+
+	if strings.Join(missingSecretNames, ",") != "DB_HOST,DB_PORT" {
+		t.Errorf("Expected DB_HOST and DB_PORT to be missing, got %v", missingSecretNames)
+	}
+
+	output, err := formatRequestedSecretsAsJSON(foundSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\n  \"DB_PASS\": \"hunter2\",\n  \"DB_USER\": \"admin\"\n}"
+	if output != expected {
+		t.Errorf("Expected %s, got %s", expected, output)
+	}
+}
+
+func TestGetSecretOrDefault(t *testing.T) {
+	secretsMap := getSecretsByKeys([]models.SingleEnvironmentVariable{{Key: "DB_USER", Value: "admin"}, {Key: "EMPTY", Value: ""}})
+
+	secret, isDefault := getSecretOrDefault(secretsMap, "DB_HOST", "localhost")
+	if !isDefault || secret.Key != "DB_HOST" || secret.Value != "localhost" {
+		t.Errorf("Expected a missing secret to fall back to the default, got %+v", secret)
+	}
+
+	secret, isDefault = getSecretOrDefault(secretsMap, "db_user", "ignored")
+	if isDefault || secret.Key != "DB_USER" || secret.Value != "admin" {
+		t.Errorf("Expected an existing secret to ignore the default, got %+v", secret)
+	}
+
+	// only a secret that does not exist falls back, an empty value is still a value
+	secret, isDefault = getSecretOrDefault(secretsMap, "EMPTY", "ignored")
+	if isDefault || secret.Value != "" {
+		t.Errorf("Expected an empty secret to ignore the default, got %+v", secret)
+	}
+}
+
+func TestSecretsGetRejectsConflictingDefault(t *testing.T) {
+	// the command exits on invalid flags, so it is run in a child process
+	if getArgs := os.Getenv("TEST_SECRETS_GET_ARGS"); getArgs != "" {
+		rootCmd.SetArgs(strings.Split(getArgs, "\n"))
+		rootCmd.Execute()
+		return
+	}
+
+	var tests = []struct {
+		Args            []string
+		ExpectedMessage string
+	}{
+		{Args: []string{"DB_HOST", "DB_PORT", "--default", "localhost"}, ExpectedMessage: "--default takes a single secret name, received 2"},
+		{Args: []string{"DB_HOST", "--all-envs", "--default", "localhost"}, ExpectedMessage: "cannot be used together with --all-envs"},
+		{Args: []string{"DB_HOST", "--wait-for-value", "--default", "localhost"}, ExpectedMessage: "--wait-for-value cannot be used together with --all-envs, --default or --allow-missing"},
+		{Args: []string{"DB_HOST", "--wait-for-value", "--all-envs"}, ExpectedMessage: "--wait-for-value cannot be used together with --all-envs, --default or --allow-missing"},
+	}
+
+	for _, test := range tests {
+		workingDirectory := t.TempDir()
+		getArgs := append([]string{"secrets", "get"}, test.Args...)
+
+		cmd := exec.Command(os.Args[0], "-test.run=^TestSecretsGetRejectsConflictingDefault$")
+		cmd.Dir = workingDirectory
+		cmd.Env = append(os.Environ(), "TEST_SECRETS_GET_ARGS="+strings.Join(getArgs, "\n"), "HOME="+workingDirectory, "INFISICAL_DISABLE_UPDATE_CHECK=1")
+		output, err := cmd.CombinedOutput()
+
+		exitErr, isExitErr := err.(*exec.ExitError)
+		if !isExitErr || exitErr.ExitCode() != 1 {
+			t.Errorf("Expected %v to exit with code 1, got [err=%v] with output [%s]", test.Args, err, output)
+		}
+
+		if !strings.Contains(string(output), test.ExpectedMessage) {
+			t.Errorf("Expected %v to be rejected with [%s], got [%s]", test.Args, test.ExpectedMessage, output)
+		}
+	}
+}
+
+func TestRunValueCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on a POSIX shell")
+	}
+	t.Setenv("SHELL", "sh")
+
+	if value, err := runValueCommand("printf '  generated value\\n'", true); err != nil || value != "generated value" {
+		t.Errorf("Expected the trimmed output of the command, got [%s] [err=%v]", value, err)
+	}
+
+	if value, err := runValueCommand("printf '  generated value\\n'", false); err != nil || value != "  generated value\n" {
+		t.Errorf("Expected the output of the command as is, got [%s] [err=%v]", value, err)
+	}
+
+	_, err := runValueCommand("echo partial; echo no entropy >&2; exit 3", true)
+	if err == nil || err.Error() != "the command exited with code 3: no entropy" {
+		t.Errorf("Expected the exit code and stderr of the failing command, got [err=%v]", err)
+	}
+}
+
+func TestGetSecretsTable(t *testing.T) {
+	columns, err := normalizeSecretColumns([]string{"KEY", " updatedat", "version", "tags", "value"})
+	if err != nil || strings.Join(columns, ",") != "key,updatedAt,version,tags,value" {
+		t.Fatalf("Expected the columns to be normalized, got %v [err=%v]", columns, err)
+	}
+
+	if _, err := normalizeSecretColumns([]string{"key", "owner"}); err == nil || !strings.Contains(err.Error(), "[owner]") || !strings.Contains(err.Error(), "key, value, type, comment, tags, version, updatedAt") {
+		t.Errorf("Expected the unknown column and the valid ones to be listed, got [err=%v]", err)
+	}
+
+	var secrets []models.SingleEnvironmentVariable
+	err = json.Unmarshal([]byte(`[{"key": "DB_PASS", "value": "hunter2", "tags": [{"slug": "db"}, {"slug": "prod"}]}, {"key": "NEW"}]`), &secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets[0].Version = 3
+	secrets[0].UpdatedAt = time.Date(2023, 5, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	headers, rows := getSecretsTable(secrets, columns)
+	if strings.Join(headers, ",") != "SECRET NAME,UPDATED AT,VERSION,TAGS,SECRET VALUE" {
+		t.Errorf("Expected the headers in the order of the columns, got %v", headers)
+	}
+
+	if len(rows) != 2 || strings.Join(rows[0], "|") != "DB_PASS|2023-05-01T10:30:00Z|3|db, prod|hunter2" || strings.Join(rows[1], "|") != "NEW||||" {
+		t.Errorf("Expected a row per secret in the order of the columns, got %v", rows)
+	}
+}
+
+func TestFilterSecretsByValue(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_URL", Value: "postgres://app@old-db.internal:5432/app"},
+		{Key: "CACHE_URL", Value: "redis://cache.internal"},
+		{Key: "REPLICA_URL", Value: "postgres://app@OLD-DB.internal/app"},
+	}
+
+	matchingSecrets := filterSecretsByValue(secrets, regexp.MustCompile(`old-db\.internal`))
+	if len(matchingSecrets) != 1 || matchingSecrets[0].Key != "DB_URL" {
+		t.Errorf("Expected DB_URL to match, got %+v", matchingSecrets)
+	}
+
+	if matchingSecrets := filterSecretsByValue(secrets, regexp.MustCompile(`(?i)old-db`)); len(matchingSecrets) != 2 {
+		t.Errorf("Expected DB_URL and REPLICA_URL to match case-insensitively, got %+v", matchingSecrets)
+	}
+}
+
+func TestReadSecretArgsFromJSON(t *testing.T) {
+	args, err := readSecretArgsFromJSON(strings.NewReader(`{"DB_URL": "postgres://u:p@db/app?sslmode=require", "FEATURES": {"beta": true}}`), true)
+	if err != nil || len(args) != 2 || args[0] != "DB_URL=postgres://u:p@db/app?sslmode=require" || args[1] != `FEATURES={"beta":true}` {
+		t.Fatalf("TestReadSecretArgsFromJSON: unexpected args %v [err=%v]", args, err)
+	}
+
+	if _, err := readSecretArgsFromJSON(strings.NewReader(`{"FEATURES": {"beta": true}}`), false); err == nil {
+		t.Errorf("TestReadSecretArgsFromJSON: expected nested objects to be refused without stringifyNested")
+	}
+
+	if _, err := readSecretArgsFromJSON(strings.NewReader(`{"A=B": "c"}`), false); err == nil {
+		t.Errorf("TestReadSecretArgsFromJSON: expected a name with = to be refused")
+	}
+}
+
+func TestWaitForSecretValues(t *testing.T) {
+	fetches := 0
+	fetch := func() ([]models.SingleEnvironmentVariable, error) {
+		fetches++
+		switch fetches {
+		case 1:
+			return []models.SingleEnvironmentVariable{}, nil
+		case 2:
+			return []models.SingleEnvironmentVariable{{Key: "DB_PASSWORD", Value: ""}}, nil
+		default:
+			return []models.SingleEnvironmentVariable{{Key: "DB_PASSWORD", Value: "hunter2"}}, nil
+		}
+	}
+
+	secrets, err := waitForSecretValues(fetch, []string{"db_password"}, time.Second, time.Millisecond)
+	if err != nil || fetches != 3 || len(secrets) != 1 || secrets[0].Value != "hunter2" {
+		t.Fatalf("TestWaitForSecretValues: expected the value after 3 fetches but got %v after %d [err=%v]", secrets, fetches, err)
+	}
+
+	_, err = waitForSecretValues(func() ([]models.SingleEnvironmentVariable, error) { return nil, nil }, []string{"DB_PASSWORD"}, 20*time.Millisecond, 5*time.Millisecond)
+	if !errors.Is(err, errWaitForSecretValuesTimeout) || !strings.Contains(err.Error(), "DB_PASSWORD") {
+		t.Errorf("TestWaitForSecretValues: expected a timeout naming the missing secret but got [err=%v]", err)
+	}
+
+	fetchErr := errors.New("unauthorized")
+	if _, err := waitForSecretValues(func() ([]models.SingleEnvironmentVariable, error) { return nil, fetchErr }, []string{"DB_PASSWORD"}, time.Second, time.Millisecond); err != fetchErr {
+		t.Errorf("TestWaitForSecretValues: expected the fetch error to be returned right away but got [err=%v]", err)
+	}
+}
+
+func TestSecretsSetAndDeleteIgnoreStoredServiceToken(t *testing.T) {
+	if executeInfisicalIfChild() {
+		return
+	}
+
+	mock := newMockUserServer(t, map[string][][2]string{
+		"dev":  {{"DB_PASSWORD", "dev-password"}},
+		"prod": {{"DB_PASSWORD", "prod-password"}},
+	})
+	projectDir := setupLoggedInUserForTest(t, mock, models.ConfigFile{}, true)
+
+	if output, err := runInfisicalForTest(t, projectDir, "secrets", "set", "DB_PASSWORD=rotated", "--env", "prod"); err != nil {
+		t.Fatalf("Expected secrets set to succeed, got [err=%v] with output [%s]", err, output)
+	}
+
+	if output, err := runInfisicalForTest(t, projectDir, "secrets", "delete", "DB_PASSWORD", "--env", "prod"); err != nil {
+		t.Fatalf("Expected secrets delete to succeed, got [err=%v] with output [%s]", err, output)
+	}
+
+	if len(mock.foreignRequests) != 0 {
+		t.Errorf("Expected every request to be made by the logged in user, got %v", mock.foreignRequests)
+	}
+
+	if strings.Join(mock.readEnvironments, ",") != "prod,prod" {
+		t.Errorf("Expected the current secrets to be read from prod, got %v", mock.readEnvironments)
+	}
+
+	if len(mock.writes) != 2 || !strings.Contains(mock.writes[0], `"id":"prod-DB_PASSWORD"`) || !strings.Contains(mock.writes[1], `"secretIds":["prod-DB_PASSWORD"]`) {
+		t.Errorf("Expected the secret of prod to be updated then deleted, got %v", mock.writes)
+	}
+}
+
+func TestSecretsBulkUpdateIgnoresServiceTokens(t *testing.T) {
+	if executeInfisicalIfChild() {
+		return
+	}
+
+	mock := newMockUserServer(t, map[string][][2]string{
+		"dev":  {{"DB_PASSWORD", "dev-password"}, {"OLD_KEY", "dev"}},
+		"prod": {{"DB_PASSWORD", "prod-password"}, {"OLD_KEY", "prod"}},
+	})
+	projectDir := setupLoggedInUserForTest(t, mock, models.ConfigFile{}, true)
+	t.Setenv(util.INFISICAL_TOKEN_NAME, testStoredServiceToken)
+
+	patchFile := filepath.Join(projectDir, "patch.json")
+	if err := os.WriteFile(patchFile, []byte(`{"update": {"DB_PASSWORD": "rotated"}, "delete": ["OLD_KEY"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if output, err := runInfisicalForTest(t, projectDir, "secrets", "bulk-update", "--file", patchFile, "--env", "prod"); err != nil {
+		t.Fatalf("Expected bulk-update to succeed, got [err=%v] with output [%s]", err, output)
+	}
+
+	if len(mock.foreignRequests) != 0 || strings.Join(mock.readEnvironments, ",") != "prod" {
+		t.Errorf("Expected the secrets of prod to be read by the logged in user, got %v read from %v", mock.foreignRequests, mock.readEnvironments)
+	}
+
+	if len(mock.writes) != 2 || !strings.Contains(mock.writes[0], `"_id":"prod-DB_PASSWORD"`) || !strings.Contains(mock.writes[1], `"_id":"prod-OLD_KEY"`) {
+		t.Errorf("Expected the secrets of prod to be updated and deleted, got %v", mock.writes)
+	}
+}
+
+func TestSecretsGetAllEnvsRejectsStoredServiceToken(t *testing.T) {
+	if executeInfisicalIfChild() {
+		return
+	}
+
+	mock := newMockUserServer(t, map[string][][2]string{"dev": {{"DB_PASSWORD", "dev-password"}}})
+	projectDir := setupLoggedInUserForTest(t, mock, models.ConfigFile{}, true)
+
+	output, err := runInfisicalForTest(t, projectDir, "secrets", "get", "DB_PASSWORD", "--all-envs")
+	exitErr, isExitErr := err.(*exec.ExitError)
+	if !isExitErr || exitErr.ExitCode() != 1 || !strings.Contains(string(output), util.AUTH_METHOD_STORED_TOKEN) {
+		t.Errorf("Expected --all-envs to be rejected with a stored service token, got [err=%v] with output [%s]", err, output)
+	}
+
+	if len(mock.readEnvironments) != 0 || len(mock.foreignRequests) != 0 {
+		t.Errorf("Expected no secrets to be fetched, got %v and %v", mock.readEnvironments, mock.foreignRequests)
+	}
+}
+
+func TestSecretsAccessAndCountUseStoredServiceToken(t *testing.T) {
+	if executeInfisicalIfChild() {
+		return
+	}
+
+	mock := newMockUserServer(t, map[string][][2]string{"dev": {{"DB_PASSWORD", "dev-password"}}})
+	projectDir := setupLoggedInUserForTest(t, mock, models.ConfigFile{}, true)
+
+	// the stored token takes precedence over the login, like when fetching, and is rejected by the mock server
+	if output, err := runInfisicalForTest(t, projectDir, "secrets", "access", "--env", "dev"); err == nil {
+		t.Errorf("Expected the access check to fail with the stored service token, got output [%s]", output)
+	}
+
+	if strings.Join(mock.foreignRequests, ",") != "GET /api/v2/service-token" {
+		t.Errorf("Expected the access of the stored service token to be checked, got %v", mock.foreignRequests)
+	}
+
+	output, err := runInfisicalForTest(t, projectDir, "secrets", "count", "--env", "dev", "--recursive")
+	exitErr, isExitErr := err.(*exec.ExitError)
+	if !isExitErr || exitErr.ExitCode() != 1 || !strings.Contains(string(output), util.AUTH_METHOD_STORED_TOKEN) {
+		t.Errorf("Expected --recursive to be rejected with a stored service token, got [err=%v] with output [%s]", err, output)
+	}
+
+	if len(mock.readEnvironments) != 0 {
+		t.Errorf("Expected no secrets to be fetched, got %v", mock.readEnvironments)
+	}
+}
+
+func TestSecretsWriteCommandsResolveEnvironmentAliases(t *testing.T) {
+	if executeInfisicalIfChild() {
+		return
+	}
+
+	mock := newMockUserServer(t, map[string][][2]string{
+		"staging":    {{"DB_PASSWORD", "staging-password"}},
+		"production": {{"DB_PASSWORD", "production-password"}},
+	})
+	configFile := models.ConfigFile{EnvironmentAliases: []models.EnvironmentAlias{{Alias: "stg", Slug: "staging"}, {Alias: "prd", Slug: "production"}}}
+	projectDir := setupLoggedInUserForTest(t, mock, configFile, false)
+
+	// both commands set their own PersistentPreRun, which replaces the one of the root command
+	if output, err := runInfisicalForTest(t, projectDir, "secrets", "promote", "--from-env", "stg", "--to-env", "prd", "--dry-run"); err != nil {
+		t.Fatalf("Expected the promotion to succeed, got [err=%v] with output [%s]", err, output)
+	}
+
+	if output, err := runInfisicalForTest(t, projectDir, "secrets", "delete", "DB_PASSWORD", "--env", "prd"); err != nil {
+		t.Fatalf("Expected the deletion to succeed, got [err=%v] with output [%s]", err, output)
+	}
+
+	if strings.Join(mock.readEnvironments, ",") != "staging,production,production" {
+		t.Errorf("Expected the aliases to be resolved to their slugs, got %v", mock.readEnvironments)
+	}
+
+	if len(mock.writes) != 1 || !strings.Contains(mock.writes[0], `"environmentName":"production"`) {
+		t.Errorf("Expected the secret to be deleted from production, got %v", mock.writes)
+	}
+}
+
+func TestSecretsDeleteResolvesNamedDomain(t *testing.T) {
+	if executeInfisicalIfChild() {
+		return
+	}
+
+	mock := newMockUserServer(t, map[string][][2]string{"prod": {{"DB_PASSWORD", "production-password"}}})
+	configFile := models.ConfigFile{Domains: []models.NamedDomain{{Name: "self-hosted", URL: mock.url()}}}
+	projectDir := setupLoggedInUserForTest(t, mock, configFile, false)
+
+	// the domain of the login would otherwise replace the one given to --domain
+	configFile, err := util.GetConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile.LoggedInUserDomain = ""
+	if err := util.WriteConfigFile(&configFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// delete sets its own PersistentPreRun, which replaces the one of the root command
+	if output, err := runInfisicalForTest(t, projectDir, "secrets", "delete", "DB_PASSWORD", "--env", "prod", "--domain", "self-hosted"); err != nil {
+		t.Fatalf("Expected the deletion to succeed, got [err=%v] with output [%s]", err, output)
+	}
+
+	if len(mock.writes) != 1 || !strings.Contains(mock.writes[0], `"prod-DB_PASSWORD"`) {
+		t.Errorf("Expected the secret to be deleted through the named domain, got %v", mock.writes)
+	}
+}
+
+func TestSecretsSetRejectsTagsFilter(t *testing.T) {
+	if executeInfisicalIfChild() {
+		return
+	}
+
+	mock := newMockUserServer(t, map[string][][2]string{"dev": {{"DB_PASSWORD", "dev-password"}}})
+	projectDir := setupLoggedInUserForTest(t, mock, models.ConfigFile{}, false)
+
+	for _, tagsFlag := range []string{"--tags", "-t"} {
+		output, err := runInfisicalForTest(t, projectDir, "secrets", "set", "DB_PASSWORD=new-password", "--env", "dev", tagsFlag, "backend")
+		exitErr, isExitErr := err.(*exec.ExitError)
+		if !isExitErr || exitErr.ExitCode() != 1 || !strings.Contains(string(output), "--set-tags") {
+			t.Errorf("Expected %s to be rejected in favor of --set-tags, got [err=%v] with output [%s]", tagsFlag, err, output)
+		}
+	}
+
+	if len(mock.writes) != 0 {
+		t.Errorf("Expected no secrets to be written, got %v", mock.writes)
+	}
+}
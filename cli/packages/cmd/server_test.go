@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/99designs/keyring"
+	"github.com/Infisical/infisical-merge/packages/crypto"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const (
+	testWorkspaceId        = "workspace-id"
+	testWorkspaceKey       = "0123456789abcdef0123456789abcdef"
+	testUserEmail          = "user@example.com"
+	testStoredServiceToken = "st.63f0a1b2c3d4e5f6a7b8c9d0.secretpart.abcdefghijklmnopqrstuvwxyz012345"
+)
+
+// mockUserServer serves the endpoints used by the commands of a logged in user, with secrets encrypted like the real backend does.
+// The secrets are given per environment and get the id <environment>-<key>
+type mockUserServer struct {
+	server  *httptest.Server
+	secrets map[string][][2]string
+	jwt     string
+	// the base64 private key of the user, the workspace key is encrypted for it
+	privateKey string
+
+	lock sync.Mutex
+	// the environments the secrets were read from, in order
+	readEnvironments []string
+	// the writes received, as METHOD path followed by the JSON body
+	writes []string
+	// the requests that were not authenticated with the JWT of the user, such as ones made with a service token
+	foreignRequests []string
+}
+
+func newMockUserServer(t *testing.T, secrets map[string][][2]string) *mockUserServer {
+	userPublicKey, userPrivateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	senderPublicKey, senderPrivateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	mock := &mockUserServer{
+		secrets:    secrets,
+		jwt:        encode([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encode([]byte(fmt.Sprintf(`{"userId":"1","exp":%d}`, time.Now().Add(time.Hour).Unix()))) + "." + encode([]byte("signature")),
+		privateKey: base64.StdEncoding.EncodeToString(userPrivateKey[:]),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/auth/checkAuth", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONForTest(w, map[string]interface{}{})
+	})
+
+	mux.HandleFunc(fmt.Sprintf("/api/v2/workspace/%s/encrypted-key", testWorkspaceId), func(w http.ResponseWriter, r *http.Request) {
+		nonce := make([]byte, 24)
+		rand.Read(nonce)
+		encryptedKey := crypto.EncryptAssymmetric([]byte(testWorkspaceKey), nonce, userPublicKey[:], senderPrivateKey[:])
+		writeJSONForTest(w, map[string]interface{}{
+			"encryptedKey": base64.StdEncoding.EncodeToString(encryptedKey),
+			"nonce":        base64.StdEncoding.EncodeToString(nonce),
+			"sender":       map[string]string{"publicKey": base64.StdEncoding.EncodeToString(senderPublicKey[:])},
+		})
+	})
+
+	mux.HandleFunc("/api/v2/secrets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			mock.recordWrite(r)
+			writeJSONForTest(w, map[string]interface{}{})
+			return
+		}
+
+		environment := r.URL.Query().Get("environment")
+		mock.lock.Lock()
+		mock.readEnvironments = append(mock.readEnvironments, environment)
+		mock.lock.Unlock()
+
+		encryptedSecrets := []map[string]interface{}{}
+		for _, secret := range mock.secrets[environment] {
+			keyCipherText, keyIv, keyTag := encryptForTest(t, secret[0])
+			valueCipherText, valueIv, valueTag := encryptForTest(t, secret[1])
+			commentCipherText, commentIv, commentTag := encryptForTest(t, "")
+			encryptedSecrets = append(encryptedSecrets, map[string]interface{}{
+				"_id": environment + "-" + secret[0], "type": util.SECRET_TYPE_SHARED, "environment": environment,
+				"secretKeyCiphertext": keyCipherText, "secretKeyIV": keyIv, "secretKeyTag": keyTag,
+				"secretValueCiphertext": valueCipherText, "secretValueIV": valueIv, "secretValueTag": valueTag,
+				"secretCommentCiphertext": commentCipherText, "secretCommentIV": commentIv, "secretCommentTag": commentTag,
+				"tags": []interface{}{},
+			})
+		}
+
+		writeJSONForTest(w, map[string]interface{}{"secrets": encryptedSecrets})
+	})
+
+	mux.HandleFunc("/api/v2/secrets/batch", func(w http.ResponseWriter, r *http.Request) {
+		mock.recordWrite(r)
+		writeJSONForTest(w, map[string]interface{}{})
+	})
+
+	// every request has to be made by the logged in user
+	mock.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+mock.jwt {
+			mock.lock.Lock()
+			mock.foreignRequests = append(mock.foreignRequests, r.Method+" "+r.URL.Path)
+			mock.lock.Unlock()
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(mock.server.Close)
+
+	return mock
+}
+
+func (mock *mockUserServer) url() string {
+	return mock.server.URL + "/api"
+}
+
+func (mock *mockUserServer) recordWrite(r *http.Request) {
+	var body interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	encodedBody, _ := json.Marshal(body)
+
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	mock.writes = append(mock.writes, r.Method+" "+r.URL.Path+" "+string(encodedBody))
+}
+
+// Logs the user in against the mock server in a new HOME and returns a project directory for it. When withStoredServiceToken is set,
+// a service token is also saved like [infisical login --method token --store file] does, so that both credentials exist
+func setupLoggedInUserForTest(t *testing.T, mock *mockUserServer, configFile models.ConfigFile, withStoredServiceToken bool) string {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("INFISICAL_VAULT_FILE_PASSPHRASE", "test-passphrase")
+	t.Setenv(util.INFISICAL_TOKEN_NAME, "")
+
+	configFile.VaultBackendType = keyring.FileBackend
+	configFile.LoggedInUserEmail = testUserEmail
+	configFile.LoggedInUserDomain = mock.url()
+	configFile.LoggedInUsers = []models.LoggedInUser{{Email: testUserEmail, Domain: mock.url()}}
+	if withStoredServiceToken {
+		configFile.ServiceTokenStore = util.SERVICE_TOKEN_STORE_FILE
+		configFile.ServiceToken = testStoredServiceToken
+		configFile.ServiceTokenDomain = mock.url()
+	}
+
+	if err := util.WriteConfigFile(&configFile); err != nil {
+		t.Fatalf("unable to write the config file [err=%v]", err)
+	}
+
+	err := util.StoreUserCredsInKeyRing(&models.UserCredentials{Email: testUserEmail, PrivateKey: mock.privateKey, JTWToken: mock.jwt})
+	if err != nil {
+		t.Fatalf("unable to store the credentials of the user [err=%v]", err)
+	}
+
+	projectDir := t.TempDir()
+	workspaceFile := fmt.Sprintf(`{"workspaceId": "%s", "defaultEnvironment": ""}`, testWorkspaceId)
+	if err := os.WriteFile(filepath.Join(projectDir, util.INFISICAL_WORKSPACE_CONFIG_FILE_NAME), []byte(workspaceFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return projectDir
+}
+
+// Commands exit on errors, so they are run in a child process that re-executes the calling test.
+// That test has to start with [if executeInfisicalIfChild() { return }]
+func runInfisicalForTest(t *testing.T, workingDirectory string, args ...string) ([]byte, error) {
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$")
+	cmd.Dir = workingDirectory
+	cmd.Env = append(os.Environ(), "TEST_INFISICAL_ARGS="+strings.Join(args, "\n"), "INFISICAL_DISABLE_UPDATE_CHECK=1")
+	return cmd.CombinedOutput()
+}
+
+func executeInfisicalIfChild() bool {
+	args := os.Getenv("TEST_INFISICAL_ARGS")
+	if args == "" {
+		return false
+	}
+
+	rootCmd.SetArgs(strings.Split(args, "\n"))
+	rootCmd.Execute()
+	return true
+}
+
+func encryptForTest(t *testing.T, plainText string) (cipherText string, iv string, tag string) {
+	result, err := crypto.EncryptSymmetric([]byte(plainText), []byte(testWorkspaceKey))
+	if err != nil {
+		t.Fatalf("encryptForTest: unable to encrypt [err=%v]", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(result.CipherText), base64.StdEncoding.EncodeToString(result.Nonce), base64.StdEncoding.EncodeToString(result.AuthTag)
+}
+
+func writeJSONForTest(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
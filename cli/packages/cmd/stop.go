@@ -0,0 +1,61 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/spf13/cobra"
+)
+
+var stopCmd = &cobra.Command{
+	Use:                   "stop",
+	Short:                 "Used to stop an application started in the background with [infisical run --detach]",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical stop --pid-file infisical-run.pid",
+	Args:                  cobra.NoArgs,
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		pidFile, err := cmd.Flags().GetString("pid-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		pid, err := util.ReadPidFile(pidFile)
+		if err != nil {
+			util.HandleError(err, "Unable to find the application to stop")
+		}
+
+		// never fails outside of Windows, where it fails when the process does not exist anymore
+		process, stopErr := os.FindProcess(pid)
+		if stopErr == nil {
+			stopErr = stopProcess(process)
+		}
+
+		hasExited := errors.Is(stopErr, os.ErrProcessDone) || errors.Is(stopErr, syscall.ESRCH)
+		if stopErr != nil && !hasExited {
+			util.HandleError(stopErr, fmt.Sprintf("Unable to stop your application with pid %d", pid))
+		}
+
+		if err := util.RemovePidFile(pidFile); err != nil {
+			util.PrintWarning(err.Error())
+		}
+
+		if hasExited {
+			util.PrintWarning(fmt.Sprintf("Your application with pid %d had already exited", pid))
+			return
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Stopped your application with pid %d", pid))
+	},
+}
+
+func init() {
+	stopCmd.Flags().String("pid-file", DETACH_DEFAULT_PID_FILE, "the pid file written by [infisical run --detach]")
+	rootCmd.AddCommand(stopCmd)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package cmd
+
+import "syscall"
+
+// the umask of the CLI is inherited by your application, and applies to the files the CLI writes from now on
+func setUmask(mask int) {
+	syscall.Umask(mask)
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package cmd
+
+import "github.com/Infisical/infisical-merge/packages/util"
+
+// Windows has no umask, files get the permissions of the directory they are created in
+func setUmask(mask int) {
+	util.PrintWarning("--umask has no effect on Windows, files are created with the permissions inherited from their directory")
+}
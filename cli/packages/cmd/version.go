@@ -0,0 +1,157 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/spf13/cobra"
+)
+
+const (
+	VERSION_OUTPUT_TEXT = "text"
+	VERSION_OUTPUT_JSON = "json"
+)
+
+var versionCmd = &cobra.Command{
+	Use:                   "version",
+	Short:                 "Used to print the version of the CLI and how it was built",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical version --check --output json",
+	Args:                  cobra.NoArgs,
+	PreRun:                toggleDebug,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if output != VERSION_OUTPUT_TEXT && output != VERSION_OUTPUT_JSON {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("invalid value [%s] for --output. Available options are [%s, %s]", output, VERSION_OUTPUT_TEXT, VERSION_OUTPUT_JSON))
+		}
+
+		shouldCheck, err := cmd.Flags().GetBool("check")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldFailIfOutdated, err := cmd.Flags().GetBool("fail-if-outdated")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if shouldFailIfOutdated && !shouldCheck {
+			util.PrintErrorMessageAndExit("--fail-if-outdated can only be used together with --check")
+		}
+
+		info := getVersionInfo()
+
+		if shouldCheck {
+			if util.IsUpdateCheckDisabled() {
+				util.PrintWarning("The latest release was not looked up because INFISICAL_DISABLE_UPDATE_CHECK is set")
+			} else {
+				latestVersion, err := util.GetLatestVersion()
+				if err != nil {
+					util.HandleError(err, "Unable to look up the latest release of the CLI")
+				}
+
+				isUpdateAvailable := latestVersion != info.Version
+				info.LatestVersion = latestVersion
+				info.IsUpdateAvailable = &isUpdateAvailable
+			}
+		}
+
+		formattedInfo, err := formatVersionInfo(info, output)
+		if err != nil {
+			util.HandleError(err, "Unable to print the version")
+		}
+		fmt.Print(formattedInfo)
+
+		// printed to stderr so that the output can still be parsed
+		if shouldFailIfOutdated && info.IsUpdateAvailable != nil && *info.IsUpdateAvailable {
+			util.PrintWarning(fmt.Sprintf("The CLI is outdated, version %s is available", info.LatestVersion))
+			if updateInstructions := util.GetUpdateInstructions(); updateInstructions != "" {
+				util.PrintWarning(updateInstructions)
+			}
+			os.Exit(util.EXIT_CODE_OUTDATED)
+		}
+	},
+}
+
+// What is printed by [infisical version]. The latest version is only looked up with --check
+type versionInfo struct {
+	Version           string `json:"version"`
+	Commit            string `json:"commit"`
+	BuildDate         string `json:"buildDate"`
+	GoVersion         string `json:"goVersion"`
+	OS                string `json:"os"`
+	Arch              string `json:"arch"`
+	LatestVersion     string `json:"latestVersion,omitempty"`
+	IsUpdateAvailable *bool  `json:"updateAvailable,omitempty"`
+}
+
+// Builds of the CLI without -ldflags, such as with [go install], fall back to the commit and commit time recorded by the go toolchain
+func getVersionInfo() versionInfo {
+	info := versionInfo{Version: util.CLI_VERSION, Commit: util.CLI_COMMIT, BuildDate: util.CLI_BUILD_DATE, GoVersion: runtime.Version(), OS: runtime.GOOS, Arch: runtime.GOARCH}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" && info.Commit == "" {
+				info.Commit = setting.Value
+			}
+			if setting.Key == "vcs.time" && info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+
+	return info
+}
+
+func formatVersionInfo(info versionInfo, output string) (string, error) {
+	if output == VERSION_OUTPUT_JSON {
+		formattedInfo, err := json.MarshalIndent(info, "", "    ")
+		if err != nil {
+			return "", err
+		}
+		return string(formattedInfo) + "\n", nil
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "infisical version %s\n", info.Version)
+	fmt.Fprintf(&builder, "commit: %s\n", info.Commit)
+	fmt.Fprintf(&builder, "built: %s\n", info.BuildDate)
+	fmt.Fprintf(&builder, "go: %s\n", info.GoVersion)
+	fmt.Fprintf(&builder, "platform: %s/%s\n", info.OS, info.Arch)
+
+	if info.IsUpdateAvailable != nil {
+		if *info.IsUpdateAvailable {
+			fmt.Fprintf(&builder, "latest: %s, an update is available\n", info.LatestVersion)
+		} else {
+			fmt.Fprintf(&builder, "latest: %s, you are up to date\n", info.LatestVersion)
+		}
+	}
+
+	return builder.String(), nil
+}
+
+func init() {
+	versionCmd.Flags().String("output", VERSION_OUTPUT_TEXT, "The format to print the version in (text, json)")
+	versionCmd.Flags().Bool("check", false, "Look up the latest release of the CLI and report whether an update is available. Skipped when INFISICAL_DISABLE_UPDATE_CHECK is set")
+	versionCmd.Flags().Bool("fail-if-outdated", false, "With --check, exit with code 7 when an update is available")
+	rootCmd.AddCommand(versionCmd)
+}
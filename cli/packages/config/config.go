@@ -1,4 +1,30 @@
 package config
 
+import "time"
+
 var INFISICAL_URL string
 var INFISICAL_URL_MANUAL_OVERRIDE string
+
+// sent as the X-Request-ID header of every API request, generated when not set with --request-id
+var INFISICAL_REQUEST_ID string
+
+// max requests per second sent to the API, set with --rate-limit. 0 means unlimited
+var INFISICAL_RATE_LIMIT float64
+
+// log sanitized metadata of every API request to stderr, set with --trace
+var INFISICAL_TRACE bool
+
+// file the sanitized trace is appended to instead of stderr, set with --trace-file. Enables the trace on its own
+var INFISICAL_TRACE_FILE string
+
+// print the auth method and identity that fetched the secrets to stderr, set with --print-auth-method
+var INFISICAL_PRINT_AUTH_METHOD bool
+
+// max time to resolve and connect to the API, set with --connect-timeout. 0 means unlimited
+var INFISICAL_CONNECT_TIMEOUT time.Duration
+
+// max time of a whole API request including reading the response, set with --timeout. 0 means unlimited
+var INFISICAL_REQUEST_TIMEOUT time.Duration
+
+// the workspace config file to load instead of looking up .infisical.json, set with --config-file
+var INFISICAL_WORKSPACE_CONFIG_FILE string
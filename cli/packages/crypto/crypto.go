@@ -7,9 +7,18 @@ import (
 	"io"
 
 	"github.com/Infisical/infisical-merge/packages/models"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/nacl/box"
 )
 
+// argon2id parameters of DeriveKeyFromPassphrase, changing them makes files encrypted so far unreadable
+const (
+	PASSPHRASE_KEY_ITERATIONS  = 3
+	PASSPHRASE_KEY_MEMORY      = 64 * 1024
+	PASSPHRASE_KEY_PARALLELISM = 1
+	PASSPHRASE_KEY_LENGTH      = 32
+)
+
 // will decrypt cipher text to plain text using iv and tag
 func DecryptSymmetric(key []byte, cipherText []byte, tag []byte, iv []byte) ([]byte, error) {
 	// Case: empty string
@@ -44,6 +53,11 @@ func GenerateNewKey() (newKey []byte, keyErr error) {
 	return key, err
 }
 
+// Derives a key usable with EncryptSymmetric from a passphrase typed by the user. The salt must be random and stored next to the cipher text
+func DeriveKeyFromPassphrase(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, PASSPHRASE_KEY_ITERATIONS, PASSPHRASE_KEY_MEMORY, PASSPHRASE_KEY_PARALLELISM, PASSPHRASE_KEY_LENGTH)
+}
+
 // Will encrypt a plain text with the provided key
 func EncryptSymmetric(plaintext []byte, key []byte) (result models.SymmetricEncryptionResult, err error) {
 	block, err := aes.NewCipher(key)
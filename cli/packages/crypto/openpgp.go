@@ -0,0 +1,77 @@
+package crypto
+
+import (
+	"bytes"
+	stdcrypto "crypto"
+	// registers the hashes OpenPGP keys may prefer, messages cannot be encrypted without one of them even unsigned.
+	// RIPEMD160 is assumed for keys without preferences
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/openpgp/packet"
+	_ "golang.org/x/crypto/ripemd160"
+)
+
+// Reads the OpenPGP public keys of a file, armored as exported by [gpg --export --armor] or binary
+func ReadOpenPGPPublicKeys(path string) (openpgp.EntityList, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read public key file [%s] [err=%v]", path, err)
+	}
+
+	var keys openpgp.EntityList
+	if strings.Contains(string(content), "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+		keys, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+	} else {
+		keys, err = openpgp.ReadKeyRing(bytes.NewReader(content))
+	}
+
+	// elliptic curve keys, the default of recent gpg versions, are not implemented by the openpgp package
+	if _, isUnsupported := err.(errors.UnsupportedError); isUnsupported {
+		return nil, fmt.Errorf("unable to read the OpenPGP public key of [%s], only RSA and ElGamal keys are supported [err=%v]", path, err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the OpenPGP public key of [%s] [err=%v]", path, err)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no OpenPGP public key found in [%s]", path)
+	}
+
+	return keys, nil
+}
+
+// Encrypts plainText so that any of recipients can decrypt it, for example with [gpg --decrypt]. Returns an armored PGP MESSAGE
+func EncryptForOpenPGPRecipients(plainText []byte, recipients openpgp.EntityList) (string, error) {
+	var armored bytes.Buffer
+	armorWriter, err := armor.Encode(&armored, "PGP MESSAGE", nil)
+	if err != nil {
+		return "", err
+	}
+
+	encryptWriter, err := openpgp.Encrypt(armorWriter, recipients, nil, nil, &packet.Config{DefaultHash: stdcrypto.SHA256})
+	if err != nil {
+		return "", fmt.Errorf("unable to encrypt for the OpenPGP recipients [err=%v]", err)
+	}
+
+	if _, err := encryptWriter.Write(plainText); err != nil {
+		return "", err
+	}
+
+	if err := encryptWriter.Close(); err != nil {
+		return "", err
+	}
+
+	if err := armorWriter.Close(); err != nil {
+		return "", err
+	}
+
+	return armored.String() + "\n", nil
+}
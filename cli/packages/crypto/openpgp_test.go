@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func Test_EncryptForOpenPGPRecipients(t *testing.T) {
+	entity, err := openpgp.NewEntity("Deploy", "", "deploy@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var publicKey bytes.Buffer
+	armorWriter, _ := armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	if err := entity.Serialize(armorWriter); err != nil {
+		t.Fatal(err)
+	}
+	armorWriter.Close()
+
+	publicKeyFile := filepath.Join(t.TempDir(), "deploy.asc")
+	if err := os.WriteFile(publicKeyFile, publicKey.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	recipients, err := ReadOpenPGPPublicKeys(publicKeyFile)
+	if err != nil || len(recipients) != 1 || recipients[0].PrivateKey != nil {
+		t.Fatalf("Test_EncryptForOpenPGPRecipients: expected the public key only but got %v [err=%v]", recipients, err)
+	}
+
+	plainText := "DB_PASSWORD='hunter2'\n"
+	cipherText, err := EncryptForOpenPGPRecipients([]byte(plainText), recipients)
+	if err != nil {
+		t.Fatalf("Test_EncryptForOpenPGPRecipients: unexpected error [err=%v]", err)
+	}
+
+	if !strings.HasPrefix(cipherText, "-----BEGIN PGP MESSAGE-----") || strings.Contains(cipherText, "hunter2") {
+		t.Fatalf("Test_EncryptForOpenPGPRecipients: expected an armored message but got %s", cipherText)
+	}
+
+	block, err := armor.Decode(strings.NewReader(cipherText))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{entity}, nil, nil)
+	if err != nil {
+		t.Fatalf("Test_EncryptForOpenPGPRecipients: unable to decrypt with the private key [err=%v]", err)
+	}
+
+	decrypted, err := io.ReadAll(message.UnverifiedBody)
+	if err != nil || string(decrypted) != plainText {
+		t.Errorf("Test_EncryptForOpenPGPRecipients: expected [%s] but got [%s] [err=%v]", plainText, decrypted, err)
+	}
+}
+
+func Test_ReadOpenPGPPublicKeys_InvalidKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(keyFile, []byte("age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadOpenPGPPublicKeys(keyFile); err == nil {
+		t.Errorf("Test_ReadOpenPGPPublicKeys_InvalidKey: expected a file without an OpenPGP key to be refused")
+	}
+}
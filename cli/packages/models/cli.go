@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/99designs/keyring"
 )
 
@@ -16,6 +18,24 @@ type ConfigFile struct {
 	LoggedInUserDomain string              `json:"LoggedInUserDomain,omitempty"`
 	VaultBackendType   keyring.BackendType `json:"vaultBackendType"`
 	LoggedInUsers      []LoggedInUser      `json:"loggedInUsers,omitempty"`
+	Domains            []NamedDomain       `json:"domains,omitempty"`
+	EnvironmentAliases []EnvironmentAlias  `json:"environmentAliases,omitempty"`
+	// Set by [infisical login --method token]. The token itself is only kept in this file when stored with --store file
+	ServiceTokenStore  string `json:"serviceTokenStore,omitempty"`
+	ServiceTokenDomain string `json:"serviceTokenDomain,omitempty"`
+	ServiceToken       string `json:"serviceToken,omitempty"`
+}
+
+// A self-hosted (or cloud) instance registered under a short name via [infisical config domains add]
+type NamedDomain struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// A short name for an environment slug registered via [infisical config env-alias add]
+type EnvironmentAlias struct {
+	Alias string `json:"alias"`
+	Slug  string `json:"slug"`
 }
 
 type LoggedInUser struct {
@@ -35,6 +55,9 @@ type SingleEnvironmentVariable struct {
 		Workspace string `json:"workspace"`
 	} `json:"tags"`
 	Comment string `json:"comment"`
+	// shown by [infisical secrets --columns], not part of exported or cached secrets
+	Version   int       `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
 
 type Workspace struct {
@@ -63,4 +86,10 @@ type GetAllSecretsParameters struct {
 	InfisicalToken           string
 	TagSlugs                 string
 	WorkspaceId              string
+	// one of fail, warn or use-cache. Empty behaves like fail
+	OnFetchError string
+	// folder to fetch the secrets of. Empty fetches the root folder
+	SecretsPath string
+	// only decrypt the keys, types and comments of the secrets, their values are left empty
+	KeysOnly bool
 }
@@ -0,0 +1,232 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	CAPTURE_MODE_COMBINED = "combined"
+	CAPTURE_MODE_SEPARATE = "separate"
+
+	// shorter values are not redacted since they would mask unrelated output (e.g. a secret set to true)
+	MIN_REDACTED_SECRET_LENGTH = 4
+	REDACTED_SECRET_MASK       = "*****"
+
+	// partial lines longer than this are written out even though a secret could be split across writes
+	maxRedactionBufferSize = 64 * 1024
+)
+
+// OutputCapture tees the output of a process to the terminal and to capture files
+type OutputCapture struct {
+	Stdout io.Writer
+	Stderr io.Writer
+
+	files   []*os.File
+	writers []*captureWriter
+}
+
+// StartOutputCapture creates the capture file(s) at path. In combined mode stdout and stderr are written to path,
+// in separate mode to path.stdout and path.stderr. When redactValues is set, these values are masked in the captured output only.
+// Labels (KEY=value) are prepended to every captured line so that the output of several invocations can be told apart
+func StartOutputCapture(path string, mode string, stdout io.Writer, stderr io.Writer, redactValues []string, labels []string) (*OutputCapture, error) {
+	capture := &OutputCapture{}
+
+	linePrefix := ""
+	if len(labels) > 0 {
+		linePrefix = "[" + strings.Join(labels, " ") + "] "
+	}
+
+	switch mode {
+	case CAPTURE_MODE_COMBINED:
+		file, err := openCaptureFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		lock := &sync.Mutex{}
+		capture.files = []*os.File{file}
+		capture.writers = []*captureWriter{newCaptureWriter(file, lock, redactValues, linePrefix), newCaptureWriter(file, lock, redactValues, linePrefix)}
+	case CAPTURE_MODE_SEPARATE:
+		stdoutFile, err := openCaptureFile(path + ".stdout")
+		if err != nil {
+			return nil, err
+		}
+
+		stderrFile, err := openCaptureFile(path + ".stderr")
+		if err != nil {
+			stdoutFile.Close()
+			return nil, err
+		}
+
+		capture.files = []*os.File{stdoutFile, stderrFile}
+		capture.writers = []*captureWriter{newCaptureWriter(stdoutFile, &sync.Mutex{}, redactValues, linePrefix), newCaptureWriter(stderrFile, &sync.Mutex{}, redactValues, linePrefix)}
+	default:
+		return nil, fmt.Errorf("invalid capture mode [%s]. Available options are [%s, %s]", mode, CAPTURE_MODE_COMBINED, CAPTURE_MODE_SEPARATE)
+	}
+
+	capture.Stdout = io.MultiWriter(stdout, capture.writers[0])
+	capture.Stderr = io.MultiWriter(stderr, capture.writers[1])
+
+	return capture, nil
+}
+
+// Close writes out any buffered output and closes the capture files. The first write error, if any, is returned
+func (capture *OutputCapture) Close() error {
+	var firstErr error
+	for _, writer := range capture.writers {
+		if err := writer.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, file := range capture.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// ParseCaptureLabels checks that the labels given to --label are in the KEY=value format
+func ParseCaptureLabels(rawLabels []string) ([]string, error) {
+	labels := []string{}
+	for _, rawLabel := range rawLabels {
+		separatorIndex := strings.Index(rawLabel, "=")
+		if separatorIndex <= 0 {
+			return nil, fmt.Errorf("invalid label [%s]. Expected the format KEY=value", rawLabel)
+		}
+
+		if strings.ContainsAny(rawLabel[:separatorIndex], " \t") || strings.ContainsAny(rawLabel, "\r\n") {
+			return nil, fmt.Errorf("invalid label [%s]. Labels cannot contain line breaks and their key cannot contain whitespace", rawLabel)
+		}
+
+		labels = append(labels, rawLabel)
+	}
+
+	return labels, nil
+}
+
+// App logs may contain sensitive data so only the current user can read the capture file. Existing files are appended to
+func openCaptureFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open capture file [%s] [err=%v]", path, err)
+	}
+
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to restrict the permissions of capture file [%s] [err=%v]", path, err)
+	}
+
+	return file, nil
+}
+
+// captureWriter writes to a capture file without ever failing so that the process output keeps reaching the terminal.
+// When redacting or prefixing lines, output is buffered per line so that secrets split across writes are still masked
+type captureWriter struct {
+	file       io.Writer
+	lock       *sync.Mutex
+	replacer   *strings.Replacer
+	linePrefix string
+	buffer     []byte
+	err        error
+	// set when a partial line was flushed, so that the rest of the line is not prefixed again
+	midLine bool
+}
+
+func newCaptureWriter(file io.Writer, lock *sync.Mutex, redactValues []string, linePrefix string) *captureWriter {
+	writer := &captureWriter{file: file, lock: lock, linePrefix: linePrefix}
+
+	valuesToRedact := []string{}
+	for _, value := range redactValues {
+		if len(value) >= MIN_REDACTED_SECRET_LENGTH {
+			valuesToRedact = append(valuesToRedact, value)
+		}
+	}
+
+	if len(valuesToRedact) > 0 {
+		// longest first so that a secret containing another one is masked as a whole
+		sort.SliceStable(valuesToRedact, func(i, j int) bool {
+			return len(valuesToRedact[i]) > len(valuesToRedact[j])
+		})
+
+		replacements := []string{}
+		for _, value := range valuesToRedact {
+			replacements = append(replacements, value, REDACTED_SECRET_MASK)
+		}
+		writer.replacer = strings.NewReplacer(replacements...)
+	}
+
+	return writer
+}
+
+func (writer *captureWriter) Write(p []byte) (int, error) {
+	if writer.replacer == nil && writer.linePrefix == "" {
+		writer.write(p)
+		return len(p), nil
+	}
+
+	writer.buffer = append(writer.buffer, p...)
+	if lastNewLine := bytes.LastIndexByte(writer.buffer, '\n'); lastNewLine != -1 {
+		writer.write([]byte(writer.transform(string(writer.buffer[:lastNewLine+1]))))
+		writer.buffer = append([]byte{}, writer.buffer[lastNewLine+1:]...)
+	} else if len(writer.buffer) > maxRedactionBufferSize {
+		writer.Flush()
+	}
+
+	return len(p), nil
+}
+
+func (writer *captureWriter) Flush() error {
+	if len(writer.buffer) > 0 {
+		writer.write([]byte(writer.transform(string(writer.buffer))))
+		writer.buffer = nil
+	}
+	return writer.err
+}
+
+// Redacts the secrets of buffered output and prefixes each line of it
+func (writer *captureWriter) transform(output string) string {
+	if writer.replacer != nil {
+		output = writer.replacer.Replace(output)
+	}
+
+	if writer.linePrefix == "" {
+		return output
+	}
+
+	transformed := &strings.Builder{}
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if line == "" {
+			continue
+		}
+
+		if !writer.midLine {
+			transformed.WriteString(writer.linePrefix)
+		}
+		transformed.WriteString(line)
+		writer.midLine = !strings.HasSuffix(line, "\n")
+	}
+
+	return transformed.String()
+}
+
+func (writer *captureWriter) write(p []byte) {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	if writer.err != nil {
+		return
+	}
+
+	if _, err := writer.file.Write(p); err != nil {
+		writer.err = fmt.Errorf("unable to write to capture file [err=%v]", err)
+	}
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_StartOutputCapture(t *testing.T) {
+	capturePath := filepath.Join(t.TempDir(), "output.log")
+	terminalStdout, terminalStderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	capture, err := StartOutputCapture(capturePath, CAPTURE_MODE_COMBINED, terminalStdout, terminalStderr, []string{"supersecret", "supersecretvalue", "abc"}, nil)
+	if err != nil {
+		t.Fatalf("Test_StartOutputCapture: unexpected error [err=%v]", err)
+	}
+
+	// the secret is split across writes and only completed on the next one
+	capture.Stdout.Write([]byte("token=super"))
+	capture.Stdout.Write([]byte("secretvalue abc\n"))
+	capture.Stderr.Write([]byte("failed with supersecret"))
+
+	if err := capture.Close(); err != nil {
+		t.Fatalf("Test_StartOutputCapture: unexpected error when closing [err=%v]", err)
+	}
+
+	captured, err := os.ReadFile(capturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(captured) != "token=***** abc\nfailed with *****" {
+		t.Errorf("Test_StartOutputCapture: unexpected captured output [%s]", captured)
+	}
+
+	if terminalStdout.String() != "token=supersecretvalue abc\n" || terminalStderr.String() != "failed with supersecret" {
+		t.Errorf("Test_StartOutputCapture: expected the terminal output to be left untouched, got [%s] [%s]", terminalStdout, terminalStderr)
+	}
+
+	info, err := os.Stat(capturePath)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Test_StartOutputCapture: expected the capture file to be created with 0600 permissions [err=%v]", err)
+	}
+
+	capture, err = StartOutputCapture(capturePath, CAPTURE_MODE_SEPARATE, terminalStdout, terminalStderr, nil, nil)
+	if err != nil {
+		t.Fatalf("Test_StartOutputCapture: unexpected error [err=%v]", err)
+	}
+
+	capture.Stdout.Write([]byte("out"))
+	capture.Stderr.Write([]byte("err"))
+	capture.Close()
+
+	capturedStdout, _ := os.ReadFile(capturePath + ".stdout")
+	capturedStderr, _ := os.ReadFile(capturePath + ".stderr")
+	if string(capturedStdout) != "out" || string(capturedStderr) != "err" {
+		t.Errorf("Test_StartOutputCapture: expected separate capture files, got [%s] [%s]", capturedStdout, capturedStderr)
+	}
+}
+
+func Test_StartOutputCapture_Labels(t *testing.T) {
+	labels, err := ParseCaptureLabels([]string{"team=payments", "deploy=abc123"})
+	if err != nil {
+		t.Fatalf("Test_StartOutputCapture_Labels: unexpected error [err=%v]", err)
+	}
+
+	capturePath := filepath.Join(t.TempDir(), "output.log")
+	terminalStdout := &bytes.Buffer{}
+	capture, err := StartOutputCapture(capturePath, CAPTURE_MODE_SEPARATE, terminalStdout, &bytes.Buffer{}, []string{"supersecret"}, labels)
+	if err != nil {
+		t.Fatalf("Test_StartOutputCapture_Labels: unexpected error [err=%v]", err)
+	}
+
+	capture.Stdout.Write([]byte("first line\nsecond "))
+	capture.Stdout.Write([]byte("line supersecret\nlast"))
+	capture.Close()
+
+	captured, _ := os.ReadFile(capturePath + ".stdout")
+	expected := "[team=payments deploy=abc123] first line\n[team=payments deploy=abc123] second line *****\n[team=payments deploy=abc123] last"
+	if string(captured) != expected {
+		t.Errorf("Test_StartOutputCapture_Labels: expected every captured line to be labelled, got [%s]", captured)
+	}
+
+	if terminalStdout.String() != "first line\nsecond line supersecret\nlast" {
+		t.Errorf("Test_StartOutputCapture_Labels: expected the terminal output to be left untouched, got [%s]", terminalStdout)
+	}
+
+	for _, invalidLabel := range []string{"team", "=payments", "my team=payments", "team=pay\nments"} {
+		if _, err := ParseCaptureLabels([]string{invalidLabel}); err == nil {
+			t.Errorf("Test_StartOutputCapture_Labels: expected an error for label [%q]", invalidLabel)
+		}
+	}
+}
@@ -17,10 +17,10 @@ import (
 )
 
 func CheckForUpdate() {
-	if checkEnv := os.Getenv("INFISICAL_DISABLE_UPDATE_CHECK"); checkEnv != "" {
+	if IsUpdateCheckDisabled() {
 		return
 	}
-	latestVersion, err := getLatestTag("Infisical", "infisical")
+	latestVersion, err := GetLatestVersion()
 	if err != nil {
 		log.Debug(err)
 		// do nothing and continue
@@ -50,6 +50,16 @@ func CheckForUpdate() {
 	}
 }
 
+// IsUpdateCheckDisabled is true when INFISICAL_DISABLE_UPDATE_CHECK is set, in which case the latest release is never looked up
+func IsUpdateCheckDisabled() bool {
+	return os.Getenv("INFISICAL_DISABLE_UPDATE_CHECK") != ""
+}
+
+// GetLatestVersion returns the version of the latest release of the CLI, without the v prefix
+func GetLatestVersion() (string, error) {
+	return getLatestTag("Infisical", "infisical")
+}
+
 func getLatestTag(repoOwner string, repoName string) (string, error) {
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/tags", repoOwner, repoName)
 	resp, err := http.Get(url)
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 )
 
 func GetHomeDir() (string, error) {
@@ -25,3 +28,76 @@ func CheckIsConnectedToInternet() (ok bool) {
 	_, err := http.Get("http://clients3.google.com/generate_204")
 	return err == nil
 }
+
+// WriteToFileAtomically writes to a temporary file next to the given path and then renames it over the path,
+// so that readers never see a partially written file
+func WriteToFileAtomically(fileName string, dataToWrite []byte, filePerm os.FileMode) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file [err=%v]", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err = tempFile.Write(dataToWrite); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("unable to write to temporary file [err=%v]", err)
+	}
+
+	if err = tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("unable to write to temporary file [err=%v]", err)
+	}
+
+	if err = tempFile.Close(); err != nil {
+		return fmt.Errorf("unable to write to temporary file [err=%v]", err)
+	}
+
+	if err = os.Chmod(tempFile.Name(), filePerm); err != nil {
+		return fmt.Errorf("unable to set file permissions [err=%v]", err)
+	}
+
+	if err = os.Rename(tempFile.Name(), fileName); err != nil {
+		return fmt.Errorf("unable to replace file [%s] [err=%v]", fileName, err)
+	}
+
+	return nil
+}
+
+// NormalizeSecretsPath cleans up a folder path the same way the Infisical API does, for example "folder//sub/" becomes "/folder/sub"
+func NormalizeSecretsPath(path string) string {
+	parts := []string{}
+	for _, part := range strings.Split(path, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return "/" + strings.Join(parts, "/")
+}
+
+var secretsPathVariablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandSecretsPathVariables replaces the ${VAR} references of a folder path with the value of the environment variable VAR, so that
+// the path can depend on the environment the CLI runs in. Unset or empty variables are an error unless allowEmptyVariables is set,
+// in which case they are removed and the path normalized
+func ExpandSecretsPathVariables(path string, allowEmptyVariables bool) (string, error) {
+	missingVariables := []string{}
+	expandedPath := secretsPathVariablePattern.ReplaceAllStringFunc(path, func(reference string) string {
+		variableName := secretsPathVariablePattern.FindStringSubmatch(reference)[1]
+		value := os.Getenv(variableName)
+		if value == "" {
+			missingVariables = append(missingVariables, variableName)
+		}
+		return value
+	})
+
+	if len(missingVariables) > 0 && !allowEmptyVariables {
+		return "", fmt.Errorf("the path [%s] references environment variable(s) [%s] which are not set or empty", path, strings.Join(missingVariables, ", "))
+	}
+
+	if len(missingVariables) > 0 {
+		return NormalizeSecretsPath(expandedPath), nil
+	}
+
+	return expandedPath, nil
+}
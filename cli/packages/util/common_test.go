@@ -0,0 +1,28 @@
+package util
+
+import "testing"
+
+func Test_ExpandSecretsPathVariables(t *testing.T) {
+	t.Setenv("TENANT_ID", "acme")
+	t.Setenv("EMPTY_VAR", "")
+
+	path, err := ExpandSecretsPathVariables("/tenants/${TENANT_ID}/config", false)
+	if err != nil || path != "/tenants/acme/config" {
+		t.Errorf("Test_ExpandSecretsPathVariables: expected /tenants/acme/config but got %s [err=%v]", path, err)
+	}
+
+	// only the ${VAR} syntax is expanded
+	path, err = ExpandSecretsPathVariables("/$TENANT_ID/${TENANT_ID", false)
+	if err != nil || path != "/$TENANT_ID/${TENANT_ID" {
+		t.Errorf("Test_ExpandSecretsPathVariables: expected the path to be left as is but got %s [err=%v]", path, err)
+	}
+
+	if _, err := ExpandSecretsPathVariables("/tenants/${UNSET_TENANT_VAR}/${EMPTY_VAR}", false); err == nil {
+		t.Errorf("Test_ExpandSecretsPathVariables: expected an error for unset and empty variables")
+	}
+
+	path, err = ExpandSecretsPathVariables("/tenants/${UNSET_TENANT_VAR}/config", true)
+	if err != nil || path != "/tenants/config" {
+		t.Errorf("Test_ExpandSecretsPathVariables: expected /tenants/config but got %s [err=%v]", path, err)
+	}
+}
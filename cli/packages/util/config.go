@@ -1,6 +1,7 @@
 package util
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -54,6 +55,8 @@ func WriteInitalConfig(userCredentials *models.UserCredentials) error {
 		LoggedInUserDomain: config.INFISICAL_URL,
 		VaultBackendType:   existingConfigFile.VaultBackendType,
 		LoggedInUsers:      existingConfigFile.LoggedInUsers,
+		Domains:            existingConfigFile.Domains,
+		EnvironmentAliases: existingConfigFile.EnvironmentAliases,
 	}
 
 	configFileMarshalled, err := json.Marshal(configFile)
@@ -85,7 +88,7 @@ func ConfigFileExists() bool {
 }
 
 func WorkspaceConfigFileExistsInCurrentPath() bool {
-	if _, err := os.Stat(INFISICAL_WORKSPACE_CONFIG_FILE_NAME); err == nil {
+	if _, err := os.Stat(GetWorkspaceConfigFileName()); err == nil {
 		return true
 	} else {
 		log.Debugln(err)
@@ -106,15 +109,47 @@ func GetWorkSpaceFromFile() (models.WorkspaceConfigFile, error) {
 
 	var workspaceConfigFile models.WorkspaceConfigFile
 	err = json.Unmarshal(configFileAsBytes, &workspaceConfigFile)
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) {
+		line, column := getLineAndColumn(configFileAsBytes, syntaxError.Offset)
+		return models.WorkspaceConfigFile{}, fmt.Errorf("unable to parse workspace config file [%s] at line %d, column %d [err=%v]", cfgFile, line, column, err)
+	}
+
 	if err != nil {
-		return models.WorkspaceConfigFile{}, err
+		return models.WorkspaceConfigFile{}, fmt.Errorf("unable to parse workspace config file [%s] [err=%v]", cfgFile, err)
 	}
 
 	return workspaceConfigFile, nil
 }
 
+// the line and column, both starting at 1, of the byte at offset
+func getLineAndColumn(content []byte, offset int64) (int, int) {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+
+	before := content[:offset]
+	return bytes.Count(before, []byte("\n")) + 1, len(before) - (bytes.LastIndexByte(before, '\n') + 1)
+}
+
+// GetWorkspaceConfigFileName returns the file given with --config-file, or .infisical.json otherwise
+func GetWorkspaceConfigFileName() string {
+	if config.INFISICAL_WORKSPACE_CONFIG_FILE != "" {
+		return config.INFISICAL_WORKSPACE_CONFIG_FILE
+	}
+	return INFISICAL_WORKSPACE_CONFIG_FILE_NAME
+}
+
 // FindWorkspaceConfigFile searches for a .infisical.json file in the current directory and all parent directories.
+// The file given with --config-file is used as is instead
 func FindWorkspaceConfigFile() (string, error) {
+	if config.INFISICAL_WORKSPACE_CONFIG_FILE != "" {
+		if _, err := os.Stat(config.INFISICAL_WORKSPACE_CONFIG_FILE); err != nil {
+			return "", fmt.Errorf("unable to find the workspace config file [%s] given with --config-file or INFISICAL_CONFIG_FILE [err=%v]", config.INFISICAL_WORKSPACE_CONFIG_FILE, err)
+		}
+		return config.INFISICAL_WORKSPACE_CONFIG_FILE, nil
+	}
+
 	dir, err := os.Getwd()
 	if err != nil {
 		return "", err
@@ -229,3 +264,25 @@ func WriteConfigFile(configFile *models.ConfigFile) error {
 
 	return nil
 }
+
+// Returns the url registered for the given domain name. If no named domain matches, the input is returned as is
+func ResolveDomain(configFile models.ConfigFile, domain string) string {
+	for _, namedDomain := range configFile.Domains {
+		if namedDomain.Name == domain {
+			return namedDomain.URL
+		}
+	}
+
+	return domain
+}
+
+// Returns the environment slug registered for the given alias. If no alias matches, the input is returned as is so that full slugs keep working
+func ResolveEnvironmentAlias(configFile models.ConfigFile, environment string) string {
+	for _, environmentAlias := range configFile.EnvironmentAliases {
+		if environmentAlias.Alias == environment {
+			return environmentAlias.Slug
+		}
+	}
+
+	return environment
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func Test_ResolveDomain(t *testing.T) {
+	configFile := models.ConfigFile{Domains: []models.NamedDomain{{Name: "prod", URL: "https://my-self-hosted-instance.com/api"}}}
+
+	if domain := ResolveDomain(configFile, "prod"); domain != "https://my-self-hosted-instance.com/api" {
+		t.Errorf("Test_ResolveDomain: expected the name to resolve to its url but got %s", domain)
+	}
+
+	// literal urls and unknown names are passed through so that --domain keeps accepting urls
+	for _, domain := range []string{"https://app.infisical.com/api", "staging", ""} {
+		if resolvedDomain := ResolveDomain(configFile, domain); resolvedDomain != domain {
+			t.Errorf("Test_ResolveDomain: expected [%s] to be kept as is but got %s", domain, resolvedDomain)
+		}
+	}
+}
+
+func Test_ResolveEnvironmentAlias(t *testing.T) {
+	configFile := models.ConfigFile{EnvironmentAliases: []models.EnvironmentAlias{{Alias: "prod", Slug: "production-us-east"}}}
+
+	if environment := ResolveEnvironmentAlias(configFile, "prod"); environment != "production-us-east" {
+		t.Errorf("Test_ResolveEnvironmentAlias: expected the alias to resolve to its slug but got %s", environment)
+	}
+
+	for _, slug := range []string{"production-us-east", "dev", ""} {
+		if environment := ResolveEnvironmentAlias(configFile, slug); environment != slug {
+			t.Errorf("Test_ResolveEnvironmentAlias: expected [%s] to be kept as is but got %s", slug, environment)
+		}
+	}
+}
+
+func Test_GetWorkSpaceFromFile_ConfigFileOverride(t *testing.T) {
+	// a discoverable .infisical.json in the current directory
+	currentDir := t.TempDir()
+	os.WriteFile(filepath.Join(currentDir, INFISICAL_WORKSPACE_CONFIG_FILE_NAME), []byte(`{"workspaceId": "discovered"}`), 0600)
+
+	previousDir, _ := os.Getwd()
+	os.Chdir(currentDir)
+	defer os.Chdir(previousDir)
+	defer func() { config.INFISICAL_WORKSPACE_CONFIG_FILE = "" }()
+
+	explicitFile := filepath.Join(t.TempDir(), "ci.json")
+	os.WriteFile(explicitFile, []byte(`{"workspaceId": "explicit", "defaultEnvironment": "staging"}`), 0600)
+
+	config.INFISICAL_WORKSPACE_CONFIG_FILE = explicitFile
+	workspaceFile, err := GetWorkSpaceFromFile()
+	if err != nil || workspaceFile.WorkspaceId != "explicit" || workspaceFile.DefaultEnvironment != "staging" {
+		t.Errorf("Test_GetWorkSpaceFromFile_ConfigFileOverride: expected the file given with --config-file to win but got %+v [err=%v]", workspaceFile, err)
+	}
+
+	config.INFISICAL_WORKSPACE_CONFIG_FILE = filepath.Join(t.TempDir(), "missing.json")
+	if _, err := GetWorkSpaceFromFile(); err == nil || !strings.Contains(err.Error(), "--config-file") {
+		t.Errorf("Test_GetWorkSpaceFromFile_ConfigFileOverride: expected a missing file to not fall back to discovery [err=%v]", err)
+	}
+
+	os.WriteFile(explicitFile, []byte("{\n  \"workspaceId\": \"explicit\",,\n}"), 0600)
+	config.INFISICAL_WORKSPACE_CONFIG_FILE = explicitFile
+	if _, err := GetWorkSpaceFromFile(); err == nil || !strings.Contains(err.Error(), "line 2, column 29") {
+		t.Errorf("Test_GetWorkSpaceFromFile_ConfigFileOverride: expected the location of the syntax error [err=%v]", err)
+	}
+
+	config.INFISICAL_WORKSPACE_CONFIG_FILE = ""
+	workspaceFile, err = GetWorkSpaceFromFile()
+	if err != nil || workspaceFile.WorkspaceId != "discovered" {
+		t.Errorf("Test_GetWorkSpaceFromFile_ConfigFileOverride: expected the discovered file without --config-file but got %+v [err=%v]", workspaceFile, err)
+	}
+}
@@ -11,8 +11,57 @@ const (
 	KEYRING_SERVICE_NAME                 = "infisical"
 	PERSONAL_SECRET_TYPE_NAME            = "personal"
 	SHARED_SECRET_TYPE_NAME              = "shared"
+	SERVICE_TOKEN_KEYRING_KEY            = "infisical-service-token"
+	SERVICE_TOKEN_BACKUP_PREFIX          = "service-token"
 )
 
+// What to do when secrets cannot be fetched, set with --on-fetch-error
+const (
+	FETCH_ERROR_POLICY_FAIL      = "fail"
+	FETCH_ERROR_POLICY_WARN      = "warn"
+	FETCH_ERROR_POLICY_USE_CACHE = "use-cache"
+)
+
+// Formats of the secrets given to [infisical run --secrets-from-json] and --env-file, set with --stdin-secrets-format and --env-file-format
+const (
+	SECRETS_INPUT_FORMAT_JSON   = "json"
+	SECRETS_INPUT_FORMAT_YAML   = "yaml"
+	SECRETS_INPUT_FORMAT_DOTENV = "dotenv"
+	// inferred from the extension of the file, or from its content. Only accepted by ReadSecretsFromFile
+	SECRETS_INPUT_FORMAT_AUTO = "auto"
+)
+
+// Where the ${KEY} references of secrets are resolved from, set with --expand-source
+const (
+	EXPAND_SOURCE_SECRET = "secret"
+	EXPAND_SOURCE_ENV    = "env"
+	EXPAND_SOURCE_BOTH   = "both"
+)
+
+// Encodings of secret values that --decode turns back into the original values
+const (
+	SECRET_VALUE_ENCODING_BASE64 = "base64"
+)
+
+// Where [infisical login --method token] saves the service token
+const (
+	SERVICE_TOKEN_STORE_KEYRING = "keyring"
+	SERVICE_TOKEN_STORE_FILE    = "file"
+	SERVICE_TOKEN_STORE_NONE    = "none"
+)
+
+// Exit codes used by the CLI when it fails for reasons other than the child process exiting
+const (
+	EXIT_CODE_WAIT_FOR_TIMEOUT   = 3
+	EXIT_CODE_RESERVED_COLLISION = 4
+	EXIT_CODE_LOGIN_EXPIRED      = 5
+	EXIT_CODE_COMMAND_NOT_FOUND  = 6
+	EXIT_CODE_OUTDATED           = 7
+)
+
+// set at build time with -ldflags -X
 var (
-	CLI_VERSION = "devel"
+	CLI_VERSION    = "devel"
+	CLI_COMMIT     = ""
+	CLI_BUILD_DATE = ""
 )
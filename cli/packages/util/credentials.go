@@ -1,19 +1,24 @@
 package util
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/99designs/keyring"
 	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/Infisical/infisical-merge/packages/config"
 	"github.com/Infisical/infisical-merge/packages/models"
-	"github.com/go-resty/resty/v2"
+	log "github.com/sirupsen/logrus"
 )
 
 type LoggedInUserDetails struct {
 	IsUserLoggedIn  bool
 	LoginExpired    bool
+	LoginExpiredAt  time.Time // only known when the expiry could be read from the token
 	UserCredentials models.UserCredentials
 }
 
@@ -83,8 +88,19 @@ func GetCurrentLoggedInUserDetails() (LoggedInUserDetails, error) {
 			return LoggedInUserDetails{}, fmt.Errorf("getCurrentLoggedInUserDetails: unable to your credentials from Keyring [err=%s]", err)
 		}
 
+		// no need to ask Infisical about a token that has already expired
+		if expiresAt, ok := GetJWTExpiry(userCreds.JTWToken); ok && !expiresAt.After(time.Now()) {
+			log.Debugf("getCurrentLoggedInUserDetails: login token expired at %s", expiresAt)
+			return LoggedInUserDetails{
+				IsUserLoggedIn:  true,
+				LoginExpired:    true,
+				LoginExpiredAt:  expiresAt,
+				UserCredentials: userCreds,
+			}, nil
+		}
+
 		// check to to see if the JWT is still valid
-		httpClient := resty.New().
+		httpClient := api.NewHttpClient().
 			SetAuthToken(userCreds.JTWToken).
 			SetHeader("Accept", "application/json")
 
@@ -92,7 +108,7 @@ func GetCurrentLoggedInUserDetails() (LoggedInUserDetails, error) {
 		//configFile.LoggedInUserDomain
 		//if not empty set as infisical url
 		if configFile.LoggedInUserDomain != "" {
-			config.INFISICAL_URL = configFile.LoggedInUserDomain
+			config.INFISICAL_URL = ResolveDomain(configFile, configFile.LoggedInUserDomain)
 		}
 
 		isAuthenticated := api.CallIsAuthenticated(httpClient)
@@ -113,3 +129,260 @@ func GetCurrentLoggedInUserDetails() (LoggedInUserDetails, error) {
 		return LoggedInUserDetails{}, nil
 	}
 }
+
+// GetJWTExpiry reads the exp claim of a JWT without verifying its signature, which is left to Infisical.
+// It returns false when the token is not a JWT or carries no expiry
+func GetJWTExpiry(token string) (time.Time, bool) {
+	tokenParts := strings.Split(token, ".")
+	if len(tokenParts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(tokenParts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(*claims.Exp), 0), true
+}
+
+// GetServiceTokenToUse returns the service token that fetching secrets uses and how it was found, in order of precedence: the given --token,
+// INFISICAL_TOKEN and the service token saved by [infisical login --method token]. The token is empty when the logged in user is used.
+// The domain a saved token was saved for applies unless another domain was asked for
+func GetServiceTokenToUse(infisicalTokenFlag string) (serviceToken string, authMethod string, err error) {
+	if infisicalTokenFlag != "" {
+		return infisicalTokenFlag, AUTH_METHOD_TOKEN_FLAG, nil
+	}
+
+	if serviceToken := os.Getenv(INFISICAL_TOKEN_NAME); serviceToken != "" {
+		return serviceToken, AUTH_METHOD_TOKEN_ENV, nil
+	}
+
+	storedServiceToken, storedServiceTokenDomain, err := GetStoredServiceToken()
+	if err != nil || storedServiceToken == "" {
+		return "", AUTH_METHOD_LOGGED_IN_USER, err
+	}
+
+	if storedServiceTokenDomain != "" && config.INFISICAL_URL == INFISICAL_DEFAULT_API_URL {
+		config.INFISICAL_URL = storedServiceTokenDomain
+	}
+
+	return storedServiceToken, AUTH_METHOD_STORED_TOKEN, nil
+}
+
+// LoginStatus is whether the credentials that commands fetch secrets with are valid, as reported by [infisical login status]
+type LoginStatus struct {
+	IsAuthenticated bool   `json:"authenticated"`
+	AuthMethod      string `json:"authMethod,omitempty"`
+	// the email of the logged in user or the name of the service token
+	Identity string `json:"identity,omitempty"`
+	// nil when the credentials never expire, or when their expiry is unknown
+	ExpiresAt *time.Time `json:"expiresAt"`
+	IsExpired bool       `json:"expired"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// GetLoginStatus checks the credentials that fetching secrets would use, in the same order: INFISICAL_TOKEN, the service token
+// saved by [infisical login --method token] and the logged in user. Never exits, unlike RequireLogin
+func GetLoginStatus() (LoginStatus, error) {
+	serviceToken, authMethod, err := GetServiceTokenToUse("")
+	if err != nil {
+		return LoginStatus{}, err
+	}
+
+	if serviceToken != "" {
+		return getServiceTokenLoginStatus(serviceToken, authMethod), nil
+	}
+
+	loggedInUserDetails, err := GetCurrentLoggedInUserDetails()
+	if err != nil {
+		return LoginStatus{}, err
+	}
+
+	if !loggedInUserDetails.IsUserLoggedIn {
+		return LoginStatus{Reason: "no credentials found. To login, run [infisical login]"}, nil
+	}
+
+	status := LoginStatus{AuthMethod: AUTH_METHOD_LOGGED_IN_USER, Identity: loggedInUserDetails.UserCredentials.Email}
+	if expiresAt, ok := GetJWTExpiry(loggedInUserDetails.UserCredentials.JTWToken); ok {
+		status.ExpiresAt = &expiresAt
+	}
+
+	if loggedInUserDetails.LoginExpired {
+		status.IsExpired = true
+		status.Reason = "your login expired. To login, run [infisical login]"
+		return status, nil
+	}
+
+	status.IsAuthenticated = true
+	return status, nil
+}
+
+func getServiceTokenLoginStatus(serviceToken string, authMethod string) LoginStatus {
+	serviceTokenDetails, err := GetServiceTokenDetails(serviceToken)
+	if err != nil {
+		return LoginStatus{AuthMethod: authMethod, Reason: fmt.Sprintf("the service token is not valid [err=%v]", err)}
+	}
+
+	status := LoginStatus{AuthMethod: authMethod, Identity: serviceTokenDetails.Name}
+	if !serviceTokenDetails.ExpiresAt.IsZero() {
+		status.ExpiresAt = &serviceTokenDetails.ExpiresAt
+	}
+
+	if status.ExpiresAt != nil && !status.ExpiresAt.After(time.Now()) {
+		status.IsExpired = true
+		status.Reason = "the service token expired"
+		return status
+	}
+
+	status.IsAuthenticated = true
+	return status
+}
+
+// ResolveServiceTokenStore picks where a service token should be saved. When no store is requested, the OS keyring is preferred
+// and the config file (which is only readable by the current user) is used on systems where the only keyring backend is the encrypted file vault
+func ResolveServiceTokenStore(requestedStore string, availableBackends []keyring.BackendType, configuredBackend keyring.BackendType) (string, error) {
+	switch requestedStore {
+	case SERVICE_TOKEN_STORE_KEYRING, SERVICE_TOKEN_STORE_FILE, SERVICE_TOKEN_STORE_NONE:
+		return requestedStore, nil
+	case "":
+		if configuredBackend != "" {
+			return SERVICE_TOKEN_STORE_KEYRING, nil
+		}
+
+		for _, backend := range availableBackends {
+			if backend != keyring.FileBackend {
+				return SERVICE_TOKEN_STORE_KEYRING, nil
+			}
+		}
+
+		return SERVICE_TOKEN_STORE_FILE, nil
+	default:
+		return "", fmt.Errorf("invalid store [%s]. Available options are [%s, %s, %s]", requestedStore, SERVICE_TOKEN_STORE_KEYRING, SERVICE_TOKEN_STORE_FILE, SERVICE_TOKEN_STORE_NONE)
+	}
+}
+
+// StoreServiceToken saves the service token in the given store so that it is used by other commands when no token is passed.
+// Any token saved previously is removed first so that only one store ever holds a token
+func StoreServiceToken(serviceToken string, store string, domain string) error {
+	err := DeleteStoredServiceToken()
+	if err != nil {
+		return fmt.Errorf("StoreServiceToken: unable to remove the previously stored token [err=%s]", err)
+	}
+
+	if store == SERVICE_TOKEN_STORE_NONE {
+		return nil
+	}
+
+	configFile, err := GetConfigFile()
+	if err != nil {
+		return fmt.Errorf("StoreServiceToken: unable to get config file [err=%s]", err)
+	}
+
+	if store == SERVICE_TOKEN_STORE_KEYRING {
+		configuredKeyring, err := GetKeyRing()
+		if err != nil {
+			return fmt.Errorf("StoreServiceToken: unable to get keyring instance with [err=%s]", err)
+		}
+
+		err = configuredKeyring.Set(keyring.Item{
+			Key:  SERVICE_TOKEN_KEYRING_KEY,
+			Data: []byte(serviceToken),
+		})
+		if err != nil {
+			return fmt.Errorf("StoreServiceToken: unable to store service token because [err=%s]", err)
+		}
+	} else {
+		configFile.ServiceToken = serviceToken
+	}
+
+	configFile.ServiceTokenStore = store
+	configFile.ServiceTokenDomain = domain
+
+	return WriteConfigFile(&configFile)
+}
+
+// GetStoredServiceToken returns the token saved by [infisical login --method token] along with the domain it was saved for. An empty token means none is stored
+func GetStoredServiceToken() (serviceToken string, domain string, err error) {
+	if !ConfigFileExists() {
+		return "", "", nil
+	}
+
+	configFile, err := GetConfigFile()
+	if err != nil {
+		return "", "", fmt.Errorf("GetStoredServiceToken: unable to get config file [err=%s]", err)
+	}
+
+	switch configFile.ServiceTokenStore {
+	case SERVICE_TOKEN_STORE_FILE:
+		return configFile.ServiceToken, configFile.ServiceTokenDomain, nil
+	case SERVICE_TOKEN_STORE_KEYRING:
+		configuredKeyring, err := GetKeyRing()
+		if err != nil {
+			return "", "", fmt.Errorf("GetStoredServiceToken: unable to get keyring instance with [err=%s]", err)
+		}
+
+		item, err := configuredKeyring.Get(SERVICE_TOKEN_KEYRING_KEY)
+		if err != nil {
+			return "", "", fmt.Errorf("GetStoredServiceToken: unable to get your service token from the keyring. Please login again with [infisical login --method token] [err=%s]", err)
+		}
+
+		return string(item.Data), configFile.ServiceTokenDomain, nil
+	default:
+		return "", "", nil
+	}
+}
+
+// DeleteStoredServiceToken removes the service token saved by [infisical login --method token], if any
+func DeleteStoredServiceToken() error {
+	if !ConfigFileExists() {
+		return nil
+	}
+
+	configFile, err := GetConfigFile()
+	if err != nil {
+		return fmt.Errorf("DeleteStoredServiceToken: unable to get config file [err=%s]", err)
+	}
+
+	if configFile.ServiceTokenStore == "" {
+		return nil
+	}
+
+	if configFile.ServiceTokenStore == SERVICE_TOKEN_STORE_KEYRING {
+		configuredKeyring, err := GetKeyRing()
+		if err == nil {
+			err = configuredKeyring.Remove(SERVICE_TOKEN_KEYRING_KEY)
+		}
+		if err != nil && err != keyring.ErrKeyNotFound {
+			log.Debugf("DeleteStoredServiceToken: unable to remove service token from keyring [err=%s]", err)
+		}
+	}
+
+	configFile.ServiceTokenStore = ""
+	configFile.ServiceTokenDomain = ""
+	configFile.ServiceToken = ""
+
+	return WriteConfigFile(&configFile)
+}
+
+// GetServiceTokenDetails checks that the service token is valid and returns what it gives access to
+func GetServiceTokenDetails(fullServiceToken string) (api.GetServiceTokenDetailsResponse, error) {
+	serviceTokenParts := strings.SplitN(fullServiceToken, ".", 4)
+	if len(serviceTokenParts) < 4 {
+		return api.GetServiceTokenDetailsResponse{}, fmt.Errorf("invalid service token entered. Please double check your service token and try again")
+	}
+
+	httpClient := api.NewHttpClient().
+		SetAuthToken(fmt.Sprintf("%v.%v.%v", serviceTokenParts[0], serviceTokenParts[1], serviceTokenParts[2])).
+		SetHeader("Accept", "application/json")
+
+	return api.CallGetServiceTokenDetailsV2(httpClient)
+}
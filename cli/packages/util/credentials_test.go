@@ -0,0 +1,230 @@
+package util
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/keyring"
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func Test_ResolveServiceTokenStore(t *testing.T) {
+	var tests = []struct {
+		RequestedStore    string
+		AvailableBackends []keyring.BackendType
+		ConfiguredBackend keyring.BackendType
+		ExpectedStore     string
+	}{
+		{RequestedStore: "", AvailableBackends: []keyring.BackendType{keyring.SecretServiceBackend, keyring.FileBackend}, ExpectedStore: SERVICE_TOKEN_STORE_KEYRING},
+		{RequestedStore: "", AvailableBackends: []keyring.BackendType{keyring.FileBackend}, ExpectedStore: SERVICE_TOKEN_STORE_FILE},
+		{RequestedStore: "", AvailableBackends: []keyring.BackendType{}, ExpectedStore: SERVICE_TOKEN_STORE_FILE},
+		{RequestedStore: "", AvailableBackends: []keyring.BackendType{keyring.FileBackend}, ConfiguredBackend: keyring.FileBackend, ExpectedStore: SERVICE_TOKEN_STORE_KEYRING},
+		{RequestedStore: SERVICE_TOKEN_STORE_KEYRING, AvailableBackends: []keyring.BackendType{keyring.FileBackend}, ExpectedStore: SERVICE_TOKEN_STORE_KEYRING},
+		{RequestedStore: SERVICE_TOKEN_STORE_FILE, AvailableBackends: []keyring.BackendType{keyring.KeychainBackend}, ExpectedStore: SERVICE_TOKEN_STORE_FILE},
+		{RequestedStore: SERVICE_TOKEN_STORE_NONE, AvailableBackends: []keyring.BackendType{keyring.KeychainBackend}, ExpectedStore: SERVICE_TOKEN_STORE_NONE},
+	}
+
+	for _, test := range tests {
+		store, err := ResolveServiceTokenStore(test.RequestedStore, test.AvailableBackends, test.ConfiguredBackend)
+		if err != nil {
+			t.Errorf("Test_ResolveServiceTokenStore: unexpected error [err=%v]", err)
+		}
+
+		if store != test.ExpectedStore {
+			t.Errorf("Test_ResolveServiceTokenStore: expected [%s] for requested store [%s] and backends %v but got [%s]", test.ExpectedStore, test.RequestedStore, test.AvailableBackends, store)
+		}
+	}
+
+	if _, err := ResolveServiceTokenStore("disk", nil, ""); err == nil {
+		t.Errorf("Test_ResolveServiceTokenStore: expected an error for an invalid store")
+	}
+}
+
+func Test_StoreServiceToken_Backends(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("INFISICAL_VAULT_FILE_PASSPHRASE", "test-passphrase")
+
+	// the keyring store is exercised with the encrypted file vault since it is available on every system
+	err := WriteConfigFile(&models.ConfigFile{VaultBackendType: keyring.FileBackend})
+	if err != nil {
+		t.Fatalf("Test_StoreServiceToken_Backends: unable to write config file [err=%v]", err)
+	}
+
+	configFilePath, _, _ := GetFullConfigFilePath()
+
+	for _, store := range []string{SERVICE_TOKEN_STORE_FILE, SERVICE_TOKEN_STORE_KEYRING, SERVICE_TOKEN_STORE_NONE} {
+		serviceToken := "st.token." + store + ".key"
+		err := StoreServiceToken(serviceToken, store, "https://example.com/api")
+		if err != nil {
+			t.Fatalf("Test_StoreServiceToken_Backends: unable to store token in [%s] [err=%v]", store, err)
+		}
+
+		storedToken, domain, err := GetStoredServiceToken()
+		if err != nil {
+			t.Fatalf("Test_StoreServiceToken_Backends: unable to read token from [%s] [err=%v]", store, err)
+		}
+
+		configFileContent, _ := os.ReadFile(configFilePath)
+		isInConfigFile := strings.Contains(string(configFileContent), serviceToken)
+
+		switch store {
+		case SERVICE_TOKEN_STORE_NONE:
+			if storedToken != "" || isInConfigFile {
+				t.Errorf("Test_StoreServiceToken_Backends: expected no token to be persisted with store [none]")
+			}
+		case SERVICE_TOKEN_STORE_FILE:
+			if storedToken != serviceToken || domain != "https://example.com/api" || !isInConfigFile {
+				t.Errorf("Test_StoreServiceToken_Backends: expected the token to be saved in the config file, got [%s] [%s]", storedToken, domain)
+			}
+
+			info, _ := os.Stat(configFilePath)
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Test_StoreServiceToken_Backends: expected the config file to be 0600 but got %v", info.Mode().Perm())
+			}
+		case SERVICE_TOKEN_STORE_KEYRING:
+			if storedToken != serviceToken || isInConfigFile {
+				t.Errorf("Test_StoreServiceToken_Backends: expected the token to only be saved in the keyring, got [%s]", storedToken)
+			}
+
+			if _, err := os.Stat(filepath.Join(homeDir, KEYRING_SERVICE_NAME+"-file-vault", SERVICE_TOKEN_KEYRING_KEY)); err != nil {
+				t.Errorf("Test_StoreServiceToken_Backends: expected the token to be in the file vault [err=%v]", err)
+			}
+		}
+	}
+
+	// storing with none cleared the token saved in the keyring by the previous iteration
+	if _, err := os.Stat(filepath.Join(homeDir, KEYRING_SERVICE_NAME+"-file-vault", SERVICE_TOKEN_KEYRING_KEY)); err == nil {
+		t.Errorf("Test_StoreServiceToken_Backends: expected the token to be removed from the file vault")
+	}
+}
+
+func getTestJWT(claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encode([]byte(claims)) + "." + encode([]byte("signature"))
+}
+
+func Test_GetJWTExpiry(t *testing.T) {
+	expiresAt, ok := GetJWTExpiry(getTestJWT(`{"userId":"1","exp":1700000000}`))
+	if !ok || !expiresAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Test_GetJWTExpiry: expected expiry [%v] but got [%v, %v]", time.Unix(1700000000, 0), expiresAt, ok)
+	}
+
+	for _, token := range []string{getTestJWT(`{"userId":"1"}`), "st.abc.def.ghi", "not-a-token", "a.%%%.c"} {
+		if _, ok := GetJWTExpiry(token); ok {
+			t.Errorf("Test_GetJWTExpiry: expected no expiry for token [%s]", token)
+		}
+	}
+}
+
+func Test_GetLoginStatus(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(INFISICAL_TOKEN_NAME, "")
+
+	status, err := GetLoginStatus()
+	if err != nil || status.IsAuthenticated || status.IsExpired || !strings.Contains(status.Reason, "no credentials") {
+		t.Errorf("Test_GetLoginStatus: expected no credentials to be found but got %+v [err=%v]", status, err)
+	}
+
+	mock := newMockInfisicalServer(t, nil)
+	t.Setenv(INFISICAL_TOKEN_NAME, testServiceToken)
+
+	status, err = GetLoginStatus()
+	if err != nil || !status.IsAuthenticated || status.AuthMethod != AUTH_METHOD_TOKEN_ENV || status.Identity != "test" || status.ExpiresAt != nil {
+		t.Errorf("Test_GetLoginStatus: expected the service token to be valid and to never expire but got %+v [err=%v]", status, err)
+	}
+
+	mock.serviceTokenExpiresAt = time.Now().Add(-time.Hour).Truncate(time.Second)
+	status, err = GetLoginStatus()
+	if err != nil || status.IsAuthenticated || !status.IsExpired || status.ExpiresAt == nil || !status.ExpiresAt.Equal(mock.serviceTokenExpiresAt) {
+		t.Errorf("Test_GetLoginStatus: expected the service token to be expired at [%v] but got %+v [err=%v]", mock.serviceTokenExpiresAt, status, err)
+	}
+
+	t.Setenv(INFISICAL_TOKEN_NAME, "not-a-token")
+	status, err = GetLoginStatus()
+	if err != nil || status.IsAuthenticated || status.IsExpired || status.Reason == "" {
+		t.Errorf("Test_GetLoginStatus: expected an invalid token to not be authenticated but got %+v [err=%v]", status, err)
+	}
+}
+
+func Test_GetServiceTokenToUse(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(INFISICAL_TOKEN_NAME, "")
+
+	serviceToken, authMethod, err := GetServiceTokenToUse("")
+	if err != nil || serviceToken != "" || authMethod != AUTH_METHOD_LOGGED_IN_USER {
+		t.Errorf("Test_GetServiceTokenToUse: expected the logged in user without service tokens but got [%s, %s] [err=%v]", serviceToken, authMethod, err)
+	}
+
+	err = WriteConfigFile(&models.ConfigFile{ServiceTokenStore: SERVICE_TOKEN_STORE_FILE, ServiceToken: "st.stored"})
+	if err != nil {
+		t.Fatalf("Test_GetServiceTokenToUse: unable to write config file [err=%v]", err)
+	}
+
+	var tests = []struct {
+		TokenFlag          string
+		TokenEnv           string
+		ExpectedToken      string
+		ExpectedAuthMethod string
+	}{
+		{TokenFlag: "st.flag", TokenEnv: "st.env", ExpectedToken: "st.flag", ExpectedAuthMethod: AUTH_METHOD_TOKEN_FLAG},
+		{TokenEnv: "st.env", ExpectedToken: "st.env", ExpectedAuthMethod: AUTH_METHOD_TOKEN_ENV},
+		{ExpectedToken: "st.stored", ExpectedAuthMethod: AUTH_METHOD_STORED_TOKEN},
+	}
+
+	for _, test := range tests {
+		t.Setenv(INFISICAL_TOKEN_NAME, test.TokenEnv)
+		serviceToken, authMethod, err := GetServiceTokenToUse(test.TokenFlag)
+		if err != nil || serviceToken != test.ExpectedToken || authMethod != test.ExpectedAuthMethod {
+			t.Errorf("Test_GetServiceTokenToUse: expected [%s, %s] but got [%s, %s] [err=%v]", test.ExpectedToken, test.ExpectedAuthMethod, serviceToken, authMethod, err)
+		}
+	}
+}
+
+func Test_RequireLogin_ExpiredToken(t *testing.T) {
+	if os.Getenv("TEST_REQUIRE_LOGIN_EXPIRED") == "1" {
+		RequireLogin()
+		return
+	}
+
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("INFISICAL_VAULT_FILE_PASSPHRASE", "test-passphrase")
+
+	err := WriteConfigFile(&models.ConfigFile{VaultBackendType: keyring.FileBackend, LoggedInUserEmail: "user@example.com"})
+	if err != nil {
+		t.Fatalf("Test_RequireLogin_ExpiredToken: unable to write config file [err=%v]", err)
+	}
+
+	expiredAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err = StoreUserCredsInKeyRing(&models.UserCredentials{
+		Email:      "user@example.com",
+		PrivateKey: "private-key",
+		JTWToken:   getTestJWT(fmt.Sprintf(`{"userId":"1","exp":%d}`, expiredAt.Unix())),
+	})
+	if err != nil {
+		t.Fatalf("Test_RequireLogin_ExpiredToken: unable to store credentials [err=%v]", err)
+	}
+
+	// RequireLogin exits, so it is run in a child process. The expiry is detected before any request is sent
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_RequireLogin_ExpiredToken$")
+	cmd.Env = append(os.Environ(), "TEST_REQUIRE_LOGIN_EXPIRED=1", "INFISICAL_API_URL=http://127.0.0.1:1/api")
+	output, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != EXIT_CODE_LOGIN_EXPIRED {
+		t.Fatalf("Test_RequireLogin_ExpiredToken: expected exit code [%d] but got [err=%v] with output [%s]", EXIT_CODE_LOGIN_EXPIRED, err, output)
+	}
+
+	expectedMessage := fmt.Sprintf("Your login expired at %s, please login in again", expiredAt.Local().Format(time.RFC1123))
+	if !strings.Contains(string(output), expectedMessage) {
+		t.Errorf("Test_RequireLogin_ExpiredToken: expected output to contain [%s] but got [%s]", expectedMessage, output)
+	}
+}
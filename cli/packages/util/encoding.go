@@ -0,0 +1,57 @@
+package util
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+// DecodeSecretValues decodes the values of the secrets named in keys, or of every secret when keys contains *.
+// Line breaks and spaces are ignored so that wrapped base64, as written by most tools, can be stored as is
+func DecodeSecretValues(secrets []models.SingleEnvironmentVariable, encoding string, keys []string) ([]models.SingleEnvironmentVariable, error) {
+	if encoding != SECRET_VALUE_ENCODING_BASE64 {
+		return nil, fmt.Errorf("unsupported secret value encoding [%s]", encoding)
+	}
+
+	shouldDecodeAll := false
+	keysToDecode := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "*" {
+			shouldDecodeAll = true
+		}
+		keysToDecode[key] = false
+	}
+
+	decodedSecrets := append([]models.SingleEnvironmentVariable{}, secrets...)
+	for i, secret := range decodedSecrets {
+		if _, ok := keysToDecode[secret.Key]; !ok && !shouldDecodeAll {
+			continue
+		}
+		keysToDecode[secret.Key] = true
+
+		value := strings.Map(func(r rune) rune {
+			if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, secret.Value)
+
+		decodedValue, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("the value of secret [%s] is not valid base64 [err=%v]", secret.Key, err)
+		}
+
+		decodedSecrets[i].Value = string(decodedValue)
+	}
+
+	for key, isFound := range keysToDecode {
+		if !isFound && key != "*" {
+			PrintWarning(fmt.Sprintf("There is no secret named [%s] to decode", key))
+		}
+	}
+
+	return decodedSecrets, nil
+}
@@ -0,0 +1,31 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func Test_DecodeSecretValues(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "CERT", Value: "LS0tLS1CRUdJTiBD\nRVJUSUZJQ0FURS0tLS0t"},
+		{Key: "PLAIN", Value: "not base64!"},
+	}
+
+	decodedSecrets, err := DecodeSecretValues(secrets, SECRET_VALUE_ENCODING_BASE64, []string{"CERT"})
+	if err != nil {
+		t.Fatalf("Test_DecodeSecretValues: unexpected error [err=%v]", err)
+	}
+
+	if decodedSecrets[0].Value != "-----BEGIN CERTIFICATE-----" || decodedSecrets[1].Value != "not base64!" {
+		t.Errorf("Test_DecodeSecretValues: expected only CERT to be decoded but got %v", decodedSecrets)
+	}
+
+	if secrets[0].Value != "LS0tLS1CRUdJTiBD\nRVJUSUZJQ0FURS0tLS0t" {
+		t.Errorf("Test_DecodeSecretValues: expected the given secrets to be left untouched")
+	}
+
+	if _, err := DecodeSecretValues(secrets, SECRET_VALUE_ENCODING_BASE64, []string{"*"}); err == nil {
+		t.Errorf("Test_DecodeSecretValues: expected an error when a value is not valid base64")
+	}
+}
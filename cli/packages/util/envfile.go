@@ -0,0 +1,113 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+// ReadEnvFile reads a dotenv file, see ParseEnvFile for the supported syntax
+func ReadEnvFile(filePath string) ([]models.SingleEnvironmentVariable, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read env file [%s] [err=%v]", filePath, err)
+	}
+
+	envs, err := ParseEnvFile(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse env file [%s] [err=%v]", filePath, err)
+	}
+
+	return envs, nil
+}
+
+// ParseEnvFile parses KEY=VALUE lines in the order they appear. Lines may start with export, # starts a comment and values can be
+// single quoted (taken literally) or double quoted (\n, \r, \t, \" and \\ are unescaped and the value may span multiple lines)
+func ParseEnvFile(content string) ([]models.SingleEnvironmentVariable, error) {
+	envs := []models.SingleEnvironmentVariable{}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	for lineNumber := 0; lineNumber < len(lines); lineNumber++ {
+		line := strings.TrimSpace(lines[lineNumber])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		separatorIndex := strings.Index(line, "=")
+		if separatorIndex <= 0 {
+			return nil, fmt.Errorf("line %d is not in the KEY=VALUE format", lineNumber+1)
+		}
+
+		key := strings.TrimSpace(line[:separatorIndex])
+		if strings.ContainsAny(key, " \t'\"") {
+			return nil, fmt.Errorf("line %d has an invalid key [%s]", lineNumber+1, key)
+		}
+
+		rawValue := strings.TrimSpace(line[separatorIndex+1:])
+		value := ""
+		switch {
+		case strings.HasPrefix(rawValue, "'"):
+			end := strings.Index(rawValue[1:], "'")
+			if end == -1 {
+				return nil, fmt.Errorf("line %d has an unterminated single quoted value", lineNumber+1)
+			}
+			value = rawValue[1 : end+1]
+		case strings.HasPrefix(rawValue, `"`):
+			startLine := lineNumber
+			quotedValue := rawValue[1:]
+			for {
+				if end := findClosingDoubleQuote(quotedValue); end != -1 {
+					value = unescapeDoubleQuotedValue(quotedValue[:end])
+					break
+				}
+
+				lineNumber++
+				if lineNumber >= len(lines) {
+					return nil, fmt.Errorf("line %d has an unterminated double quoted value", startLine+1)
+				}
+				quotedValue += "\n" + lines[lineNumber]
+			}
+		default:
+			value = rawValue
+			if commentIndex := strings.Index(value, " #"); commentIndex != -1 {
+				value = strings.TrimSpace(value[:commentIndex])
+			}
+		}
+
+		envs = append(envs, models.SingleEnvironmentVariable{Key: key, Value: value})
+	}
+
+	return envs, nil
+}
+
+func findClosingDoubleQuote(value string) int {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == '"' {
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDoubleQuotedValue(value string) string {
+	return strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n", `\r`, "\r", `\t`, "\t").Replace(value)
+}
+
+// FormatEnvFile writes the secrets as KEY="value" lines that ParseEnvFile reads back as is. Values are double quoted
+// so that quotes, backslashes and line breaks survive, escaped the way most dotenv libraries expect
+func FormatEnvFile(secrets []models.SingleEnvironmentVariable) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+	var envFile strings.Builder
+	for _, secret := range secrets {
+		envFile.WriteString(secret.Key + `="` + escaper.Replace(secret.Value) + "\"\n")
+	}
+	return envFile.String()
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func Test_ParseEnvFile(t *testing.T) {
+	content := "# local overrides\n" +
+		"PLAIN=value\n" +
+		"export EXPORTED=yes\n" +
+		"  SPACED = padded  \n" +
+		"WITH_COMMENT=value # a comment\n" +
+		"WITH_HASH=a#b\n" +
+		"SINGLE='${NOT_EXPANDED} \\n'\n" +
+		"DOUBLE=\"line1\\nline2 \\\"quoted\\\"\"\n" +
+		"MULTI_LINE=\"first\nsecond\"\n" +
+		"EMPTY=\n" +
+		"CONN=host=${DB_HOST}\r\n"
+
+	envs, err := ParseEnvFile(content)
+	if err != nil {
+		t.Fatalf("Test_ParseEnvFile: unexpected error [err=%v]", err)
+	}
+
+	expected := [][2]string{
+		{"PLAIN", "value"},
+		{"EXPORTED", "yes"},
+		{"SPACED", "padded"},
+		{"WITH_COMMENT", "value"},
+		{"WITH_HASH", "a#b"},
+		{"SINGLE", "${NOT_EXPANDED} \\n"},
+		{"DOUBLE", "line1\nline2 \"quoted\""},
+		{"MULTI_LINE", "first\nsecond"},
+		{"EMPTY", ""},
+		{"CONN", "host=${DB_HOST}"},
+	}
+
+	if len(envs) != len(expected) {
+		t.Fatalf("Test_ParseEnvFile: expected %d variables but got %+v", len(expected), envs)
+	}
+
+	for i, env := range envs {
+		if env.Key != expected[i][0] || env.Value != expected[i][1] {
+			t.Errorf("Test_ParseEnvFile: expected %s=[%s] but got %s=[%s]", expected[i][0], expected[i][1], env.Key, env.Value)
+		}
+	}
+
+	for _, invalidContent := range []string{"NO_SEPARATOR", "=value", "KEY='unterminated", "KEY=\"unterminated\nline"} {
+		if _, err := ParseEnvFile(invalidContent); err == nil {
+			t.Errorf("Test_ParseEnvFile: expected an error for [%s]", invalidContent)
+		}
+	}
+}
+
+func Test_FormatEnvFile(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "QUOTES", Value: `it's "quoted"`},
+		{Key: "CERT", Value: "-----BEGIN-----\r\nline\t2\n-----END-----\n"},
+		{Key: "BACKSLASHES", Value: `C:\new\\path\"`},
+		{Key: "COMMENT", Value: "a #not a comment"},
+		{Key: "EMPTY", Value: ""},
+	}
+
+	envFile := FormatEnvFile(secrets)
+	if !strings.HasPrefix(envFile, `QUOTES="it's \"quoted\""`+"\n") {
+		t.Errorf("Test_FormatEnvFile: expected double quoted values but got %s", envFile)
+	}
+
+	parsedSecrets, err := ParseEnvFile(envFile)
+	if err != nil || len(parsedSecrets) != len(secrets) {
+		t.Fatalf("Test_FormatEnvFile: expected %d secrets to be parsed back but got %+v [err=%v]", len(secrets), parsedSecrets, err)
+	}
+
+	for i, secret := range parsedSecrets {
+		if secret.Key != secrets[i].Key || secret.Value != secrets[i].Value {
+			t.Errorf("Test_FormatEnvFile: expected %s=%q but got %s=%q", secrets[i].Key, secrets[i].Value, secret.Key, secret.Value)
+		}
+	}
+}
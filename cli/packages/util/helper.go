@@ -9,7 +9,9 @@ import (
 	"os/exec"
 	"path"
 	"strings"
+	"time"
 
+	"github.com/Infisical/infisical-merge/packages/config"
 	"github.com/Infisical/infisical-merge/packages/models"
 )
 
@@ -85,7 +87,7 @@ func RequireLogin() {
 	}
 
 	if currentUserDetails.LoginExpired {
-		PrintErrorMessageAndExit("Your login expired, please login in again. To login, run [infisical login]")
+		printErrorMessageAndExitWithCode(EXIT_CODE_LOGIN_EXPIRED, getLoginExpiredMessage(currentUserDetails))
 	}
 
 	if currentUserDetails.UserCredentials.Email == "" && currentUserDetails.UserCredentials.JTWToken == "" && currentUserDetails.UserCredentials.PrivateKey == "" {
@@ -93,6 +95,14 @@ func RequireLogin() {
 	}
 }
 
+func getLoginExpiredMessage(userDetails LoggedInUserDetails) string {
+	if userDetails.LoginExpiredAt.IsZero() {
+		return "Your login expired, please login in again. To login, run [infisical login]"
+	}
+
+	return fmt.Sprintf("Your login expired at %s, please login in again. To login, run [infisical login]", userDetails.LoginExpiredAt.Local().Format(time.RFC1123))
+}
+
 func RequireServiceToken() {
 	serviceToken := os.Getenv(INFISICAL_TOKEN_NAME)
 	if serviceToken == "" {
@@ -101,7 +111,11 @@ func RequireServiceToken() {
 }
 
 func RequireLocalWorkspaceFile() {
-	workspaceFilePath, _ := FindWorkspaceConfigFile()
+	workspaceFilePath, err := FindWorkspaceConfigFile()
+	if workspaceFilePath == "" && config.INFISICAL_WORKSPACE_CONFIG_FILE != "" {
+		HandleError(err)
+	}
+
 	if workspaceFilePath == "" {
 		PrintErrorMessageAndExit("It looks you have not yet connected this project to Infisical", "To do so, run [infisical init] then run your command again")
 	}
@@ -0,0 +1,444 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"gopkg.in/yaml.v3"
+)
+
+// InjectPath maps a field of a structured file (for example $.db.password) to the secret whose value should be written to it
+type InjectPath struct {
+	Path      string
+	SecretKey string
+	segments  []injectPathSegment
+}
+
+type injectPathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+type valueSpan struct {
+	start int
+	end   int
+}
+
+// ParseInjectPaths parses values of the form $.db.password=DB_PASSWORD
+func ParseInjectPaths(rawPaths []string) ([]InjectPath, error) {
+	injectPaths := []InjectPath{}
+	for _, rawPath := range rawPaths {
+		separatorIndex := strings.LastIndex(rawPath, "=")
+		if separatorIndex == -1 {
+			return nil, fmt.Errorf("invalid --set-path [%s]. Expected the format $.path.to.field=SECRET_NAME", rawPath)
+		}
+
+		path := strings.TrimSpace(rawPath[:separatorIndex])
+		secretKey := strings.TrimSpace(rawPath[separatorIndex+1:])
+		if secretKey == "" {
+			return nil, fmt.Errorf("invalid --set-path [%s]. The secret name cannot be empty", rawPath)
+		}
+
+		segments, err := parseInjectPathSegments(path)
+		if err != nil {
+			return nil, err
+		}
+
+		injectPaths = append(injectPaths, InjectPath{Path: path, SecretKey: secretKey, segments: segments})
+	}
+
+	return injectPaths, nil
+}
+
+// Supports the dot notation ($.db.password), array indexes ($.servers[0]) and bracket notation for keys with dots ($["my.key"])
+func parseInjectPathSegments(path string) ([]injectPathSegment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid path [%s]. Paths must start with $", path)
+	}
+
+	segments := []injectPathSegment{}
+	remaining := path[1:]
+	for len(remaining) > 0 {
+		switch remaining[0] {
+		case '.':
+			end := strings.IndexAny(remaining[1:], ".[")
+			if end == -1 {
+				end = len(remaining) - 1
+			}
+			key := remaining[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("invalid path [%s]. Empty key found", path)
+			}
+			segments = append(segments, injectPathSegment{key: key})
+			remaining = remaining[end+1:]
+		case '[':
+			end := strings.IndexByte(remaining, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid path [%s]. Missing closing bracket", path)
+			}
+			inside := remaining[1:end]
+			if len(inside) >= 2 && (inside[0] == '"' || inside[0] == '\'') && inside[len(inside)-1] == inside[0] {
+				segments = append(segments, injectPathSegment{key: inside[1 : len(inside)-1]})
+			} else {
+				index, err := strconv.Atoi(inside)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid path [%s]. [%s] is not a valid array index", path, inside)
+				}
+				segments = append(segments, injectPathSegment{index: index, isIndex: true})
+			}
+			remaining = remaining[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid path [%s]. Unexpected character [%c]", path, remaining[0])
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("invalid path [%s]. The path must point to a field", path)
+	}
+
+	return segments, nil
+}
+
+// InjectSecretsIntoFile replaces the fields of a JSON or YAML file pointed at by the given paths with the value of their secret.
+// Only the replaced values are rewritten so that the formatting, comments and key order of the file are preserved
+func InjectSecretsIntoFile(filePath string, injectPaths []InjectPath, secrets []models.SingleEnvironmentVariable) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("unable to read [%s] [err=%v]", filePath, err)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("unable to read [%s] [err=%v]", filePath, err)
+	}
+
+	patchedContent, err := InjectSecretsIntoContent(content, strings.ToLower(filepath.Ext(filePath)), injectPaths, secrets)
+	if err != nil {
+		return err
+	}
+
+	return WriteToFileAtomically(filePath, patchedContent, info.Mode().Perm())
+}
+
+// InjectSecretsIntoContent does the same as InjectSecretsIntoFile on in memory content. The extension (.json, .yaml or .yml) selects the parser
+func InjectSecretsIntoContent(content []byte, extension string, injectPaths []InjectPath, secrets []models.SingleEnvironmentVariable) ([]byte, error) {
+	secretsByKey := make(map[string]string)
+	for _, secret := range secrets {
+		secretsByKey[secret.Key] = secret.Value
+	}
+
+	var locate func(content []byte, segments []injectPathSegment) (valueSpan, error)
+	var render func(value string) string
+	switch extension {
+	case ".json":
+		locate = locateJSONValue
+		render = renderJSONString
+	case ".yaml", ".yml":
+		locate = locateYAMLValue
+		// JSON strings are valid YAML double quoted scalars
+		render = renderJSONString
+	default:
+		return nil, fmt.Errorf("unsupported file type [%s]. Only .json, .yaml and .yml files can be injected into", extension)
+	}
+
+	type replacement struct {
+		span  valueSpan
+		value string
+	}
+
+	replacements := []replacement{}
+	for _, injectPath := range injectPaths {
+		value, ok := secretsByKey[injectPath.SecretKey]
+		if !ok {
+			return nil, fmt.Errorf("the secret [%s] used for path [%s] was not found", injectPath.SecretKey, injectPath.Path)
+		}
+
+		span, err := locate(content, injectPath.segments)
+		if err != nil {
+			return nil, fmt.Errorf("unable to inject into path [%s] [err=%v]", injectPath.Path, err)
+		}
+
+		replacements = append(replacements, replacement{span: span, value: render(value)})
+	}
+
+	// apply from the end of the file so that earlier offsets stay valid
+	sort.SliceStable(replacements, func(i, j int) bool {
+		return replacements[i].span.start > replacements[j].span.start
+	})
+
+	patchedContent := append([]byte{}, content...)
+	for idx, replacement := range replacements {
+		if idx > 0 && replacement.span.end > replacements[idx-1].span.start {
+			if replacement.span == replacements[idx-1].span {
+				return nil, fmt.Errorf("the same field is set more than once")
+			}
+			return nil, fmt.Errorf("overlapping paths are not supported")
+		}
+
+		patchedContent = append(patchedContent[:replacement.span.start], append([]byte(replacement.value), patchedContent[replacement.span.end:]...)...)
+	}
+
+	return patchedContent, nil
+}
+
+// GetJSONPathValue returns the value at the given path of a JSON document. The path uses the syntax of --set-path, the leading $ may be
+// left out as in jq (.db.password) and . alone selects the whole document
+func GetJSONPathValue(content []byte, path string) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("the value is not valid JSON [err=%v]", err)
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("the value is not valid JSON [err=unexpected content after the JSON value]")
+	}
+
+	path = strings.TrimSpace(path)
+	if path == "" || path == "." || path == "$" {
+		return document, nil
+	}
+
+	if !strings.HasPrefix(path, "$") {
+		path = "$" + path
+	}
+
+	segments, err := parseInjectPathSegments(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := document
+	for _, segment := range segments {
+		switch typedValue := current.(type) {
+		case map[string]interface{}:
+			value, ok := typedValue[segment.key]
+			if segment.isIndex || !ok {
+				return nil, fmt.Errorf("the field [%s] of path [%s] was not found", segment.String(), path)
+			}
+			current = value
+		case []interface{}:
+			if !segment.isIndex || segment.index >= len(typedValue) {
+				return nil, fmt.Errorf("the field [%s] of path [%s] was not found", segment.String(), path)
+			}
+			current = typedValue[segment.index]
+		default:
+			return nil, fmt.Errorf("the field [%s] of path [%s] was not found", segment.String(), path)
+		}
+	}
+
+	return current, nil
+}
+
+func (segment injectPathSegment) String() string {
+	if segment.isIndex {
+		return fmt.Sprintf("[%d]", segment.index)
+	}
+	return segment.key
+}
+
+func renderJSONString(value string) string {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+func locateJSONValue(content []byte, segments []injectPathSegment) (valueSpan, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	span, found, err := locateJSONValueInDecoder(decoder, content, segments)
+	if err != nil {
+		return valueSpan{}, err
+	}
+
+	if !found {
+		return valueSpan{}, fmt.Errorf("field not found")
+	}
+
+	return span, nil
+}
+
+// Expects the next token of the decoder to be the start of a value
+func locateJSONValueInDecoder(decoder *json.Decoder, content []byte, segments []injectPathSegment) (valueSpan, bool, error) {
+	start := int(decoder.InputOffset())
+	for start < len(content) && strings.IndexByte(" \t\r\n:,", content[start]) != -1 {
+		start++
+	}
+
+	token, err := decoder.Token()
+	if err != nil {
+		return valueSpan{}, false, err
+	}
+
+	delimiter, isDelimiter := token.(json.Delim)
+	if len(segments) == 0 {
+		if isDelimiter {
+			return valueSpan{}, false, fmt.Errorf("the field is an object or an array, only single values can be replaced")
+		}
+		return valueSpan{start: start, end: int(decoder.InputOffset())}, true, nil
+	}
+
+	if !isDelimiter {
+		return valueSpan{}, false, nil
+	}
+
+	index := 0
+	for decoder.More() {
+		isMatch := false
+		if delimiter == '{' {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return valueSpan{}, false, err
+			}
+			key, _ := keyToken.(string)
+			isMatch = !segments[0].isIndex && key == segments[0].key
+		} else {
+			isMatch = segments[0].isIndex && index == segments[0].index
+			index++
+		}
+
+		if isMatch {
+			return locateJSONValueInDecoder(decoder, content, segments[1:])
+		}
+
+		var skipped json.RawMessage
+		if err := decoder.Decode(&skipped); err != nil {
+			return valueSpan{}, false, err
+		}
+	}
+
+	return valueSpan{}, false, nil
+}
+
+func locateYAMLValue(content []byte, segments []injectPathSegment) (valueSpan, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return valueSpan{}, err
+	}
+
+	if len(document.Content) == 0 {
+		return valueSpan{}, fmt.Errorf("field not found")
+	}
+
+	node := document.Content[0]
+	isInFlow := false
+	for _, segment := range segments {
+		isInFlow = isInFlow || node.Style&yaml.FlowStyle != 0
+
+		var next *yaml.Node
+		switch {
+		case node.Kind == yaml.MappingNode && !segment.isIndex:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == segment.key {
+					next = node.Content[i+1]
+				}
+			}
+		case node.Kind == yaml.SequenceNode && segment.isIndex:
+			if segment.index < len(node.Content) {
+				next = node.Content[segment.index]
+			}
+		}
+
+		if next == nil {
+			return valueSpan{}, fmt.Errorf("field not found")
+		}
+		node = next
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return valueSpan{}, fmt.Errorf("the field is a mapping or a sequence, only single values can be replaced")
+	}
+
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 || node.Anchor != "" || (node.Style&yaml.TaggedStyle != 0) {
+		return valueSpan{}, fmt.Errorf("block scalars, anchors and tagged values cannot be replaced")
+	}
+
+	start, err := yamlOffset(content, node.Line, node.Column)
+	if err != nil {
+		return valueSpan{}, err
+	}
+
+	end := start
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		end++
+		for end < len(content) && content[end] != '"' {
+			if content[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		end++
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		end++
+		for end < len(content) {
+			if content[end] == '\'' {
+				if end+1 < len(content) && content[end+1] == '\'' {
+					end += 2
+					continue
+				}
+				break
+			}
+			end++
+		}
+		end++
+	default:
+		if strings.Contains(node.Value, "\n") {
+			return valueSpan{}, fmt.Errorf("multi-line values cannot be replaced")
+		}
+
+		terminators := "\r\n"
+		if isInFlow {
+			terminators += ",]}"
+		}
+		for end < len(content) && strings.IndexByte(terminators, content[end]) == -1 {
+			if content[end] == '#' && end > start && (content[end-1] == ' ' || content[end-1] == '\t') {
+				break
+			}
+			end++
+		}
+		for end > start && (content[end-1] == ' ' || content[end-1] == '\t') {
+			end--
+		}
+	}
+
+	if end > len(content) {
+		return valueSpan{}, fmt.Errorf("unable to find the end of the value")
+	}
+
+	return valueSpan{start: start, end: end}, nil
+}
+
+// yaml reports 1 based lines and columns in characters, converts them to a byte offset
+func yamlOffset(content []byte, line int, column int) (int, error) {
+	offset := 0
+	for currentLine := 1; currentLine < line; currentLine++ {
+		newLineIndex := bytes.IndexByte(content[offset:], '\n')
+		if newLineIndex == -1 {
+			return 0, fmt.Errorf("unable to locate line %d", line)
+		}
+		offset += newLineIndex + 1
+	}
+
+	for currentColumn := 1; currentColumn < column; currentColumn++ {
+		if offset >= len(content) {
+			return 0, fmt.Errorf("unable to locate column %d of line %d", column, line)
+		}
+		_, size := utf8.DecodeRune(content[offset:])
+		offset += size
+	}
+
+	return offset, nil
+}
@@ -0,0 +1,146 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+var injectTestSecrets = []models.SingleEnvironmentVariable{
+	{Key: "DB_PASSWORD", Value: `pa"ss\word`},
+	{Key: "API_KEY", Value: "key # not a comment"},
+}
+
+func Test_InjectSecretsIntoContent_JSON(t *testing.T) {
+	content := `{
+    "db": {
+        "host": "localhost",
+        "password": "changeme"
+    },
+    "servers": [ {"key": null}, {"key": "x"} ]
+}
+`
+	expected := `{
+    "db": {
+        "host": "localhost",
+        "password": "pa\"ss\\word"
+    },
+    "servers": [ {"key": null}, {"key": "key # not a comment"} ]
+}
+`
+
+	paths, err := ParseInjectPaths([]string{"$.db.password=DB_PASSWORD", "$.servers[1].key=API_KEY"})
+	if err != nil {
+		t.Fatalf("Test_InjectSecretsIntoContent_JSON: unexpected error [err=%v]", err)
+	}
+
+	patched, err := InjectSecretsIntoContent([]byte(content), ".json", paths, injectTestSecrets)
+	if err != nil {
+		t.Fatalf("Test_InjectSecretsIntoContent_JSON: unexpected error [err=%v]", err)
+	}
+
+	if string(patched) != expected {
+		t.Errorf("Test_InjectSecretsIntoContent_JSON: expected [%s] but got [%s]", expected, patched)
+	}
+}
+
+func Test_InjectSecretsIntoContent_YAML(t *testing.T) {
+	content := `# database settings
+db:
+  host: localhost
+  password: changeme # set by infisical
+servers:
+  - key: 'old'
+  - {name: b, key: "old"}
+`
+	expected := `# database settings
+db:
+  host: localhost
+  password: "pa\"ss\\word" # set by infisical
+servers:
+  - key: "key # not a comment"
+  - {name: b, key: "pa\"ss\\word"}
+`
+
+	paths, err := ParseInjectPaths([]string{"$.db.password=DB_PASSWORD", "$.servers[0].key=API_KEY", `$.servers[1]["key"]=DB_PASSWORD`})
+	if err != nil {
+		t.Fatalf("Test_InjectSecretsIntoContent_YAML: unexpected error [err=%v]", err)
+	}
+
+	patched, err := InjectSecretsIntoContent([]byte(content), ".yaml", paths, injectTestSecrets)
+	if err != nil {
+		t.Fatalf("Test_InjectSecretsIntoContent_YAML: unexpected error [err=%v]", err)
+	}
+
+	if string(patched) != expected {
+		t.Errorf("Test_InjectSecretsIntoContent_YAML: expected [%s] but got [%s]", expected, patched)
+	}
+}
+
+func Test_InjectSecretsIntoContent_Errors(t *testing.T) {
+	var tests = []struct {
+		Content   string
+		Extension string
+		Path      string
+	}{
+		{Content: `{"db": {}}`, Extension: ".json", Path: "$.db.password=DB_PASSWORD"},
+		{Content: `{"db": {}}`, Extension: ".json", Path: "$.db=DB_PASSWORD"},
+		{Content: `{"db": "x"}`, Extension: ".json", Path: "$.db=MISSING"},
+		{Content: "db: |\n  block\n", Extension: ".yaml", Path: "$.db=DB_PASSWORD"},
+		{Content: "db=x", Extension: ".ini", Path: "$.db=DB_PASSWORD"},
+	}
+
+	for _, test := range tests {
+		paths, err := ParseInjectPaths([]string{test.Path})
+		if err != nil {
+			t.Fatalf("Test_InjectSecretsIntoContent_Errors: unexpected error [err=%v]", err)
+		}
+
+		if _, err := InjectSecretsIntoContent([]byte(test.Content), test.Extension, paths, injectTestSecrets); err == nil {
+			t.Errorf("Test_InjectSecretsIntoContent_Errors: expected an error for [%s] on [%s]", test.Path, test.Content)
+		}
+	}
+}
+
+func Test_GetJSONPathValue(t *testing.T) {
+	content := []byte(`{"db": {"hosts": ["primary", "replica"], "port": 5432, "my.key": true}}`)
+
+	var tests = []struct {
+		Path     string
+		Expected interface{}
+	}{
+		{Path: ".db.hosts[1]", Expected: "replica"},
+		{Path: "$.db.hosts[0]", Expected: "primary"},
+		{Path: `.db["my.key"]`, Expected: true},
+		{Path: ".db.port", Expected: json.Number("5432")},
+	}
+
+	for _, test := range tests {
+		value, err := GetJSONPathValue(content, test.Path)
+		if err != nil {
+			t.Errorf("Test_GetJSONPathValue: unexpected error for path [%s] [err=%v]", test.Path, err)
+			continue
+		}
+
+		if value != test.Expected {
+			t.Errorf("Test_GetJSONPathValue: expected [%v] for path [%s] but got [%v]", test.Expected, test.Path, value)
+		}
+	}
+
+	if value, err := GetJSONPathValue(content, "."); err != nil || value.(map[string]interface{})["db"] == nil {
+		t.Errorf("Test_GetJSONPathValue: expected . to select the whole document but got [%v] [err=%v]", value, err)
+	}
+
+	for _, path := range []string{".db.missing", ".db.hosts[2]", ".db.port.value", ".db[0]", "db"} {
+		if _, err := GetJSONPathValue(content, path); err == nil {
+			t.Errorf("Test_GetJSONPathValue: expected an error for path [%s]", path)
+		}
+	}
+
+	for _, invalidContent := range []string{"not json", `{"a": 1} {}`, ""} {
+		if _, err := GetJSONPathValue([]byte(invalidContent), "."); err == nil {
+			t.Errorf("Test_GetJSONPathValue: expected an error for content [%s]", invalidContent)
+		}
+	}
+}
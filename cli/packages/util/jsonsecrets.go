@@ -0,0 +1,241 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"gopkg.in/yaml.v3"
+)
+
+// ReadSecretsFromFile loads secrets from a file in the given format (json, yaml, dotenv or auto), or from stdin when the path is -.
+// The secrets keep the order of the file
+func ReadSecretsFromFile(path string, format string) ([]models.SingleEnvironmentVariable, error) {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secrets from [%s] [err=%v]", path, err)
+	}
+
+	if strings.ToLower(format) == SECRETS_INPUT_FORMAT_AUTO {
+		format = DetectSecretsFormat(path, content)
+	}
+
+	secrets, err := ParseSecrets(content, format)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse secrets from [%s] [err=%v]", path, err)
+	}
+
+	return secrets, nil
+}
+
+// DetectSecretsFormat infers the format of a file of secrets from its extension. Files without a known extension, such as
+// .env.local, are json when they start with { or [, dotenv when their first line has an = before any : and yaml otherwise
+func DetectSecretsFormat(path string, content []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return SECRETS_INPUT_FORMAT_JSON
+	case ".yaml", ".yml":
+		return SECRETS_INPUT_FORMAT_YAML
+	case ".env":
+		return SECRETS_INPUT_FORMAT_DOTENV
+	}
+
+	trimmedContent := strings.TrimSpace(string(content))
+	if strings.HasPrefix(trimmedContent, "{") || strings.HasPrefix(trimmedContent, "[") {
+		return SECRETS_INPUT_FORMAT_JSON
+	}
+
+	for _, line := range strings.Split(trimmedContent, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+
+		separatorIndex := strings.IndexAny(line, "=:")
+		if separatorIndex != -1 && line[separatorIndex] == ':' {
+			return SECRETS_INPUT_FORMAT_YAML
+		}
+		break
+	}
+
+	return SECRETS_INPUT_FORMAT_DOTENV
+}
+
+// ParseSecrets parses secrets written in one of the SECRETS_INPUT_FORMAT_* formats
+func ParseSecrets(content []byte, format string) ([]models.SingleEnvironmentVariable, error) {
+	switch strings.ToLower(format) {
+	case SECRETS_INPUT_FORMAT_JSON:
+		secrets, err := ParseSecretsFromJSON(content, false)
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			return nil, fmt.Errorf("line %d: %v", bytes.Count(content[:syntaxError.Offset], []byte("\n"))+1, err)
+		}
+		return secrets, err
+	case SECRETS_INPUT_FORMAT_YAML:
+		return ParseSecretsFromYAML(content)
+	case SECRETS_INPUT_FORMAT_DOTENV:
+		secrets, err := ParseEnvFile(string(content))
+		for i := range secrets {
+			secrets[i].Type = SECRET_TYPE_SHARED
+		}
+		return secrets, err
+	default:
+		return nil, fmt.Errorf("invalid secrets format [%s]. Available formats are [%s, %s, %s]", format, SECRETS_INPUT_FORMAT_JSON, SECRETS_INPUT_FORMAT_YAML, SECRETS_INPUT_FORMAT_DOTENV)
+	}
+}
+
+// ParseSecretsFromJSON parses a JSON object of secrets. String values are used as is, numbers and booleans as they are written.
+// Objects and arrays are refused unless stringifyNested is set, in which case they are used as their compact JSON.
+// The array of secrets written by [infisical export --format json] is accepted too
+func ParseSecretsFromJSON(content []byte, stringifyNested bool) ([]models.SingleEnvironmentVariable, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if delimiter, ok := token.(json.Delim); ok && delimiter == '[' {
+		return parseExportedSecretsFromJSON(content)
+	}
+
+	if delimiter, ok := token.(json.Delim); !ok || delimiter != '{' {
+		return nil, fmt.Errorf("expected a JSON object of secrets, for example {\"KEY\": \"value\"}")
+	}
+
+	secrets := []models.SingleEnvironmentVariable{}
+	seenKeys := make(map[string]bool)
+	for decoder.More() {
+		keyToken, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := keyToken.(string)
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		var stringValue string
+		switch typedValue := value.(type) {
+		case string:
+			stringValue = typedValue
+		case json.Number:
+			stringValue = typedValue.String()
+		case bool:
+			stringValue = fmt.Sprintf("%t", typedValue)
+		case map[string]interface{}, []interface{}:
+			if !stringifyNested {
+				return nil, fmt.Errorf("the value of [%s] must be a string, a number or a boolean, not a nested object or array", key)
+			}
+
+			nestedValue, err := json.Marshal(typedValue)
+			if err != nil {
+				return nil, fmt.Errorf("unable to stringify the value of [%s] [err=%v]", key, err)
+			}
+			stringValue = string(nestedValue)
+		default:
+			return nil, fmt.Errorf("the value of [%s] must be a string, a number or a boolean", key)
+		}
+
+		if seenKeys[key] {
+			return nil, fmt.Errorf("the secret [%s] is defined more than once", key)
+		}
+		seenKeys[key] = true
+
+		secrets = append(secrets, models.SingleEnvironmentVariable{Key: key, Value: stringValue, Type: SECRET_TYPE_SHARED})
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected content after the JSON object")
+	}
+
+	return secrets, nil
+}
+
+// parses the array of {"key": ..., "value": ...} objects written by [infisical export --format json], other fields are ignored
+func parseExportedSecretsFromJSON(content []byte) ([]models.SingleEnvironmentVariable, error) {
+	var exportedSecrets []struct {
+		Key   *string `json:"key"`
+		Value *string `json:"value"`
+	}
+
+	if err := json.Unmarshal(content, &exportedSecrets); err != nil {
+		return nil, fmt.Errorf("expected an array of secrets as written by [infisical export --format json] [err=%v]", err)
+	}
+
+	secrets := []models.SingleEnvironmentVariable{}
+	seenKeys := make(map[string]bool)
+	for index, exportedSecret := range exportedSecrets {
+		if exportedSecret.Key == nil || exportedSecret.Value == nil {
+			return nil, fmt.Errorf("the secret at index %d must have a key and a value", index)
+		}
+
+		if seenKeys[*exportedSecret.Key] {
+			return nil, fmt.Errorf("the secret [%s] is defined more than once", *exportedSecret.Key)
+		}
+		seenKeys[*exportedSecret.Key] = true
+
+		secrets = append(secrets, models.SingleEnvironmentVariable{Key: *exportedSecret.Key, Value: *exportedSecret.Value, Type: SECRET_TYPE_SHARED})
+	}
+
+	return secrets, nil
+}
+
+// ParseSecretsFromYAML parses a YAML mapping of secrets. Scalar values are used as they are written
+func ParseSecretsFromYAML(content []byte) ([]models.SingleEnvironmentVariable, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	if len(document.Content) == 0 {
+		return []models.SingleEnvironmentVariable{}, nil
+	}
+
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a YAML mapping of secrets, for example KEY: value", mapping.Line)
+	}
+
+	secrets := []models.SingleEnvironmentVariable{}
+	seenKeys := make(map[string]bool)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keyNode, valueNode := mapping.Content[i], mapping.Content[i+1]
+		if keyNode.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: the names of secrets must be strings", keyNode.Line)
+		}
+
+		if valueNode.Kind != yaml.ScalarNode || valueNode.Tag == "!!null" {
+			return nil, fmt.Errorf("line %d: the value of [%s] must be a string, a number or a boolean", valueNode.Line, keyNode.Value)
+		}
+
+		if seenKeys[keyNode.Value] {
+			return nil, fmt.Errorf("line %d: the secret [%s] is defined more than once", keyNode.Line, keyNode.Value)
+		}
+		seenKeys[keyNode.Value] = true
+
+		secrets = append(secrets, models.SingleEnvironmentVariable{Key: keyNode.Value, Value: valueNode.Value, Type: SECRET_TYPE_SHARED})
+	}
+
+	return secrets, nil
+}
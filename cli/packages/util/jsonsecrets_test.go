@@ -0,0 +1,119 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ParseSecretsFromJSON(t *testing.T) {
+	secrets, err := ParseSecretsFromJSON([]byte(`{"ZED": "last", "PORT": 5432, "DEBUG": false, "URL": "postgres://${HOST}"}`), false)
+	if err != nil {
+		t.Fatalf("Test_ParseSecretsFromJSON: unexpected error [err=%v]", err)
+	}
+
+	expected := [][2]string{{"ZED", "last"}, {"PORT", "5432"}, {"DEBUG", "false"}, {"URL", "postgres://${HOST}"}}
+	if len(secrets) != len(expected) {
+		t.Fatalf("Test_ParseSecretsFromJSON: expected %d secrets but got %+v", len(expected), secrets)
+	}
+
+	for i, secret := range secrets {
+		if secret.Key != expected[i][0] || secret.Value != expected[i][1] || secret.Type != SECRET_TYPE_SHARED {
+			t.Errorf("Test_ParseSecretsFromJSON: expected %s=[%s] but got %+v", expected[i][0], expected[i][1], secret)
+		}
+	}
+
+	for _, invalidContent := range []string{`["KEY"]`, `{"KEY": {"nested": true}}`, `{"KEY": null}`, `{"KEY": "a", "KEY": "b"}`, `{"KEY": "a"} {}`, `{"KEY": "a"`} {
+		if _, err := ParseSecretsFromJSON([]byte(invalidContent), false); err == nil {
+			t.Errorf("Test_ParseSecretsFromJSON: expected an error for [%s]", invalidContent)
+		}
+	}
+}
+
+func Test_ParseSecretsFromJSON_StringifyNested(t *testing.T) {
+	secrets, err := ParseSecretsFromJSON([]byte(`{"FEATURES": {"beta": true, "limit": 10}, "HOSTS": ["a", "b"]}`), true)
+	if err != nil || len(secrets) != 2 {
+		t.Fatalf("Test_ParseSecretsFromJSON_StringifyNested: expected 2 secrets but got %+v [err=%v]", secrets, err)
+	}
+
+	if secrets[0].Value != `{"beta":true,"limit":10}` || secrets[1].Value != `["a","b"]` {
+		t.Errorf("Test_ParseSecretsFromJSON_StringifyNested: expected the compact JSON of the nested values but got %+v", secrets)
+	}
+}
+
+func Test_ParseSecretsFromJSON_ExportedArray(t *testing.T) {
+	exported := `[{"key":"DB_PASSWORD","value":"hunter2","type":"shared","_id":"a","tags":[],"comment":""},{"key":"PORT","value":"5432","type":"shared","_id":"b","tags":null,"comment":"db"}]`
+	secrets, err := ParseSecretsFromJSON([]byte(exported), false)
+	if err != nil || len(secrets) != 2 || secrets[0].Key != "DB_PASSWORD" || secrets[0].Value != "hunter2" || secrets[1].Key != "PORT" || secrets[1].Value != "5432" {
+		t.Fatalf("Test_ParseSecretsFromJSON_ExportedArray: expected the exported secrets but got %+v [err=%v]", secrets, err)
+	}
+
+	for _, invalidContent := range []string{`[{"key": "A"}]`, `[{"key": "A", "value": "1"}, {"key": "A", "value": "2"}]`} {
+		if _, err := ParseSecretsFromJSON([]byte(invalidContent), false); err == nil {
+			t.Errorf("Test_ParseSecretsFromJSON_ExportedArray: expected an error for [%s]", invalidContent)
+		}
+	}
+}
+
+func Test_ParseSecrets(t *testing.T) {
+	inputs := map[string]string{
+		SECRETS_INPUT_FORMAT_JSON:   `{"ZED": "last", "PORT": 5432}`,
+		SECRETS_INPUT_FORMAT_YAML:   "ZED: last\nPORT: 5432\n",
+		SECRETS_INPUT_FORMAT_DOTENV: "ZED=last\nexport PORT=5432\n",
+	}
+
+	for format, content := range inputs {
+		secrets, err := ParseSecrets([]byte(content), format)
+		if err != nil {
+			t.Fatalf("Test_ParseSecrets: unexpected error for the %s format [err=%v]", format, err)
+		}
+
+		if len(secrets) != 2 || secrets[0].Key != "ZED" || secrets[0].Value != "last" || secrets[1].Key != "PORT" || secrets[1].Value != "5432" || secrets[1].Type != SECRET_TYPE_SHARED {
+			t.Errorf("Test_ParseSecrets: expected ZED and PORT in order for the %s format but got %+v", format, secrets)
+		}
+	}
+
+	var tests = []struct {
+		Format       string
+		Content      string
+		ExpectedLine string
+	}{
+		{Format: SECRETS_INPUT_FORMAT_JSON, Content: "{\n\"KEY\": \"a\",\n\"OTHER\" \"b\"\n}", ExpectedLine: "line 3"},
+		{Format: SECRETS_INPUT_FORMAT_YAML, Content: "KEY: a\nOTHER:\n  nested: b\n", ExpectedLine: "line 3"},
+		{Format: SECRETS_INPUT_FORMAT_YAML, Content: "KEY: a\nKEY: b\n", ExpectedLine: "line 2"},
+		{Format: SECRETS_INPUT_FORMAT_YAML, Content: "- KEY\n", ExpectedLine: "line 1"},
+		{Format: SECRETS_INPUT_FORMAT_DOTENV, Content: "KEY=a\nnot a secret\n", ExpectedLine: "line 2"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseSecrets([]byte(test.Content), test.Format)
+		if err == nil || !strings.Contains(err.Error(), test.ExpectedLine) {
+			t.Errorf("Test_ParseSecrets: expected an error on %s for %s content [%q] but got [err=%v]", test.ExpectedLine, test.Format, test.Content, err)
+		}
+	}
+
+	if _, err := ParseSecrets([]byte("KEY=a"), "toml"); err == nil {
+		t.Errorf("Test_ParseSecrets: expected an error for an unknown format")
+	}
+}
+
+func Test_DetectSecretsFormat(t *testing.T) {
+	tests := []struct {
+		path     string
+		content  string
+		expected string
+	}{
+		{"overrides.json", "", SECRETS_INPUT_FORMAT_JSON},
+		{"overrides.YML", "", SECRETS_INPUT_FORMAT_YAML},
+		{"prod.env", "", SECRETS_INPUT_FORMAT_DOTENV},
+		{".env.local", "  {\"DB_HOST\": \"localhost\"}", SECRETS_INPUT_FORMAT_JSON},
+		{".env.local", "# local overrides\n\nexport DB_URL=postgres://localhost:5432", SECRETS_INPUT_FORMAT_DOTENV},
+		{".env.local", "---\n# local overrides\nDB_URL: postgres://localhost:5432/app?sslmode=disable", SECRETS_INPUT_FORMAT_YAML},
+		{"-", "", SECRETS_INPUT_FORMAT_DOTENV},
+	}
+
+	for _, test := range tests {
+		if format := DetectSecretsFormat(test.path, []byte(test.content)); format != test.expected {
+			t.Errorf("Test_DetectSecretsFormat: expected [%s] with content %q to be %s but got %s", test.path, test.content, test.expected, format)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+const (
+	INFISICAL_LINT_CONFIG_FILE_NAME = ".infisical-lint.json"
+
+	LINT_RULE_KEY_NAMING             = "key-naming"
+	LINT_RULE_EMPTY_VALUE            = "empty-value"
+	LINT_RULE_SURROUNDING_WHITESPACE = "surrounding-whitespace"
+	LINT_RULE_DUPLICATE_VALUE        = "duplicate-value"
+
+	// SCREAMING_SNAKE_CASE
+	DEFAULT_LINT_KEY_PATTERN = `^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`
+)
+
+var lintRules = []string{LINT_RULE_KEY_NAMING, LINT_RULE_EMPTY_VALUE, LINT_RULE_SURROUNDING_WHITESPACE, LINT_RULE_DUPLICATE_VALUE}
+
+// LintConfig is read from .infisical-lint.json
+type LintConfig struct {
+	// regular expression every secret name must match
+	KeyPattern    string   `json:"keyPattern"`
+	DisabledRules []string `json:"disabledRules"`
+	// secrets that are not checked at all
+	IgnoredKeys []string `json:"ignoredKeys"`
+}
+
+type LintViolation struct {
+	Key         string
+	RuleId      string
+	Description string
+}
+
+// ReadLintConfig reads the lint rules from the given file. The default rules are used when the file does not exist and isOptional is set
+func ReadLintConfig(path string, isOptional bool) (LintConfig, error) {
+	config := LintConfig{KeyPattern: DEFAULT_LINT_KEY_PATTERN}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && isOptional {
+		return config, nil
+	}
+
+	if err != nil {
+		return LintConfig{}, fmt.Errorf("unable to read lint config [%s] [err=%v]", path, err)
+	}
+
+	if err := json.Unmarshal(content, &config); err != nil {
+		return LintConfig{}, fmt.Errorf("unable to parse lint config [%s] [err=%v]", path, err)
+	}
+
+	if config.KeyPattern == "" {
+		config.KeyPattern = DEFAULT_LINT_KEY_PATTERN
+	}
+
+	for _, disabledRule := range config.DisabledRules {
+		isKnownRule := false
+		for _, rule := range lintRules {
+			isKnownRule = isKnownRule || rule == disabledRule
+		}
+
+		if !isKnownRule {
+			return LintConfig{}, fmt.Errorf("unknown rule [%s] in lint config [%s]. Available rules are [%s]", disabledRule, path, strings.Join(lintRules, ", "))
+		}
+	}
+
+	return config, nil
+}
+
+// LintSecrets checks the secrets against the rules of the config. Violations are sorted by secret name and rule
+func LintSecrets(secrets []models.SingleEnvironmentVariable, config LintConfig) ([]LintViolation, error) {
+	keyPattern, err := regexp.Compile(config.KeyPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyPattern [%s] [err=%v]", config.KeyPattern, err)
+	}
+
+	isEnabled := func(rule string) bool {
+		for _, disabledRule := range config.DisabledRules {
+			if disabledRule == rule {
+				return false
+			}
+		}
+		return true
+	}
+
+	ignoredKeys := make(map[string]bool)
+	for _, key := range config.IgnoredKeys {
+		ignoredKeys[key] = true
+	}
+
+	violations := []LintViolation{}
+	keysByValue := make(map[string][]string)
+	for _, secret := range secrets {
+		if ignoredKeys[secret.Key] {
+			continue
+		}
+
+		if isEnabled(LINT_RULE_KEY_NAMING) && !keyPattern.MatchString(secret.Key) {
+			violations = append(violations, LintViolation{Key: secret.Key, RuleId: LINT_RULE_KEY_NAMING, Description: fmt.Sprintf("name does not match %s", config.KeyPattern)})
+		}
+
+		if secret.Value == "" {
+			if isEnabled(LINT_RULE_EMPTY_VALUE) {
+				violations = append(violations, LintViolation{Key: secret.Key, RuleId: LINT_RULE_EMPTY_VALUE, Description: "value is empty"})
+			}
+			continue
+		}
+
+		if isEnabled(LINT_RULE_SURROUNDING_WHITESPACE) && strings.TrimSpace(secret.Value) != secret.Value {
+			violations = append(violations, LintViolation{Key: secret.Key, RuleId: LINT_RULE_SURROUNDING_WHITESPACE, Description: "value starts or ends with whitespace or a line break"})
+		}
+
+		keysByValue[secret.Value] = append(keysByValue[secret.Value], secret.Key)
+	}
+
+	if isEnabled(LINT_RULE_DUPLICATE_VALUE) {
+		for _, keys := range keysByValue {
+			if len(keys) < 2 {
+				continue
+			}
+
+			for _, key := range keys {
+				otherKeys := []string{}
+				for _, otherKey := range keys {
+					if otherKey != key {
+						otherKeys = append(otherKeys, otherKey)
+					}
+				}
+				sort.Strings(otherKeys)
+				violations = append(violations, LintViolation{Key: key, RuleId: LINT_RULE_DUPLICATE_VALUE, Description: fmt.Sprintf("value is the same as [%s]", strings.Join(otherKeys, ", "))})
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Key == violations[j].Key {
+			return violations[i].RuleId < violations[j].RuleId
+		}
+		return violations[i].Key < violations[j].Key
+	})
+
+	return violations, nil
+}
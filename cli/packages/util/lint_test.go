@@ -0,0 +1,83 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func Test_LintSecrets(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_PASSWORD", Value: "hunter2"},
+		{Key: "dbUser", Value: "admin"},
+		{Key: "API_KEY", Value: "key-with-newline\n"},
+		{Key: "EMPTY", Value: ""},
+		{Key: "OTHER_EMPTY", Value: ""},
+		{Key: "ADMIN_PASSWORD", Value: "hunter2"},
+		{Key: "TRAILING_", Value: "value"},
+		{Key: "IGNORED key", Value: "admin"},
+	}
+
+	config := LintConfig{KeyPattern: DEFAULT_LINT_KEY_PATTERN, IgnoredKeys: []string{"IGNORED key"}}
+	violations, err := LintSecrets(secrets, config)
+	if err != nil {
+		t.Fatalf("Test_LintSecrets: unexpected error [err=%v]", err)
+	}
+
+	expected := [][2]string{
+		{"ADMIN_PASSWORD", LINT_RULE_DUPLICATE_VALUE},
+		{"API_KEY", LINT_RULE_SURROUNDING_WHITESPACE},
+		{"DB_PASSWORD", LINT_RULE_DUPLICATE_VALUE},
+		{"EMPTY", LINT_RULE_EMPTY_VALUE},
+		{"OTHER_EMPTY", LINT_RULE_EMPTY_VALUE},
+		{"TRAILING_", LINT_RULE_KEY_NAMING},
+		{"dbUser", LINT_RULE_KEY_NAMING},
+	}
+
+	if len(violations) != len(expected) {
+		t.Fatalf("Test_LintSecrets: expected %d violations but got %+v", len(expected), violations)
+	}
+
+	for i, violation := range violations {
+		if violation.Key != expected[i][0] || violation.RuleId != expected[i][1] {
+			t.Errorf("Test_LintSecrets: expected %s (%s) but got %+v", expected[i][0], expected[i][1], violation)
+		}
+	}
+
+	if violations[0].Description != "value is the same as [DB_PASSWORD]" {
+		t.Errorf("Test_LintSecrets: expected the duplicate to be named, got [%s]", violations[0].Description)
+	}
+
+	config.DisabledRules = []string{LINT_RULE_DUPLICATE_VALUE, LINT_RULE_EMPTY_VALUE}
+	config.KeyPattern = `^[a-zA-Z_]+$`
+	violations, _ = LintSecrets(secrets, config)
+	if len(violations) != 1 || violations[0].Key != "API_KEY" {
+		t.Errorf("Test_LintSecrets: expected the disabled rules and custom pattern to apply, got %+v", violations)
+	}
+}
+
+func Test_ReadLintConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), INFISICAL_LINT_CONFIG_FILE_NAME)
+
+	config, err := ReadLintConfig(configPath, true)
+	if err != nil || config.KeyPattern != DEFAULT_LINT_KEY_PATTERN {
+		t.Errorf("Test_ReadLintConfig: expected the default rules for a missing optional file, got %+v [err=%v]", config, err)
+	}
+
+	if _, err := ReadLintConfig(configPath, false); err == nil {
+		t.Errorf("Test_ReadLintConfig: expected an error for a missing file that was asked for")
+	}
+
+	os.WriteFile(configPath, []byte(`{"disabledRules": ["duplicate-value"], "ignoredKeys": ["legacy_key"]}`), 0600)
+	config, err = ReadLintConfig(configPath, true)
+	if err != nil || config.KeyPattern != DEFAULT_LINT_KEY_PATTERN || len(config.DisabledRules) != 1 || config.IgnoredKeys[0] != "legacy_key" {
+		t.Errorf("Test_ReadLintConfig: unexpected config %+v [err=%v]", config, err)
+	}
+
+	os.WriteFile(configPath, []byte(`{"disabledRules": ["unknown-rule"]}`), 0600)
+	if _, err := ReadLintConfig(configPath, true); err == nil {
+		t.Errorf("Test_ReadLintConfig: expected an error for an unknown rule")
+	}
+}
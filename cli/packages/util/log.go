@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/fatih/color"
 )
 
@@ -20,6 +21,8 @@ func PrintErrorAndExit(exitCode int, err error, messages ...string) {
 		}
 	}
 
+	printRequestId()
+
 	supportMsg := fmt.Sprintf("\n\nIf this issue continues, get support at https://infisical.com/slack")
 	fmt.Fprintln(os.Stderr, supportMsg)
 
@@ -35,15 +38,28 @@ func PrintSuccessMessage(message string) {
 }
 
 func PrintErrorMessageAndExit(messages ...string) {
+	printErrorMessageAndExitWithCode(1, messages...)
+}
+
+func printErrorMessageAndExitWithCode(exitCode int, messages ...string) {
 	if len(messages) > 0 {
 		for _, message := range messages {
 			fmt.Fprintln(os.Stderr, message)
 		}
 	}
 
-	os.Exit(1)
+	printRequestId()
+
+	os.Exit(exitCode)
 }
 
 func printError(e error) {
 	color.New(color.FgRed).Fprintf(os.Stderr, "Hmm, we ran into an error: %v", e)
 }
+
+// the request id lets server logs be matched with this invocation when reporting an issue
+func printRequestId() {
+	if requestId := api.GetRequestIdIfSent(); requestId != "" {
+		fmt.Fprintf(os.Stderr, "\nRequest ID: %s\n", requestId)
+	}
+}
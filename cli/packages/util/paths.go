@@ -0,0 +1,253 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/fatih/color"
+)
+
+// max number of folders or environments fetched at the same time
+const MAX_CONCURRENT_FETCHES = 4
+
+// PathFetchResult holds the secrets of a single folder, or the reason they could not be fetched
+type PathFetchResult struct {
+	Path    string
+	Secrets []models.SingleEnvironmentVariable
+	Err     error
+}
+
+// RunConcurrently calls fn for every index in [0, count) with at most limit calls running at the same time.
+// Once shouldStop returns true, calls that have not started yet are skipped. shouldStop may be nil
+func RunConcurrently(count int, limit int, fn func(index int), shouldStop func() bool) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, limit)
+	for index := 0; index < count; index++ {
+		slots <- struct{}{}
+		if shouldStop != nil && shouldStop() {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(index)
+		}(index)
+	}
+
+	wg.Wait()
+}
+
+// GetAllEnvironmentVariablesOfPaths fetches the secrets of every folder in paths, resolving credentials only once.
+// The fetch error policy applies to each folder on its own. Unless keepGoing is set, folders that were not fetched yet
+// when the first folder failed are skipped and left without result. Results are in the order of paths
+func GetAllEnvironmentVariablesOfPaths(params models.GetAllSecretsParameters, paths []string, keepGoing bool) ([]PathFetchResult, error) {
+	fetchSecretsOfPath, err := prepareSecretsFetch(params)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]PathFetchResult, len(paths))
+	var lock sync.Mutex
+	hasFailed := false
+
+	RunConcurrently(len(paths), MAX_CONCURRENT_FETCHES, func(index int) {
+		secretsPath := NormalizeSecretsPath(paths[index])
+		secrets, readCachedSecrets, err := fetchSecretsOfPath(secretsPath)
+		secrets, err = applyFetchErrorPolicy(params.OnFetchError, secrets, readCachedSecrets, err)
+		if err != nil {
+			err = fmt.Errorf("unable to fetch secrets of path [%s] [err=%v]", secretsPath, err)
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		results[index] = PathFetchResult{Path: secretsPath, Secrets: secrets, Err: err}
+		hasFailed = hasFailed || err != nil
+	}, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return hasFailed && !keepGoing
+	})
+
+	for _, result := range results {
+		if result.Err != nil && !keepGoing {
+			return nil, result.Err
+		}
+	}
+
+	return results, nil
+}
+
+// EnvironmentFetchResult holds the secrets of a single environment, or the reason they could not be fetched
+type EnvironmentFetchResult struct {
+	Environment string
+	Secrets     []models.SingleEnvironmentVariable
+	Err         error
+}
+
+// GetAllEnvironmentVariablesOfEnvironments fetches the secrets of params.SecretsPath in every environment of environments,
+// at most concurrency at the same time. Credentials are resolved one environment after the other so that the keyring is
+// never prompted for concurrently. Unless keepGoing is set, environments that were not fetched yet when the first
+// environment failed are skipped and left without result. Results are in the order of environments
+func GetAllEnvironmentVariablesOfEnvironments(params models.GetAllSecretsParameters, environments []string, concurrency int, keepGoing bool) ([]EnvironmentFetchResult, error) {
+	fetchSecretsOfEnvironments := make([]fetchSecretsOfPathFunc, len(environments))
+	for index, environment := range environments {
+		environmentParams := params
+		environmentParams.Environment = environment
+
+		fetchSecretsOfPath, err := prepareSecretsFetch(environmentParams)
+		if err != nil {
+			return nil, err
+		}
+		fetchSecretsOfEnvironments[index] = fetchSecretsOfPath
+	}
+
+	results := make([]EnvironmentFetchResult, len(environments))
+	var lock sync.Mutex
+	hasFailed := false
+
+	RunConcurrently(len(environments), concurrency, func(index int) {
+		secrets, readCachedSecrets, err := fetchSecretsOfEnvironments[index](params.SecretsPath)
+		secrets, err = applyFetchErrorPolicy(params.OnFetchError, secrets, readCachedSecrets, err)
+		if err != nil {
+			err = fmt.Errorf("unable to fetch secrets of environment [%s] [err=%v]", environments[index], err)
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		results[index] = EnvironmentFetchResult{Environment: environments[index], Secrets: secrets, Err: err}
+		hasFailed = hasFailed || err != nil
+	}, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return hasFailed && !keepGoing
+	})
+
+	for _, result := range results {
+		if result.Err != nil && !keepGoing {
+			return nil, result.Err
+		}
+	}
+
+	return results, nil
+}
+
+// MergeSecretsOfPaths combines the secrets of the folders that were fetched. When several folders have a secret of the
+// same name and type, the folder listed last wins while the secret keeps the position it was first seen at
+func MergeSecretsOfPaths(results []PathFetchResult) []models.SingleEnvironmentVariable {
+	mergedSecrets := []models.SingleEnvironmentVariable{}
+	indexByKeyAndType := make(map[string]int)
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		for _, secret := range result.Secrets {
+			keyAndType := secret.Type + "/" + secret.Key
+			if index, ok := indexByKeyAndType[keyAndType]; ok {
+				mergedSecrets[index] = secret
+				continue
+			}
+
+			indexByKeyAndType[keyAndType] = len(mergedSecrets)
+			mergedSecrets = append(mergedSecrets, secret)
+		}
+	}
+
+	return mergedSecrets
+}
+
+// DuplicateKey is a secret name found in more than one of the folders that were fetched
+type DuplicateKey struct {
+	Key   string
+	Paths []string
+}
+
+// FindDuplicateKeysOfPaths lists the secret names found in more than one fetched folder, whatever their type, in the
+// order they were first seen at. A personal and a shared secret of the same name in a single folder are no duplicate
+func FindDuplicateKeysOfPaths(results []PathFetchResult) []DuplicateKey {
+	duplicateKeys := []DuplicateKey{}
+	indexByKey := make(map[string]int)
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		for _, secret := range result.Secrets {
+			index, ok := indexByKey[secret.Key]
+			if !ok {
+				indexByKey[secret.Key] = len(duplicateKeys)
+				duplicateKeys = append(duplicateKeys, DuplicateKey{Key: secret.Key, Paths: []string{result.Path}})
+				continue
+			}
+
+			paths := duplicateKeys[index].Paths
+			if paths[len(paths)-1] != result.Path {
+				duplicateKeys[index].Paths = append(paths, result.Path)
+			}
+		}
+	}
+
+	onlyDuplicates := []DuplicateKey{}
+	for _, duplicateKey := range duplicateKeys {
+		if len(duplicateKey.Paths) > 1 {
+			onlyDuplicates = append(onlyDuplicates, duplicateKey)
+		}
+	}
+
+	return onlyDuplicates
+}
+
+// PrintPathFetchErrors writes a report of the folders that could not be fetched to stderr and returns how many failed
+func PrintPathFetchErrors(results []PathFetchResult) int {
+	failedCount := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failedCount++
+		}
+	}
+
+	if failedCount == 0 {
+		return 0
+	}
+
+	color.New(color.FgRed).Fprintf(os.Stderr, "Unable to fetch the secrets of %d of %d path(s):\n", failedCount, len(results))
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(os.Stderr, "  - %v\n", result.Err)
+		}
+	}
+
+	return failedCount
+}
+
+// PrintEnvironmentFetchErrors writes a report of the environments that could not be fetched to stderr and returns how many failed
+func PrintEnvironmentFetchErrors(results []EnvironmentFetchResult) int {
+	failedCount := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failedCount++
+		}
+	}
+
+	if failedCount == 0 {
+		return 0
+	}
+
+	color.New(color.FgRed).Fprintf(os.Stderr, "Unable to fetch the secrets of %d of %d environment(s):\n", failedCount, len(results))
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(os.Stderr, "  - %v\n", result.Err)
+		}
+	}
+
+	return failedCount
+}
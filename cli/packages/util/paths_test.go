@@ -0,0 +1,157 @@
+package util
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func Test_GetAllEnvironmentVariablesOfPaths(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(INFISICAL_TOKEN_NAME, "")
+	mock := newMockInfisicalServer(t, [][2]string{{"DB_PASSWORD", "hunter2"}})
+	mock.missingPaths = map[string]bool{"/missing": true}
+
+	params := models.GetAllSecretsParameters{Environment: "dev", InfisicalToken: testServiceToken}
+	paths := []string{"/", "missing", "/api/"}
+
+	if _, err := GetAllEnvironmentVariablesOfPaths(params, paths, false); err == nil {
+		t.Errorf("Test_GetAllEnvironmentVariablesOfPaths: expected the failing path to abort the fetch")
+	}
+
+	results, err := GetAllEnvironmentVariablesOfPaths(params, paths, true)
+	if err != nil || len(results) != 3 {
+		t.Fatalf("Test_GetAllEnvironmentVariablesOfPaths: expected a result per path but got %+v [err=%v]", results, err)
+	}
+
+	expectedPaths := []string{"/", "/missing", "/api"}
+	for i, result := range results {
+		if result.Path != expectedPaths[i] {
+			t.Errorf("Test_GetAllEnvironmentVariablesOfPaths: expected path %s but got %s", expectedPaths[i], result.Path)
+		}
+
+		if hasFailed := result.Err != nil; hasFailed != (result.Path == "/missing") {
+			t.Errorf("Test_GetAllEnvironmentVariablesOfPaths: unexpected result for path %s [err=%v]", result.Path, result.Err)
+		}
+	}
+
+	if secrets := MergeSecretsOfPaths(results); len(secrets) != 1 || secrets[0].Value != "hunter2" {
+		t.Errorf("Test_GetAllEnvironmentVariablesOfPaths: expected the secrets of the successful paths but got %+v", secrets)
+	}
+}
+
+func Test_GetAllEnvironmentVariablesOfEnvironments(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(INFISICAL_TOKEN_NAME, "")
+	mock := newMockInfisicalServer(t, [][2]string{{"DB_PASSWORD", "hunter2"}})
+	mock.latency = 200 * time.Millisecond
+
+	params := models.GetAllSecretsParameters{InfisicalToken: testServiceToken}
+	environments := []string{"dev", "staging", "prod", "qa"}
+
+	startedAt := time.Now()
+	results, err := GetAllEnvironmentVariablesOfEnvironments(params, environments, len(environments), false)
+	if err != nil || len(results) != len(environments) {
+		t.Fatalf("Test_GetAllEnvironmentVariablesOfEnvironments: expected a result per environment but got %+v [err=%v]", results, err)
+	}
+
+	// fetched one after the other, the environments would take the latency of the mock server each
+	if elapsed := time.Since(startedAt); elapsed >= time.Duration(len(environments))*mock.latency {
+		t.Errorf("Test_GetAllEnvironmentVariablesOfEnvironments: expected the environments to be fetched concurrently but it took %v", elapsed)
+	}
+
+	for i, result := range results {
+		if result.Environment != environments[i] || result.Err != nil || len(result.Secrets) != 1 {
+			t.Errorf("Test_GetAllEnvironmentVariablesOfEnvironments: unexpected result for environment %s: %+v", environments[i], result)
+		}
+	}
+
+	mock.failWithStatus = http.StatusInternalServerError
+	if _, err := GetAllEnvironmentVariablesOfEnvironments(params, environments, 1, false); err == nil {
+		t.Errorf("Test_GetAllEnvironmentVariablesOfEnvironments: expected the failing environment to abort the fetch")
+	}
+
+	results, err = GetAllEnvironmentVariablesOfEnvironments(params, environments, 2, true)
+	if err != nil || len(results) != len(environments) {
+		t.Fatalf("Test_GetAllEnvironmentVariablesOfEnvironments: expected a result per environment with keepGoing but got %+v [err=%v]", results, err)
+	}
+
+	for _, result := range results {
+		if result.Err == nil {
+			t.Errorf("Test_GetAllEnvironmentVariablesOfEnvironments: expected environment %s to have failed", result.Environment)
+		}
+	}
+}
+
+func Test_MergeSecretsOfPaths(t *testing.T) {
+	results := []PathFetchResult{
+		{Path: "/", Secrets: []models.SingleEnvironmentVariable{{Key: "A", Value: "root", Type: SECRET_TYPE_SHARED}, {Key: "B", Value: "root", Type: SECRET_TYPE_SHARED}}},
+		{Path: "/failed", Err: errors.New("not found")},
+		{Path: "/api", Secrets: []models.SingleEnvironmentVariable{{Key: "C", Value: "api", Type: SECRET_TYPE_SHARED}, {Key: "A", Value: "api", Type: SECRET_TYPE_SHARED}, {Key: "A", Value: "personal", Type: SECRET_TYPE_PERSONAL}}},
+	}
+
+	secrets := MergeSecretsOfPaths(results)
+	expected := []string{"A=api", "B=root", "C=api", "A=personal"}
+	if len(secrets) != len(expected) {
+		t.Fatalf("Test_MergeSecretsOfPaths: expected %d secrets but got %+v", len(expected), secrets)
+	}
+
+	for i, secret := range secrets {
+		if secret.Key+"="+secret.Value != expected[i] {
+			t.Errorf("Test_MergeSecretsOfPaths: expected %s but got %s=%s", expected[i], secret.Key, secret.Value)
+		}
+	}
+}
+
+func Test_FindDuplicateKeysOfPaths(t *testing.T) {
+	results := []PathFetchResult{
+		{Path: "/", Secrets: []models.SingleEnvironmentVariable{{Key: "A", Type: SECRET_TYPE_SHARED}, {Key: "A", Type: SECRET_TYPE_PERSONAL}, {Key: "B", Type: SECRET_TYPE_SHARED}}},
+		{Path: "/failed", Err: errors.New("not found")},
+		{Path: "/api", Secrets: []models.SingleEnvironmentVariable{{Key: "C", Type: SECRET_TYPE_SHARED}, {Key: "B", Type: SECRET_TYPE_PERSONAL}}},
+		{Path: "/worker", Secrets: []models.SingleEnvironmentVariable{{Key: "B", Type: SECRET_TYPE_SHARED}, {Key: "D", Type: SECRET_TYPE_SHARED}}},
+	}
+
+	duplicateKeys := FindDuplicateKeysOfPaths(results)
+	if len(duplicateKeys) != 1 || duplicateKeys[0].Key != "B" || strings.Join(duplicateKeys[0].Paths, ",") != "/,/api,/worker" {
+		t.Errorf("Test_FindDuplicateKeysOfPaths: expected B to be found in /, /api and /worker but got %+v", duplicateKeys)
+	}
+
+	if duplicateKeys := FindDuplicateKeysOfPaths(results[:2]); len(duplicateKeys) != 0 {
+		t.Errorf("Test_FindDuplicateKeysOfPaths: expected no duplicates within a single path but got %+v", duplicateKeys)
+	}
+}
+
+func Test_RunConcurrently(t *testing.T) {
+	var running, maxRunning, calls int32
+	RunConcurrently(20, 3, func(index int) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			observed := atomic.LoadInt32(&maxRunning)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxRunning, observed, current) {
+				break
+			}
+		}
+		atomic.AddInt32(&calls, 1)
+		atomic.AddInt32(&running, -1)
+	}, nil)
+
+	if calls != 20 || maxRunning > 3 {
+		t.Errorf("Test_RunConcurrently: expected 20 calls with at most 3 at a time but got %d calls and %d at a time", calls, maxRunning)
+	}
+
+	calls = 0
+	RunConcurrently(20, 1, func(index int) {
+		atomic.AddInt32(&calls, 1)
+	}, func() bool {
+		return atomic.LoadInt32(&calls) >= 5
+	})
+
+	if calls != 5 {
+		t.Errorf("Test_RunConcurrently: expected calls to stop once asked to but got %d calls", calls)
+	}
+}
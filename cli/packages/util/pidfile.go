@@ -0,0 +1,61 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WritePidFile writes pid to path. The file is replaced atomically so tools reading it never see a partial pid
+func WritePidFile(path string, pid int) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to write pid file [%s] [err=%v]", path, err)
+	}
+
+	_, err = tempFile.WriteString(strconv.Itoa(pid) + "\n")
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tempFile.Name(), 0644)
+	}
+
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return fmt.Errorf("unable to write pid file [%s] [err=%v]", path, err)
+	}
+
+	return nil
+}
+
+// RemovePidFile removes the pid file once the process exited. A missing file is not an error
+func RemovePidFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove pid file [%s] [err=%v]", path, err)
+	}
+	return nil
+}
+
+// ReadPidFile reads the pid written by WritePidFile
+func ReadPidFile(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read pid file [%s] [err=%v]", path, err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("pid file [%s] does not contain a valid pid", path)
+	}
+
+	return pid, nil
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_WritePidFile(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "app.pid")
+
+	if err := WritePidFile(pidFile, 1234); err != nil {
+		t.Fatalf("Test_WritePidFile: unexpected error [err=%v]", err)
+	}
+
+	// a restart replaces the pid of the previous process
+	if err := WritePidFile(pidFile, 5678); err != nil {
+		t.Fatalf("Test_WritePidFile: unexpected error [err=%v]", err)
+	}
+
+	content, err := os.ReadFile(pidFile)
+	if err != nil || string(content) != "5678\n" {
+		t.Errorf("Test_WritePidFile: expected the latest pid but got [%s] [err=%v]", content, err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(pidFile))
+	if len(entries) != 1 {
+		t.Errorf("Test_WritePidFile: expected no temporary files to be left behind but got %d files", len(entries))
+	}
+
+	if err := RemovePidFile(pidFile); err != nil {
+		t.Errorf("Test_WritePidFile: unexpected error when removing [err=%v]", err)
+	}
+
+	if err := RemovePidFile(pidFile); err != nil {
+		t.Errorf("Test_WritePidFile: expected removing a missing pid file to succeed [err=%v]", err)
+	}
+
+	if err := WritePidFile(filepath.Join(t.TempDir(), "missing", "app.pid"), 1234); err == nil {
+		t.Errorf("Test_WritePidFile: expected an error when the directory does not exist")
+	}
+}
+
+func Test_ReadPidFile(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "app.pid")
+
+	if err := WritePidFile(pidFile, 1234); err != nil {
+		t.Fatalf("Test_ReadPidFile: unexpected error [err=%v]", err)
+	}
+
+	pid, err := ReadPidFile(pidFile)
+	if err != nil || pid != 1234 {
+		t.Errorf("Test_ReadPidFile: expected pid 1234 but got %d [err=%v]", pid, err)
+	}
+
+	os.WriteFile(pidFile, []byte("not a pid\n"), 0644)
+	if _, err := ReadPidFile(pidFile); err == nil {
+		t.Errorf("Test_ReadPidFile: expected an error for a file without a pid")
+	}
+
+	if _, err := ReadPidFile(filepath.Join(t.TempDir(), "missing.pid")); err == nil {
+		t.Errorf("Test_ReadPidFile: expected an error for a missing file")
+	}
+}
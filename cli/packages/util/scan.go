@@ -0,0 +1,416 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	INFISICAL_SCAN_IGNORE_FILE_NAME = ".infisicalignore"
+	SCAN_RULE_INFISICAL_SECRET      = "infisical-secret"
+	SCAN_RULE_HIGH_ENTROPY          = "high-entropy-string"
+
+	// files larger than this are most likely generated or binary so we do not scan them
+	scanMaxFileSize = 5 * 1024 * 1024
+	// secrets shorter than this are too generic (true, 8080, dev...) to be reported when found in a file
+	scanMinSecretValueLength = 6
+	scanMinEntropyLength     = 20
+	scanMinEntropy           = 4.0
+)
+
+type ScanFinding struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	RuleId      string `json:"ruleId"`
+	Description string `json:"description"`
+}
+
+// Fingerprint uniquely identifies a finding so that it can be added to a .infisicalignore file
+func (f ScanFinding) Fingerprint() string {
+	return fmt.Sprintf("%s:%d", filepath.ToSlash(f.File), f.Line)
+}
+
+type scanRule struct {
+	id          string
+	description string
+	regex       *regexp.Regexp
+}
+
+var scanRules = []scanRule{
+	{id: "aws-access-key-id", description: "AWS access key id", regex: regexp.MustCompile(`\b(AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{id: "aws-secret-access-key", description: "AWS secret access key", regex: regexp.MustCompile(`(?i)aws.{0,20}(secret|private).{0,20}['"][0-9a-zA-Z/+]{40}['"]`)},
+	{id: "private-key", description: "Private key", regex: regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`)},
+	{id: "github-token", description: "GitHub token", regex: regexp.MustCompile(`\bgh[pousr]_[0-9a-zA-Z]{36}\b`)},
+	{id: "slack-token", description: "Slack token", regex: regexp.MustCompile(`\bxox[baprs]-[0-9a-zA-Z-]{10,48}\b`)},
+	{id: "infisical-service-token", description: "Infisical service token", regex: regexp.MustCompile(`\bst\.[0-9a-f]{24}\.[0-9a-f]{32}\.[0-9a-f]{32}\b`)},
+}
+
+var highEntropyCandidateRegex = regexp.MustCompile(`[A-Za-z0-9+/_\-]{20,}={0,2}`)
+
+// ScanDirectory walks the given directory (respecting .gitignore and .infisicalignore files) and returns every line that looks like it contains a secret.
+// Any value of knownSecrets found in a file is reported as well
+func ScanDirectory(root string, knownSecrets []models.SingleEnvironmentVariable) ([]ScanFinding, error) {
+	ignoredFingerprints, err := readScanIgnoreFingerprints(filepath.Join(root, INFISICAL_SCAN_IGNORE_FILE_NAME))
+	if err != nil {
+		return nil, err
+	}
+
+	secretsToLookFor := []models.SingleEnvironmentVariable{}
+	for _, secret := range knownSecrets {
+		if len(secret.Value) >= scanMinSecretValueLength {
+			secretsToLookFor = append(secretsToLookFor, secret)
+		}
+	}
+
+	matcher := &ignoreMatcher{}
+	findings := []ScanFinding{}
+
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Debugf("ScanDirectory: unable to access [path=%s] [err=%v]", path, err)
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			if relativePath != "." && matcher.isIgnored(relativePath, true) {
+				return filepath.SkipDir
+			}
+
+			// ignore files apply to the directory they are in and all of its children
+			for _, ignoreFileName := range []string{".gitignore", INFISICAL_SCAN_IGNORE_FILE_NAME} {
+				err = matcher.loadIgnoreFile(filepath.Join(path, ignoreFileName), relativePath)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		if !entry.Type().IsRegular() || matcher.isIgnored(relativePath, false) {
+			return nil
+		}
+
+		fileFindings, err := scanFile(path, relativePath, secretsToLookFor)
+		if err != nil {
+			log.Debugf("ScanDirectory: unable to scan [path=%s] [err=%v]", path, err)
+			return nil
+		}
+
+		for _, finding := range fileFindings {
+			if _, ignored := ignoredFingerprints[finding.Fingerprint()]; !ignored {
+				findings = append(findings, finding)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+func scanFile(path string, relativePath string, secretsToLookFor []models.SingleEnvironmentVariable) ([]ScanFinding, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() > scanMaxFileSize {
+		log.Debugf("scanFile: skipping [path=%s] because it is too large", path)
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// binary files
+	if bytes.IndexByte(content[:minInt(len(content), 8000)], 0) != -1 {
+		return nil, nil
+	}
+
+	findings := []ScanFinding{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), scanMaxFileSize)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+
+		for _, finding := range ScanLine(line, secretsToLookFor) {
+			finding.File = relativePath
+			finding.Line = lineNumber
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings, scanner.Err()
+}
+
+// ScanLine returns a finding for every rule that matches the given line. File and line number are left empty
+func ScanLine(line string, secretsToLookFor []models.SingleEnvironmentVariable) []ScanFinding {
+	findings := []ScanFinding{}
+	for _, rule := range scanRules {
+		if rule.regex.MatchString(line) {
+			findings = append(findings, ScanFinding{RuleId: rule.id, Description: rule.description})
+		}
+	}
+
+	for _, secret := range secretsToLookFor {
+		if strings.Contains(line, secret.Value) {
+			findings = append(findings, ScanFinding{RuleId: SCAN_RULE_INFISICAL_SECRET, Description: fmt.Sprintf("Value of Infisical secret [%s]", secret.Key)})
+		}
+	}
+
+	// only report high entropy strings when nothing more specific was found on this line
+	if len(findings) == 0 {
+		for _, candidate := range highEntropyCandidateRegex.FindAllString(line, -1) {
+			if isHighEntropyString(candidate) {
+				findings = append(findings, ScanFinding{RuleId: SCAN_RULE_HIGH_ENTROPY, Description: "High entropy string"})
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+func isHighEntropyString(candidate string) bool {
+	candidate = strings.TrimRight(candidate, "=")
+	if len(candidate) < scanMinEntropyLength {
+		return false
+	}
+
+	// identifiers such as SOME_LONG_CONSTANT_NAME or some-long-file-name are not secrets
+	hasDigit := strings.ContainsAny(candidate, "0123456789")
+	hasLetter := strings.IndexFunc(candidate, func(r rune) bool { return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') }) != -1
+	if !hasDigit || !hasLetter {
+		return false
+	}
+
+	return shannonEntropy(candidate) >= scanMinEntropy
+}
+
+func shannonEntropy(value string) float64 {
+	frequencies := make(map[rune]float64)
+	for _, char := range value {
+		frequencies[char]++
+	}
+
+	entropy := 0.0
+	length := float64(len([]rune(value)))
+	for _, count := range frequencies {
+		probability := count / length
+		entropy -= probability * math.Log2(probability)
+	}
+
+	return entropy
+}
+
+// Reads the finding fingerprints (path:line) listed in the .infisicalignore file at the root of the scan
+func readScanIgnoreFingerprints(path string) (map[string]struct{}, error) {
+	fingerprints := make(map[string]struct{})
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fingerprints, nil
+		}
+		return nil, fmt.Errorf("unable to read [%s] [err=%v]", path, err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		separatorIndex := strings.LastIndex(line, ":")
+		if separatorIndex == -1 {
+			continue
+		}
+
+		if _, err := strconv.Atoi(line[separatorIndex+1:]); err == nil {
+			fingerprints[line] = struct{}{}
+		}
+	}
+
+	return fingerprints, nil
+}
+
+type ignorePattern struct {
+	regex    *regexp.Regexp
+	baseDir  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreMatcher implements the subset of the .gitignore syntax that matters for scanning: comments, negation, directory only patterns,
+// anchored patterns and the * ? ** wildcards
+type ignoreMatcher struct {
+	patterns []ignorePattern
+}
+
+func (m *ignoreMatcher) loadIgnoreFile(path string, baseDir string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("unable to read [%s] [err=%v]", path, err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		m.addPattern(strings.TrimRight(line, "\r"), baseDir)
+	}
+
+	return nil
+}
+
+func (m *ignoreMatcher) addPattern(line string, baseDir string) {
+	line = strings.TrimRight(line, " ")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	pattern := ignorePattern{baseDir: baseDir}
+	if baseDir == "." {
+		pattern.baseDir = ""
+	}
+
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// a slash anywhere but at the end means the pattern is relative to the directory of the ignore file
+	if strings.Contains(line, "/") {
+		pattern.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	// lines of the form path:line are finding fingerprints and not path patterns
+	if separatorIndex := strings.LastIndex(line, ":"); separatorIndex != -1 {
+		if _, err := strconv.Atoi(line[separatorIndex+1:]); err == nil {
+			return
+		}
+	}
+
+	regex, err := regexp.Compile("^" + globToRegex(line) + "$")
+	if err != nil {
+		log.Debugf("ignoreMatcher: skipping invalid pattern [pattern=%s] [err=%v]", line, err)
+		return
+	}
+
+	pattern.regex = regex
+	m.patterns = append(m.patterns, pattern)
+}
+
+func (m *ignoreMatcher) isIgnored(relativePath string, isDir bool) bool {
+	ignored := false
+	for _, pattern := range m.patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+
+		pathToMatch := relativePath
+		if pattern.baseDir != "" {
+			if !strings.HasPrefix(relativePath, pattern.baseDir+"/") {
+				continue
+			}
+			pathToMatch = strings.TrimPrefix(relativePath, pattern.baseDir+"/")
+		}
+
+		if !pattern.anchored {
+			pathToMatch = filepath.Base(pathToMatch)
+		}
+
+		if pattern.regex.MatchString(pathToMatch) {
+			ignored = !pattern.negate
+		}
+	}
+
+	return ignored
+}
+
+func globToRegex(glob string) string {
+	var regex strings.Builder
+	for i := 0; i < len(glob); i++ {
+		char := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			regex.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			regex.WriteString(".*")
+			i++
+		case char == '*':
+			regex.WriteString("[^/]*")
+		case char == '?':
+			regex.WriteString("[^/]")
+		case char == '[':
+			closingIndex := strings.IndexByte(glob[i:], ']')
+			if closingIndex == -1 {
+				regex.WriteString(regexp.QuoteMeta(string(char)))
+				continue
+			}
+			class := glob[i+1 : i+closingIndex]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			regex.WriteString("[" + class + "]")
+			i += closingIndex
+		default:
+			regex.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+
+	return regex.String()
+}
+
+// SortScanFindings orders findings by file and line number so that reports are stable
+func SortScanFindings(findings []ScanFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
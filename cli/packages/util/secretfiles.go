@@ -0,0 +1,123 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+// SecretFiles are the files written by WriteSecretsAsFiles, to be removed with Remove once they are no longer needed
+type SecretFiles struct {
+	Dir   string
+	paths []string
+	// the directories created for Dir, from Dir up to the topmost one
+	createdDirs []string
+}
+
+// WriteSecretsAsFiles writes the value of every secret to dir/<name of the secret>, readable by the current user only.
+// dir and its missing parents are created readable by the current user only. Existing files are never overwritten, and
+// the files written so far are removed when any secret cannot be written
+func WriteSecretsAsFiles(dir string, secrets []models.SingleEnvironmentVariable) (*SecretFiles, error) {
+	absoluteDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve secrets directory [%s] [err=%v]", dir, err)
+	}
+
+	secretFiles := &SecretFiles{Dir: absoluteDir}
+	for missingDir := absoluteDir; ; missingDir = filepath.Dir(missingDir) {
+		if _, err := os.Stat(missingDir); !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		secretFiles.createdDirs = append(secretFiles.createdDirs, missingDir)
+	}
+
+	if err := os.MkdirAll(absoluteDir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create secrets directory [%s] [err=%v]", dir, err)
+	}
+
+	for _, secret := range secrets {
+		if err := writeSecretFile(secretFiles, secret); err != nil {
+			secretFiles.Remove()
+			return nil, err
+		}
+	}
+
+	return secretFiles, nil
+}
+
+func writeSecretFile(secretFiles *SecretFiles, secret models.SingleEnvironmentVariable) error {
+	if secret.Key == "." || secret.Key == ".." || strings.ContainsAny(secret.Key, "/\\\x00") {
+		return fmt.Errorf("the secret [%s] cannot be written to a file, its name is not a valid file name", secret.Key)
+	}
+
+	path := filepath.Join(secretFiles.Dir, secret.Key)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to write the secret [%s] to [%s] [err=%v]", secret.Key, path, err)
+	}
+	secretFiles.paths = append(secretFiles.paths, path)
+
+	_, err = file.WriteString(secret.Value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to write the secret [%s] to [%s] [err=%v]", secret.Key, path, err)
+	}
+
+	return nil
+}
+
+// Remove overwrites the files with zeros before removing them, and the directories created by WriteSecretsAsFiles
+// that are left empty. Overwriting is best effort, filesystems that copy on write or journal data keep the old blocks
+func (secretFiles *SecretFiles) Remove() error {
+	failedPaths := []string{}
+	for _, path := range secretFiles.paths {
+		if err := shredFile(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			failedPaths = append(failedPaths, path)
+		}
+	}
+	secretFiles.paths = nil
+
+	// directories that something else was written to in the meantime are kept
+	for _, createdDir := range secretFiles.createdDirs {
+		if err := os.Remove(createdDir); err != nil {
+			break
+		}
+	}
+	secretFiles.createdDirs = nil
+
+	if len(failedPaths) != 0 {
+		return fmt.Errorf("unable to remove the secret files [%s]", strings.Join(failedPaths, ", "))
+	}
+
+	return nil
+}
+
+func shredFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err == nil {
+		_, err = file.Write(make([]byte, info.Size()))
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if removeErr := os.Remove(path); removeErr != nil {
+		return removeErr
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+func Test_WriteSecretsAsFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	dir := filepath.Join(tempDir, "run", "secrets")
+	secrets := []models.SingleEnvironmentVariable{{Key: "DB_PASSWORD", Value: "hunter2"}, {Key: "TLS_CERT", Value: "line1\nline2\n"}}
+
+	secretFiles, err := WriteSecretsAsFiles(dir, secrets)
+	if err != nil {
+		t.Fatalf("Test_WriteSecretsAsFiles: unexpected error [err=%v]", err)
+	}
+
+	for _, secret := range secrets {
+		content, err := os.ReadFile(filepath.Join(dir, secret.Key))
+		if err != nil || string(content) != secret.Value {
+			t.Errorf("Test_WriteSecretsAsFiles: expected [%s] to hold its value as is but got [%s] [err=%v]", secret.Key, content, err)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		dirInfo, _ := os.Stat(dir)
+		fileInfo, _ := os.Stat(filepath.Join(dir, "DB_PASSWORD"))
+		if dirInfo.Mode().Perm() != 0700 || fileInfo.Mode().Perm() != 0600 {
+			t.Errorf("Test_WriteSecretsAsFiles: expected the directory and files to be readable by the current user only but got %v and %v", dirInfo.Mode().Perm(), fileInfo.Mode().Perm())
+		}
+	}
+
+	if err := secretFiles.Remove(); err != nil {
+		t.Errorf("Test_WriteSecretsAsFiles: unexpected error [err=%v]", err)
+	}
+
+	if entries, err := os.ReadDir(tempDir); err != nil || len(entries) != 0 {
+		t.Errorf("Test_WriteSecretsAsFiles: expected the created directories to be removed but got %v [err=%v]", entries, err)
+	}
+}
+
+func Test_WriteSecretsAsFiles_KeepsExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "API_KEY"), []byte("mine"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := WriteSecretsAsFiles(dir, []models.SingleEnvironmentVariable{{Key: "DB_PASSWORD", Value: "hunter2"}, {Key: "API_KEY", Value: "theirs"}})
+	if err == nil {
+		t.Fatalf("Test_WriteSecretsAsFiles_KeepsExistingFiles: expected an existing file to not be overwritten")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	content, _ := os.ReadFile(filepath.Join(dir, "API_KEY"))
+	if len(entries) != 1 || string(content) != "mine" {
+		t.Errorf("Test_WriteSecretsAsFiles_KeepsExistingFiles: expected only the existing file to be left but got %d files holding [%s]", len(entries), content)
+	}
+
+	if _, err := WriteSecretsAsFiles(dir, []models.SingleEnvironmentVariable{{Key: "../ESCAPE", Value: "x"}}); err == nil {
+		t.Errorf("Test_WriteSecretsAsFiles_KeepsExistingFiles: expected a name that is not a file name to be refused")
+	}
+}
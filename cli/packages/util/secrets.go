@@ -8,8 +8,10 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
 	"github.com/Infisical/infisical-merge/packages/crypto"
 	"github.com/Infisical/infisical-merge/packages/models"
 	log "github.com/sirupsen/logrus"
@@ -17,7 +19,7 @@ import (
 	"github.com/go-resty/resty/v2"
 )
 
-func GetPlainTextSecretsViaServiceToken(fullServiceToken string) ([]models.SingleEnvironmentVariable, api.GetServiceTokenDetailsResponse, error) {
+func GetPlainTextSecretsViaServiceToken(fullServiceToken string, secretsPath string, keysOnly bool) ([]models.SingleEnvironmentVariable, api.GetServiceTokenDetailsResponse, error) {
 	serviceTokenParts := strings.SplitN(fullServiceToken, ".", 4)
 	if len(serviceTokenParts) < 4 {
 		return nil, api.GetServiceTokenDetailsResponse{}, fmt.Errorf("invalid service token entered. Please double check your service token and try again")
@@ -25,7 +27,7 @@ func GetPlainTextSecretsViaServiceToken(fullServiceToken string) ([]models.Singl
 
 	serviceToken := fmt.Sprintf("%v.%v.%v", serviceTokenParts[0], serviceTokenParts[1], serviceTokenParts[2])
 
-	httpClient := resty.New()
+	httpClient := api.NewHttpClient()
 
 	httpClient.SetAuthToken(serviceToken).
 		SetHeader("Accept", "application/json")
@@ -38,6 +40,7 @@ func GetPlainTextSecretsViaServiceToken(fullServiceToken string) ([]models.Singl
 	encryptedSecrets, err := api.CallGetSecretsV2(httpClient, api.GetEncryptedSecretsV2Request{
 		WorkspaceId: serviceTokenDetails.Workspace,
 		Environment: serviceTokenDetails.Environment,
+		SecretsPath: secretsPath,
 	})
 
 	if err != nil {
@@ -54,7 +57,7 @@ func GetPlainTextSecretsViaServiceToken(fullServiceToken string) ([]models.Singl
 		return nil, api.GetServiceTokenDetailsResponse{}, fmt.Errorf("unable to decrypt the required workspace key")
 	}
 
-	plainTextSecrets, err := GetPlainTextSecrets(plainTextWorkspaceKey, encryptedSecrets)
+	plainTextSecrets, err := getPlainTextSecrets(plainTextWorkspaceKey, encryptedSecrets, !keysOnly)
 	if err != nil {
 		return nil, api.GetServiceTokenDetailsResponse{}, fmt.Errorf("unable to decrypt your secrets [err=%v]", err)
 	}
@@ -62,11 +65,37 @@ func GetPlainTextSecretsViaServiceToken(fullServiceToken string) ([]models.Singl
 	return plainTextSecrets, serviceTokenDetails, nil
 }
 
-func GetPlainTextSecretsViaJTW(JTWToken string, receiversPrivateKey string, workspaceId string, environmentName string, tagSlugs string) ([]models.SingleEnvironmentVariable, error) {
-	httpClient := resty.New()
+func GetPlainTextSecretsViaJTW(JTWToken string, receiversPrivateKey string, workspaceId string, environmentName string, tagSlugs string, secretsPath string, keysOnly bool) ([]models.SingleEnvironmentVariable, error) {
+	httpClient := api.NewHttpClient()
 	httpClient.SetAuthToken(JTWToken).
 		SetHeader("Accept", "application/json")
 
+	plainTextWorkspaceKey, err := GetPlainTextWorkspaceKey(httpClient, receiversPrivateKey, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedSecrets, err := api.CallGetSecretsV2(httpClient, api.GetEncryptedSecretsV2Request{
+		WorkspaceId: workspaceId,
+		Environment: environmentName,
+		TagSlugs:    tagSlugs,
+		SecretsPath: secretsPath,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	plainTextSecrets, err := getPlainTextSecrets(plainTextWorkspaceKey, encryptedSecrets, !keysOnly)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt your secrets [err=%v]", err)
+	}
+
+	return plainTextSecrets, nil
+}
+
+// GetPlainTextWorkspaceKey fetches the encrypted key of the workspace with the given client and decrypts it with the private key of the logged in user
+func GetPlainTextWorkspaceKey(httpClient *resty.Client, receiversPrivateKey string, workspaceId string) ([]byte, error) {
 	request := api.GetEncryptedWorkspaceKeyRequest{
 		WorkspaceId: workspaceId,
 	}
@@ -101,40 +130,73 @@ func GetPlainTextSecretsViaJTW(JTWToken string, receiversPrivateKey string, work
 		PrintErrorMessageAndExit("Some required user credentials are missing to generate your [plainTextEncryptionKey]. Please run [infisical login] then try again")
 	}
 
-	plainTextWorkspaceKey := crypto.DecryptAsymmetric(encryptedWorkspaceKey, encryptedWorkspaceKeyNonce, encryptedWorkspaceKeySenderPublicKey, currentUsersPrivateKey)
-
-	encryptedSecrets, err := api.CallGetSecretsV2(httpClient, api.GetEncryptedSecretsV2Request{
-		WorkspaceId: workspaceId,
-		Environment: environmentName,
-		TagSlugs:    tagSlugs,
-	})
+	return crypto.DecryptAsymmetric(encryptedWorkspaceKey, encryptedWorkspaceKeyNonce, encryptedWorkspaceKeySenderPublicKey, currentUsersPrivateKey), nil
+}
 
+func GetAllEnvironmentVariables(params models.GetAllSecretsParameters) ([]models.SingleEnvironmentVariable, error) {
+	fetchSecretsOfPath, err := prepareSecretsFetch(params)
 	if err != nil {
 		return nil, err
 	}
 
-	plainTextSecrets, err := GetPlainTextSecrets(plainTextWorkspaceKey, encryptedSecrets)
-	if err != nil {
-		return nil, fmt.Errorf("unable to decrypt your secrets [err=%v]", err)
+	secrets, readCachedSecrets, err := fetchSecretsOfPath(params.SecretsPath)
+	return applyFetchErrorPolicy(params.OnFetchError, secrets, readCachedSecrets, err)
+}
+
+func applyFetchErrorPolicy(policy string, secrets []models.SingleEnvironmentVariable, readCachedSecrets func() ([]models.SingleEnvironmentVariable, error), err error) ([]models.SingleEnvironmentVariable, error) {
+	if err == nil {
+		return secrets, nil
 	}
 
-	return plainTextSecrets, nil
+	switch policy {
+	case FETCH_ERROR_POLICY_WARN:
+		PrintWarning(fmt.Sprintf("Unable to fetch secrets, continuing with %d secret(s) [err=%v]", len(secrets), err))
+		return secrets, nil
+	case FETCH_ERROR_POLICY_USE_CACHE:
+		if readCachedSecrets == nil {
+			return nil, fmt.Errorf("unable to fetch secrets and there is no cache to fall back to [err=%v]", err)
+		}
+
+		cachedSecrets, cacheErr := readCachedSecrets()
+		if cacheErr != nil || len(cachedSecrets) == 0 {
+			log.Debugf("GetAllEnvironmentVariables: unable to read cached secrets [err=%v]", cacheErr)
+			return nil, fmt.Errorf("unable to fetch secrets and no secrets from a previous successful fetch were found [err=%v]", err)
+		}
+
+		PrintWarning("Unable to fetch latest secret(s), serving secrets from last successful fetch. For more info, run with --debug")
+		return cachedSecrets, nil
+	default:
+		return secrets, err
+	}
 }
 
-func GetAllEnvironmentVariables(params models.GetAllSecretsParameters) ([]models.SingleEnvironmentVariable, error) {
-	var infisicalToken string
-	if params.InfisicalToken == "" {
-		infisicalToken = os.Getenv(INFISICAL_TOKEN_NAME)
-	} else {
-		infisicalToken = params.InfisicalToken
+// ValidateFetchErrorPolicy checks the value given to --on-fetch-error
+func ValidateFetchErrorPolicy(policy string) error {
+	switch policy {
+	case FETCH_ERROR_POLICY_FAIL, FETCH_ERROR_POLICY_WARN, FETCH_ERROR_POLICY_USE_CACHE:
+		return nil
+	default:
+		return fmt.Errorf("invalid value [%s] for --on-fetch-error. Available options are [%s, %s, %s]", policy, FETCH_ERROR_POLICY_FAIL, FETCH_ERROR_POLICY_WARN, FETCH_ERROR_POLICY_USE_CACHE)
+	}
+}
+
+// The secrets of a folder and a function reading the secrets of the last successful fetch of the same folder, when enough is known to locate them
+type fetchSecretsOfPathFunc func(secretsPath string) ([]models.SingleEnvironmentVariable, func() ([]models.SingleEnvironmentVariable, error), error)
+
+// Resolves whichever credentials are available once, so that the returned function can fetch the secrets of several folders.
+// The returned function is safe to call concurrently
+func prepareSecretsFetch(params models.GetAllSecretsParameters) (fetchSecretsOfPathFunc, error) {
+	infisicalToken, authMethod, err := GetServiceTokenToUse(params.InfisicalToken)
+	if err != nil {
+		return nil, err
 	}
 
-	isConnected := CheckIsConnectedToInternet()
-	var secretsToReturn []models.SingleEnvironmentVariable
-	// var serviceTokenDetails api.GetServiceTokenDetailsResponse
-	var errorToReturn error
+	if authMethod == AUTH_METHOD_STORED_TOKEN {
+		log.Debug("GetAllEnvironmentVariables: using the service token saved by [infisical login --method token]")
+	}
 
 	if infisicalToken == "" {
+		isConnected := CheckIsConnectedToInternet()
 		if isConnected {
 			log.Debug("GetAllEnvironmentVariables: Connected to internet, checking logged in creds")
 			RequireLocalWorkspaceFile()
@@ -157,44 +219,132 @@ func GetAllEnvironmentVariables(params models.GetAllSecretsParameters) ([]models
 			workspaceFile.WorkspaceId = params.WorkspaceId
 		}
 
-		// Verify environment
-		err = ValidateEnvironmentName(params.Environment, workspaceFile.WorkspaceId, loggedInUserDetails.UserCredentials)
-		if err != nil {
-			return nil, fmt.Errorf("unable to validate environment name because [err=%s]", err)
+		backupSecretsEncryptionKey := []byte(loggedInUserDetails.UserCredentials.PrivateKey)[0:32]
+		readCachedSecretsOfPath := func(secretsPath string) func() ([]models.SingleEnvironmentVariable, error) {
+			return withoutValuesIf(params.KeysOnly, func() ([]models.SingleEnvironmentVariable, error) {
+				return ReadBackupSecrets(workspaceFile.WorkspaceId, params.Environment+getBackupEnvironmentSuffix(secretsPath), backupSecretsEncryptionKey)
+			})
 		}
 
-		secretsToReturn, errorToReturn = GetPlainTextSecretsViaJTW(loggedInUserDetails.UserCredentials.JTWToken, loggedInUserDetails.UserCredentials.PrivateKey, workspaceFile.WorkspaceId, params.Environment, params.TagSlugs)
-		log.Debugf("GetAllEnvironmentVariables: Trying to fetch secrets JTW token [err=%s]", errorToReturn)
+		// Verify environment, on the first fetch so that the fetches of several environments validate them concurrently
+		var validateEnvironmentOnce sync.Once
+		var environmentErr error
+
+		return func(secretsPath string) ([]models.SingleEnvironmentVariable, func() ([]models.SingleEnvironmentVariable, error), error) {
+			secretsPath = NormalizeSecretsPath(secretsPath)
+			readCachedSecrets := readCachedSecretsOfPath(secretsPath)
+			validateEnvironmentOnce.Do(func() {
+				environmentErr = ValidateEnvironmentName(params.Environment, workspaceFile.WorkspaceId, loggedInUserDetails.UserCredentials)
+			})
+			if environmentErr != nil {
+				return nil, readCachedSecrets, fmt.Errorf("unable to validate environment name because [err=%s]", environmentErr)
+			}
 
-		backupSecretsEncryptionKey := []byte(loggedInUserDetails.UserCredentials.PrivateKey)[0:32]
-		if errorToReturn == nil {
-			WriteBackupSecrets(workspaceFile.WorkspaceId, params.Environment, backupSecretsEncryptionKey, secretsToReturn)
-		}
+			secretsToReturn, errorToReturn := GetPlainTextSecretsViaJTW(loggedInUserDetails.UserCredentials.JTWToken, loggedInUserDetails.UserCredentials.PrivateKey, workspaceFile.WorkspaceId, params.Environment, params.TagSlugs, secretsPath, params.KeysOnly)
+			log.Debugf("GetAllEnvironmentVariables: Trying to fetch secrets JTW token [err=%s]", errorToReturn)
 
-		// only attempt to serve cached secrets if no internet connection and if at least one secret cached
-		if !isConnected {
-			backedSecrets, err := ReadBackupSecrets(workspaceFile.WorkspaceId, params.Environment, backupSecretsEncryptionKey)
-			if len(backedSecrets) > 0 {
-				PrintWarning("Unable to fetch latest secret(s) due to connection error, serving secrets from last successful fetch. For more info, run with --debug")
-				secretsToReturn = backedSecrets
-				errorToReturn = err
+			if errorToReturn == nil {
+				reportAuthMethod(AUTH_METHOD_LOGGED_IN_USER, loggedInUserDetails.UserCredentials.Email)
 			}
-		}
 
-	} else {
+			// secrets fetched without values must not replace the ones of the last successful fetch
+			if errorToReturn == nil && !params.KeysOnly {
+				WriteBackupSecrets(workspaceFile.WorkspaceId, params.Environment+getBackupEnvironmentSuffix(secretsPath), backupSecretsEncryptionKey, secretsToReturn)
+			}
+
+			// only attempt to serve cached secrets if no internet connection and if at least one secret cached
+			if !isConnected {
+				backedSecrets, err := readCachedSecrets()
+				if len(backedSecrets) > 0 {
+					PrintWarning("Unable to fetch latest secret(s) due to connection error, serving secrets from last successful fetch. For more info, run with --debug")
+					secretsToReturn = backedSecrets
+					errorToReturn = err
+				}
+			}
+
+			return secretsToReturn, readCachedSecrets, errorToReturn
+		}, nil
+	}
+
+	return func(secretsPath string) ([]models.SingleEnvironmentVariable, func() ([]models.SingleEnvironmentVariable, error), error) {
+		secretsPath = NormalizeSecretsPath(secretsPath)
+
 		log.Debug("Trying to fetch secrets using service token")
-		secretsToReturn, _, errorToReturn = GetPlainTextSecretsViaServiceToken(infisicalToken)
+		secretsToReturn, serviceTokenDetails, errorToReturn := GetPlainTextSecretsViaServiceToken(infisicalToken, secretsPath, params.KeysOnly)
+		if errorToReturn == nil {
+			reportAuthMethod(authMethod, serviceTokenDetails.Name)
+		}
 
 		// if serviceTokenDetails.Environment != params.Environment {
 		// 	PrintErrorMessageAndExit(fmt.Sprintf("Fetch secrets failed: token allows [%s] environment access, not [%s]. Service tokens are environment-specific; no need for --env flag.", params.Environment, serviceTokenDetails.Environment))
 		// }
+
+		// secrets fetched with a service token are cached under the id of the token, encrypted with the key embedded in the token
+		var readCachedSecrets func() ([]models.SingleEnvironmentVariable, error)
+		serviceTokenParts := strings.SplitN(infisicalToken, ".", 4)
+		if len(serviceTokenParts) == 4 && len(serviceTokenParts[3]) == 32 {
+			backupName := SERVICE_TOKEN_BACKUP_PREFIX + serviceTokenParts[1]
+			backupSecretsEncryptionKey := []byte(serviceTokenParts[3])
+			readCachedSecrets = withoutValuesIf(params.KeysOnly, func() ([]models.SingleEnvironmentVariable, error) {
+				return ReadBackupSecrets(backupName, SERVICE_TOKEN_BACKUP_PREFIX+getBackupEnvironmentSuffix(secretsPath), backupSecretsEncryptionKey)
+			})
+
+			if errorToReturn == nil && !params.KeysOnly {
+				WriteBackupSecrets(backupName, SERVICE_TOKEN_BACKUP_PREFIX+getBackupEnvironmentSuffix(secretsPath), backupSecretsEncryptionKey, secretsToReturn)
+			}
+		}
+
+		return secretsToReturn, readCachedSecrets, errorToReturn
+	}, nil
+}
+
+// the credentials secrets can be fetched with, described for --print-auth-method
+const (
+	AUTH_METHOD_TOKEN_FLAG     = "service token from --token"
+	AUTH_METHOD_TOKEN_ENV      = "service token from " + INFISICAL_TOKEN_NAME
+	AUTH_METHOD_STORED_TOKEN   = "service token saved by [infisical login --method token]"
+	AUTH_METHOD_LOGGED_IN_USER = "logged in user"
+)
+
+var reportAuthMethodOnce sync.Once
+
+// Logs the auth method and identity that fetched secrets, and prints them to stderr with --print-auth-method. Only the
+// first successful fetch is reported since every fetch of an invocation uses the same credentials
+func reportAuthMethod(authMethod string, identity string) {
+	log.Debugf("secrets fetched with the %s [%s]", authMethod, identity)
+
+	reportAuthMethodOnce.Do(func() {
+		if config.INFISICAL_PRINT_AUTH_METHOD {
+			fmt.Fprintf(os.Stderr, "Authenticated with the %s [%s]\n", authMethod, identity)
+		}
+	})
+}
+
+// clears the values of the cached secrets read by readCachedSecrets when only the keys were asked for
+func withoutValuesIf(keysOnly bool, readCachedSecrets func() ([]models.SingleEnvironmentVariable, error)) func() ([]models.SingleEnvironmentVariable, error) {
+	if !keysOnly {
+		return readCachedSecrets
+	}
+
+	return func() ([]models.SingleEnvironmentVariable, error) {
+		secrets, err := readCachedSecrets()
+		for i := range secrets {
+			secrets[i].Value = ""
+		}
+		return secrets, err
 	}
+}
 
-	return secretsToReturn, errorToReturn
+// secrets of folders are cached next to the ones of the root folder, the path is encoded to stay a valid file name
+func getBackupEnvironmentSuffix(secretsPath string) string {
+	if secretsPath == "/" {
+		return ""
+	}
+	return "_" + base64.RawURLEncoding.EncodeToString([]byte(secretsPath))
 }
 
 func ValidateEnvironmentName(environmentName string, workspaceId string, userLoggedInDetails models.UserCredentials) error {
-	httpClient := resty.New()
+	httpClient := api.NewHttpClient()
 	httpClient.SetAuthToken(userLoggedInDetails.JTWToken).
 		SetHeader("Accept", "application/json")
 
@@ -220,7 +370,98 @@ func ValidateEnvironmentName(environmentName string, workspaceId string, userLog
 
 }
 
-func getExpandedEnvVariable(secrets []models.SingleEnvironmentVariable, variableWeAreLookingFor string, hashMapOfCompleteVariables map[string]string, hashMapOfSelfRefs map[string]string) string {
+// GetAccessibleEnvironmentSlugs returns the slugs of the environments of the project the user can access, in the order of the project
+func GetAccessibleEnvironmentSlugs(workspaceId string, userLoggedInDetails models.UserCredentials) ([]string, error) {
+	httpClient := api.NewHttpClient()
+	httpClient.SetAuthToken(userLoggedInDetails.JTWToken).
+		SetHeader("Accept", "application/json")
+
+	response, err := api.CallGetAccessibleEnvironments(httpClient, api.GetAccessibleEnvironmentsRequest{WorkspaceId: workspaceId})
+	if err != nil {
+		return nil, err
+	}
+
+	environmentSlugs := []string{}
+	for _, environment := range response.AccessibleEnvironments {
+		environmentSlugs = append(environmentSlugs, environment.Slug)
+	}
+
+	return environmentSlugs, nil
+}
+
+// FindReferenceCycle returns the names of secrets whose ${KEY} references lead back to themselves, such as [A B A] for A=${B}
+// and B=${A}, or nil when there is none. Such secrets cannot be expanded. A secret referencing only itself is not a cycle, the
+// reference is left as is
+func FindReferenceCycle(secrets []models.SingleEnvironmentVariable) []string {
+	regex := regexp.MustCompile(`\${([^\}]*)}`)
+	referencesByKey := make(map[string][]string, len(secrets))
+	keys := []string{}
+	for _, secret := range secrets {
+		// the first secret of a name is the one that is expanded
+		if _, found := referencesByKey[secret.Key]; found {
+			continue
+		}
+
+		references := []string{}
+		for _, match := range regex.FindAllStringSubmatch(secret.Value, -1) {
+			if match[1] != secret.Key {
+				references = append(references, match[1])
+			}
+		}
+		referencesByKey[secret.Key] = references
+		keys = append(keys, secret.Key)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make(map[string]int, len(keys))
+	path := []string{}
+
+	var visit func(key string) []string
+	visit = func(key string) []string {
+		states[key] = visiting
+		path = append(path, key)
+
+		for _, reference := range referencesByKey[key] {
+			if _, isSecret := referencesByKey[reference]; !isSecret {
+				continue
+			}
+
+			switch states[reference] {
+			case visiting:
+				for idx, pathKey := range path {
+					if pathKey == reference {
+						return append(append([]string{}, path[idx:]...), reference)
+					}
+				}
+			case unvisited:
+				if cycle := visit(reference); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		states[key] = visited
+		return nil
+	}
+
+	for _, key := range keys {
+		if states[key] == unvisited {
+			if cycle := visit(key); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookupEnv, when set, resolves the references that are not secrets
+func getExpandedEnvVariable(secrets []models.SingleEnvironmentVariable, variableWeAreLookingFor string, hashMapOfCompleteVariables map[string]string, hashMapOfSelfRefs map[string]string, lookupEnv func(string) (string, bool)) string {
 	if value, found := hashMapOfCompleteVariables[variableWeAreLookingFor]; found {
 		return value
 	}
@@ -250,7 +491,7 @@ func getExpandedEnvVariable(secrets []models.SingleEnvironmentVariable, variable
 					if preComputedVariable, found := hashMapOfCompleteVariables[variableWithoutSign]; found {
 						expandedVariableValue = preComputedVariable
 					} else {
-						expandedVariableValue = getExpandedEnvVariable(secrets, variableWithoutSign, hashMapOfCompleteVariables, hashMapOfSelfRefs)
+						expandedVariableValue = getExpandedEnvVariable(secrets, variableWithoutSign, hashMapOfCompleteVariables, hashMapOfSelfRefs, lookupEnv)
 						hashMapOfCompleteVariables[variableWithoutSign] = expandedVariableValue
 					}
 
@@ -270,27 +511,75 @@ func getExpandedEnvVariable(secrets []models.SingleEnvironmentVariable, variable
 		}
 	}
 
+	if lookupEnv != nil {
+		if value, found := lookupEnv(variableWeAreLookingFor); found {
+			return value
+		}
+	}
+
 	return "${" + variableWeAreLookingFor + "}"
 }
 
 func SubstituteSecrets(secrets []models.SingleEnvironmentVariable) []models.SingleEnvironmentVariable {
+	return substituteSecrets(secrets, nil)
+}
+
+// SubstituteSecretsFromSource expands the ${KEY} references of secrets with the source(s) set with --expand-source.
+// With EXPAND_SOURCE_BOTH a reference is resolved with the secret of that name first and with the environment variable otherwise
+func SubstituteSecretsFromSource(secrets []models.SingleEnvironmentVariable, source string, lookupEnv func(string) (string, bool)) []models.SingleEnvironmentVariable {
+	switch source {
+	case EXPAND_SOURCE_BOTH:
+		return substituteSecrets(secrets, lookupEnv)
+	case EXPAND_SOURCE_ENV:
+		regex := regexp.MustCompile(`\${([^\}]*)}`)
+		expandedSecrets := []models.SingleEnvironmentVariable{}
+		for _, secret := range secrets {
+			expandedValue := regex.ReplaceAllStringFunc(secret.Value, func(reference string) string {
+				if value, found := lookupEnv(reference[2 : len(reference)-1]); found {
+					return value
+				}
+				return reference
+			})
+			expandedSecret := secret
+			expandedSecret.Value = expandedValue
+			expandedSecrets = append(expandedSecrets, expandedSecret)
+		}
+		return expandedSecrets
+	default:
+		return substituteSecrets(secrets, nil)
+	}
+}
+
+func substituteSecrets(secrets []models.SingleEnvironmentVariable, lookupEnv func(string) (string, bool)) []models.SingleEnvironmentVariable {
 	hashMapOfCompleteVariables := make(map[string]string)
 	hashMapOfSelfRefs := make(map[string]string)
 	expandedSecrets := []models.SingleEnvironmentVariable{}
 
 	for _, secret := range secrets {
-		expandedVariable := getExpandedEnvVariable(secrets, secret.Key, hashMapOfCompleteVariables, hashMapOfSelfRefs)
-		expandedSecrets = append(expandedSecrets, models.SingleEnvironmentVariable{
-			Key:   secret.Key,
-			Value: expandedVariable,
-			Type:  secret.Type,
-		})
+		// the other fields such as the comment are kept
+		expandedSecret := secret
+		expandedSecret.Value = getExpandedEnvVariable(secrets, secret.Key, hashMapOfCompleteVariables, hashMapOfSelfRefs, lookupEnv)
+		expandedSecrets = append(expandedSecrets, expandedSecret)
 
 	}
 
 	return expandedSecrets
 }
 
+var commentMetadataRegex = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_.-]*)[:=]([^\s:=/][^\s]*)$`)
+
+// ParseCommentMetadata extracts the key:value (or key=value) directives of a secret comment, such as "# rotate:30d type:json".
+// Free form words, and tokens like urls that only look like directives, are ignored
+func ParseCommentMetadata(comment string) map[string]string {
+	metadata := make(map[string]string)
+	for _, token := range strings.Fields(comment) {
+		if match := commentMetadataRegex.FindStringSubmatch(token); match != nil {
+			metadata[match[1]] = match[2]
+		}
+	}
+	return metadata
+}
+
 func OverrideSecrets(secrets []models.SingleEnvironmentVariable, secretType string) []models.SingleEnvironmentVariable {
 	personalSecrets := make(map[string]models.SingleEnvironmentVariable)
 	sharedSecrets := make(map[string]models.SingleEnvironmentVariable)
@@ -335,6 +624,10 @@ func OverrideSecrets(secrets []models.SingleEnvironmentVariable, secretType stri
 }
 
 func GetPlainTextSecrets(key []byte, encryptedSecrets api.GetEncryptedSecretsV2Response) ([]models.SingleEnvironmentVariable, error) {
+	return getPlainTextSecrets(key, encryptedSecrets, true)
+}
+
+func getPlainTextSecrets(key []byte, encryptedSecrets api.GetEncryptedSecretsV2Response, withValues bool) ([]models.SingleEnvironmentVariable, error) {
 	plainTextSecrets := []models.SingleEnvironmentVariable{}
 	for _, secret := range encryptedSecrets.Secrets {
 		// Decrypt key
@@ -359,24 +652,27 @@ func GetPlainTextSecrets(key []byte, encryptedSecrets api.GetEncryptedSecretsV2R
 		}
 
 		// Decrypt value
-		value_iv, err := base64.StdEncoding.DecodeString(secret.SecretValueIV)
-		if err != nil {
-			return nil, fmt.Errorf("unable to decode secret IV for secret value")
-		}
+		var plainTextValue []byte
+		if withValues {
+			value_iv, err := base64.StdEncoding.DecodeString(secret.SecretValueIV)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode secret IV for secret value")
+			}
 
-		value_tag, err := base64.StdEncoding.DecodeString(secret.SecretValueTag)
-		if err != nil {
-			return nil, fmt.Errorf("unable to decode secret authentication tag for secret value")
-		}
+			value_tag, err := base64.StdEncoding.DecodeString(secret.SecretValueTag)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode secret authentication tag for secret value")
+			}
 
-		value_ciphertext, _ := base64.StdEncoding.DecodeString(secret.SecretValueCiphertext)
-		if err != nil {
-			return nil, fmt.Errorf("unable to decode secret cipher text for secret key")
-		}
+			value_ciphertext, _ := base64.StdEncoding.DecodeString(secret.SecretValueCiphertext)
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode secret cipher text for secret key")
+			}
 
-		plainTextValue, err := crypto.DecryptSymmetric(key, value_ciphertext, value_tag, value_iv)
-		if err != nil {
-			return nil, fmt.Errorf("unable to symmetrically decrypt secret value")
+			plainTextValue, err = crypto.DecryptSymmetric(key, value_ciphertext, value_tag, value_iv)
+			if err != nil {
+				return nil, fmt.Errorf("unable to symmetrically decrypt secret value")
+			}
 		}
 
 		// Decrypt comment
@@ -401,12 +697,14 @@ func GetPlainTextSecrets(key []byte, encryptedSecrets api.GetEncryptedSecretsV2R
 		}
 
 		plainTextSecret := models.SingleEnvironmentVariable{
-			Key:     string(plainTextKey),
-			Value:   string(plainTextValue),
-			Type:    string(secret.Type),
-			ID:      secret.ID,
-			Tags:    secret.Tags,
-			Comment: string(plainTextComment),
+			Key:       string(plainTextKey),
+			Value:     string(plainTextValue),
+			Type:      string(secret.Type),
+			ID:        secret.ID,
+			Tags:      secret.Tags,
+			Comment:   string(plainTextComment),
+			Version:   secret.Version,
+			UpdatedAt: secret.UpdatedAt,
 		}
 
 		plainTextSecrets = append(plainTextSecrets, plainTextSecret)
@@ -427,7 +725,7 @@ func WriteBackupSecrets(workspace string, environment string, encryptionKey []by
 	// create secrets backup directory
 	fullPathToSecretsBackupFolder := fmt.Sprintf("%s/%s", fullConfigFileDirPath, secrets_backup_folder_name)
 	if _, err := os.Stat(fullPathToSecretsBackupFolder); errors.Is(err, os.ErrNotExist) {
-		err := os.Mkdir(fullPathToSecretsBackupFolder, os.ModePerm)
+		err := os.MkdirAll(fullPathToSecretsBackupFolder, os.ModePerm)
 		if err != nil {
 			return err
 		}
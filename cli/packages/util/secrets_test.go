@@ -2,8 +2,10 @@ package util
 
 import (
 	"io"
+	"net/http"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/Infisical/infisical-merge/packages/models"
@@ -162,6 +164,53 @@ func Test_SubstituteSecrets_When_No_SubstituteNeeded(t *testing.T) {
 	}
 }
 
+func Test_SubstituteSecretsFromSource(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "HOST", Value: "db.internal"},
+		{Key: "URL", Value: "postgres://${HOST}:${PORT}/${HOSTNAME}"},
+	}
+
+	environment := map[string]string{"HOST": "localhost", "HOSTNAME": "web-1"}
+	lookupEnv := func(key string) (string, bool) {
+		value, found := environment[key]
+		return value, found
+	}
+
+	var tests = []struct {
+		Source        string
+		ExpectedValue string
+	}{
+		{Source: EXPAND_SOURCE_SECRET, ExpectedValue: "postgres://db.internal:${PORT}/${HOSTNAME}"},
+		{Source: EXPAND_SOURCE_BOTH, ExpectedValue: "postgres://db.internal:${PORT}/web-1"},
+		{Source: EXPAND_SOURCE_ENV, ExpectedValue: "postgres://localhost:${PORT}/web-1"},
+	}
+
+	for _, test := range tests {
+		results := SubstituteSecretsFromSource(secrets, test.Source, lookupEnv)
+		if results[1].Value != test.ExpectedValue {
+			t.Errorf("Test_SubstituteSecretsFromSource: expected [%s] with source [%s] but got [%s]", test.ExpectedValue, test.Source, results[1].Value)
+		}
+	}
+}
+
+func Test_FindReferenceCycle(t *testing.T) {
+	var tests = []struct {
+		Secrets       []models.SingleEnvironmentVariable
+		ExpectedCycle string
+	}{
+		{Secrets: []models.SingleEnvironmentVariable{{Key: "A", Value: "${B}"}, {Key: "B", Value: "${A}"}}, ExpectedCycle: "A B A"},
+		{Secrets: []models.SingleEnvironmentVariable{{Key: "URL", Value: "${HOST}/${PATH_A}"}, {Key: "HOST", Value: "db"}, {Key: "PATH_A", Value: "${PATH_B}"}, {Key: "PATH_B", Value: "x${PATH_A}"}}, ExpectedCycle: "PATH_A PATH_B PATH_A"},
+		{Secrets: []models.SingleEnvironmentVariable{{Key: "SELF", Value: "${SELF}"}, {Key: "URL", Value: "${HOST}:${MISSING}"}, {Key: "HOST", Value: "db"}}, ExpectedCycle: ""},
+	}
+
+	for _, test := range tests {
+		cycle := strings.Join(FindReferenceCycle(test.Secrets), " ")
+		if cycle != test.ExpectedCycle {
+			t.Errorf("Test_FindReferenceCycle: expected [%s] but got [%s] for %v", test.ExpectedCycle, cycle, test.Secrets)
+		}
+	}
+}
+
 func Test_Read_Env_From_File(t *testing.T) {
 	type testCase struct {
 		TestFile    string
@@ -256,3 +305,97 @@ func copyTestFile(t *testing.T, src, dst string) {
 		t.Errorf("Test_Read_Env_From_File_By_Branch: Failed to copy file: %s", err)
 	}
 }
+
+func Test_GetAllEnvironmentVariables_OnFetchError(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(INFISICAL_TOKEN_NAME, "")
+	mock := newMockInfisicalServer(t, [][2]string{{"DB_PASSWORD", "hunter2"}})
+
+	params := models.GetAllSecretsParameters{Environment: "dev", InfisicalToken: testServiceToken}
+
+	// use-cache without any previous successful fetch
+	mock.failWithStatus = http.StatusInternalServerError
+	params.OnFetchError = FETCH_ERROR_POLICY_USE_CACHE
+	if _, err := GetAllEnvironmentVariables(params); err == nil {
+		t.Errorf("Test_GetAllEnvironmentVariables_OnFetchError: expected use-cache to error when no cache exists")
+	}
+
+	// a successful fetch populates the cache
+	mock.failWithStatus = 0
+	params.OnFetchError = FETCH_ERROR_POLICY_FAIL
+	secrets, err := GetAllEnvironmentVariables(params)
+	if err != nil || len(secrets) != 1 {
+		t.Fatalf("Test_GetAllEnvironmentVariables_OnFetchError: expected 1 secret but got %v [err=%v]", secrets, err)
+	}
+
+	mock.failWithStatus = http.StatusInternalServerError
+
+	params.OnFetchError = FETCH_ERROR_POLICY_FAIL
+	if _, err := GetAllEnvironmentVariables(params); err == nil {
+		t.Errorf("Test_GetAllEnvironmentVariables_OnFetchError: expected fail to return the fetch error")
+	}
+
+	params.OnFetchError = FETCH_ERROR_POLICY_WARN
+	secrets, err = GetAllEnvironmentVariables(params)
+	if err != nil || len(secrets) != 0 {
+		t.Errorf("Test_GetAllEnvironmentVariables_OnFetchError: expected warn to continue without secrets but got %v [err=%v]", secrets, err)
+	}
+
+	params.OnFetchError = FETCH_ERROR_POLICY_USE_CACHE
+	secrets, err = GetAllEnvironmentVariables(params)
+	if err != nil || len(secrets) != 1 || secrets[0].Key != "DB_PASSWORD" || secrets[0].Value != "hunter2" {
+		t.Errorf("Test_GetAllEnvironmentVariables_OnFetchError: expected use-cache to return the cached secret but got %v [err=%v]", secrets, err)
+	}
+}
+
+func Test_ParseCommentMetadata(t *testing.T) {
+	metadata := ParseCommentMetadata("# rotate:30d type:json owner=payments see https://wiki.example.com DEFAULT: 5432 ratio:1:2")
+
+	expected := map[string]string{"rotate": "30d", "type": "json", "owner": "payments", "ratio": "1:2"}
+	if len(metadata) != len(expected) {
+		t.Fatalf("Test_ParseCommentMetadata: expected %v but got %v", expected, metadata)
+	}
+
+	for key, value := range expected {
+		if metadata[key] != value {
+			t.Errorf("Test_ParseCommentMetadata: expected [%s] for [%s] but got [%s]", value, key, metadata[key])
+		}
+	}
+
+	if metadata := ParseCommentMetadata("just a free form comment"); len(metadata) != 0 {
+		t.Errorf("Test_ParseCommentMetadata: expected no metadata for a free form comment but got %v", metadata)
+	}
+}
+
+func Test_GetAllEnvironmentVariables_KeysOnly(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(INFISICAL_TOKEN_NAME, "")
+	mock := newMockInfisicalServer(t, [][2]string{{"DB_PASSWORD", "hunter2"}})
+
+	params := models.GetAllSecretsParameters{Environment: "dev", InfisicalToken: testServiceToken}
+
+	// populates the cache with the values
+	if _, err := GetAllEnvironmentVariables(params); err != nil {
+		t.Fatalf("Test_GetAllEnvironmentVariables_KeysOnly: unexpected error [err=%v]", err)
+	}
+
+	params.KeysOnly = true
+	secrets, err := GetAllEnvironmentVariables(params)
+	if err != nil || len(secrets) != 1 || secrets[0].Key != "DB_PASSWORD" || secrets[0].Value != "" {
+		t.Errorf("Test_GetAllEnvironmentVariables_KeysOnly: expected the key without its value but got %v [err=%v]", secrets, err)
+	}
+
+	// the cache of the previous fetch is left untouched, but also served without values
+	mock.failWithStatus = http.StatusInternalServerError
+	params.OnFetchError = FETCH_ERROR_POLICY_USE_CACHE
+	secrets, err = GetAllEnvironmentVariables(params)
+	if err != nil || len(secrets) != 1 || secrets[0].Value != "" {
+		t.Errorf("Test_GetAllEnvironmentVariables_KeysOnly: expected the cached key without its value but got %v [err=%v]", secrets, err)
+	}
+
+	params.KeysOnly = false
+	secrets, err = GetAllEnvironmentVariables(params)
+	if err != nil || len(secrets) != 1 || secrets[0].Value != "hunter2" {
+		t.Errorf("Test_GetAllEnvironmentVariables_KeysOnly: expected keys-only fetches to not overwrite the cache but got %v [err=%v]", secrets, err)
+	}
+}
@@ -0,0 +1,101 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/crypto"
+)
+
+const (
+	testServiceTokenKey = "abcdefghijklmnopqrstuvwxyz012345"
+	testWorkspaceKey    = "0123456789abcdef0123456789abcdef"
+	testServiceToken    = "st.63f0a1b2c3d4e5f6a7b8c9d0.secretpart." + testServiceTokenKey
+)
+
+// mockInfisicalServer serves the service token and secrets endpoints of the Infisical API with secrets encrypted like the real backend does
+type mockInfisicalServer struct {
+	server  *httptest.Server
+	secrets [][2]string
+	// when set, the secrets endpoint responds with this status code instead of the secrets
+	failWithStatus int
+	// folders for which the secrets endpoint responds with a not found error
+	missingPaths map[string]bool
+	// how long the secrets endpoint waits before responding
+	latency time.Duration
+	// when set, the service token expires at this time
+	serviceTokenExpiresAt time.Time
+}
+
+// newMockInfisicalServer starts the mock server and points the CLI at it for the duration of the test
+func newMockInfisicalServer(t *testing.T, secrets [][2]string) *mockInfisicalServer {
+	mock := &mockInfisicalServer{secrets: secrets}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/service-token", func(w http.ResponseWriter, r *http.Request) {
+		cipherText, iv, tag := encryptForTest(t, testWorkspaceKey, testServiceTokenKey)
+		serviceTokenDetails := map[string]string{"_id": "token-id", "name": "test", "workspace": "workspace-id", "environment": "dev", "encryptedKey": cipherText, "iv": iv, "tag": tag}
+		if !mock.serviceTokenExpiresAt.IsZero() {
+			serviceTokenDetails["expiresAt"] = mock.serviceTokenExpiresAt.Format(time.RFC3339)
+		}
+		writeJSONForTest(w, serviceTokenDetails)
+	})
+
+	mux.HandleFunc("/api/v2/secrets", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(mock.latency)
+
+		if mock.failWithStatus != 0 {
+			w.WriteHeader(mock.failWithStatus)
+			return
+		}
+
+		if mock.missingPaths[r.URL.Query().Get("secretsPath")] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		encryptedSecrets := []map[string]interface{}{}
+		for idx, secret := range mock.secrets {
+			keyCipherText, keyIv, keyTag := encryptForTest(t, secret[0], testWorkspaceKey)
+			valueCipherText, valueIv, valueTag := encryptForTest(t, secret[1], testWorkspaceKey)
+			commentCipherText, commentIv, commentTag := encryptForTest(t, "", testWorkspaceKey)
+			encryptedSecrets = append(encryptedSecrets, map[string]interface{}{
+				"_id": string(rune('a' + idx)), "type": SECRET_TYPE_SHARED, "environment": r.URL.Query().Get("environment"),
+				"secretKeyCiphertext": keyCipherText, "secretKeyIV": keyIv, "secretKeyTag": keyTag,
+				"secretValueCiphertext": valueCipherText, "secretValueIV": valueIv, "secretValueTag": valueTag,
+				"secretCommentCiphertext": commentCipherText, "secretCommentIV": commentIv, "secretCommentTag": commentTag,
+				"tags": []interface{}{},
+			})
+		}
+
+		writeJSONForTest(w, map[string]interface{}{"secrets": encryptedSecrets})
+	})
+
+	mock.server = httptest.NewServer(mux)
+	t.Cleanup(mock.server.Close)
+
+	previousUrl := config.INFISICAL_URL
+	config.INFISICAL_URL = mock.server.URL + "/api"
+	t.Cleanup(func() { config.INFISICAL_URL = previousUrl })
+
+	return mock
+}
+
+func encryptForTest(t *testing.T, plainText string, key string) (cipherText string, iv string, tag string) {
+	result, err := crypto.EncryptSymmetric([]byte(plainText), []byte(key))
+	if err != nil {
+		t.Fatalf("encryptForTest: unable to encrypt [err=%v]", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(result.CipherText), base64.StdEncoding.EncodeToString(result.Nonce), base64.StdEncoding.EncodeToString(result.AuthTag)
+}
+
+func writeJSONForTest(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
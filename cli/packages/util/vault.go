@@ -69,3 +69,38 @@ func fileKeyringPassphrasePrompt(prompt string) (string, error) {
 	fmt.Println("")
 	return string(b), nil
 }
+
+// Reads a passphrase from the environment variable envName, or from the terminal without echoing it. When shouldConfirm
+// is set, the passphrase has to be typed twice so that a typo does not make the encrypted file unreadable
+func ReadPassphrase(envName string, prompt string, shouldConfirm bool) (string, error) {
+	if passphrase, ok := os.LookupEnv(envName); ok {
+		return passphrase, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "You may set the environment variable `%s` with your passphrase to avoid typing it\n", envName)
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the passphrase [err=%v]", err)
+	}
+
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("the passphrase cannot be empty")
+	}
+
+	if shouldConfirm {
+		fmt.Fprint(os.Stderr, "Confirm the passphrase: ")
+		confirmation, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("unable to read the passphrase [err=%v]", err)
+		}
+
+		if string(confirmation) != string(passphrase) {
+			return "", fmt.Errorf("the passphrases do not match")
+		}
+	}
+
+	return string(passphrase), nil
+}
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const waitForPollInterval = 500 * time.Millisecond
+
+// ValidateWaitForTargets checks that the given TCP addresses and HTTP urls are well formed before we start waiting on them
+func ValidateWaitForTargets(tcpAddresses []string, httpUrls []string) error {
+	for _, address := range tcpAddresses {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("invalid --wait-for address [%s]. Expected the format host:port [err=%v]", address, err)
+		}
+	}
+
+	for _, rawUrl := range httpUrls {
+		parsedUrl, err := url.ParseRequestURI(rawUrl)
+		if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") {
+			return fmt.Errorf("invalid --wait-for-http url [%s]. Expected an http or https url", rawUrl)
+		}
+	}
+
+	return nil
+}
+
+// WaitForDependencies blocks until every TCP address accepts connections and every HTTP url responds successfully, or until the timeout elapses
+func WaitForDependencies(tcpAddresses []string, httpUrls []string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for _, address := range tcpAddresses {
+		if err := waitForTCPAddress(address, deadline); err != nil {
+			return err
+		}
+	}
+
+	for _, rawUrl := range httpUrls {
+		if err := waitForHTTPUrl(rawUrl, deadline); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func waitForTCPAddress(address string, deadline time.Time) error {
+	for {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+			log.Debugf("waitForTCPAddress: [address=%s] is reachable", address)
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for [%s] to accept TCP connections [err=%v]", address, err)
+		}
+
+		log.Debugf("waitForTCPAddress: [address=%s] not reachable yet [err=%v]", address, err)
+		time.Sleep(waitForPollInterval)
+	}
+}
+
+func waitForHTTPUrl(rawUrl string, deadline time.Time) error {
+	httpClient := http.Client{Timeout: time.Second * 5}
+
+	for {
+		response, err := httpClient.Get(rawUrl)
+		if err == nil {
+			response.Body.Close()
+			if response.StatusCode >= 200 && response.StatusCode < 400 {
+				log.Debugf("waitForHTTPUrl: [url=%s] is ready [status=%d]", rawUrl, response.StatusCode)
+				return nil
+			}
+			err = fmt.Errorf("unexpected status code %d", response.StatusCode)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for [%s] to become ready [err=%v]", rawUrl, err)
+		}
+
+		log.Debugf("waitForHTTPUrl: [url=%s] not ready yet [err=%v]", rawUrl, err)
+		time.Sleep(waitForPollInterval)
+	}
+}
@@ -0,0 +1,87 @@
+package util
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_WaitForDependencies_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Test_WaitForDependencies_TCP: unable to listen [err=%v]", err)
+	}
+	defer listener.Close()
+
+	if err := WaitForDependencies([]string{listener.Addr().String()}, nil, time.Second*5); err != nil {
+		t.Errorf("Test_WaitForDependencies_TCP: expected [%s] to be reachable [err=%v]", listener.Addr().String(), err)
+	}
+}
+
+func Test_WaitForDependencies_HTTP(t *testing.T) {
+	var requestCount int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the dependency only becomes ready after a few polls
+		if atomic.AddInt32(&requestCount, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if err := WaitForDependencies(nil, []string{server.URL + "/health"}, time.Second*10); err != nil {
+		t.Fatalf("Test_WaitForDependencies_HTTP: expected [%s] to become ready [err=%v]", server.URL, err)
+	}
+
+	if count := atomic.LoadInt32(&requestCount); count != 3 {
+		t.Errorf("Test_WaitForDependencies_HTTP: expected 3 requests but got %d", count)
+	}
+}
+
+func Test_WaitForDependencies_Timeout(t *testing.T) {
+	// listen on a free port and close it right away so that nothing accepts connections on it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Test_WaitForDependencies_Timeout: unable to listen [err=%v]", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	startedAt := time.Now()
+	if err := WaitForDependencies([]string{address}, nil, time.Millisecond*100); err == nil {
+		t.Fatalf("Test_WaitForDependencies_Timeout: expected waiting for [%s] to time out", address)
+	}
+
+	if elapsed := time.Since(startedAt); elapsed > time.Second*5 {
+		t.Errorf("Test_WaitForDependencies_Timeout: expected to give up shortly after the timeout but waited %s", elapsed)
+	}
+}
+
+func Test_ValidateWaitForTargets(t *testing.T) {
+	var tests = []struct {
+		TcpAddresses []string
+		HttpUrls     []string
+		IsValid      bool
+	}{
+		{TcpAddresses: []string{"localhost:5432", "127.0.0.1:6379"}, HttpUrls: []string{"http://localhost:8080/health", "https://api.example.com"}, IsValid: true},
+		{TcpAddresses: []string{"localhost"}, IsValid: false},
+		{TcpAddresses: []string{"localhost:5432:1"}, IsValid: false},
+		{HttpUrls: []string{"localhost:8080/health"}, IsValid: false},
+		{HttpUrls: []string{"ftp://example.com"}, IsValid: false},
+		{HttpUrls: []string{"not a url"}, IsValid: false},
+	}
+
+	for _, test := range tests {
+		err := ValidateWaitForTargets(test.TcpAddresses, test.HttpUrls)
+		if test.IsValid && err != nil {
+			t.Errorf("Test_ValidateWaitForTargets: expected %v and %v to be valid [err=%v]", test.TcpAddresses, test.HttpUrls, err)
+		}
+		if !test.IsValid && err == nil {
+			t.Errorf("Test_ValidateWaitForTargets: expected %v and %v to be rejected", test.TcpAddresses, test.HttpUrls)
+		}
+	}
+}
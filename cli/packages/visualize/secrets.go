@@ -12,3 +12,14 @@ func PrintAllSecretDetails(secrets []models.SingleEnvironmentVariable) {
 
 	Table(headers, rows)
 }
+
+func PrintAllSecretKeys(secrets []models.SingleEnvironmentVariable) {
+	rows := [][3]string{}
+	for _, secret := range secrets {
+		rows = append(rows, [...]string{secret.Key, secret.Comment, secret.Type})
+	}
+
+	headers := [...]string{"SECRET NAME", "SECRET COMMENT", "SECRET TYPE"}
+
+	Table(headers, rows)
+}
@@ -23,14 +23,27 @@ type TableOptions struct {
 // }
 
 const (
-	// combined width of the table borders and padding
-	borderWidths = 10
+	// width of a table border and of the padding of a column
+	borderWidth  = 1
+	paddingWidth = 2
 	// char to indicate that a string has been truncated
 	ellipsis = "…"
 )
 
 // Given headers and rows, this function will print out a table
 func Table(headers [3]string, rows [][3]string) {
+	tableRows := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		tableRows = append(tableRows, []string{row[0], row[1], row[2]})
+	}
+
+	// only truncate the second column (secret value)
+	TableWithColumns(headers[:], tableRows, 1, 0)
+}
+
+// Prints a table of any number of columns. In a terminal, the column at truncatedColumn is truncated to the width left
+// by the other columns, -1 truncates none. Cells wider than maxColumnWidth are truncated when it is above 0
+func TableWithColumns(headers []string, rows [][]string, truncatedColumn int, maxColumnWidth int) {
 	// if we're not in a terminal or cygwin terminal, don't truncate the secret value
 	shouldTruncate := isatty.IsTerminal(os.Stdout.Fd())
 
@@ -45,8 +58,23 @@ func Table(headers [3]string, rows [][3]string) {
 		}
 	}
 
-	longestSecretName, longestSecretType := getLongestValues(append(rows, headers))
-	availableWidth := width - longestSecretName - longestSecretType - borderWidths
+	if maxColumnWidth > 0 {
+		for _, row := range rows {
+			for i, val := range row {
+				if stringWidth(val) > maxColumnWidth {
+					row[i] = truncate.StringWithTail(val, uint(maxColumnWidth), ellipsis)
+				}
+			}
+		}
+	}
+
+	longestValues := getLongestValues(append(rows, headers), len(headers))
+	availableWidth := width - borderWidth*(len(headers)+1) - paddingWidth*len(headers)
+	for i, longestValue := range longestValues {
+		if i != truncatedColumn {
+			availableWidth -= longestValue
+		}
+	}
 	if availableWidth < 0 {
 		availableWidth = 0
 	}
@@ -69,8 +97,7 @@ func Table(headers [3]string, rows [][3]string) {
 	for _, row := range rows {
 		tableRow := table.Row{}
 		for i, val := range row {
-			// only truncate the first column (secret value)
-			if i == 1 && stringWidth(val) > availableWidth && shouldTruncate {
+			if i == truncatedColumn && stringWidth(val) > availableWidth && shouldTruncate {
 				val = truncate.StringWithTail(val, uint(availableWidth), ellipsis)
 			}
 			tableRow = append(tableRow, val)
@@ -81,17 +108,17 @@ func Table(headers [3]string, rows [][3]string) {
 	t.Render()
 }
 
-// getLongestValues returns the length of the longest secret name and type from all rows (including the header).
-func getLongestValues(rows [][3]string) (longestSecretName, longestSecretType int) {
+// getLongestValues returns the width of the longest value of every column from all rows (including the header).
+func getLongestValues(rows [][]string, columnCount int) []int {
+	longestValues := make([]int, columnCount)
 	for _, row := range rows {
-		if len(row[0]) > longestSecretName {
-			longestSecretName = stringWidth(row[0])
-		}
-		if len(row[2]) > longestSecretType {
-			longestSecretType = stringWidth(row[2])
+		for i, val := range row {
+			if i < columnCount && stringWidth(val) > longestValues[i] {
+				longestValues[i] = stringWidth(val)
+			}
 		}
 	}
-	return
+	return longestValues
 }
 
 // stringWidth returns the width of a string.